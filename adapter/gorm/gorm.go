@@ -3,13 +3,88 @@ package gorm
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Robotech-Org/gordian"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// --- Transactor Implementation ---
+
+type txKey struct{}
+
+// Transactor runs gordian units of work inside a GORM transaction.
+// Stores built on the same *gorm.DB pick the transaction up from the context.
+type Transactor struct {
+	DB *gorm.DB
+}
+
+func NewTransactor(db *gorm.DB) *Transactor {
+	return &Transactor{DB: db}
+}
+
+// WithinTransaction satisfies the gordian.Transactor interface.
+// On PostgreSQL it also sets CurrentOrgSetting to the active organization of ctx, for RLSPolicy.
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	var committed func()
+	err := conn(ctx, t.DB).Transaction(func(tx *gorm.DB) error {
+		if err := setCurrentOrg(ctx, tx); err != nil {
+			return err
+		}
+		var txCtx context.Context
+		txCtx, committed = gordian.WithAfterCommit(context.WithValue(ctx, txKey{}, tx))
+		return fn(txCtx)
+	})
+	if err != nil {
+		return err
+	}
+	committed()
+	return nil
+}
+
+// TransactionDB returns the transaction started by Transactor.WithinTransaction. Queries on
+// tables protected by RLSPolicy must run on it: only its connection carries CurrentOrgSetting.
+func TransactionDB(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txKey{}).(*gorm.DB)
+	if !ok {
+		return nil, false
+	}
+	return tx.WithContext(ctx), true
+}
+
+// translateErr maps driver errors onto the gordian sentinel errors.
+func translateErr(db *gorm.DB, err error) error {
+	if err == nil {
+		return nil
+	}
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("%w: %w", gordian.ErrNotFound, err)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return fmt.Errorf("%w: %w", gordian.ErrConflict, err)
+	}
+	return err
+}
+
+// conn returns the transaction carried by ctx, or db when there is none.
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
 // --- OrganizationStore Implementation ---
 
 type OrganizationStore struct {
@@ -22,17 +97,99 @@ func NewOrganizationStore(db *gorm.DB) *OrganizationStore {
 
 // Create satisfies the gordian.OrganizationStore interface.
 func (s *OrganizationStore) Create(ctx context.Context, org *gordian.Organization) error {
-	return s.DB.WithContext(ctx).Create(org).Error
+	return translateErr(s.DB, conn(ctx, s.DB).Create(org).Error)
 }
 
 func (s *OrganizationStore) Get(ctx context.Context, id uuid.UUID) (*gordian.Organization, error) {
 	var org gordian.Organization
-	if err := s.DB.WithContext(ctx).First(&org, id).Error; err != nil {
-		return nil, err
+	if err := conn(ctx, s.DB).First(&org, id).Error; err != nil {
+		return nil, translateErr(s.DB, err)
 	}
 	return &org, nil
 }
 
+// GetBySlug falls back to the organization_slug_redirects table for former slugs.
+func (s *OrganizationStore) GetBySlug(ctx context.Context, slug string) (*gordian.Organization, error) {
+	var org gordian.Organization
+	err := conn(ctx, s.DB).First(&org, "slug = ?", slug).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		redirect := conn(ctx, s.DB).Model(&gordian.OrganizationSlugRedirect{}).Select("organization_id").Where("slug = ?", slug)
+		err = conn(ctx, s.DB).First(&org, "id = (?)", redirect).Error
+	}
+	if err != nil {
+		return nil, translateErr(s.DB, err)
+	}
+	return &org, nil
+}
+
+// Update leaves the subscription columns alone; only UpdateSubscription writes them.
+func (s *OrganizationStore) Update(ctx context.Context, org *gordian.Organization) error {
+	err := conn(ctx, s.DB).Model(org).
+		Select("name", "slug", "owner_id", "parent_id", "plan").
+		Updates(org).Error
+	return translateErr(s.DB, err)
+}
+
+// UpdateSubscription leaves every other column alone, so it cannot undo a concurrent Update.
+func (s *OrganizationStore) UpdateSubscription(ctx context.Context, org *gordian.Organization) error {
+	err := conn(ctx, s.DB).Model(org).
+		Select("plan", "billing_customer_id", "subscription_id", "subscription_status").
+		Updates(org).Error
+	return translateErr(s.DB, err)
+}
+
+func (s *OrganizationStore) Delete(ctx context.Context, id uuid.UUID) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Delete(&gordian.Organization{}, "id = ?", id).Error)
+}
+
+// Lock writes the row instead of selecting it FOR UPDATE, like UsageStore.LockUsage.
+func (s *OrganizationStore) Lock(ctx context.Context, id uuid.UUID) error {
+	res := conn(ctx, s.DB).Exec("UPDATE organizations SET name = name WHERE id = ?", id)
+	if res.Error != nil {
+		return translateErr(s.DB, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("organization not found: %w", gordian.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *OrganizationStore) AddSlugRedirect(ctx context.Context, redirect *gordian.OrganizationSlugRedirect) error {
+	err := conn(ctx, s.DB).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "slug"}},
+		DoUpdates: clause.AssignmentColumns([]string{"organization_id", "created_at"}),
+	}).Create(redirect).Error
+	return translateErr(s.DB, err)
+}
+
+// ListAncestors walks parent_id upwards with a recursive query.
+func (s *OrganizationStore) ListAncestors(ctx context.Context, id uuid.UUID) ([]*gordian.Organization, error) {
+	var orgs []*gordian.Organization
+	err := conn(ctx, s.DB).Raw(`WITH RECURSIVE ancestors AS (
+		SELECT o.*, 1 AS depth FROM organizations o WHERE o.id = (SELECT parent_id FROM organizations WHERE id = ?)
+		UNION ALL
+		SELECT o.*, a.depth + 1 FROM organizations o JOIN ancestors a ON o.id = a.parent_id WHERE a.depth < ?
+	) SELECT * FROM ancestors ORDER BY depth`, id, gordian.MaxOrganizationDepth).Scan(&orgs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ancestors: %w", err)
+	}
+	return orgs, nil
+}
+
+// ListDescendants walks parent_id downwards with a recursive query.
+func (s *OrganizationStore) ListDescendants(ctx context.Context, id uuid.UUID) ([]*gordian.Organization, error) {
+	var orgs []*gordian.Organization
+	err := conn(ctx, s.DB).Raw(`WITH RECURSIVE descendants AS (
+		SELECT o.*, 1 AS depth FROM organizations o WHERE o.parent_id = ?
+		UNION ALL
+		SELECT o.*, d.depth + 1 FROM organizations o JOIN descendants d ON o.parent_id = d.id WHERE d.depth < ?
+	) SELECT * FROM descendants ORDER BY depth, name`, id, gordian.MaxOrganizationDepth).Scan(&orgs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list descendants: %w", err)
+	}
+	return orgs, nil
+}
+
 // --- UserStore Implementation ---
 
 type UserStore struct {
@@ -45,20 +202,20 @@ func NewUserStore(db *gorm.DB) *UserStore {
 
 // Create satisfies the gordian.UserStore interface.
 func (s *UserStore) Create(ctx context.Context, user *gordian.User) error {
-	return s.DB.WithContext(ctx).Create(user).Error
+	return translateErr(s.DB, conn(ctx, s.DB).Create(user).Error)
 }
 
 func (s *UserStore) Get(ctx context.Context, id uuid.UUID) (*gordian.User, error) {
 	var user gordian.User
-	if err := s.DB.WithContext(ctx).First(&user, id).Error; err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
+	if err := conn(ctx, s.DB).First(&user, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", translateErr(s.DB, err))
 	}
 	return &user, nil
 }
 
 func (s *UserStore) GetUserRole(ctx context.Context, userID uuid.UUID) (string, error) {
 	var userRole string
-	err := s.DB.WithContext(ctx).Model(&gordian.Membership{}).Where("user_id = ?", userID).Pluck("role", &userRole).Error
+	err := conn(ctx, s.DB).Model(&gordian.Membership{}).Where("user_id = ?", userID).Pluck("role", &userRole).Error
 	if err != nil {
 		return "", fmt.Errorf("failed to get user role: %w", err)
 	}
@@ -67,15 +224,19 @@ func (s *UserStore) GetUserRole(ctx context.Context, userID uuid.UUID) (string,
 
 func (s *UserStore) FindByEmail(ctx context.Context, email string) (gordian.User, error) {
 	var user gordian.User
-	if err := s.DB.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+	if err := conn(ctx, s.DB).Where("LOWER(email) = LOWER(?)", email).First(&user).Error; err != nil {
 		if err.Error() == gorm.ErrRecordNotFound.Error() {
-			return gordian.User{}, fmt.Errorf("no user found")
+			return gordian.User{}, fmt.Errorf("no user found: %w", gordian.ErrNotFound)
 		}
 		return gordian.User{}, fmt.Errorf("failed to find user: %w", err)
 	}
 	return user, nil
 }
 
+func (s *UserStore) Update(ctx context.Context, user *gordian.User) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Save(user).Error)
+}
+
 // --- MembershipStore Implementation ---
 
 type MembershipStore struct {
@@ -88,13 +249,13 @@ func NewMembershipStore(db *gorm.DB) *MembershipStore {
 
 // Create satisfies the gordian.MembershipStore interface.
 func (s *MembershipStore) Create(ctx context.Context, membership *gordian.Membership) error {
-	return s.DB.WithContext(ctx).Create(membership).Error
+	return translateErr(s.DB, conn(ctx, s.DB).Create(membership).Error)
 }
 
 
 func (s *MembershipStore) GetMembers(ctx context.Context, orgID uuid.UUID) ([]*gordian.Membership, error) {
 	var memberships []*gordian.Membership
-	err := s.DB.WithContext(ctx).Where("organization_id = ?", orgID).Find(&memberships).Error
+	err := conn(ctx, s.DB).Where("organization_id = ?", orgID).Find(&memberships).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get members: %w", err)
 	}
@@ -102,18 +263,35 @@ func (s *MembershipStore) GetMembers(ctx context.Context, orgID uuid.UUID) ([]*g
 }
 
 
+func (s *MembershipStore) ListForUser(ctx context.Context, userID uuid.UUID) ([]*gordian.Membership, error) {
+	var memberships []*gordian.Membership
+	err := conn(ctx, s.DB).Where("user_id = ?", userID).Order("joined_at").Find(&memberships).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memberships: %w", err)
+	}
+	return memberships, nil
+}
+
 func (s *MembershipStore) GetMembership(ctx context.Context, userID uuid.UUID, orgID uuid.UUID) (gordian.Membership, error) {
 	var membership gordian.Membership
-	err := s.DB.WithContext(ctx).Where("user_id = ? AND organization_id = ?", userID, orgID).First(&membership).Error
+	err := conn(ctx, s.DB).Where("user_id = ? AND organization_id = ?", userID, orgID).First(&membership).Error
 	if err != nil {
 		if err.Error() == gorm.ErrRecordNotFound.Error() {
-			return gordian.Membership{}, fmt.Errorf("no membership found")
+			return gordian.Membership{}, fmt.Errorf("no membership found: %w", gordian.ErrNotFound)
 		}
 		return gordian.Membership{}, fmt.Errorf("failed to get membership: %w", err)
 	}
 	return membership, nil
 }
 
+func (s *MembershipStore) Update(ctx context.Context, membership *gordian.Membership) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Save(membership).Error)
+}
+
+func (s *MembershipStore) Delete(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, s.DB).Delete(&gordian.Membership{}, "id = ?", id).Error
+}
+
 // --- InviteStore Implementation ---
 
 type InviteStore struct {
@@ -126,15 +304,611 @@ func NewInviteStore(db *gorm.DB) *InviteStore {
 
 // Create satisfies the gordian.InviteStore interface.
 func (s *InviteStore) Create(ctx context.Context, invite *gordian.Invite) error {
-	return s.DB.WithContext(ctx).Create(invite).Error
+	return translateErr(s.DB, conn(ctx, s.DB).Create(invite).Error)
 }
 
+// Verify reports whether token belongs to a pending invitation that has not expired.
 func (s *InviteStore) Verify(ctx context.Context, token string) (bool, error) {
-	query := `SELECT id FROM invites WHERE token = ?`
-	var inviteID string
-	err := s.DB.WithContext(ctx).Raw(query, token).Scan(&inviteID).Error
+	var count int64
+	err := conn(ctx, s.DB).Model(&gordian.Invite{}).
+		Where("token = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", token, time.Now()).
+		Count(&count).Error
 	if err != nil {
 		return false, fmt.Errorf("failed to verify invitation: %w", err)
 	}
-	return true, nil
-}
\ No newline at end of file
+	return count > 0, nil
+}
+
+func (s *InviteStore) Get(ctx context.Context, id uuid.UUID) (*gordian.Invite, error) {
+	var invite gordian.Invite
+	if err := conn(ctx, s.DB).First(&invite, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %w", translateErr(s.DB, err))
+	}
+	return &invite, nil
+}
+
+func (s *InviteStore) GetByToken(ctx context.Context, token string) (*gordian.Invite, error) {
+	var invite gordian.Invite
+	if err := conn(ctx, s.DB).Where("token = ?", token).First(&invite).Error; err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %w", translateErr(s.DB, err))
+	}
+	return &invite, nil
+}
+
+func (s *InviteStore) ListPending(ctx context.Context, orgID uuid.UUID) ([]*gordian.Invite, error) {
+	var invites []*gordian.Invite
+	err := conn(ctx, s.DB).
+		Where("organization_id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", orgID, time.Now()).
+		Order("created_at").
+		Find(&invites).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	return invites, nil
+}
+
+func (s *InviteStore) Update(ctx context.Context, invite *gordian.Invite) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Save(invite).Error)
+}
+
+// --- OutboxStore Implementation ---
+
+type OutboxStore struct {
+	DB *gorm.DB
+}
+
+func NewOutboxStore(db *gorm.DB) *OutboxStore {
+	return &OutboxStore{DB: db}
+}
+
+// Create satisfies the gordian.OutboxStore interface.
+func (s *OutboxStore) Create(ctx context.Context, msg *gordian.OutboxMessage) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Create(msg).Error)
+}
+
+func (s *OutboxStore) Get(ctx context.Context, id uuid.UUID) (*gordian.OutboxMessage, error) {
+	var msg gordian.OutboxMessage
+	if err := conn(ctx, s.DB).First(&msg, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get outbox message: %w", translateErr(s.DB, err))
+	}
+	return &msg, nil
+}
+
+func (s *OutboxStore) Update(ctx context.Context, msg *gordian.OutboxMessage) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Save(msg).Error)
+}
+
+func (s *OutboxStore) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*gordian.OutboxMessage, error) {
+	var msgs []*gordian.OutboxMessage
+	err := conn(ctx, s.DB).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", gordian.OutboxPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&msgs).Error
+		if err != nil || len(msgs) == 0 {
+			return err
+		}
+		ids := make([]uuid.UUID, len(msgs))
+		for i, msg := range msgs {
+			ids[i] = msg.ID
+		}
+		return tx.Model(&gordian.OutboxMessage{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	return msgs, nil
+}
+
+func (s *OutboxStore) ListByStatus(ctx context.Context, status gordian.OutboxStatus, limit int) ([]*gordian.OutboxMessage, error) {
+	var msgs []*gordian.OutboxMessage
+	err := conn(ctx, s.DB).Where("status = ?", status).Order("created_at").Limit(limit).Find(&msgs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox messages: %w", err)
+	}
+	return msgs, nil
+}
+
+// --- WebhookStore Implementation ---
+
+type WebhookStore struct {
+	DB *gorm.DB
+}
+
+func NewWebhookStore(db *gorm.DB) *WebhookStore {
+	return &WebhookStore{DB: db}
+}
+
+// CreateEndpoint satisfies the gordian.WebhookStore interface.
+func (s *WebhookStore) CreateEndpoint(ctx context.Context, endpoint *gordian.WebhookEndpoint) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Create(endpoint).Error)
+}
+
+func (s *WebhookStore) GetEndpoint(ctx context.Context, id uuid.UUID) (*gordian.WebhookEndpoint, error) {
+	var endpoint gordian.WebhookEndpoint
+	if err := conn(ctx, s.DB).First(&endpoint, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", translateErr(s.DB, err))
+	}
+	return &endpoint, nil
+}
+
+func (s *WebhookStore) ListEndpoints(ctx context.Context, orgID uuid.UUID) ([]*gordian.WebhookEndpoint, error) {
+	var endpoints []*gordian.WebhookEndpoint
+	err := conn(ctx, s.DB).Where("organization_id = ?", orgID).Order("created_at").Find(&endpoints).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+func (s *WebhookStore) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, s.DB).Delete(&gordian.WebhookEndpoint{}, "id = ?", id).Error
+}
+
+func (s *WebhookStore) CreateDelivery(ctx context.Context, delivery *gordian.WebhookDelivery) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Create(delivery).Error)
+}
+
+func (s *WebhookStore) UpdateDelivery(ctx context.Context, delivery *gordian.WebhookDelivery) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Save(delivery).Error)
+}
+
+func (s *WebhookStore) ListDeliveries(ctx context.Context, endpointID uuid.UUID, limit int) ([]*gordian.WebhookDelivery, error) {
+	var deliveries []*gordian.WebhookDelivery
+	err := conn(ctx, s.DB).Where("endpoint_id = ?", endpointID).Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (s *WebhookStore) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*gordian.WebhookDelivery, error) {
+	var deliveries []*gordian.WebhookDelivery
+	err := conn(ctx, s.DB).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", gordian.WebhookPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		ids := make([]uuid.UUID, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&gordian.WebhookDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// --- AuditStore Implementation ---
+
+type AuditStore struct {
+	DB *gorm.DB
+}
+
+func NewAuditStore(db *gorm.DB) *AuditStore {
+	return &AuditStore{DB: db}
+}
+
+// Create satisfies the gordian.AuditStore interface.
+func (s *AuditStore) Create(ctx context.Context, entry *gordian.AuditEntry) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Create(entry).Error)
+}
+
+func (s *AuditStore) List(ctx context.Context, filter gordian.AuditFilter) ([]*gordian.AuditEntry, error) {
+	query := conn(ctx, s.DB).Where("organization_id = ?", filter.OrganizationID)
+	if filter.ActorID != uuid.Nil {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetID != uuid.Nil {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+
+	var entries []*gordian.AuditEntry
+	err := query.Order("created_at DESC, id").Limit(filter.Limit).Offset(filter.Offset).Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// --- APIKeyStore Implementation ---
+
+type APIKeyStore struct {
+	DB *gorm.DB
+}
+
+func NewAPIKeyStore(db *gorm.DB) *APIKeyStore {
+	return &APIKeyStore{DB: db}
+}
+
+// Create satisfies the gordian.APIKeyStore interface.
+func (s *APIKeyStore) Create(ctx context.Context, key *gordian.APIKey) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Create(key).Error)
+}
+
+func (s *APIKeyStore) Get(ctx context.Context, id uuid.UUID) (*gordian.APIKey, error) {
+	var key gordian.APIKey
+	if err := conn(ctx, s.DB).First(&key, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", translateErr(s.DB, err))
+	}
+	return &key, nil
+}
+
+func (s *APIKeyStore) GetByPrefix(ctx context.Context, prefix string) (*gordian.APIKey, error) {
+	var key gordian.APIKey
+	if err := conn(ctx, s.DB).First(&key, "prefix = ?", prefix).Error; err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", translateErr(s.DB, err))
+	}
+	return &key, nil
+}
+
+func (s *APIKeyStore) List(ctx context.Context, orgID uuid.UUID) ([]*gordian.APIKey, error) {
+	var keys []*gordian.APIKey
+	err := conn(ctx, s.DB).Where("organization_id = ?", orgID).Order("created_at").Find(&keys).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *APIKeyStore) Update(ctx context.Context, key *gordian.APIKey) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Save(key).Error)
+}
+
+func (s *APIKeyStore) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return conn(ctx, s.DB).Model(&gordian.APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
+
+// --- TeamStore Implementation ---
+
+type TeamStore struct {
+	DB *gorm.DB
+}
+
+func NewTeamStore(db *gorm.DB) *TeamStore {
+	return &TeamStore{DB: db}
+}
+
+// CreateTeam satisfies the gordian.TeamStore interface.
+func (s *TeamStore) CreateTeam(ctx context.Context, team *gordian.Team) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Create(team).Error)
+}
+
+func (s *TeamStore) GetTeam(ctx context.Context, id uuid.UUID) (*gordian.Team, error) {
+	var team gordian.Team
+	if err := conn(ctx, s.DB).First(&team, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get team: %w", translateErr(s.DB, err))
+	}
+	return &team, nil
+}
+
+func (s *TeamStore) ListTeams(ctx context.Context, orgID uuid.UUID) ([]*gordian.Team, error) {
+	var teams []*gordian.Team
+	err := conn(ctx, s.DB).Where("organization_id = ?", orgID).Order("name").Find(&teams).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list teams: %w", err)
+	}
+	return teams, nil
+}
+
+func (s *TeamStore) UpdateTeam(ctx context.Context, team *gordian.Team) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Save(team).Error)
+}
+
+func (s *TeamStore) DeleteTeam(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, s.DB).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&gordian.TeamMembership{}, "team_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&gordian.Team{}, "id = ?", id).Error
+	})
+}
+
+func (s *TeamStore) AddMember(ctx context.Context, membership *gordian.TeamMembership) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Create(membership).Error)
+}
+
+func (s *TeamStore) GetMember(ctx context.Context, teamID, userID uuid.UUID) (*gordian.TeamMembership, error) {
+	var membership gordian.TeamMembership
+	if err := conn(ctx, s.DB).First(&membership, "team_id = ? AND user_id = ?", teamID, userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get team member: %w", translateErr(s.DB, err))
+	}
+	return &membership, nil
+}
+
+func (s *TeamStore) ListMembers(ctx context.Context, teamID uuid.UUID) ([]*gordian.TeamMembership, error) {
+	var memberships []*gordian.TeamMembership
+	err := conn(ctx, s.DB).Where("team_id = ?", teamID).Order("joined_at").Find(&memberships).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team members: %w", err)
+	}
+	return memberships, nil
+}
+
+func (s *TeamStore) ListForUser(ctx context.Context, orgID, userID uuid.UUID) ([]*gordian.Team, error) {
+	var teams []*gordian.Team
+	err := conn(ctx, s.DB).
+		Joins("JOIN team_memberships ON team_memberships.team_id = teams.id").
+		Where("teams.organization_id = ? AND team_memberships.user_id = ?", orgID, userID).
+		Order("teams.name").
+		Find(&teams).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list teams of user: %w", err)
+	}
+	return teams, nil
+}
+
+func (s *TeamStore) UpdateMember(ctx context.Context, membership *gordian.TeamMembership) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Save(membership).Error)
+}
+
+func (s *TeamStore) RemoveMember(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, s.DB).Delete(&gordian.TeamMembership{}, "id = ?", id).Error
+}
+
+func (s *TeamStore) RemoveUser(ctx context.Context, orgID, userID uuid.UUID) error {
+	return conn(ctx, s.DB).Delete(&gordian.TeamMembership{}, "organization_id = ? AND user_id = ?", orgID, userID).Error
+}
+
+// --- DomainStore Implementation ---
+
+type DomainStore struct {
+	DB *gorm.DB
+}
+
+func NewDomainStore(db *gorm.DB) *DomainStore {
+	return &DomainStore{DB: db}
+}
+
+// Create satisfies the gordian.DomainStore interface.
+func (s *DomainStore) Create(ctx context.Context, domain *gordian.OrganizationDomain) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Create(domain).Error)
+}
+
+func (s *DomainStore) Get(ctx context.Context, id uuid.UUID) (*gordian.OrganizationDomain, error) {
+	var domain gordian.OrganizationDomain
+	if err := conn(ctx, s.DB).First(&domain, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get domain: %w", translateErr(s.DB, err))
+	}
+	return &domain, nil
+}
+
+func (s *DomainStore) List(ctx context.Context, orgID uuid.UUID) ([]*gordian.OrganizationDomain, error) {
+	var domains []*gordian.OrganizationDomain
+	err := conn(ctx, s.DB).Where("organization_id = ?", orgID).Order("domain").Find(&domains).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+	return domains, nil
+}
+
+func (s *DomainStore) GetVerified(ctx context.Context, domain string) (*gordian.OrganizationDomain, error) {
+	var claim gordian.OrganizationDomain
+	if err := conn(ctx, s.DB).First(&claim, "domain = ? AND verified_at IS NOT NULL", domain).Error; err != nil {
+		return nil, fmt.Errorf("failed to get verified domain: %w", translateErr(s.DB, err))
+	}
+	return &claim, nil
+}
+
+func (s *DomainStore) Update(ctx context.Context, domain *gordian.OrganizationDomain) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Save(domain).Error)
+}
+
+func (s *DomainStore) Delete(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, s.DB).Delete(&gordian.OrganizationDomain{}, "id = ?", id).Error
+}
+
+// --- JoinRequestStore Implementation ---
+
+type JoinRequestStore struct {
+	DB *gorm.DB
+}
+
+func NewJoinRequestStore(db *gorm.DB) *JoinRequestStore {
+	return &JoinRequestStore{DB: db}
+}
+
+// Create satisfies the gordian.JoinRequestStore interface.
+func (s *JoinRequestStore) Create(ctx context.Context, request *gordian.JoinRequest) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Create(request).Error)
+}
+
+func (s *JoinRequestStore) Get(ctx context.Context, id uuid.UUID) (*gordian.JoinRequest, error) {
+	var request gordian.JoinRequest
+	if err := conn(ctx, s.DB).First(&request, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get join request: %w", translateErr(s.DB, err))
+	}
+	return &request, nil
+}
+
+func (s *JoinRequestStore) GetPending(ctx context.Context, orgID, userID uuid.UUID) (*gordian.JoinRequest, error) {
+	var request gordian.JoinRequest
+	err := conn(ctx, s.DB).First(&request, "organization_id = ? AND user_id = ? AND status = ?",
+		orgID, userID, gordian.JoinRequestStatusPending).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get join request: %w", translateErr(s.DB, err))
+	}
+	return &request, nil
+}
+
+func (s *JoinRequestStore) List(ctx context.Context, orgID uuid.UUID, status gordian.JoinRequestStatus) ([]*gordian.JoinRequest, error) {
+	var requests []*gordian.JoinRequest
+	query := conn(ctx, s.DB).Where("organization_id = ?", orgID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at DESC").Find(&requests).Error; err != nil {
+		return nil, fmt.Errorf("failed to list join requests: %w", err)
+	}
+	return requests, nil
+}
+
+func (s *JoinRequestStore) Update(ctx context.Context, request *gordian.JoinRequest) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Save(request).Error)
+}
+
+// --- InviteLinkStore Implementation ---
+
+type InviteLinkStore struct {
+	DB *gorm.DB
+}
+
+func NewInviteLinkStore(db *gorm.DB) *InviteLinkStore {
+	return &InviteLinkStore{DB: db}
+}
+
+// Create satisfies the gordian.InviteLinkStore interface.
+func (s *InviteLinkStore) Create(ctx context.Context, link *gordian.InviteLink) error {
+	return translateErr(s.DB, conn(ctx, s.DB).Create(link).Error)
+}
+
+func (s *InviteLinkStore) Get(ctx context.Context, id uuid.UUID) (*gordian.InviteLink, error) {
+	var link gordian.InviteLink
+	if err := conn(ctx, s.DB).First(&link, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get invite link: %w", translateErr(s.DB, err))
+	}
+	return &link, nil
+}
+
+func (s *InviteLinkStore) GetByToken(ctx context.Context, token string) (*gordian.InviteLink, error) {
+	var link gordian.InviteLink
+	if err := conn(ctx, s.DB).First(&link, "token = ?", token).Error; err != nil {
+		return nil, fmt.Errorf("failed to get invite link: %w", translateErr(s.DB, err))
+	}
+	return &link, nil
+}
+
+func (s *InviteLinkStore) List(ctx context.Context, orgID uuid.UUID) ([]*gordian.InviteLink, error) {
+	var links []*gordian.InviteLink
+	err := conn(ctx, s.DB).Where("organization_id = ?", orgID).Order("created_at").Find(&links).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invite links: %w", err)
+	}
+	return links, nil
+}
+
+// Update leaves Uses alone, so it cannot undo a concurrent redemption.
+func (s *InviteLinkStore) Update(ctx context.Context, link *gordian.InviteLink) error {
+	err := conn(ctx, s.DB).Model(link).
+		Select("role", "max_uses", "allowed_domain", "expires_at", "revoked_at").
+		Updates(link).Error
+	return translateErr(s.DB, err)
+}
+
+func (s *InviteLinkStore) Redeem(ctx context.Context, redemption *gordian.InviteLinkRedemption) error {
+	return conn(ctx, s.DB).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&gordian.InviteLink{}).
+			Where("id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?) AND (max_uses = 0 OR uses < max_uses)",
+				redemption.LinkID, redemption.RedeemedAt).
+			UpdateColumn("uses", gorm.Expr("uses + 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("invite link is revoked, expired or used up: %w", gordian.ErrConflict)
+		}
+		return translateErr(s.DB, tx.Create(redemption).Error)
+	})
+}
+
+func (s *InviteLinkStore) ListRedemptions(ctx context.Context, linkID uuid.UUID) ([]*gordian.InviteLinkRedemption, error) {
+	var redemptions []*gordian.InviteLinkRedemption
+	err := conn(ctx, s.DB).Where("link_id = ?", linkID).Order("redeemed_at").Find(&redemptions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invite link redemptions: %w", err)
+	}
+	return redemptions, nil
+}
+
+// --- UsageStore Implementation ---
+
+type UsageStore struct {
+	DB *gorm.DB
+}
+
+func NewUsageStore(db *gorm.DB) *UsageStore {
+	return &UsageStore{DB: db}
+}
+
+// LockUsage satisfies the gordian.UsageStore interface. It writes the organization row instead of
+// selecting it FOR UPDATE, which locks on every dialect, including SQLite.
+func (s *UsageStore) LockUsage(ctx context.Context, orgID uuid.UUID) error {
+	res := conn(ctx, s.DB).Exec("UPDATE organizations SET plan = plan WHERE id = ?", orgID)
+	if res.Error != nil {
+		return translateErr(s.DB, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("organization not found: %w", gordian.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *UsageStore) GetUsage(ctx context.Context, orgID uuid.UUID, now time.Time) (gordian.Usage, error) {
+	var usage gordian.Usage
+	err := conn(ctx, s.DB).Raw(`SELECT
+		(SELECT COUNT(*) FROM memberships WHERE organization_id = @org) AS members,
+		(SELECT COUNT(*) FROM invites WHERE organization_id = @org
+			AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > @now) AS pending_invites,
+		(SELECT COUNT(*) FROM teams WHERE organization_id = @org) AS teams`,
+		sql.Named("org", orgID), sql.Named("now", now)).Scan(&usage).Error
+	if err != nil {
+		return gordian.Usage{}, fmt.Errorf("failed to count usage: %w", err)
+	}
+	return usage, nil
+}
+
+// --- SettingsStore Implementation ---
+
+type SettingsStore struct {
+	DB *gorm.DB
+}
+
+func NewSettingsStore(db *gorm.DB) *SettingsStore {
+	return &SettingsStore{DB: db}
+}
+
+// Get satisfies the gordian.SettingsStore interface.
+func (s *SettingsStore) Get(ctx context.Context, orgID uuid.UUID) (*gordian.OrganizationSettings, error) {
+	var settings gordian.OrganizationSettings
+	if err := conn(ctx, s.DB).Take(&settings, "organization_id = ?", orgID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", translateErr(s.DB, err))
+	}
+	return &settings, nil
+}
+
+func (s *SettingsStore) Save(ctx context.Context, settings *gordian.OrganizationSettings) error {
+	err := conn(ctx, s.DB).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "organization_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"overrides", "rollouts", "updated_at"}),
+	}).Create(settings).Error
+	return translateErr(s.DB, err)
+}
+
+// LockSettings satisfies the gordian.SettingsStore interface. Like UsageStore.LockUsage, it
+// writes the organization row.
+func (s *SettingsStore) LockSettings(ctx context.Context, orgID uuid.UUID) error {
+	res := conn(ctx, s.DB).Exec("UPDATE organizations SET plan = plan WHERE id = ?", orgID)
+	if res.Error != nil {
+		return translateErr(s.DB, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("organization not found: %w", gordian.ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package gorm_test
+
+import (
+	"os"
+	"testing"
+
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/internal/pgtest"
+	"github.com/Robotech-Org/gordian/internal/sqlitetest"
+	"github.com/Robotech-Org/gordian/internal/storetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func stores(db *gorm.DB) storetest.Stores {
+	return storetest.Stores{
+		Transactor:    gormadapter.NewTransactor(db),
+		Organizations: gormadapter.NewOrganizationStore(db),
+		Users:         gormadapter.NewUserStore(db),
+		Memberships:   gormadapter.NewMembershipStore(db),
+		Invites:       gormadapter.NewInviteStore(db),
+		Outbox:        gormadapter.NewOutboxStore(db),
+		Webhooks:      gormadapter.NewWebhookStore(db),
+		Audit:         gormadapter.NewAuditStore(db),
+		APIKeys:       gormadapter.NewAPIKeyStore(db),
+		Teams:         gormadapter.NewTeamStore(db),
+		Domains:       gormadapter.NewDomainStore(db),
+		JoinRequests:  gormadapter.NewJoinRequestStore(db),
+		InviteLinks:   gormadapter.NewInviteLinkStore(db),
+		Usage:         gormadapter.NewUsageStore(db),
+		Settings:      gormadapter.NewSettingsStore(db),
+	}
+}
+
+func TestStoresSQLite(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		return stores(sqlitetest.Open(t))
+	})
+}
+
+func TestStoresPostgres(t *testing.T) {
+	if os.Getenv(pgtest.DSNEnv) == "" {
+		t.Skipf("%s is not set", pgtest.DSNEnv)
+	}
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		db, err := gorm.Open(postgres.Open(pgtest.Migrated(t)), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { sqlDB.Close() })
+		return stores(db)
+	})
+}
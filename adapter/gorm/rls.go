@@ -0,0 +1,77 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Robotech-Org/gordian"
+	"gorm.io/gorm"
+)
+
+// CurrentOrgSetting is the PostgreSQL setting read by the row-level security policies.
+// Transactor and TenantRouter.WithinTenant set it to the active organization at the start of
+// their transactions, and nothing else does: queries on other connections see no rows.
+const CurrentOrgSetting = "app.current_org"
+
+// RLSPolicy describes a tenant isolation policy on one PostgreSQL table.
+type RLSPolicy struct {
+	Table  string // may be schema qualified, e.g. "app.projects"
+	Column string // organization ID column, "organization_id" when empty
+}
+
+// Statements returns the SQL enabling row-level security on the table and restricting
+// reads and writes to rows whose Column equals CurrentOrgSetting. The statements are
+// idempotent. Sessions without the setting see no rows at all.
+//
+// FORCE makes the policy apply to the table owner too; jobs that must see every tenant
+// need a role with BYPASSRLS.
+func (p RLSPolicy) Statements() []string {
+	column := p.Column
+	if column == "" {
+		column = "organization_id"
+	}
+	table := quoteIdent(p.Table)
+	check := fmt.Sprintf("%s = NULLIF(current_setting('%s', true), '')::uuid", quoteIdent(column), CurrentOrgSetting)
+	return []string{
+		fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", table),
+		fmt.Sprintf("ALTER TABLE %s FORCE ROW LEVEL SECURITY", table),
+		fmt.Sprintf("DROP POLICY IF EXISTS gordian_tenant_isolation ON %s", table),
+		fmt.Sprintf("CREATE POLICY gordian_tenant_isolation ON %s USING (%s) WITH CHECK (%s)", table, check, check),
+	}
+}
+
+// ApplyRLSPolicies runs the statements of every policy in a single transaction.
+func ApplyRLSPolicies(ctx context.Context, db *gorm.DB, policies ...RLSPolicy) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, p := range policies {
+			for _, stmt := range p.Statements() {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return fmt.Errorf("failed to apply row-level security on %s: %w", p.Table, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// setCurrentOrg sets CurrentOrgSetting for the rest of tx when ctx carries an active organization.
+// It only applies to PostgreSQL.
+func setCurrentOrg(ctx context.Context, tx *gorm.DB) error {
+	orgID, ok := gordian.ActiveOrgID(ctx)
+	if !ok || tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	if err := tx.Exec("SELECT set_config(?, ?, true)", CurrentOrgSetting, orgID.String()).Error; err != nil {
+		return fmt.Errorf("failed to set %s: %w", CurrentOrgSetting, err)
+	}
+	return nil
+}
+
+func quoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
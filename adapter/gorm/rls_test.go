@@ -0,0 +1,141 @@
+package gorm_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/internal/pgtest"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type rlsProject struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null"`
+	Name           string
+}
+
+// rlsRole is the unprivileged role the test switches to when connected as a superuser or a
+// role with BYPASSRLS, which row-level security does not apply to.
+const rlsRole = "gordian_rls_test"
+
+func openRLSDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn, schema := pgtest.Schema(t)
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	// A single connection, so the SET ROLE below holds for every statement of the test.
+	sqlDB.SetMaxOpenConns(1)
+
+	// The search_path of dsn puts rls_projects in schema.
+	if err := db.AutoMigrate(&rlsProject{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gormadapter.ApplyRLSPolicies(context.Background(), db, gormadapter.RLSPolicy{Table: "rls_projects"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var privileged bool
+	if err := db.Raw("SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").Scan(&privileged).Error; err != nil {
+		t.Fatal(err)
+	}
+	if privileged {
+		for _, stmt := range []string{
+			fmt.Sprintf(`DO $$ BEGIN CREATE ROLE %s NOLOGIN; EXCEPTION WHEN duplicate_object THEN NULL; END $$`, rlsRole),
+			fmt.Sprintf(`GRANT USAGE ON SCHEMA %q TO %s`, schema, rlsRole),
+			fmt.Sprintf(`GRANT SELECT, INSERT ON %q.rls_projects TO %s`, schema, rlsRole),
+			fmt.Sprintf(`SET ROLE %s`, rlsRole),
+		} {
+			if err := db.Exec(stmt).Error; err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+		}
+	}
+	return db
+}
+
+func TestRLSPolicyIsolatesTenants(t *testing.T) {
+	db := openRLSDatabase(t)
+	transactor := gormadapter.NewTransactor(db)
+	orgA, orgB := uuid.New(), uuid.New()
+	tenant := func(orgID uuid.UUID) context.Context {
+		return gordian.WithActiveTenant(context.Background(), orgID, "owner", uuid.New())
+	}
+
+	for _, orgID := range []uuid.UUID{orgA, orgB} {
+		err := transactor.WithinTransaction(tenant(orgID), func(ctx context.Context) error {
+			tx, _ := gormadapter.TransactionDB(ctx)
+			return tx.Create(&[]rlsProject{
+				{ID: uuid.New(), OrganizationID: orgID, Name: "one"},
+				{ID: uuid.New(), OrganizationID: orgID, Name: "two"},
+			}).Error
+		})
+		if err != nil {
+			t.Fatalf("failed to write the projects of %s: %v", orgID, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		inTx  bool
+		wantN int
+		want  uuid.UUID // organization of every row returned
+	}{
+		{name: "organization a", ctx: tenant(orgA), inTx: true, wantN: 2, want: orgA},
+		{name: "organization b", ctx: tenant(orgB), inTx: true, wantN: 2, want: orgB},
+		{name: "transaction without active tenant", ctx: context.Background(), inTx: true},
+		{name: "active tenant outside a transaction", ctx: tenant(orgA)},
+		{name: "no setting", ctx: context.Background()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows []rlsProject
+			find := func(ctx context.Context, db *gorm.DB) error {
+				return db.WithContext(ctx).Find(&rows).Error
+			}
+			var err error
+			if tt.inTx {
+				err = transactor.WithinTransaction(tt.ctx, func(ctx context.Context) error {
+					tx, _ := gormadapter.TransactionDB(ctx)
+					return find(ctx, tx)
+				})
+			} else {
+				err = find(tt.ctx, db)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != tt.wantN {
+				t.Fatalf("got %d rows, want %d", len(rows), tt.wantN)
+			}
+			for _, row := range rows {
+				if row.OrganizationID != tt.want {
+					t.Errorf("row %s of organization %s is visible to %s", row.Name, row.OrganizationID, tt.want)
+				}
+			}
+		})
+	}
+
+	t.Run("write for another organization", func(t *testing.T) {
+		err := transactor.WithinTransaction(tenant(orgA), func(ctx context.Context) error {
+			tx, _ := gormadapter.TransactionDB(ctx)
+			return tx.Create(&rlsProject{ID: uuid.New(), OrganizationID: orgB, Name: "planted"}).Error
+		})
+		if err == nil {
+			t.Error("the policy accepted a row of another organization")
+		}
+	})
+}
@@ -0,0 +1,365 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IsolationMode says where the application data of an organization is stored.
+type IsolationMode string
+
+const (
+	IsolationShared   IsolationMode = "shared"   // shared tables in the control database, see TenantPlugin
+	IsolationSchema   IsolationMode = "schema"   // a dedicated PostgreSQL schema in the control database
+	IsolationDatabase IsolationMode = "database" // a dedicated PostgreSQL database
+)
+
+// TenantPlacement records where an organization with physical isolation lives.
+// Organizations without a placement use IsolationShared.
+type TenantPlacement struct {
+	OrganizationID uuid.UUID     `gorm:"type:uuid;primaryKey"`
+	Mode           IsolationMode `gorm:"not null"`
+	Schema         string
+	Database       string
+	CreatedAt      time.Time
+}
+
+type tenantDBKey struct{}
+
+// TenantDB returns the tenant scoped transaction started by TenantRouter.WithinTenant.
+func TenantDB(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(tenantDBKey{}).(*gorm.DB)
+	return tx, ok
+}
+
+// TenantRouter hands out connections to the storage of each organization. It keeps the
+// Gordian tables and the tenant placements in the control database, and places the
+// application data of new organizations according to Mode.
+type TenantRouter struct {
+	// DB is the control database.
+	DB *gorm.DB
+	// Migrate creates or updates the application tables of one tenant.
+	Migrate func(ctx context.Context, db *gorm.DB) error
+	// Mode picks the isolation of a new organization. Defaults to IsolationSchema for every organization.
+	Mode func(org *gordian.Organization) IsolationMode
+	// Open and DatabaseDSN connect to dedicated databases; required for IsolationDatabase.
+	Open        func(dsn string) gorm.Dialector
+	DatabaseDSN func(database string) string
+
+	mu         sync.Mutex
+	placements map[uuid.UUID]*TenantPlacement
+	databases  map[string]*gorm.DB
+}
+
+func NewTenantRouter(db *gorm.DB, migrate func(ctx context.Context, db *gorm.DB) error) *TenantRouter {
+	return &TenantRouter{
+		DB:         db,
+		Migrate:    migrate,
+		placements: map[uuid.UUID]*TenantPlacement{},
+		databases:  map[string]*gorm.DB{},
+	}
+}
+
+// Register provisions the storage of every organization created through the Service, and
+// removes it once the organization is deleted. Provisioning runs as a before-hook, so the
+// storage exists by the time CreateOrganization returns and a failed provisioning aborts it.
+// CreateOrganization can still fail after the hook, e.g. on a slug conflict; run
+// DeprovisionOrphans periodically to remove the storage left behind.
+func (r *TenantRouter) Register(bus *gordian.EventBus) {
+	gordian.OnBefore(bus, func(ctx context.Context, e gordian.OrganizationCreated) error {
+		return r.Provision(ctx, e.Organization)
+	})
+	gordian.OnAfter(bus, func(ctx context.Context, e gordian.OrganizationDeleted) {
+		if err := r.Deprovision(ctx, e.Organization.ID); err != nil {
+			log.Printf("ERROR: failed to deprovision tenant %s: %v", e.Organization.ID, err)
+		}
+	})
+}
+
+// Provision creates the schema or database of org, migrates it and records its placement.
+// It is idempotent. When it fails, it drops the schema or database it created.
+func (r *TenantRouter) Provision(ctx context.Context, org *gordian.Organization) (err error) {
+	mode := IsolationSchema
+	if r.Mode != nil {
+		mode = r.Mode(org)
+	}
+	placement := &TenantPlacement{OrganizationID: org.ID, Mode: mode}
+	name := tenantName(org.ID)
+
+	var created bool
+	switch mode {
+	case IsolationShared:
+		return nil
+	case IsolationSchema:
+		placement.Schema = name
+		created, err = r.createSchema(ctx, name)
+	case IsolationDatabase:
+		placement.Database = name
+		created, err = r.createDatabase(ctx, name)
+	default:
+		return fmt.Errorf("unknown isolation mode %q: %w", mode, gordian.ErrInvalidArgument)
+	}
+	if err != nil {
+		return err
+	}
+	if created {
+		defer func() {
+			if err != nil {
+				err = errors.Join(err, r.drop(context.WithoutCancel(ctx), placement))
+			}
+		}()
+	}
+
+	if err := r.migrate(ctx, placement); err != nil {
+		return err
+	}
+	err = r.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(placement).Error
+	if err != nil {
+		return fmt.Errorf("failed to record tenant placement: %w", translateErr(r.DB, err))
+	}
+	r.mu.Lock()
+	r.placements[org.ID] = placement
+	r.mu.Unlock()
+	return nil
+}
+
+// Deprovision drops the schema or database of orgID, with all of its data, and forgets its
+// placement. Organizations in the shared tables are left alone; delete their rows yourself.
+func (r *TenantRouter) Deprovision(ctx context.Context, orgID uuid.UUID) error {
+	placement := &TenantPlacement{}
+	err := r.DB.WithContext(ctx).Where("organization_id = ?", orgID).Take(placement).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get tenant placement: %w", err)
+	}
+	if err := r.drop(ctx, placement); err != nil {
+		return err
+	}
+	if err := r.DB.WithContext(ctx).Delete(placement).Error; err != nil {
+		return fmt.Errorf("failed to delete tenant placement: %w", err)
+	}
+	r.mu.Lock()
+	delete(r.placements, orgID)
+	r.mu.Unlock()
+	return nil
+}
+
+// DeprovisionOrphans deprovisions the tenants whose organization does not exist, because
+// CreateOrganization failed after provisioning or the organization was deleted while the
+// router was not registered. Placements younger than minAge are skipped, their organization
+// may still be in the middle of being created. It returns the number of tenants removed.
+func (r *TenantRouter) DeprovisionOrphans(ctx context.Context, minAge time.Duration) (int, error) {
+	var orphans []uuid.UUID
+	err := r.DB.WithContext(ctx).Model(&TenantPlacement{}).
+		Where("created_at < ?", time.Now().Add(-minAge)).
+		Where("NOT EXISTS (SELECT 1 FROM organizations WHERE organizations.id = tenant_placements.organization_id)").
+		Pluck("organization_id", &orphans).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to list orphaned tenant placements: %w", err)
+	}
+	for i, orgID := range orphans {
+		if err := r.Deprovision(ctx, orgID); err != nil {
+			return i, err
+		}
+	}
+	return len(orphans), nil
+}
+
+// MigrateAll runs Migrate on the shared tables and on every provisioned tenant.
+func (r *TenantRouter) MigrateAll(ctx context.Context) error {
+	if err := r.Migrate(ctx, r.DB.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to migrate shared tables: %w", err)
+	}
+	var placements []*TenantPlacement
+	if err := r.DB.WithContext(ctx).Order("created_at").Find(&placements).Error; err != nil {
+		return fmt.Errorf("failed to list tenant placements: %w", err)
+	}
+	for _, p := range placements {
+		if err := r.migrate(ctx, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithinTenant runs fn in a transaction on the storage of the active organization of ctx.
+// fn reaches the transaction through TenantDB. For schema isolated tenants the transaction's
+// search_path is the tenant schema, so unqualified table names resolve to the tenant's tables.
+func (r *TenantRouter) WithinTenant(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := TenantDB(ctx); ok {
+		return fn(ctx)
+	}
+	orgID, ok := gordian.ActiveOrgID(ctx)
+	if !ok {
+		return ErrNoActiveTenant
+	}
+	placement, err := r.placement(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	return r.within(ctx, placement, fn)
+}
+
+// Close closes the connections to dedicated tenant databases.
+func (r *TenantRouter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for name, db := range r.databases {
+		if sqlDB, err := db.DB(); err == nil {
+			errs = append(errs, sqlDB.Close())
+		}
+		delete(r.databases, name)
+	}
+	return errors.Join(errs...)
+}
+
+func (r *TenantRouter) within(ctx context.Context, placement *TenantPlacement, fn func(ctx context.Context) error) error {
+	db := r.DB
+	if placement.Mode == IsolationDatabase {
+		var err error
+		if db, err = r.database(placement.Database); err != nil {
+			return err
+		}
+	}
+	var committed func()
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if placement.Mode == IsolationSchema {
+			if err := tx.Exec("SELECT set_config('search_path', ?, true)", quoteIdent(placement.Schema)).Error; err != nil {
+				return fmt.Errorf("failed to set tenant search_path: %w", err)
+			}
+		}
+		if err := setCurrentOrg(ctx, tx); err != nil {
+			return err
+		}
+		var txCtx context.Context
+		txCtx, committed = gordian.WithAfterCommit(context.WithValue(ctx, tenantDBKey{}, tx))
+		return fn(txCtx)
+	})
+	if err != nil {
+		return err
+	}
+	committed()
+	return nil
+}
+
+func (r *TenantRouter) migrate(ctx context.Context, placement *TenantPlacement) error {
+	err := r.within(ctx, placement, func(ctx context.Context) error {
+		tx, _ := TenantDB(ctx)
+		return r.Migrate(ctx, tx)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to migrate tenant %s: %w", placement.OrganizationID, err)
+	}
+	return nil
+}
+
+func (r *TenantRouter) placement(ctx context.Context, orgID uuid.UUID) (*TenantPlacement, error) {
+	r.mu.Lock()
+	placement, ok := r.placements[orgID]
+	r.mu.Unlock()
+	if ok {
+		return placement, nil
+	}
+
+	placement = &TenantPlacement{}
+	err := r.DB.WithContext(ctx).Where("organization_id = ?", orgID).Take(placement).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &TenantPlacement{OrganizationID: orgID, Mode: IsolationShared}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant placement: %w", err)
+	}
+	r.mu.Lock()
+	r.placements[orgID] = placement
+	r.mu.Unlock()
+	return placement, nil
+}
+
+// tenantName is the name of the schema or database of orgID.
+func tenantName(orgID uuid.UUID) string {
+	return "tenant_" + strings.ReplaceAll(orgID.String(), "-", "")
+}
+
+// createSchema creates the schema name and reports whether it did not exist yet.
+func (r *TenantRouter) createSchema(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	if err := r.DB.WithContext(ctx).Raw("SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = ?)", name).Scan(&exists).Error; err != nil {
+		return false, fmt.Errorf("failed to look up tenant schema: %w", err)
+	}
+	if exists {
+		return false, nil
+	}
+	if err := r.DB.WithContext(ctx).Exec("CREATE SCHEMA IF NOT EXISTS " + quoteIdent(name)).Error; err != nil {
+		return false, fmt.Errorf("failed to create tenant schema: %w", err)
+	}
+	return true, nil
+}
+
+// createDatabase creates the database name and reports whether it did not exist yet.
+func (r *TenantRouter) createDatabase(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	if err := r.DB.WithContext(ctx).Raw("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = ?)", name).Scan(&exists).Error; err != nil {
+		return false, fmt.Errorf("failed to look up tenant database: %w", err)
+	}
+	if exists {
+		return false, nil
+	}
+	if err := r.DB.WithContext(ctx).Exec("CREATE DATABASE " + quoteIdent(name)).Error; err != nil {
+		return false, fmt.Errorf("failed to create tenant database: %w", err)
+	}
+	return true, nil
+}
+
+// drop removes the schema or database of placement, closing the router's connection to it first.
+func (r *TenantRouter) drop(ctx context.Context, placement *TenantPlacement) error {
+	switch placement.Mode {
+	case IsolationSchema:
+		if err := r.DB.WithContext(ctx).Exec("DROP SCHEMA IF EXISTS " + quoteIdent(placement.Schema) + " CASCADE").Error; err != nil {
+			return fmt.Errorf("failed to drop tenant schema: %w", err)
+		}
+	case IsolationDatabase:
+		r.mu.Lock()
+		db, ok := r.databases[placement.Database]
+		delete(r.databases, placement.Database)
+		r.mu.Unlock()
+		if ok {
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		}
+		if err := r.DB.WithContext(ctx).Exec("DROP DATABASE IF EXISTS " + quoteIdent(placement.Database)).Error; err != nil {
+			return fmt.Errorf("failed to drop tenant database: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *TenantRouter) database(name string) (*gorm.DB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if db, ok := r.databases[name]; ok {
+		return db, nil
+	}
+	if r.Open == nil || r.DatabaseDSN == nil {
+		return nil, errors.New("tenant router: Open and DatabaseDSN are required for database isolation")
+	}
+	db, err := gorm.Open(r.Open(r.DatabaseDSN(name)), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to tenant database: %w", err)
+	}
+	r.databases[name] = db
+	return db, nil
+}
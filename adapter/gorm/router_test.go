@@ -0,0 +1,212 @@
+package gorm_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/internal/pgtest"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type noopEmailer struct{}
+
+func (noopEmailer) SendInvitation(context.Context, *gordian.Invite) error { return nil }
+func (noopEmailer) SendJoinRequest(context.Context, *gordian.JoinRequest, []*gordian.User) error {
+	return nil
+}
+func (noopEmailer) SendJoinRequestDecision(context.Context, *gordian.JoinRequest, *gordian.User) error {
+	return nil
+}
+
+type note struct {
+	ID   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Text string
+}
+
+var errMigrationFailed = errors.New("migration failed")
+
+type routerEnv struct {
+	ctx         context.Context
+	db          *gorm.DB
+	svc         *gordian.Service
+	router      *gormadapter.TenantRouter
+	owner       *gordian.User
+	failMigrate bool      // makes the tenant migration fail
+	lastOrgID   uuid.UUID // organization provisioned last
+}
+
+// newRouterEnv registers a TenantRouter with schema isolation on a Service.
+func newRouterEnv(t *testing.T) *routerEnv {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open(pgtest.Migrated(t)), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	env := &routerEnv{ctx: context.Background(), db: db}
+	env.svc = gordian.New(
+		gormadapter.NewOrganizationStore(db),
+		gormadapter.NewUserStore(db),
+		gormadapter.NewMembershipStore(db),
+		gormadapter.NewInviteStore(db),
+		noopEmailer{},
+		gordian.WithTransactor(gormadapter.NewTransactor(db)),
+	)
+	env.router = gormadapter.NewTenantRouter(db, func(ctx context.Context, tx *gorm.DB) error {
+		if env.failMigrate {
+			return errMigrationFailed
+		}
+		return tx.AutoMigrate(&note{})
+	})
+	env.router.Mode = func(org *gordian.Organization) gormadapter.IsolationMode {
+		env.lastOrgID = org.ID
+		return gormadapter.IsolationSchema
+	}
+	env.router.Register(env.svc.Events())
+	t.Cleanup(func() { env.router.Close() })
+	t.Cleanup(env.svc.Events().Wait)
+
+	if env.owner, err = env.svc.CreateUser(env.ctx, "owner@example.com", "Owner"); err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+// provisioned reports whether the schema and the placement of orgID exist. It drops the schema
+// when the test ends, as tenant schemas live outside the test's own.
+func (env *routerEnv) provisioned(t *testing.T, orgID uuid.UUID) (schema, placement bool) {
+	t.Helper()
+	name := "tenant_" + strings.ReplaceAll(orgID.String(), "-", "")
+	t.Cleanup(func() { env.db.Exec(`DROP SCHEMA IF EXISTS "` + name + `" CASCADE`) })
+	if err := env.db.Raw("SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = ?)", name).Scan(&schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	if err := env.db.Model(&gormadapter.TenantPlacement{}).Where("organization_id = ?", orgID).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return schema, n > 0
+}
+
+func TestTenantRouterLifecycle(t *testing.T) {
+	tests := []struct {
+		name string
+		// run changes the organizations and returns the one to check
+		run           func(t *testing.T, env *routerEnv) uuid.UUID
+		wantSchema    bool
+		wantPlacement bool
+	}{
+		{
+			name: "created organization",
+			run: func(t *testing.T, env *routerEnv) uuid.UUID {
+				org, err := env.svc.CreateOrganization(env.ctx, "Acme", env.owner.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return org.ID
+			},
+			wantSchema:    true,
+			wantPlacement: true,
+		},
+		{
+			name: "failed migration",
+			run: func(t *testing.T, env *routerEnv) uuid.UUID {
+				env.failMigrate = true
+				if _, err := env.svc.CreateOrganization(env.ctx, "Acme", env.owner.ID); !errors.Is(err, gordian.ErrVetoed) {
+					t.Fatalf("CreateOrganization = %v, want ErrVetoed", err)
+				}
+				return env.lastOrgID
+			},
+		},
+		{
+			name: "deleted organization",
+			run: func(t *testing.T, env *routerEnv) uuid.UUID {
+				org, err := env.svc.CreateOrganization(env.ctx, "Acme", env.owner.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := env.svc.DeleteOrganization(env.ctx, org.ID); err != nil {
+					t.Fatal(err)
+				}
+				env.svc.Events().Wait()
+				return org.ID
+			},
+		},
+		{
+			name: "orphan of a failed create",
+			run: func(t *testing.T, env *routerEnv) uuid.UUID {
+				// The owner does not exist, so the transaction fails after provisioning.
+				if _, err := env.svc.CreateOrganization(env.ctx, "Acme", uuid.New()); err == nil {
+					t.Fatal("CreateOrganization succeeded without an owner")
+				}
+				if _, placement := env.provisioned(t, env.lastOrgID); !placement {
+					t.Fatal("the failed create was not provisioned, nothing to clean up")
+				}
+				n, err := env.router.DeprovisionOrphans(env.ctx, 0)
+				if err != nil || n != 1 {
+					t.Fatalf("DeprovisionOrphans = %d, %v, want 1", n, err)
+				}
+				return env.lastOrgID
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newRouterEnv(t)
+			orgID := tt.run(t, env)
+			schema, placement := env.provisioned(t, orgID)
+			if schema != tt.wantSchema || placement != tt.wantPlacement {
+				t.Errorf("schema %v, placement %v, want %v, %v", schema, placement, tt.wantSchema, tt.wantPlacement)
+			}
+		})
+	}
+}
+
+func TestTenantRouterWithinTenant(t *testing.T) {
+	env := newRouterEnv(t)
+	var orgs []uuid.UUID
+	for _, name := range []string{"Acme", "Globex"} {
+		org, err := env.svc.CreateOrganization(env.ctx, name, env.owner.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.provisioned(t, org.ID)
+		orgs = append(orgs, org.ID)
+	}
+	tenant := func(orgID uuid.UUID) context.Context {
+		return gordian.WithActiveTenant(env.ctx, orgID, "owner", uuid.New())
+	}
+
+	err := env.router.WithinTenant(tenant(orgs[0]), func(ctx context.Context) error {
+		tx, _ := gormadapter.TenantDB(ctx)
+		return tx.Create(&note{ID: uuid.New(), Text: "acme"}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{1, 0} {
+		var notes []note
+		err := env.router.WithinTenant(tenant(orgs[i]), func(ctx context.Context) error {
+			tx, _ := gormadapter.TenantDB(ctx)
+			return tx.Find(&notes).Error
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notes) != want {
+			t.Errorf("tenant %d has %d notes, want %d", i, len(notes), want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/Robotech-Org/gordian"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TenantScoped marks application models whose rows belong to a single organization.
+// TenantPlugin scopes every query on such models to the active organization of the context.
+type TenantScoped interface {
+	TenantScoped()
+}
+
+// ErrNoActiveTenant is returned for queries on TenantScoped models when the context carries
+// neither an active organization nor WithoutTenantScope.
+var ErrNoActiveTenant = errors.New("tenant scoped query without an active organization")
+
+// ErrCrossTenantWrite is returned when a write on a TenantScoped model would create, move or
+// overwrite a record of another organization than the active one.
+var ErrCrossTenantWrite = errors.New("record belongs to another organization than the active one")
+
+// upsertKey marks statements whose ON CONFLICT DO UPDATE was limited to the active organization,
+// with the number of records they write.
+const upsertKey = "gordian:tenant_upsert"
+
+type skipTenantKey struct{}
+
+// WithoutTenantScope returns a context whose queries are not scoped by TenantPlugin.
+// Use it for admin and background jobs that work across organizations.
+func WithoutTenantScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipTenantKey{}, true)
+}
+
+// TenantPlugin is a GORM plugin that filters queries, updates and deletes of TenantScoped models
+// by the organization stored with gordian.WithActiveTenant, and fills it in on create.
+// Updates cannot move a record to another organization, and upserts (including the create
+// Save falls back to) only overwrite records of the active one; that needs the ON CONFLICT ...
+// DO UPDATE ... WHERE of Postgres or SQLite. The Gordian models are not TenantScoped, so the
+// stores in this package are unaffected. Raw and Exec statements are never scoped.
+//
+//	db.Use(gormadapter.NewTenantPlugin())
+//	db.WithContext(r.Context()).Find(&projects) // WHERE projects.organization_id = <active org>
+type TenantPlugin struct {
+	// Column holding the organization ID, "organization_id" by default.
+	Column string
+}
+
+func NewTenantPlugin() *TenantPlugin {
+	return &TenantPlugin{Column: "organization_id"}
+}
+
+// Name satisfies the gorm.Plugin interface.
+func (p *TenantPlugin) Name() string {
+	return "gordian:tenant"
+}
+
+// Initialize satisfies the gorm.Plugin interface.
+func (p *TenantPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("gordian:tenant_create", p.assignTenant); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("gordian:tenant_upsert", p.checkUpsert); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("gordian:tenant_query", p.scopeTenant); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("gordian:tenant_update", p.scopeUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("gordian:tenant_delete", p.scopeTenant); err != nil {
+		return err
+	}
+	return cb.Row().Before("gorm:row").Register("gordian:tenant_row", p.scopeTenant)
+}
+
+// activeTenant returns the organization the statement must be scoped to.
+// It reports false when the statement needs no scoping or has already failed.
+func (p *TenantPlugin) activeTenant(db *gorm.DB) (uuid.UUID, bool) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return uuid.Nil, false
+	}
+	if _, ok := reflect.New(stmt.Schema.ModelType).Interface().(TenantScoped); !ok {
+		return uuid.Nil, false
+	}
+	if skip, _ := stmt.Context.Value(skipTenantKey{}).(bool); skip {
+		return uuid.Nil, false
+	}
+	orgID, ok := gordian.ActiveOrgID(stmt.Context)
+	if !ok {
+		db.AddError(fmt.Errorf("%s: %w", stmt.Schema.Table, ErrNoActiveTenant))
+		return uuid.Nil, false
+	}
+	return orgID, true
+}
+
+func (p *TenantPlugin) scopeTenant(db *gorm.DB) {
+	orgID, ok := p.activeTenant(db)
+	if !ok {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: p.Column}, Value: orgID},
+	}})
+}
+
+func (p *TenantPlugin) assignTenant(db *gorm.DB) {
+	orgID, ok := p.activeTenant(db)
+	if !ok {
+		return
+	}
+	field := db.Statement.Schema.LookUpField(p.Column)
+	if field == nil {
+		db.AddError(fmt.Errorf("%s has no %s column", db.Statement.Schema.Table, p.Column))
+		return
+	}
+
+	assign := func(rv reflect.Value) {
+		ctx := db.Statement.Context
+		value, zero := field.ValueOf(ctx, rv)
+		if !zero && !sameTenant(value, orgID) {
+			db.AddError(fmt.Errorf("%s: %w", db.Statement.Schema.Table, ErrCrossTenantWrite))
+			return
+		}
+		if err := field.Set(ctx, rv, orgID); err != nil {
+			db.AddError(err)
+		}
+	}
+	records := 1
+	switch rv := db.Statement.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		records = rv.Len()
+		for i := 0; i < rv.Len(); i++ {
+			assign(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		assign(rv)
+	}
+	p.scopeUpsert(db, orgID, records)
+}
+
+// scopeUpsert limits the DO UPDATE of an ON CONFLICT clause to rows of orgID, so an upsert whose
+// key collides with a row of another organization writes nothing; checkUpsert turns that into
+// ErrCrossTenantWrite.
+func (p *TenantPlugin) scopeUpsert(db *gorm.DB, orgID uuid.UUID, records int) {
+	stmt := db.Statement
+	c, ok := stmt.Clauses[clause.OnConflict{}.Name()]
+	if !ok || db.Error != nil {
+		return
+	}
+	onConflict, ok := c.Expression.(clause.OnConflict)
+	if !ok || onConflict.DoNothing {
+		return
+	}
+	if name := db.Dialector.Name(); name != "postgres" && name != "sqlite" {
+		db.AddError(fmt.Errorf("%s: upserts of tenant scoped models are not supported on %s", stmt.Schema.Table, name))
+		return
+	}
+	for _, assignment := range onConflict.DoUpdates {
+		if assignment.Column.Name != p.Column {
+			continue
+		}
+		if _, excluded := assignment.Value.(clause.Column); !excluded && !sameTenant(assignment.Value, orgID) {
+			db.AddError(fmt.Errorf("%s: %w", stmt.Schema.Table, ErrCrossTenantWrite))
+			return
+		}
+	}
+	onConflict.Where.Exprs = append(onConflict.Where.Exprs,
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: p.Column}, Value: orgID},
+	)
+	stmt.AddClause(onConflict)
+	db.InstanceSet(upsertKey, records)
+}
+
+func (p *TenantPlugin) checkUpsert(db *gorm.DB) {
+	records, ok := db.InstanceGet(upsertKey)
+	if !ok || db.Error != nil || db.DryRun {
+		return
+	}
+	if db.RowsAffected < int64(records.(int)) {
+		db.AddError(fmt.Errorf("%s: %w", db.Statement.Schema.Table, ErrCrossTenantWrite))
+	}
+}
+
+// scopeUpdate scopes an update to the active organization and rejects new values for the
+// organization column, which would move the record out of it. Save writes every column, so a
+// zero organization in its record is set to the active one.
+func (p *TenantPlugin) scopeUpdate(db *gorm.DB) {
+	orgID, ok := p.activeTenant(db)
+	if !ok {
+		return
+	}
+	stmt := db.Statement
+	p.scopeTenant(db)
+	field := stmt.Schema.LookUpField(p.Column)
+	if field == nil {
+		return
+	}
+	crossTenant := func() {
+		db.AddError(fmt.Errorf("%s: %w", stmt.Schema.Table, ErrCrossTenantWrite))
+	}
+
+	switch dest := stmt.Dest.(type) {
+	case map[string]any:
+		for _, key := range []string{field.DBName, field.Name} {
+			if value, ok := dest[key]; ok && !sameTenant(value, orgID) {
+				crossTenant()
+				return
+			}
+		}
+	default:
+		rv := reflect.Indirect(reflect.ValueOf(dest))
+		if rv.Kind() != reflect.Struct || rv.Type() != stmt.Schema.ModelType {
+			return
+		}
+		columns, _ := stmt.SelectAndOmitColumns(false, true)
+		selected, listed := columns[field.DBName]
+		if listed && !selected {
+			return // omitted
+		}
+		value, zero := field.ValueOf(stmt.Context, rv)
+		switch {
+		case !zero && !sameTenant(value, orgID):
+			crossTenant()
+		case zero && selected && rv.CanAddr():
+			if err := field.Set(stmt.Context, rv, orgID); err != nil {
+				db.AddError(err)
+			}
+		}
+	}
+}
+
+// sameTenant reports whether value, an organization column value, is orgID.
+func sameTenant(value any, orgID uuid.UUID) bool {
+	switch v := value.(type) {
+	case uuid.UUID:
+		return v == orgID
+	case *uuid.UUID:
+		return v != nil && *v == orgID
+	case string:
+		return v == orgID.String()
+	}
+	return false
+}
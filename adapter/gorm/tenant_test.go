@@ -0,0 +1,202 @@
+package gorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/internal/sqlitetest"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type project struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;index"`
+	Name           string
+}
+
+func (project) TenantScoped() {}
+
+type tenantEnv struct {
+	db         *gorm.DB
+	orgA, orgB uuid.UUID
+	ctxA, ctxB context.Context
+	a, b       project // one project of each organization
+}
+
+func newTenantEnv(t *testing.T) *tenantEnv {
+	t.Helper()
+	db := sqlitetest.Open(t)
+	if err := db.AutoMigrate(&project{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(gormadapter.NewTenantPlugin()); err != nil {
+		t.Fatal(err)
+	}
+	env := &tenantEnv{db: db, orgA: uuid.New(), orgB: uuid.New()}
+	env.ctxA = gordian.WithActiveTenant(context.Background(), env.orgA, "owner", uuid.New())
+	env.ctxB = gordian.WithActiveTenant(context.Background(), env.orgB, "owner", uuid.New())
+	env.a = project{ID: uuid.New(), Name: "a"}
+	env.b = project{ID: uuid.New(), Name: "b"}
+	if err := db.WithContext(env.ctxA).Create(&env.a).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WithContext(env.ctxB).Create(&env.b).Error; err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+// stored reads a project bypassing the plugin.
+func (env *tenantEnv) stored(t *testing.T, id uuid.UUID) project {
+	t.Helper()
+	var p project
+	if err := env.db.WithContext(gormadapter.WithoutTenantScope(context.Background())).First(&p, "id = ?", id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestTenantPluginScopesQueries(t *testing.T) {
+	env := newTenantEnv(t)
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		want    []string
+		wantErr error
+	}{
+		{name: "organization a", ctx: env.ctxA, want: []string{"a"}},
+		{name: "organization b", ctx: env.ctxB, want: []string{"b"}},
+		{name: "without scope", ctx: gormadapter.WithoutTenantScope(context.Background()), want: []string{"a", "b"}},
+		{name: "no active tenant", ctx: context.Background(), wantErr: gormadapter.ErrNoActiveTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			err := env.db.WithContext(tt.ctx).Model(&project{}).Order("name").Pluck("name", &names).Error
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && len(names) != len(tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantPluginWrites(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(env *tenantEnv) error
+		wantErr error
+		wantB   string // name of the project of organization b after the write
+	}{
+		{
+			name: "create for another organization",
+			write: func(env *tenantEnv) error {
+				return env.db.WithContext(env.ctxA).Create(&project{ID: uuid.New(), OrganizationID: env.orgB}).Error
+			},
+			wantErr: gormadapter.ErrCrossTenantWrite,
+			wantB:   "b",
+		},
+		{
+			name: "save a record of another organization",
+			write: func(env *tenantEnv) error {
+				return env.db.WithContext(env.ctxA).Save(&project{ID: env.b.ID, Name: "stolen"}).Error
+			},
+			wantErr: gormadapter.ErrCrossTenantWrite,
+			wantB:   "b",
+		},
+		{
+			name: "save that moves a record to another organization",
+			write: func(env *tenantEnv) error {
+				return env.db.WithContext(env.ctxA).Save(&project{ID: env.a.ID, OrganizationID: env.orgB, Name: "moved"}).Error
+			},
+			wantErr: gormadapter.ErrCrossTenantWrite,
+			wantB:   "b",
+		},
+		{
+			name: "upsert over another organization",
+			write: func(env *tenantEnv) error {
+				return env.db.WithContext(env.ctxA).Clauses(clause.OnConflict{UpdateAll: true}).
+					Create(&project{ID: env.b.ID, Name: "stolen"}).Error
+			},
+			wantErr: gormadapter.ErrCrossTenantWrite,
+			wantB:   "b",
+		},
+		{
+			name: "upsert assigning another organization",
+			write: func(env *tenantEnv) error {
+				return env.db.WithContext(env.ctxA).Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "id"}},
+					DoUpdates: clause.Assignments(map[string]any{"organization_id": env.orgB}),
+				}).Create(&project{ID: env.a.ID, Name: "a"}).Error
+			},
+			wantErr: gormadapter.ErrCrossTenantWrite,
+			wantB:   "b",
+		},
+		{
+			name: "update of the organization column",
+			write: func(env *tenantEnv) error {
+				return env.db.WithContext(env.ctxA).Model(&project{}).Where("id = ?", env.a.ID).Update("organization_id", env.orgB).Error
+			},
+			wantErr: gormadapter.ErrCrossTenantWrite,
+			wantB:   "b",
+		},
+		{
+			name: "updates with a struct naming another organization",
+			write: func(env *tenantEnv) error {
+				return env.db.WithContext(env.ctxA).Model(&project{ID: env.a.ID}).Updates(project{OrganizationID: env.orgB, Name: "moved"}).Error
+			},
+			wantErr: gormadapter.ErrCrossTenantWrite,
+			wantB:   "b",
+		},
+		{
+			name: "update of another organization",
+			write: func(env *tenantEnv) error {
+				return env.db.WithContext(env.ctxA).Model(&project{}).Where("id = ?", env.b.ID).Update("name", "stolen").Error
+			},
+			wantB: "b",
+		},
+		{
+			name: "delete of another organization",
+			write: func(env *tenantEnv) error {
+				return env.db.WithContext(env.ctxA).Delete(&project{}, "id = ?", env.b.ID).Error
+			},
+			wantB: "b",
+		},
+		{
+			name: "upsert of own record",
+			write: func(env *tenantEnv) error {
+				return env.db.WithContext(env.ctxB).Clauses(clause.OnConflict{UpdateAll: true}).
+					Create(&project{ID: env.b.ID, Name: "renamed"}).Error
+			},
+			wantB: "renamed",
+		},
+		{
+			name: "save of own record without organization",
+			write: func(env *tenantEnv) error {
+				return env.db.WithContext(env.ctxB).Save(&project{ID: env.b.ID, Name: "saved"}).Error
+			},
+			wantB: "saved",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTenantEnv(t)
+			if err := tt.write(env); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if b := env.stored(t, env.b.ID); b.Name != tt.wantB || b.OrganizationID != env.orgB {
+				t.Errorf("project b = %q of %s, want %q of %s", b.Name, b.OrganizationID, tt.wantB, env.orgB)
+			}
+			if a := env.stored(t, env.a.ID); a.OrganizationID != env.orgA {
+				t.Errorf("project a moved to %s", a.OrganizationID)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package pgx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/Robotech-Org/gordian"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MembershipChannel is the NOTIFY channel MembershipStore publishes membership changes on.
+const MembershipChannel = "gordian_memberships"
+
+// Operations reported in MembershipChange.
+const (
+	MembershipCreated = "created"
+	MembershipUpdated = "updated"
+	MembershipDeleted = "deleted"
+)
+
+// MembershipChange is the JSON payload of a notification on MembershipChannel.
+type MembershipChange struct {
+	Op             string    `json:"op"`
+	MembershipID   uuid.UUID `json:"membership_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+// notifyMembership queues a notification on q. Inside a transaction PostgreSQL delivers it
+// on commit, and drops it on rollback.
+func notifyMembership(ctx context.Context, q querier, op string, m *gordian.Membership) error {
+	payload, err := json.Marshal(MembershipChange{Op: op, MembershipID: m.ID, UserID: m.UserID, OrganizationID: m.OrganizationID})
+	if err != nil {
+		return err
+	}
+	if _, err := q.Exec(ctx, "SELECT pg_notify($1, $2)", MembershipChannel, string(payload)); err != nil {
+		return fmt.Errorf("failed to notify membership change: %w", err)
+	}
+	return nil
+}
+
+// ListenMemberships calls fn for every membership change committed by any process,
+// until ctx is cancelled. It holds one pool connection for its whole lifetime.
+//
+//	go pgxadapter.ListenMemberships(ctx, pool, func(c pgxadapter.MembershipChange) {
+//		cache.InvalidateUser(c.UserID)
+//	})
+func ListenMemberships(ctx context.Context, pool *pgxpool.Pool, fn func(MembershipChange)) error {
+	c, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire listener connection: %w", err)
+	}
+	defer c.Release()
+
+	if _, err := c.Exec(ctx, "LISTEN "+MembershipChannel); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", MembershipChannel, err)
+	}
+	for {
+		n, err := c.Conn().WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to wait for notification: %w", err)
+		}
+		var change MembershipChange
+		if err := json.Unmarshal([]byte(n.Payload), &change); err != nil {
+			log.Printf("ERROR: invalid %s payload %q: %v", MembershipChannel, n.Payload, err)
+			continue
+		}
+		fn(change)
+	}
+}
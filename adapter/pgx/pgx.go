@@ -0,0 +1,1283 @@
+// package pgx provides a pgx/pgxpool implementation of the Gordian store interfaces.
+//
+// It expects the schema of the migrations package (PostgreSQL dialect). Queries use
+// pgx's statement cache, so every statement is prepared once per connection; call
+// PrepareStatements from pgxpool.Config.AfterConnect to prepare the hot path up front.
+package pgx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// --- Connection helpers ---
+
+// querier is satisfied by *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+type txKey struct{}
+
+// conn returns the transaction carried by ctx, or pool when there is none.
+func conn(ctx context.Context, pool *pgxpool.Pool) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return pool
+}
+
+// translateErr maps driver errors onto the gordian sentinel errors.
+func translateErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", gordian.ErrNotFound, err)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+		return fmt.Errorf("%w: %w", gordian.ErrConflict, err)
+	}
+	return err
+}
+
+// mustAffect turns an UPDATE or DELETE that matched no row into ErrNotFound.
+func mustAffect(tag pgconn.CommandTag, err error) error {
+	if err != nil {
+		return translateErr(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: no row updated", gordian.ErrNotFound)
+	}
+	return nil
+}
+
+// collect scans every row with scan.
+func collect[T any](rows pgx.Rows, err error, scan func(pgx.Row) (*T, error)) ([]*T, error) {
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*T, error) {
+		return scan(row)
+	})
+}
+
+// hotStatements are prepared by PrepareStatements.
+var hotStatements = []string{
+	getMembershipSQL,
+	getUserSQL,
+	getOrganizationSQL,
+	getAPIKeyByPrefixSQL,
+}
+
+// PrepareStatements prepares the statements used on every request, such as the
+// membership lookup of TenancyMiddleware. Use it as pgxpool.Config.AfterConnect.
+func PrepareStatements(ctx context.Context, c *pgx.Conn) error {
+	for _, sql := range hotStatements {
+		if _, err := c.Prepare(ctx, sql, sql); err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// --- Transactor Implementation ---
+
+// CurrentOrgSetting is set to the active organization at the start of every transaction,
+// for row-level security policies.
+const CurrentOrgSetting = "app.current_org"
+
+// Transactor runs gordian units of work inside a pgx transaction.
+// Stores built on the same pool pick the transaction up from the context.
+type Transactor struct {
+	Pool *pgxpool.Pool
+}
+
+func NewTransactor(pool *pgxpool.Pool) *Transactor {
+	return &Transactor{Pool: pool}
+}
+
+// WithinTransaction satisfies the gordian.Transactor interface.
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+	var committed func()
+	err := pgx.BeginFunc(ctx, t.Pool, func(tx pgx.Tx) error {
+		if orgID, ok := gordian.ActiveOrgID(ctx); ok {
+			if _, err := tx.Exec(ctx, "SELECT set_config($1, $2, true)", CurrentOrgSetting, orgID.String()); err != nil {
+				return fmt.Errorf("failed to set %s: %w", CurrentOrgSetting, err)
+			}
+		}
+		var txCtx context.Context
+		txCtx, committed = gordian.WithAfterCommit(context.WithValue(ctx, txKey{}, tx))
+		return fn(txCtx)
+	})
+	if err != nil {
+		return err
+	}
+	committed()
+	return nil
+}
+
+// --- OrganizationStore Implementation ---
+
+const (
+	organizationColumns = "id, name, slug, owner_id, parent_id, plan, created_at, billing_customer_id, subscription_id, subscription_status"
+	getOrganizationSQL  = "SELECT " + organizationColumns + " FROM organizations WHERE id = $1"
+)
+
+func scanOrganization(row pgx.Row) (*gordian.Organization, error) {
+	var org gordian.Organization
+	err := row.Scan(&org.ID, &org.Name, &org.Slug, &org.OwnerID, &org.ParentID, &org.Plan, &org.CreatedAt,
+		&org.BillingCustomerID, &org.SubscriptionID, &org.SubscriptionStatus)
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+type OrganizationStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewOrganizationStore(pool *pgxpool.Pool) *OrganizationStore {
+	return &OrganizationStore{Pool: pool}
+}
+
+// Create satisfies the gordian.OrganizationStore interface.
+func (s *OrganizationStore) Create(ctx context.Context, org *gordian.Organization) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO organizations ("+organizationColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		org.ID, org.Name, org.Slug, org.OwnerID, org.ParentID, org.Plan, org.CreatedAt,
+		org.BillingCustomerID, org.SubscriptionID, org.SubscriptionStatus)
+	return translateErr(err)
+}
+
+func (s *OrganizationStore) Get(ctx context.Context, id uuid.UUID) (*gordian.Organization, error) {
+	org, err := scanOrganization(conn(ctx, s.Pool).QueryRow(ctx, getOrganizationSQL, id))
+	if err != nil {
+		return nil, translateErr(err)
+	}
+	return org, nil
+}
+
+func (s *OrganizationStore) Update(ctx context.Context, org *gordian.Organization) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE organizations SET name = $2, slug = $3, owner_id = $4, parent_id = $5, plan = $6 WHERE id = $1",
+		org.ID, org.Name, org.Slug, org.OwnerID, org.ParentID, org.Plan))
+}
+
+// UpdateSubscription satisfies the gordian.OrganizationStore interface.
+func (s *OrganizationStore) UpdateSubscription(ctx context.Context, org *gordian.Organization) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE organizations SET plan = $2, billing_customer_id = $3, subscription_id = $4, subscription_status = $5 WHERE id = $1",
+		org.ID, org.Plan, org.BillingCustomerID, org.SubscriptionID, org.SubscriptionStatus))
+}
+
+func (s *OrganizationStore) Delete(ctx context.Context, id uuid.UUID) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx, "DELETE FROM organizations WHERE id = $1", id))
+}
+
+func (s *OrganizationStore) Lock(ctx context.Context, id uuid.UUID) error {
+	var locked uuid.UUID
+	err := conn(ctx, s.Pool).QueryRow(ctx, "SELECT id FROM organizations WHERE id = $1 FOR UPDATE", id).Scan(&locked)
+	return translateErr(err)
+}
+
+// GetBySlug falls back to organization_slug_redirects for former slugs.
+func (s *OrganizationStore) GetBySlug(ctx context.Context, slug string) (*gordian.Organization, error) {
+	org, err := scanOrganization(conn(ctx, s.Pool).QueryRow(ctx,
+		"SELECT "+organizationColumns+" FROM organizations WHERE slug = $1"+
+			" OR id = (SELECT organization_id FROM organization_slug_redirects WHERE slug = $1)"+
+			" ORDER BY slug = $1 DESC LIMIT 1", slug))
+	if err != nil {
+		return nil, translateErr(err)
+	}
+	return org, nil
+}
+
+func (s *OrganizationStore) AddSlugRedirect(ctx context.Context, r *gordian.OrganizationSlugRedirect) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO organization_slug_redirects (slug, organization_id, created_at) VALUES ($1, $2, $3)"+
+			" ON CONFLICT (slug) DO UPDATE SET organization_id = EXCLUDED.organization_id, created_at = EXCLUDED.created_at",
+		r.Slug, r.OrganizationID, r.CreatedAt)
+	return translateErr(err)
+}
+
+func (s *OrganizationStore) ListAncestors(ctx context.Context, id uuid.UUID) ([]*gordian.Organization, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx, `WITH RECURSIVE ancestors AS (
+		SELECT `+organizationColumns+`, 1 AS depth FROM organizations WHERE id = (SELECT parent_id FROM organizations WHERE id = $1)
+		UNION ALL
+		SELECT o.id, o.name, o.slug, o.owner_id, o.parent_id, o.plan, o.created_at,
+			o.billing_customer_id, o.subscription_id, o.subscription_status, a.depth + 1
+		FROM organizations o JOIN ancestors a ON o.id = a.parent_id WHERE a.depth < $2
+	) SELECT `+organizationColumns+` FROM ancestors ORDER BY depth`, id, gordian.MaxOrganizationDepth)
+	orgs, err := collect(rows, err, scanOrganization)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ancestors: %w", err)
+	}
+	return orgs, nil
+}
+
+func (s *OrganizationStore) ListDescendants(ctx context.Context, id uuid.UUID) ([]*gordian.Organization, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx, `WITH RECURSIVE descendants AS (
+		SELECT `+organizationColumns+`, 1 AS depth FROM organizations WHERE parent_id = $1
+		UNION ALL
+		SELECT o.id, o.name, o.slug, o.owner_id, o.parent_id, o.plan, o.created_at,
+			o.billing_customer_id, o.subscription_id, o.subscription_status, d.depth + 1
+		FROM organizations o JOIN descendants d ON o.parent_id = d.id WHERE d.depth < $2
+	) SELECT `+organizationColumns+` FROM descendants ORDER BY depth, name`, id, gordian.MaxOrganizationDepth)
+	orgs, err := collect(rows, err, scanOrganization)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list descendants: %w", err)
+	}
+	return orgs, nil
+}
+
+// --- UserStore Implementation ---
+
+const (
+	userColumns = "id, email, name, email_verified_at, created_at"
+	getUserSQL  = "SELECT " + userColumns + " FROM users WHERE id = $1"
+)
+
+func scanUser(row pgx.Row) (*gordian.User, error) {
+	var user gordian.User
+	if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.EmailVerifiedAt, &user.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+type UserStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewUserStore(pool *pgxpool.Pool) *UserStore {
+	return &UserStore{Pool: pool}
+}
+
+// Create satisfies the gordian.UserStore interface.
+func (s *UserStore) Create(ctx context.Context, user *gordian.User) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO users ("+userColumns+") VALUES ($1, $2, $3, $4, $5)",
+		user.ID, user.Email, user.Name, user.EmailVerifiedAt, user.CreatedAt)
+	return translateErr(err)
+}
+
+func (s *UserStore) Get(ctx context.Context, id uuid.UUID) (*gordian.User, error) {
+	user, err := scanUser(conn(ctx, s.Pool).QueryRow(ctx, getUserSQL, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", translateErr(err))
+	}
+	return user, nil
+}
+
+func (s *UserStore) GetUserRole(ctx context.Context, userID uuid.UUID) (string, error) {
+	var role string
+	err := conn(ctx, s.Pool).QueryRow(ctx, "SELECT role FROM memberships WHERE user_id = $1 LIMIT 1", userID).Scan(&role)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("failed to get user role: %w", err)
+	}
+	return role, nil
+}
+
+func (s *UserStore) FindByEmail(ctx context.Context, email string) (gordian.User, error) {
+	user, err := scanUser(conn(ctx, s.Pool).QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE LOWER(email) = LOWER($1)", email))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gordian.User{}, fmt.Errorf("no user found: %w", gordian.ErrNotFound)
+	}
+	if err != nil {
+		return gordian.User{}, fmt.Errorf("failed to find user: %w", err)
+	}
+	return *user, nil
+}
+
+func (s *UserStore) Update(ctx context.Context, user *gordian.User) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE users SET email = $2, name = $3, email_verified_at = $4 WHERE id = $1",
+		user.ID, user.Email, user.Name, user.EmailVerifiedAt))
+}
+
+// --- MembershipStore Implementation ---
+
+const (
+	membershipColumns = "id, organization_id, user_id, role, joined_at"
+	getMembershipSQL  = "SELECT " + membershipColumns + " FROM memberships WHERE user_id = $1 AND organization_id = $2"
+)
+
+func scanMembership(row pgx.Row) (*gordian.Membership, error) {
+	var m gordian.Membership
+	if err := row.Scan(&m.ID, &m.OrganizationID, &m.UserID, &m.Role, &m.JoinedAt); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// MembershipKey identifies the membership of a user in an organization.
+type MembershipKey struct {
+	UserID         uuid.UUID
+	OrganizationID uuid.UUID
+}
+
+// MembershipStore publishes every change on MembershipChannel, see ListenMemberships.
+type MembershipStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewMembershipStore(pool *pgxpool.Pool) *MembershipStore {
+	return &MembershipStore{Pool: pool}
+}
+
+// Create satisfies the gordian.MembershipStore interface.
+func (s *MembershipStore) Create(ctx context.Context, membership *gordian.Membership) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO memberships ("+membershipColumns+") VALUES ($1, $2, $3, $4, $5)",
+		membership.ID, membership.OrganizationID, membership.UserID, membership.Role, membership.JoinedAt)
+	if err != nil {
+		return translateErr(err)
+	}
+	return notifyMembership(ctx, conn(ctx, s.Pool), MembershipCreated, membership)
+}
+
+func (s *MembershipStore) GetMembers(ctx context.Context, orgID uuid.UUID) ([]*gordian.Membership, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx, "SELECT "+membershipColumns+" FROM memberships WHERE organization_id = $1", orgID)
+	memberships, err := collect(rows, err, scanMembership)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members: %w", err)
+	}
+	return memberships, nil
+}
+
+func (s *MembershipStore) ListForUser(ctx context.Context, userID uuid.UUID) ([]*gordian.Membership, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx, "SELECT "+membershipColumns+" FROM memberships WHERE user_id = $1 ORDER BY joined_at", userID)
+	memberships, err := collect(rows, err, scanMembership)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memberships: %w", err)
+	}
+	return memberships, nil
+}
+
+func (s *MembershipStore) GetMembership(ctx context.Context, userID uuid.UUID, orgID uuid.UUID) (gordian.Membership, error) {
+	membership, err := scanMembership(conn(ctx, s.Pool).QueryRow(ctx, getMembershipSQL, userID, orgID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return gordian.Membership{}, fmt.Errorf("no membership found: %w", gordian.ErrNotFound)
+	}
+	if err != nil {
+		return gordian.Membership{}, fmt.Errorf("failed to get membership: %w", err)
+	}
+	return *membership, nil
+}
+
+// GetMemberships looks up several memberships in one round trip. The result is
+// aligned with keys and holds nil where the user is not a member.
+func (s *MembershipStore) GetMemberships(ctx context.Context, keys []MembershipKey) ([]*gordian.Membership, error) {
+	batch := &pgx.Batch{}
+	for _, key := range keys {
+		batch.Queue(getMembershipSQL, key.UserID, key.OrganizationID)
+	}
+	results := conn(ctx, s.Pool).SendBatch(ctx, batch)
+	defer results.Close()
+
+	memberships := make([]*gordian.Membership, len(keys))
+	for i := range keys {
+		membership, err := scanMembership(results.QueryRow())
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get memberships: %w", err)
+		}
+		memberships[i] = membership
+	}
+	return memberships, nil
+}
+
+func (s *MembershipStore) Update(ctx context.Context, membership *gordian.Membership) error {
+	err := mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE memberships SET role = $2 WHERE id = $1", membership.ID, membership.Role))
+	if err != nil {
+		return err
+	}
+	return notifyMembership(ctx, conn(ctx, s.Pool), MembershipUpdated, membership)
+}
+
+func (s *MembershipStore) Delete(ctx context.Context, id uuid.UUID) error {
+	membership, err := scanMembership(conn(ctx, s.Pool).QueryRow(ctx,
+		"DELETE FROM memberships WHERE id = $1 RETURNING "+membershipColumns, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return notifyMembership(ctx, conn(ctx, s.Pool), MembershipDeleted, membership)
+}
+
+// --- InviteStore Implementation ---
+
+const inviteColumns = "id, organization_id, inviter_id, invitee_email, role, token, expires_at, created_at, accepted_at, revoked_at"
+
+func scanInvite(row pgx.Row) (*gordian.Invite, error) {
+	var i gordian.Invite
+	err := row.Scan(&i.ID, &i.OrganizationID, &i.InviterID, &i.InviteeEmail, &i.Role, &i.Token,
+		&i.ExpiresAt, &i.CreatedAt, &i.AcceptedAt, &i.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+type InviteStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewInviteStore(pool *pgxpool.Pool) *InviteStore {
+	return &InviteStore{Pool: pool}
+}
+
+// Create satisfies the gordian.InvitationStore interface.
+func (s *InviteStore) Create(ctx context.Context, i *gordian.Invite) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO invites ("+inviteColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		i.ID, i.OrganizationID, i.InviterID, i.InviteeEmail, i.Role, i.Token, i.ExpiresAt, i.CreatedAt, i.AcceptedAt, i.RevokedAt)
+	return translateErr(err)
+}
+
+// Verify reports whether token belongs to a pending invitation that has not expired.
+func (s *InviteStore) Verify(ctx context.Context, token string) (bool, error) {
+	var valid bool
+	err := conn(ctx, s.Pool).QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM invites WHERE token = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $2)",
+		token, time.Now()).Scan(&valid)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify invitation: %w", err)
+	}
+	return valid, nil
+}
+
+func (s *InviteStore) Get(ctx context.Context, id uuid.UUID) (*gordian.Invite, error) {
+	invite, err := scanInvite(conn(ctx, s.Pool).QueryRow(ctx, "SELECT "+inviteColumns+" FROM invites WHERE id = $1", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %w", translateErr(err))
+	}
+	return invite, nil
+}
+
+func (s *InviteStore) GetByToken(ctx context.Context, token string) (*gordian.Invite, error) {
+	invite, err := scanInvite(conn(ctx, s.Pool).QueryRow(ctx, "SELECT "+inviteColumns+" FROM invites WHERE token = $1", token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %w", translateErr(err))
+	}
+	return invite, nil
+}
+
+func (s *InviteStore) ListPending(ctx context.Context, orgID uuid.UUID) ([]*gordian.Invite, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx,
+		"SELECT "+inviteColumns+" FROM invites WHERE organization_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $2 ORDER BY created_at",
+		orgID, time.Now())
+	invites, err := collect(rows, err, scanInvite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	return invites, nil
+}
+
+func (s *InviteStore) Update(ctx context.Context, i *gordian.Invite) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE invites SET invitee_email = $2, role = $3, token = $4, expires_at = $5, accepted_at = $6, revoked_at = $7 WHERE id = $1",
+		i.ID, i.InviteeEmail, i.Role, i.Token, i.ExpiresAt, i.AcceptedAt, i.RevokedAt))
+}
+
+// --- OutboxStore Implementation ---
+
+const outboxColumns = "id, kind, payload, status, attempts, last_error, next_attempt_at, created_at, sent_at"
+
+func scanOutboxMessage(row pgx.Row) (*gordian.OutboxMessage, error) {
+	var m gordian.OutboxMessage
+	err := row.Scan(&m.ID, &m.Kind, &m.Payload, &m.Status, &m.Attempts, &m.LastError, &m.NextAttemptAt, &m.CreatedAt, &m.SentAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+type OutboxStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewOutboxStore(pool *pgxpool.Pool) *OutboxStore {
+	return &OutboxStore{Pool: pool}
+}
+
+// Create satisfies the gordian.OutboxStore interface.
+func (s *OutboxStore) Create(ctx context.Context, m *gordian.OutboxMessage) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO outbox_messages ("+outboxColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		m.ID, m.Kind, m.Payload, m.Status, m.Attempts, m.LastError, m.NextAttemptAt, m.CreatedAt, m.SentAt)
+	return translateErr(err)
+}
+
+func (s *OutboxStore) Get(ctx context.Context, id uuid.UUID) (*gordian.OutboxMessage, error) {
+	msg, err := scanOutboxMessage(conn(ctx, s.Pool).QueryRow(ctx, "SELECT "+outboxColumns+" FROM outbox_messages WHERE id = $1", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outbox message: %w", translateErr(err))
+	}
+	return msg, nil
+}
+
+func (s *OutboxStore) Update(ctx context.Context, m *gordian.OutboxMessage) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE outbox_messages SET status = $2, attempts = $3, last_error = $4, next_attempt_at = $5, sent_at = $6 WHERE id = $1",
+		m.ID, m.Status, m.Attempts, m.LastError, m.NextAttemptAt, m.SentAt))
+}
+
+func (s *OutboxStore) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*gordian.OutboxMessage, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx, `
+		UPDATE outbox_messages SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM outbox_messages
+			WHERE status = $1 AND next_attempt_at <= $3
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+outboxColumns,
+		gordian.OutboxPending, now.Add(lease), now, limit)
+	msgs, err := collect(rows, err, scanOutboxMessage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	return msgs, nil
+}
+
+func (s *OutboxStore) ListByStatus(ctx context.Context, status gordian.OutboxStatus, limit int) ([]*gordian.OutboxMessage, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx,
+		"SELECT "+outboxColumns+" FROM outbox_messages WHERE status = $1 ORDER BY created_at LIMIT $2", status, limit)
+	msgs, err := collect(rows, err, scanOutboxMessage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox messages: %w", err)
+	}
+	return msgs, nil
+}
+
+// --- WebhookStore Implementation ---
+
+const (
+	endpointColumns = "id, organization_id, url, secret, event_types, created_at"
+	deliveryColumns = "id, endpoint_id, organization_id, event_type, payload, status, attempts, response_status, last_error, next_attempt_at, created_at, delivered_at"
+)
+
+// EventTypes is a []string to pgx, which would encode it as an array; it is stored as JSON text.
+func scanEndpoint(row pgx.Row) (*gordian.WebhookEndpoint, error) {
+	var e gordian.WebhookEndpoint
+	var eventTypes string
+	if err := row.Scan(&e.ID, &e.OrganizationID, &e.URL, &e.Secret, &eventTypes, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := e.EventTypes.Scan(eventTypes); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func scanDelivery(row pgx.Row) (*gordian.WebhookDelivery, error) {
+	var d gordian.WebhookDelivery
+	err := row.Scan(&d.ID, &d.EndpointID, &d.OrganizationID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.ResponseStatus, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+type WebhookStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewWebhookStore(pool *pgxpool.Pool) *WebhookStore {
+	return &WebhookStore{Pool: pool}
+}
+
+// CreateEndpoint satisfies the gordian.WebhookStore interface.
+func (s *WebhookStore) CreateEndpoint(ctx context.Context, e *gordian.WebhookEndpoint) error {
+	eventTypes, err := e.EventTypes.Value()
+	if err != nil {
+		return err
+	}
+	_, err = conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO webhook_endpoints ("+endpointColumns+") VALUES ($1, $2, $3, $4, $5, $6)",
+		e.ID, e.OrganizationID, e.URL, e.Secret, eventTypes, e.CreatedAt)
+	return translateErr(err)
+}
+
+func (s *WebhookStore) GetEndpoint(ctx context.Context, id uuid.UUID) (*gordian.WebhookEndpoint, error) {
+	endpoint, err := scanEndpoint(conn(ctx, s.Pool).QueryRow(ctx, "SELECT "+endpointColumns+" FROM webhook_endpoints WHERE id = $1", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", translateErr(err))
+	}
+	return endpoint, nil
+}
+
+func (s *WebhookStore) ListEndpoints(ctx context.Context, orgID uuid.UUID) ([]*gordian.WebhookEndpoint, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx,
+		"SELECT "+endpointColumns+" FROM webhook_endpoints WHERE organization_id = $1 ORDER BY created_at", orgID)
+	endpoints, err := collect(rows, err, scanEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+func (s *WebhookStore) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx, "DELETE FROM webhook_endpoints WHERE id = $1", id)
+	return err
+}
+
+func (s *WebhookStore) CreateDelivery(ctx context.Context, d *gordian.WebhookDelivery) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO webhook_deliveries ("+deliveryColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		d.ID, d.EndpointID, d.OrganizationID, d.EventType, d.Payload, d.Status, d.Attempts,
+		d.ResponseStatus, d.LastError, d.NextAttemptAt, d.CreatedAt, d.DeliveredAt)
+	return translateErr(err)
+}
+
+func (s *WebhookStore) UpdateDelivery(ctx context.Context, d *gordian.WebhookDelivery) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE webhook_deliveries SET status = $2, attempts = $3, response_status = $4, last_error = $5, next_attempt_at = $6, delivered_at = $7 WHERE id = $1",
+		d.ID, d.Status, d.Attempts, d.ResponseStatus, d.LastError, d.NextAttemptAt, d.DeliveredAt))
+}
+
+func (s *WebhookStore) ListDeliveries(ctx context.Context, endpointID uuid.UUID, limit int) ([]*gordian.WebhookDelivery, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE endpoint_id = $1 ORDER BY created_at DESC LIMIT $2", endpointID, limit)
+	deliveries, err := collect(rows, err, scanDelivery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (s *WebhookStore) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*gordian.WebhookDelivery, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx, `
+		UPDATE webhook_deliveries SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= $3
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns,
+		gordian.WebhookPending, now.Add(lease), now, limit)
+	deliveries, err := collect(rows, err, scanDelivery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// --- AuditStore Implementation ---
+
+const auditColumns = "id, organization_id, actor_id, action, target_type, target_id, before, after, ip_address, user_agent, request_id, created_at"
+
+func scanAuditEntry(row pgx.Row) (*gordian.AuditEntry, error) {
+	var e gordian.AuditEntry
+	err := row.Scan(&e.ID, &e.OrganizationID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID,
+		&e.Before, &e.After, &e.IPAddress, &e.UserAgent, &e.RequestID, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+type AuditStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewAuditStore(pool *pgxpool.Pool) *AuditStore {
+	return &AuditStore{Pool: pool}
+}
+
+// Create satisfies the gordian.AuditStore interface.
+func (s *AuditStore) Create(ctx context.Context, e *gordian.AuditEntry) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO audit_entries ("+auditColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		e.ID, e.OrganizationID, e.ActorID, e.Action, e.TargetType, e.TargetID,
+		e.Before, e.After, e.IPAddress, e.UserAgent, e.RequestID, e.CreatedAt)
+	return translateErr(err)
+}
+
+func (s *AuditStore) List(ctx context.Context, filter gordian.AuditFilter) ([]*gordian.AuditEntry, error) {
+	conds := []string{"organization_id = $1"}
+	args := []any{filter.OrganizationID}
+	where := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.ActorID != uuid.Nil {
+		where("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.TargetID != uuid.Nil {
+		where("target_id = $%d", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		where("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		where("created_at < $%d", filter.Until)
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf("SELECT %s FROM audit_entries WHERE %s ORDER BY created_at DESC, id LIMIT $%d OFFSET $%d",
+		auditColumns, strings.Join(conds, " AND "), len(args)-1, len(args))
+
+	rows, err := conn(ctx, s.Pool).Query(ctx, query, args...)
+	entries, err := collect(rows, err, scanAuditEntry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// --- APIKeyStore Implementation ---
+
+const (
+	apiKeyColumns        = "id, organization_id, name, prefix, secret_hash, role, expires_at, last_used_at, created_at, revoked_at"
+	getAPIKeyByPrefixSQL = "SELECT " + apiKeyColumns + " FROM api_keys WHERE prefix = $1"
+)
+
+func scanAPIKey(row pgx.Row) (*gordian.APIKey, error) {
+	var k gordian.APIKey
+	err := row.Scan(&k.ID, &k.OrganizationID, &k.Name, &k.Prefix, &k.SecretHash, &k.Role,
+		&k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt, &k.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+type APIKeyStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewAPIKeyStore(pool *pgxpool.Pool) *APIKeyStore {
+	return &APIKeyStore{Pool: pool}
+}
+
+// Create satisfies the gordian.APIKeyStore interface.
+func (s *APIKeyStore) Create(ctx context.Context, k *gordian.APIKey) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO api_keys ("+apiKeyColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		k.ID, k.OrganizationID, k.Name, k.Prefix, k.SecretHash, k.Role, k.ExpiresAt, k.LastUsedAt, k.CreatedAt, k.RevokedAt)
+	return translateErr(err)
+}
+
+func (s *APIKeyStore) Get(ctx context.Context, id uuid.UUID) (*gordian.APIKey, error) {
+	key, err := scanAPIKey(conn(ctx, s.Pool).QueryRow(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", translateErr(err))
+	}
+	return key, nil
+}
+
+func (s *APIKeyStore) GetByPrefix(ctx context.Context, prefix string) (*gordian.APIKey, error) {
+	key, err := scanAPIKey(conn(ctx, s.Pool).QueryRow(ctx, getAPIKeyByPrefixSQL, prefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", translateErr(err))
+	}
+	return key, nil
+}
+
+func (s *APIKeyStore) List(ctx context.Context, orgID uuid.UUID) ([]*gordian.APIKey, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE organization_id = $1 ORDER BY created_at", orgID)
+	keys, err := collect(rows, err, scanAPIKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *APIKeyStore) Update(ctx context.Context, k *gordian.APIKey) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE api_keys SET name = $2, role = $3, expires_at = $4, last_used_at = $5, revoked_at = $6 WHERE id = $1",
+		k.ID, k.Name, k.Role, k.ExpiresAt, k.LastUsedAt, k.RevokedAt))
+}
+
+func (s *APIKeyStore) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx, "UPDATE api_keys SET last_used_at = $2 WHERE id = $1", id, at))
+}
+
+// --- TeamStore Implementation ---
+
+const (
+	teamColumns           = "id, organization_id, name, description, permissions, created_at"
+	teamMembershipColumns = "id, team_id, organization_id, user_id, role, joined_at"
+)
+
+// Permissions is a []string to pgx as well; it is stored as JSON text like EventTypes.
+func scanTeam(row pgx.Row) (*gordian.Team, error) {
+	var t gordian.Team
+	var permissions string
+	if err := row.Scan(&t.ID, &t.OrganizationID, &t.Name, &t.Description, &permissions, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := t.Permissions.Scan(permissions); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func scanTeamMembership(row pgx.Row) (*gordian.TeamMembership, error) {
+	var m gordian.TeamMembership
+	if err := row.Scan(&m.ID, &m.TeamID, &m.OrganizationID, &m.UserID, &m.Role, &m.JoinedAt); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+type TeamStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewTeamStore(pool *pgxpool.Pool) *TeamStore {
+	return &TeamStore{Pool: pool}
+}
+
+// CreateTeam satisfies the gordian.TeamStore interface.
+func (s *TeamStore) CreateTeam(ctx context.Context, t *gordian.Team) error {
+	permissions, err := t.Permissions.Value()
+	if err != nil {
+		return err
+	}
+	_, err = conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO teams ("+teamColumns+") VALUES ($1, $2, $3, $4, $5, $6)",
+		t.ID, t.OrganizationID, t.Name, t.Description, permissions, t.CreatedAt)
+	return translateErr(err)
+}
+
+func (s *TeamStore) GetTeam(ctx context.Context, id uuid.UUID) (*gordian.Team, error) {
+	team, err := scanTeam(conn(ctx, s.Pool).QueryRow(ctx, "SELECT "+teamColumns+" FROM teams WHERE id = $1", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team: %w", translateErr(err))
+	}
+	return team, nil
+}
+
+func (s *TeamStore) ListTeams(ctx context.Context, orgID uuid.UUID) ([]*gordian.Team, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx, "SELECT "+teamColumns+" FROM teams WHERE organization_id = $1 ORDER BY name", orgID)
+	teams, err := collect(rows, err, scanTeam)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list teams: %w", err)
+	}
+	return teams, nil
+}
+
+func (s *TeamStore) UpdateTeam(ctx context.Context, t *gordian.Team) error {
+	permissions, err := t.Permissions.Value()
+	if err != nil {
+		return err
+	}
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE teams SET name = $2, description = $3, permissions = $4 WHERE id = $1",
+		t.ID, t.Name, t.Description, permissions))
+}
+
+// DeleteTeam relies on the ON DELETE CASCADE of team_memberships.team_id.
+func (s *TeamStore) DeleteTeam(ctx context.Context, id uuid.UUID) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx, "DELETE FROM teams WHERE id = $1", id)
+	return err
+}
+
+func (s *TeamStore) AddMember(ctx context.Context, m *gordian.TeamMembership) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO team_memberships ("+teamMembershipColumns+") VALUES ($1, $2, $3, $4, $5, $6)",
+		m.ID, m.TeamID, m.OrganizationID, m.UserID, m.Role, m.JoinedAt)
+	return translateErr(err)
+}
+
+func (s *TeamStore) GetMember(ctx context.Context, teamID, userID uuid.UUID) (*gordian.TeamMembership, error) {
+	membership, err := scanTeamMembership(conn(ctx, s.Pool).QueryRow(ctx,
+		"SELECT "+teamMembershipColumns+" FROM team_memberships WHERE team_id = $1 AND user_id = $2", teamID, userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team member: %w", translateErr(err))
+	}
+	return membership, nil
+}
+
+func (s *TeamStore) ListMembers(ctx context.Context, teamID uuid.UUID) ([]*gordian.TeamMembership, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx,
+		"SELECT "+teamMembershipColumns+" FROM team_memberships WHERE team_id = $1 ORDER BY joined_at", teamID)
+	memberships, err := collect(rows, err, scanTeamMembership)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team members: %w", err)
+	}
+	return memberships, nil
+}
+
+func (s *TeamStore) ListForUser(ctx context.Context, orgID, userID uuid.UUID) ([]*gordian.Team, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx,
+		"SELECT t.id, t.organization_id, t.name, t.description, t.permissions, t.created_at FROM teams t"+
+			" JOIN team_memberships m ON m.team_id = t.id WHERE t.organization_id = $1 AND m.user_id = $2 ORDER BY t.name",
+		orgID, userID)
+	teams, err := collect(rows, err, scanTeam)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list teams of user: %w", err)
+	}
+	return teams, nil
+}
+
+func (s *TeamStore) UpdateMember(ctx context.Context, m *gordian.TeamMembership) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx, "UPDATE team_memberships SET role = $2 WHERE id = $1", m.ID, m.Role))
+}
+
+func (s *TeamStore) RemoveMember(ctx context.Context, id uuid.UUID) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx, "DELETE FROM team_memberships WHERE id = $1", id)
+	return err
+}
+
+func (s *TeamStore) RemoveUser(ctx context.Context, orgID, userID uuid.UUID) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx, "DELETE FROM team_memberships WHERE organization_id = $1 AND user_id = $2", orgID, userID)
+	return err
+}
+
+// --- DomainStore Implementation ---
+
+const domainColumns = "id, organization_id, domain, verification_token, policy, default_role, verified_at, created_at"
+
+func scanDomain(row pgx.Row) (*gordian.OrganizationDomain, error) {
+	var d gordian.OrganizationDomain
+	err := row.Scan(&d.ID, &d.OrganizationID, &d.Domain, &d.VerificationToken, &d.Policy, &d.DefaultRole, &d.VerifiedAt, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+type DomainStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewDomainStore(pool *pgxpool.Pool) *DomainStore {
+	return &DomainStore{Pool: pool}
+}
+
+// Create satisfies the gordian.DomainStore interface.
+func (s *DomainStore) Create(ctx context.Context, d *gordian.OrganizationDomain) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO organization_domains ("+domainColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		d.ID, d.OrganizationID, d.Domain, d.VerificationToken, d.Policy, d.DefaultRole, d.VerifiedAt, d.CreatedAt)
+	return translateErr(err)
+}
+
+func (s *DomainStore) Get(ctx context.Context, id uuid.UUID) (*gordian.OrganizationDomain, error) {
+	domain, err := scanDomain(conn(ctx, s.Pool).QueryRow(ctx, "SELECT "+domainColumns+" FROM organization_domains WHERE id = $1", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain: %w", translateErr(err))
+	}
+	return domain, nil
+}
+
+func (s *DomainStore) List(ctx context.Context, orgID uuid.UUID) ([]*gordian.OrganizationDomain, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx,
+		"SELECT "+domainColumns+" FROM organization_domains WHERE organization_id = $1 ORDER BY domain", orgID)
+	domains, err := collect(rows, err, scanDomain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+	return domains, nil
+}
+
+func (s *DomainStore) GetVerified(ctx context.Context, domain string) (*gordian.OrganizationDomain, error) {
+	claim, err := scanDomain(conn(ctx, s.Pool).QueryRow(ctx,
+		"SELECT "+domainColumns+" FROM organization_domains WHERE domain = $1 AND verified_at IS NOT NULL", domain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verified domain: %w", translateErr(err))
+	}
+	return claim, nil
+}
+
+func (s *DomainStore) Update(ctx context.Context, d *gordian.OrganizationDomain) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE organization_domains SET policy = $2, default_role = $3, verified_at = $4 WHERE id = $1",
+		d.ID, d.Policy, d.DefaultRole, d.VerifiedAt))
+}
+
+func (s *DomainStore) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx, "DELETE FROM organization_domains WHERE id = $1", id)
+	return err
+}
+
+// --- JoinRequestStore Implementation ---
+
+const joinRequestColumns = "id, organization_id, user_id, message, source, role, status, decider_id, created_at, decided_at"
+
+func scanJoinRequest(row pgx.Row) (*gordian.JoinRequest, error) {
+	var r gordian.JoinRequest
+	err := row.Scan(&r.ID, &r.OrganizationID, &r.UserID, &r.Message, &r.Source, &r.Role, &r.Status,
+		&r.DeciderID, &r.CreatedAt, &r.DecidedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+type JoinRequestStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewJoinRequestStore(pool *pgxpool.Pool) *JoinRequestStore {
+	return &JoinRequestStore{Pool: pool}
+}
+
+// Create satisfies the gordian.JoinRequestStore interface.
+func (s *JoinRequestStore) Create(ctx context.Context, r *gordian.JoinRequest) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO join_requests ("+joinRequestColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		r.ID, r.OrganizationID, r.UserID, r.Message, r.Source, r.Role, r.Status, r.DeciderID, r.CreatedAt, r.DecidedAt)
+	return translateErr(err)
+}
+
+func (s *JoinRequestStore) Get(ctx context.Context, id uuid.UUID) (*gordian.JoinRequest, error) {
+	request, err := scanJoinRequest(conn(ctx, s.Pool).QueryRow(ctx, "SELECT "+joinRequestColumns+" FROM join_requests WHERE id = $1", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get join request: %w", translateErr(err))
+	}
+	return request, nil
+}
+
+func (s *JoinRequestStore) GetPending(ctx context.Context, orgID, userID uuid.UUID) (*gordian.JoinRequest, error) {
+	request, err := scanJoinRequest(conn(ctx, s.Pool).QueryRow(ctx,
+		"SELECT "+joinRequestColumns+" FROM join_requests WHERE organization_id = $1 AND user_id = $2 AND status = $3",
+		orgID, userID, gordian.JoinRequestStatusPending))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get join request: %w", translateErr(err))
+	}
+	return request, nil
+}
+
+func (s *JoinRequestStore) List(ctx context.Context, orgID uuid.UUID, status gordian.JoinRequestStatus) ([]*gordian.JoinRequest, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx,
+		"SELECT "+joinRequestColumns+" FROM join_requests WHERE organization_id = $1 AND ($2 = '' OR status = $2) ORDER BY created_at DESC",
+		orgID, status)
+	requests, err := collect(rows, err, scanJoinRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list join requests: %w", err)
+	}
+	return requests, nil
+}
+
+func (s *JoinRequestStore) Update(ctx context.Context, r *gordian.JoinRequest) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE join_requests SET role = $2, status = $3, decider_id = $4, decided_at = $5 WHERE id = $1",
+		r.ID, r.Role, r.Status, r.DeciderID, r.DecidedAt))
+}
+
+// --- InviteLinkStore Implementation ---
+
+const (
+	inviteLinkColumns           = "id, organization_id, creator_id, token, role, max_uses, uses, allowed_domain, expires_at, created_at, revoked_at"
+	inviteLinkRedemptionColumns = "id, link_id, organization_id, user_id, membership_id, redeemed_at"
+)
+
+func scanInviteLink(row pgx.Row) (*gordian.InviteLink, error) {
+	var l gordian.InviteLink
+	err := row.Scan(&l.ID, &l.OrganizationID, &l.CreatorID, &l.Token, &l.Role, &l.MaxUses, &l.Uses,
+		&l.AllowedDomain, &l.ExpiresAt, &l.CreatedAt, &l.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func scanInviteLinkRedemption(row pgx.Row) (*gordian.InviteLinkRedemption, error) {
+	var r gordian.InviteLinkRedemption
+	if err := row.Scan(&r.ID, &r.LinkID, &r.OrganizationID, &r.UserID, &r.MembershipID, &r.RedeemedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+type InviteLinkStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewInviteLinkStore(pool *pgxpool.Pool) *InviteLinkStore {
+	return &InviteLinkStore{Pool: pool}
+}
+
+// Create satisfies the gordian.InviteLinkStore interface.
+func (s *InviteLinkStore) Create(ctx context.Context, l *gordian.InviteLink) error {
+	_, err := conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO invite_links ("+inviteLinkColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+		l.ID, l.OrganizationID, l.CreatorID, l.Token, l.Role, l.MaxUses, l.Uses, l.AllowedDomain, l.ExpiresAt, l.CreatedAt, l.RevokedAt)
+	return translateErr(err)
+}
+
+func (s *InviteLinkStore) Get(ctx context.Context, id uuid.UUID) (*gordian.InviteLink, error) {
+	link, err := scanInviteLink(conn(ctx, s.Pool).QueryRow(ctx, "SELECT "+inviteLinkColumns+" FROM invite_links WHERE id = $1", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite link: %w", translateErr(err))
+	}
+	return link, nil
+}
+
+func (s *InviteLinkStore) GetByToken(ctx context.Context, token string) (*gordian.InviteLink, error) {
+	link, err := scanInviteLink(conn(ctx, s.Pool).QueryRow(ctx, "SELECT "+inviteLinkColumns+" FROM invite_links WHERE token = $1", token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite link: %w", translateErr(err))
+	}
+	return link, nil
+}
+
+func (s *InviteLinkStore) List(ctx context.Context, orgID uuid.UUID) ([]*gordian.InviteLink, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx,
+		"SELECT "+inviteLinkColumns+" FROM invite_links WHERE organization_id = $1 ORDER BY created_at", orgID)
+	links, err := collect(rows, err, scanInviteLink)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invite links: %w", err)
+	}
+	return links, nil
+}
+
+// Update leaves uses alone, so it cannot undo a concurrent redemption.
+func (s *InviteLinkStore) Update(ctx context.Context, l *gordian.InviteLink) error {
+	return mustAffect(conn(ctx, s.Pool).Exec(ctx,
+		"UPDATE invite_links SET role = $2, max_uses = $3, allowed_domain = $4, expires_at = $5, revoked_at = $6 WHERE id = $1",
+		l.ID, l.Role, l.MaxUses, l.AllowedDomain, l.ExpiresAt, l.RevokedAt))
+}
+
+// Redeem counts the use and inserts the redemption in one statement.
+func (s *InviteLinkStore) Redeem(ctx context.Context, r *gordian.InviteLinkRedemption) error {
+	tag, err := conn(ctx, s.Pool).Exec(ctx, `WITH used AS (
+		UPDATE invite_links SET uses = uses + 1
+		WHERE id = $2 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > $6) AND (max_uses = 0 OR uses < max_uses)
+		RETURNING id
+	) INSERT INTO invite_link_redemptions (`+inviteLinkRedemptionColumns+`) SELECT $1, id, $3, $4, $5, $6 FROM used`,
+		r.ID, r.LinkID, r.OrganizationID, r.UserID, r.MembershipID, r.RedeemedAt)
+	if err != nil {
+		return translateErr(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("invite link is revoked, expired or used up: %w", gordian.ErrConflict)
+	}
+	return nil
+}
+
+func (s *InviteLinkStore) ListRedemptions(ctx context.Context, linkID uuid.UUID) ([]*gordian.InviteLinkRedemption, error) {
+	rows, err := conn(ctx, s.Pool).Query(ctx,
+		"SELECT "+inviteLinkRedemptionColumns+" FROM invite_link_redemptions WHERE link_id = $1 ORDER BY redeemed_at", linkID)
+	redemptions, err := collect(rows, err, scanInviteLinkRedemption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invite link redemptions: %w", err)
+	}
+	return redemptions, nil
+}
+
+// --- UsageStore Implementation ---
+
+type UsageStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewUsageStore(pool *pgxpool.Pool) *UsageStore {
+	return &UsageStore{Pool: pool}
+}
+
+// LockUsage satisfies the gordian.UsageStore interface.
+func (s *UsageStore) LockUsage(ctx context.Context, orgID uuid.UUID) error {
+	var id uuid.UUID
+	err := conn(ctx, s.Pool).QueryRow(ctx, "SELECT id FROM organizations WHERE id = $1 FOR UPDATE", orgID).Scan(&id)
+	return translateErr(err)
+}
+
+func (s *UsageStore) GetUsage(ctx context.Context, orgID uuid.UUID, now time.Time) (gordian.Usage, error) {
+	var usage gordian.Usage
+	err := conn(ctx, s.Pool).QueryRow(ctx, `SELECT
+		(SELECT COUNT(*) FROM memberships WHERE organization_id = $1),
+		(SELECT COUNT(*) FROM invites WHERE organization_id = $1
+			AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $2),
+		(SELECT COUNT(*) FROM teams WHERE organization_id = $1)`, orgID, now).
+		Scan(&usage.Members, &usage.PendingInvites, &usage.Teams)
+	if err != nil {
+		return gordian.Usage{}, fmt.Errorf("failed to count usage: %w", translateErr(err))
+	}
+	return usage, nil
+}
+
+// --- SettingsStore Implementation ---
+
+const settingsColumns = "organization_id, overrides, rollouts, updated_at"
+
+func scanSettings(row pgx.Row) (*gordian.OrganizationSettings, error) {
+	var settings gordian.OrganizationSettings
+	var overrides, rollouts string
+	if err := row.Scan(&settings.OrganizationID, &overrides, &rollouts, &settings.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := settings.Overrides.Scan(overrides); err != nil {
+		return nil, err
+	}
+	if err := settings.Rollouts.Scan(rollouts); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+type SettingsStore struct {
+	Pool *pgxpool.Pool
+}
+
+func NewSettingsStore(pool *pgxpool.Pool) *SettingsStore {
+	return &SettingsStore{Pool: pool}
+}
+
+// Get satisfies the gordian.SettingsStore interface.
+func (s *SettingsStore) Get(ctx context.Context, orgID uuid.UUID) (*gordian.OrganizationSettings, error) {
+	settings, err := scanSettings(conn(ctx, s.Pool).QueryRow(ctx,
+		"SELECT "+settingsColumns+" FROM organization_settings WHERE organization_id = $1", orgID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", translateErr(err))
+	}
+	return settings, nil
+}
+
+func (s *SettingsStore) Save(ctx context.Context, settings *gordian.OrganizationSettings) error {
+	overrides, err := settings.Overrides.Value()
+	if err != nil {
+		return err
+	}
+	rollouts, err := settings.Rollouts.Value()
+	if err != nil {
+		return err
+	}
+	_, err = conn(ctx, s.Pool).Exec(ctx,
+		"INSERT INTO organization_settings ("+settingsColumns+") VALUES ($1, $2, $3, $4)"+
+			" ON CONFLICT (organization_id) DO UPDATE SET overrides = EXCLUDED.overrides, rollouts = EXCLUDED.rollouts, updated_at = EXCLUDED.updated_at",
+		settings.OrganizationID, overrides, rollouts, settings.UpdatedAt)
+	return translateErr(err)
+}
+
+// LockSettings satisfies the gordian.SettingsStore interface.
+func (s *SettingsStore) LockSettings(ctx context.Context, orgID uuid.UUID) error {
+	var id uuid.UUID
+	err := conn(ctx, s.Pool).QueryRow(ctx, "SELECT id FROM organizations WHERE id = $1 FOR UPDATE", orgID).Scan(&id)
+	return translateErr(err)
+}
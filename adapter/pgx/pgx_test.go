@@ -0,0 +1,238 @@
+package pgx_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	pgxadapter "github.com/Robotech-Org/gordian/adapter/pgx"
+	"github.com/Robotech-Org/gordian/internal/pgtest"
+	"github.com/Robotech-Org/gordian/internal/storetest"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// openPool connects to a new, migrated schema with the statements of PrepareStatements prepared
+// on every connection.
+func openPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	cfg, err := pgxpool.ParseConfig(pgtest.Migrated(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AfterConnect = pgxadapter.PrepareStatements
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func stores(pool *pgxpool.Pool) storetest.Stores {
+	return storetest.Stores{
+		Transactor:    pgxadapter.NewTransactor(pool),
+		Organizations: pgxadapter.NewOrganizationStore(pool),
+		Users:         pgxadapter.NewUserStore(pool),
+		Memberships:   pgxadapter.NewMembershipStore(pool),
+		Invites:       pgxadapter.NewInviteStore(pool),
+		Outbox:        pgxadapter.NewOutboxStore(pool),
+		Webhooks:      pgxadapter.NewWebhookStore(pool),
+		Audit:         pgxadapter.NewAuditStore(pool),
+		APIKeys:       pgxadapter.NewAPIKeyStore(pool),
+		Teams:         pgxadapter.NewTeamStore(pool),
+		Domains:       pgxadapter.NewDomainStore(pool),
+		JoinRequests:  pgxadapter.NewJoinRequestStore(pool),
+		InviteLinks:   pgxadapter.NewInviteLinkStore(pool),
+		Usage:         pgxadapter.NewUsageStore(pool),
+		Settings:      pgxadapter.NewSettingsStore(pool),
+	}
+}
+
+func skipWithoutPostgres(t *testing.T) {
+	t.Helper()
+	if os.Getenv(pgtest.DSNEnv) == "" {
+		t.Skipf("%s is not set", pgtest.DSNEnv)
+	}
+}
+
+func TestStores(t *testing.T) {
+	skipWithoutPostgres(t)
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		return stores(openPool(t))
+	})
+}
+
+// fixture creates a user owning an organization.
+func fixture(t *testing.T, s storetest.Stores) (*gordian.User, *gordian.Organization) {
+	t.Helper()
+	ctx := context.Background()
+	owner := gordian.NewUser("owner@example.com", "Owner")
+	org := gordian.NewOrganization(owner.ID, "Acme")
+	org.Slug = "acme"
+	err := s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.Users.Create(ctx, owner); err != nil {
+			return err
+		}
+		if err := s.Organizations.Create(ctx, org); err != nil {
+			return err
+		}
+		return s.Memberships.Create(ctx, gordian.NewMembership(owner.ID, org.ID, "owner"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return owner, org
+}
+
+func TestGetMemberships(t *testing.T) {
+	skipWithoutPostgres(t)
+	s := stores(openPool(t))
+	owner, org := fixture(t, s)
+	memberships := s.Memberships.(*pgxadapter.MembershipStore)
+	stranger := uuid.New()
+
+	tests := []struct {
+		name      string
+		keys      []pgxadapter.MembershipKey
+		wantRoles []string // "" where no membership is expected
+	}{
+		{name: "no keys", keys: nil, wantRoles: []string{}},
+		{name: "member", keys: []pgxadapter.MembershipKey{{UserID: owner.ID, OrganizationID: org.ID}}, wantRoles: []string{"owner"}},
+		{
+			name: "mixed, aligned with the keys",
+			keys: []pgxadapter.MembershipKey{
+				{UserID: stranger, OrganizationID: org.ID},
+				{UserID: owner.ID, OrganizationID: org.ID},
+				{UserID: owner.ID, OrganizationID: uuid.New()},
+			},
+			wantRoles: []string{"", "owner", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := memberships.GetMemberships(context.Background(), tt.keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.wantRoles) {
+				t.Fatalf("got %d results for %d keys", len(got), len(tt.keys))
+			}
+			for i, want := range tt.wantRoles {
+				switch {
+				case want == "" && got[i] != nil:
+					t.Errorf("result %d = %+v, want nil", i, got[i])
+				case want != "" && (got[i] == nil || got[i].Role != want || got[i].UserID != tt.keys[i].UserID):
+					t.Errorf("result %d = %+v, want role %s of %s", i, got[i], want, tt.keys[i].UserID)
+				}
+			}
+		})
+	}
+}
+
+func TestListenMemberships(t *testing.T) {
+	skipWithoutPostgres(t)
+	pool := openPool(t)
+	s := stores(pool)
+	_, org := fixture(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan pgxadapter.MembershipChange, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- pgxadapter.ListenMemberships(ctx, pool, func(c pgxadapter.MembershipChange) { changes <- c })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("ListenMemberships = %v", err)
+		}
+	})
+	// ListenMemberships subscribes asynchronously; wait until its LISTEN is in place.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var listening bool
+		err := pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_stat_activity WHERE query = $1)", "LISTEN "+pgxadapter.MembershipChannel).Scan(&listening)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if listening {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("ListenMemberships did not subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	errRollback := errors.New("rollback")
+	var member *gordian.Membership
+	tests := []struct {
+		name   string
+		change func(ctx context.Context) error
+		wantOp string // "" when no notification is expected
+	}{
+		{
+			name: "rolled back create",
+			change: func(ctx context.Context) error {
+				// A notification sent here would reach the next case first and fail it.
+				return s.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+					user := gordian.NewUser("rolled-back@example.com", "Rolled back")
+					if err := s.Users.Create(ctx, user); err != nil {
+						return err
+					}
+					if err := s.Memberships.Create(ctx, gordian.NewMembership(user.ID, org.ID, "member")); err != nil {
+						return err
+					}
+					return errRollback
+				})
+			},
+		},
+		{
+			name: "create",
+			change: func(ctx context.Context) error {
+				user := gordian.NewUser("alice@example.com", "Alice")
+				if err := s.Users.Create(ctx, user); err != nil {
+					return err
+				}
+				member = gordian.NewMembership(user.ID, org.ID, "member")
+				return s.Memberships.Create(ctx, member)
+			},
+			wantOp: pgxadapter.MembershipCreated,
+		},
+		{
+			name: "update",
+			change: func(ctx context.Context) error {
+				member.Role = "admin"
+				return s.Memberships.Update(ctx, member)
+			},
+			wantOp: pgxadapter.MembershipUpdated,
+		},
+		{
+			name:   "delete",
+			change: func(ctx context.Context) error { return s.Memberships.Delete(ctx, member.ID) },
+			wantOp: pgxadapter.MembershipDeleted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(context.Background()); err != nil && !errors.Is(err, errRollback) {
+				t.Fatal(err)
+			}
+			if tt.wantOp == "" {
+				return
+			}
+			select {
+			case c := <-changes:
+				if c.Op != tt.wantOp || c.MembershipID != member.ID || c.UserID != member.UserID || c.OrganizationID != org.ID {
+					t.Errorf("change = %+v, want %s of %s", c, tt.wantOp, member.ID)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("no %s notification", tt.wantOp)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package gordian
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to recognise and scan for.
+const APIKeyPrefix = "gdn_"
+
+// APIKeyLastUsedInterval limits how often AuthenticateAPIKey writes LastUsedAt for the same key.
+const APIKeyLastUsedInterval = time.Minute
+
+// APIKey lets a machine client act inside one organization without a User.
+// Keys have the form "gdn_<prefix>_<secret>"; only the prefix and a hash of the key are stored.
+type APIKey struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	Name           string     // e.g. "GitHub Actions"
+	Prefix         string     // Public part of the key, unique, shown in listings and used for lookup
+	SecretHash     []byte     // SHA-256 of the full key
+	Role           string     // Role the key acts with, like the role of a Membership
+	ExpiresAt      *time.Time // nil for keys that never expire
+	LastUsedAt     *time.Time
+	CreatedAt      time.Time
+	RevokedAt      *time.Time
+}
+
+func NewAPIKey(organizationID uuid.UUID, name, prefix string, secretHash []byte, role string, expiresAt *time.Time) *APIKey {
+	return &APIKey{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+		Name:           name,
+		Prefix:         prefix,
+		SecretHash:     secretHash,
+		Role:           role,
+		ExpiresAt:      expiresAt,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// Active reports whether the key is neither revoked nor expired at now.
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+type apiKeyContextKey struct{}
+
+// WithAPIKey stores the key that authenticated the request in ctx.
+func WithAPIKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// APIKeyFromContext returns the key set by APIKeyMiddleware, or nil for requests made by a user.
+func APIKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return key
+}
+
+// CreateAPIKey issues a key for orgID. The returned plaintext key is not stored and
+// cannot be retrieved again.
+func (s *Service) CreateAPIKey(ctx context.Context, orgID uuid.UUID, name, role string, expiresAt *time.Time) (*APIKey, string, error) {
+	if s.apiKeyStore == nil {
+		return nil, "", notConfigured("API keys")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("API key name is required: %w", ErrInvalidArgument)
+	}
+	if role == "" || role == "owner" {
+		return nil, "", fmt.Errorf("API keys cannot have role %q: %w", role, ErrInvalidArgument)
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("API key expiry must be in the future: %w", ErrInvalidArgument)
+	}
+	if _, err := s.orgStore.Get(ctx, orgID); err != nil {
+		return nil, "", fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	prefix, plaintext, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key := NewAPIKey(orgID, name, prefix, hashAPIKey(plaintext), role, expiresAt)
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.apiKeyStore.Create(ctx, key); err != nil {
+			return fmt.Errorf("failed to create API key: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditAPIKeyCreated, TargetType: "api_key", TargetID: key.ID}
+		return s.audit(ctx, entry, nil, snapshotAPIKey(key))
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return key, plaintext, nil
+}
+
+// ListAPIKeys returns every key of orgID, including revoked and expired ones.
+func (s *Service) ListAPIKeys(ctx context.Context, orgID uuid.UUID) ([]*APIKey, error) {
+	if s.apiKeyStore == nil {
+		return nil, notConfigured("API keys")
+	}
+	keys, err := s.apiKeyStore.List(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey disables a key of orgID immediately. The key stays listed.
+func (s *Service) RevokeAPIKey(ctx context.Context, orgID, keyID uuid.UUID) error {
+	if s.apiKeyStore == nil {
+		return notConfigured("API keys")
+	}
+	key, err := s.apiKeyStore.Get(ctx, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to get API key: %w", err)
+	}
+	if key.OrganizationID != orgID {
+		return fmt.Errorf("API key does not belong to organization: %w", ErrNotFound)
+	}
+	if key.RevokedAt != nil {
+		return fmt.Errorf("API key is already revoked: %w", ErrConflict)
+	}
+
+	before := snapshotAPIKey(key)
+	revokedAt := time.Now()
+	key.RevokedAt = &revokedAt
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.apiKeyStore.Update(ctx, key); err != nil {
+			return fmt.Errorf("failed to update API key: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditAPIKeyRevoked, TargetType: "api_key", TargetID: key.ID}
+		return s.audit(ctx, entry, before, snapshotAPIKey(key))
+	})
+}
+
+// AuthenticateAPIKey returns the active key matching plaintext, and records that it was used.
+// Unknown, malformed, revoked and expired keys all fail with ErrUnauthenticated.
+func (s *Service) AuthenticateAPIKey(ctx context.Context, plaintext string) (*APIKey, error) {
+	if s.apiKeyStore == nil {
+		return nil, notConfigured("API keys")
+	}
+	prefix, ok := apiKeyPrefix(plaintext)
+	if !ok {
+		return nil, fmt.Errorf("malformed API key: %w", ErrUnauthenticated)
+	}
+	key, err := s.apiKeyStore.GetByPrefix(ctx, prefix)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("unknown API key: %w", ErrUnauthenticated)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	now := time.Now()
+	if subtle.ConstantTimeCompare(key.SecretHash, hashAPIKey(plaintext)) != 1 {
+		return nil, fmt.Errorf("unknown API key: %w", ErrUnauthenticated)
+	}
+	if !key.Active(now) {
+		return nil, fmt.Errorf("API key is revoked or expired: %w", ErrUnauthenticated)
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= APIKeyLastUsedInterval {
+		if err := s.apiKeyStore.TouchLastUsed(ctx, key.ID, now); err != nil {
+			log.Printf("ERROR: failed to record use of API key %s: %v", key.ID, err)
+		} else {
+			key.LastUsedAt = &now
+		}
+	}
+	return key, nil
+}
+
+// APIKeyMiddleware authenticates requests carrying "Authorization: Bearer <API key>" and
+// populates the same tenant context as TenancyMiddleware, with the key's organization and role.
+// The key is available to handlers through APIKeyFromContext. An X-Tenant-ID header, if sent,
+// must name the key's organization.
+func (s *Service) APIKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plaintext, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || plaintext == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Missing API key", http.StatusUnauthorized)
+			return
+		}
+		key, err := s.AuthenticateAPIKey(r.Context(), strings.TrimSpace(plaintext))
+		if errors.Is(err, ErrUnauthenticated) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			http.Error(w, "Failed to authenticate API key", http.StatusInternalServerError)
+			return
+		}
+
+		if tenant := r.Header.Get("X-Tenant-ID"); tenant != "" {
+			if orgID, err := uuid.Parse(tenant); err != nil || orgID != key.OrganizationID {
+				http.Error(w, "API key does not belong to organization", http.StatusForbidden)
+				return
+			}
+		}
+
+		ctx := WithActiveTenant(r.Context(), key.OrganizationID, key.Role, uuid.Nil)
+		ctx = WithAPIKey(ctx, key)
+		if s.settingsStore != nil {
+			settings, err := s.GetSettings(r.Context(), key.OrganizationID)
+			if err != nil {
+				log.Printf("ERROR: failed to load settings of %s: %v", key.OrganizationID, err)
+				http.Error(w, "Failed to load organization settings", http.StatusInternalServerError)
+				return
+			}
+			ctx = WithActiveSettings(ctx, settings)
+		}
+		ctx = WithRequestMetadata(ctx, s.RequestMetadata(r))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// generateAPIKey returns a new key and its prefix.
+func generateAPIKey() (prefix, plaintext string, err error) {
+	raw := make([]byte, 38)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	prefix = APIKeyPrefix + hex.EncodeToString(raw[:6])
+	return prefix, prefix + "_" + hex.EncodeToString(raw[6:]), nil
+}
+
+// apiKeyPrefix extracts the prefix of a key of the form "gdn_<prefix>_<secret>".
+func apiKeyPrefix(plaintext string) (string, bool) {
+	if !strings.HasPrefix(plaintext, APIKeyPrefix) {
+		return "", false
+	}
+	i := strings.LastIndexByte(plaintext, '_')
+	if i <= len(APIKeyPrefix) || i == len(plaintext)-1 {
+		return "", false
+	}
+	return plaintext[:i], true
+}
+
+// hashAPIKey hashes the whole key. Keys carry 256 random bits, so a fast hash is sufficient.
+func hashAPIKey(plaintext string) []byte {
+	sum := sha256.Sum256([]byte(plaintext))
+	return sum[:]
+}
@@ -0,0 +1,208 @@
+package gordian_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func withAPIKeys(db *gorm.DB) gordian.Option {
+	return gordian.WithAPIKeys(gormadapter.NewAPIKeyStore(db))
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	env := newTestEnv(t, withAPIKeys)
+	org := env.org("Acme", env.user("owner@example.com"))
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		orgID     uuid.UUID
+		keyName   string
+		role      string
+		expiresAt *time.Time
+		wantErr   error
+	}{
+		{name: "valid", orgID: org.ID, keyName: " CI ", role: "member"},
+		{name: "with expiry", orgID: org.ID, keyName: "CI", role: "admin", expiresAt: &future},
+		{name: "blank name", orgID: org.ID, keyName: "  ", role: "member", wantErr: gordian.ErrInvalidArgument},
+		{name: "no role", orgID: org.ID, keyName: "CI", wantErr: gordian.ErrInvalidArgument},
+		{name: "owner role", orgID: org.ID, keyName: "CI", role: "owner", wantErr: gordian.ErrInvalidArgument},
+		{name: "expired", orgID: org.ID, keyName: "CI", role: "member", expiresAt: &past, wantErr: gordian.ErrInvalidArgument},
+		{name: "unknown organization", orgID: uuid.New(), keyName: "CI", role: "member", wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, plaintext, err := env.svc.CreateAPIKey(env.ctx, tt.orgID, tt.keyName, tt.role, tt.expiresAt)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateAPIKey = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key.Name != strings.TrimSpace(tt.keyName) || key.Role != tt.role {
+				t.Errorf("key = %+v", key)
+			}
+			if !strings.HasPrefix(plaintext, key.Prefix+"_") || !strings.HasPrefix(key.Prefix, gordian.APIKeyPrefix) {
+				t.Errorf("plaintext %q does not start with prefix %q", plaintext, key.Prefix)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	env := newTestEnv(t, withAPIKeys)
+	org := env.org("Acme", env.user("owner@example.com"))
+	store := gormadapter.NewAPIKeyStore(env.db)
+	create := func() (*gordian.APIKey, string) {
+		t.Helper()
+		key, plaintext, err := env.svc.CreateAPIKey(env.ctx, org.ID, "CI", "member", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, plaintext
+	}
+	valid, validPlaintext := create()
+	revoked, revokedPlaintext := create()
+	if err := env.svc.RevokeAPIKey(env.ctx, org.ID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	expired, expiredPlaintext := create()
+	expiresAt := time.Now().Add(-time.Minute)
+	expired.ExpiresAt = &expiresAt
+	if err := store.Update(env.ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		plaintext string
+		wantID    uuid.UUID // uuid.Nil when ErrUnauthenticated is expected
+	}{
+		{name: "valid", plaintext: validPlaintext, wantID: valid.ID},
+		{name: "empty", plaintext: ""},
+		{name: "without prefix", plaintext: strings.TrimPrefix(validPlaintext, gordian.APIKeyPrefix)},
+		{name: "without secret", plaintext: valid.Prefix + "_"},
+		{name: "unknown prefix", plaintext: gordian.APIKeyPrefix + "000000000000_secret"},
+		{name: "wrong secret", plaintext: valid.Prefix + "_secret"},
+		{name: "revoked", plaintext: revokedPlaintext},
+		{name: "expired", plaintext: expiredPlaintext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := env.svc.AuthenticateAPIKey(env.ctx, tt.plaintext)
+			if tt.wantID == uuid.Nil {
+				if !errors.Is(err, gordian.ErrUnauthenticated) {
+					t.Fatalf("AuthenticateAPIKey = %v, want ErrUnauthenticated", err)
+				}
+				return
+			}
+			if err != nil || key.ID != tt.wantID {
+				t.Fatalf("AuthenticateAPIKey = %v, %v, want key %s", key, err, tt.wantID)
+			}
+			stored, err := store.Get(env.ctx, key.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.LastUsedAt == nil {
+				t.Error("LastUsedAt was not recorded")
+			}
+		})
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	env := newTestEnv(t, withAPIKeys)
+	owner := env.user("owner@example.com")
+	org, other := env.org("Acme", owner), env.org("Globex", owner)
+	key, _, err := env.svc.CreateAPIKey(env.ctx, org.ID, "CI", "member", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cases run in order: the key is revoked by the second.
+	tests := []struct {
+		name    string
+		orgID   uuid.UUID
+		keyID   uuid.UUID
+		wantErr error
+	}{
+		{name: "other organization", orgID: other.ID, keyID: key.ID, wantErr: gordian.ErrNotFound},
+		{name: "revoke", orgID: org.ID, keyID: key.ID},
+		{name: "already revoked", orgID: org.ID, keyID: key.ID, wantErr: gordian.ErrConflict},
+		{name: "unknown key", orgID: org.ID, keyID: uuid.New(), wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := env.svc.RevokeAPIKey(env.ctx, tt.orgID, tt.keyID)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("RevokeAPIKey = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	keys, err := env.svc.ListAPIKeys(env.ctx, org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("ListAPIKeys = %+v, want the revoked key", keys)
+	}
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	env := newTestEnv(t, withAPIKeys)
+	org := env.org("Acme", env.user("owner@example.com"))
+	key, plaintext, err := env.svc.CreateAPIKey(env.ctx, org.ID, "CI", "admin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := env.svc.APIKeyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orgID, _ := gordian.ActiveOrgID(r.Context())
+		role := gordian.ActiveRole(r.Context())
+		if orgID != org.ID || role != "admin" || gordian.APIKeyFromContext(r.Context()).ID != key.ID {
+			t.Errorf("tenant context = %s, %q", orgID, role)
+		}
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		tenant        string
+		wantStatus    int
+	}{
+		{name: "valid", authorization: "Bearer " + plaintext, wantStatus: http.StatusOK},
+		{name: "matching tenant", authorization: "Bearer " + plaintext, tenant: org.ID.String(), wantStatus: http.StatusOK},
+		{name: "missing", wantStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", authorization: "Basic " + plaintext, wantStatus: http.StatusUnauthorized},
+		{name: "invalid", authorization: "Bearer " + key.Prefix + "_secret", wantStatus: http.StatusUnauthorized},
+		{name: "other tenant", authorization: "Bearer " + plaintext, tenant: uuid.NewString(), wantStatus: http.StatusForbidden},
+		{name: "malformed tenant", authorization: "Bearer " + plaintext, tenant: "acme", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.tenant != "" {
+				req.Header.Set("X-Tenant-ID", tt.tenant)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package gordian
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditOrganizationCreated             = "organization.created"
+	AuditOwnershipTransferred            = "organization.ownership_transferred"
+	AuditOrganizationMoved               = "organization.moved"
+	AuditOrganizationRenamed             = "organization.renamed"
+	AuditOrganizationSlugChanged         = "organization.slug_changed"
+	AuditOrganizationPlanChanged         = "organization.plan_changed"
+	AuditOrganizationSubscriptionChanged = "organization.subscription_changed"
+	AuditOrganizationDeleted             = "organization.deleted"
+	AuditSettingsChanged                 = "organization.settings_changed"
+	AuditMemberAdded                     = "member.added"
+	AuditMemberRemoved                   = "member.removed"
+	AuditRoleChanged                     = "member.role_changed"
+	AuditInvitationCreated               = "invitation.created"
+	AuditInvitationRevoked               = "invitation.revoked"
+	AuditInvitationAccepted              = "invitation.accepted"
+	AuditInviteLinkCreated               = "invite_link.created"
+	AuditInviteLinkRevoked               = "invite_link.revoked"
+	AuditInviteLinkRedeemed              = "invite_link.redeemed"
+	AuditWebhookCreated                  = "webhook.created"
+	AuditWebhookDeleted                  = "webhook.deleted"
+	AuditAPIKeyCreated                   = "api_key.created"
+	AuditAPIKeyRevoked                   = "api_key.revoked"
+	AuditTeamCreated                     = "team.created"
+	AuditTeamUpdated                     = "team.updated"
+	AuditTeamPermissionsChanged          = "team.permissions_changed"
+	AuditTeamDeleted                     = "team.deleted"
+	AuditTeamMemberAdded                 = "team.member_added"
+	AuditTeamMemberRemoved               = "team.member_removed"
+	AuditTeamRoleChanged                 = "team.role_changed"
+	AuditDomainClaimed                   = "domain.claimed"
+	AuditDomainVerified                  = "domain.verified"
+	AuditDomainPolicyChanged             = "domain.policy_changed"
+	AuditDomainRemoved                   = "domain.removed"
+	AuditJoinRequestCreated              = "join_request.created"
+	AuditJoinRequestApproved             = "join_request.approved"
+	AuditJoinRequestDenied               = "join_request.denied"
+)
+
+// AuditFormat selects the encoding used by ExportAuditLog.
+type AuditFormat string
+
+const (
+	AuditFormatJSONL AuditFormat = "jsonl"
+	AuditFormatCSV   AuditFormat = "csv"
+)
+
+// AuditEntry is an immutable record of one change made to an organization.
+type AuditEntry struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	ActorID        uuid.UUID `json:"actor_id"` // User or APIKey ID; uuid.Nil when the change was made by neither
+	Action         string    `json:"action"`
+	TargetType     string    `json:"target_type"` // e.g. "membership", "invite"
+	TargetID       uuid.UUID `json:"target_id"`
+	Before         []byte    `json:"before,omitempty"` // JSON snapshot of the target before the change
+	After          []byte    `json:"after,omitempty"`  // JSON snapshot of the target after the change
+	IPAddress      string    `json:"ip_address,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	RequestID      string    `json:"request_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// MarshalJSON embeds the snapshots as JSON values instead of base64 strings.
+func (e AuditEntry) MarshalJSON() ([]byte, error) {
+	type plain AuditEntry
+	return json.Marshal(struct {
+		plain
+		Before json.RawMessage `json:"before,omitempty"`
+		After  json.RawMessage `json:"after,omitempty"`
+	}{plain(e), e.Before, e.After})
+}
+
+// AuditFilter selects audit entries of one organization. Zero fields match everything.
+type AuditFilter struct {
+	OrganizationID uuid.UUID
+	ActorID        uuid.UUID
+	Action         string
+	TargetID       uuid.UUID
+	Since          time.Time
+	Until          time.Time
+	Limit          int // Page size, entries are returned newest first
+	Offset         int
+}
+
+// RequestMetadata describes the request that caused a change, for the audit log.
+type RequestMetadata struct {
+	IPAddress string
+	UserAgent string
+	RequestID string
+}
+
+type requestMetadataKey struct{}
+
+// WithRequestMetadata attaches md to ctx so audit entries written with it record the request.
+func WithRequestMetadata(ctx context.Context, md RequestMetadata) context.Context {
+	return context.WithValue(ctx, requestMetadataKey{}, md)
+}
+
+// RequestMetadataFromContext returns the metadata attached with WithRequestMetadata.
+func RequestMetadataFromContext(ctx context.Context) RequestMetadata {
+	md, _ := ctx.Value(requestMetadataKey{}).(RequestMetadata)
+	return md
+}
+
+// NewRequestMetadata extracts the client address, user agent and X-Request-ID of r.
+// X-Forwarded-For is only believed when r comes from one of trustedProxies: the client is
+// then the last address in it that is not a trusted proxy. Otherwise it is r.RemoteAddr.
+func NewRequestMetadata(r *http.Request, trustedProxies ...netip.Prefix) RequestMetadata {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if trusted(ip, trustedProxies) {
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			ip = hop
+			if !trusted(hop, trustedProxies) {
+				break
+			}
+		}
+	}
+	return RequestMetadata{
+		IPAddress: ip,
+		UserAgent: r.UserAgent(),
+		RequestID: r.Header.Get("X-Request-ID"),
+	}
+}
+
+// RequestMetadata is NewRequestMetadata with the proxies set by WithTrustedProxies.
+func (s *Service) RequestMetadata(r *http.Request) RequestMetadata {
+	return NewRequestMetadata(r, s.trustedProxies...)
+}
+
+// trusted reports whether ip is in one of proxies.
+func trusted(ip string, proxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, proxy := range proxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ActorFromContext returns the authenticated user set under the "user_id" key by the auth middleware,
+// or else the ID of the API key set by APIKeyMiddleware.
+func ActorFromContext(ctx context.Context) uuid.UUID {
+	if actorID, ok := ctx.Value("user_id").(uuid.UUID); ok {
+		return actorID
+	}
+	if key := APIKeyFromContext(ctx); key != nil {
+		return key.ID
+	}
+	return uuid.Nil
+}
+
+// audit writes entry to the audit store, filling in the actor and request metadata from ctx.
+// It must be called inside the transaction of the change it records.
+func (s *Service) audit(ctx context.Context, entry AuditEntry, before, after any) error {
+	if s.auditStore == nil {
+		return nil
+	}
+	if actorID := ActorFromContext(ctx); actorID != uuid.Nil {
+		entry.ActorID = actorID
+	}
+	md := RequestMetadataFromContext(ctx)
+	entry.ID = uuid.New()
+	entry.IPAddress = md.IPAddress
+	entry.UserAgent = md.UserAgent
+	entry.RequestID = md.RequestID
+	entry.CreatedAt = time.Now()
+
+	var err error
+	if before != nil {
+		if entry.Before, err = json.Marshal(before); err != nil {
+			return fmt.Errorf("failed to encode audit snapshot: %w", err)
+		}
+	}
+	if after != nil {
+		if entry.After, err = json.Marshal(after); err != nil {
+			return fmt.Errorf("failed to encode audit snapshot: %w", err)
+		}
+	}
+	if err := s.auditStore.Create(ctx, &entry); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEntries returns one page of the audit log of filter.OrganizationID.
+func (s *Service) ListAuditEntries(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) {
+	if s.auditStore == nil {
+		return nil, notConfigured("audit log")
+	}
+	if filter.OrganizationID == uuid.Nil {
+		return nil, fmt.Errorf("organization id is required: %w", ErrInvalidArgument)
+	}
+	if filter.Limit <= 0 || filter.Limit > 1000 {
+		filter.Limit = 100
+	}
+	entries, err := s.auditStore.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// ExportAuditLog writes every entry matching filter to w as JSON Lines or CSV.
+// filter.Limit and filter.Offset are ignored; the log is read page by page.
+func (s *Service) ExportAuditLog(ctx context.Context, filter AuditFilter, format AuditFormat, w io.Writer) error {
+	var write func(*AuditEntry) error
+	var flush func() error
+	switch format {
+	case AuditFormatJSONL:
+		enc := json.NewEncoder(w)
+		write = func(e *AuditEntry) error { return enc.Encode(e) }
+		flush = func() error { return nil }
+	case AuditFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(auditCSVHeader); err != nil {
+			return err
+		}
+		write = func(e *AuditEntry) error { return cw.Write(auditCSVRecord(e)) }
+		flush = func() error { cw.Flush(); return cw.Error() }
+	default:
+		return fmt.Errorf("unsupported audit export format %q", format)
+	}
+
+	// Freeze the upper bound so entries written during the export do not shift the pages.
+	if filter.Until.IsZero() {
+		filter.Until = time.Now()
+	}
+	filter.Limit = 500
+	filter.Offset = 0
+	for {
+		entries, err := s.ListAuditEntries(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := write(e); err != nil {
+				return fmt.Errorf("failed to write audit export: %w", err)
+			}
+		}
+		if len(entries) < filter.Limit {
+			return flush()
+		}
+		filter.Offset += len(entries)
+	}
+}
+
+var auditCSVHeader = []string{
+	"id", "created_at", "organization_id", "actor_id", "action", "target_type", "target_id",
+	"before", "after", "ip_address", "user_agent", "request_id",
+}
+
+func auditCSVRecord(e *AuditEntry) []string {
+	return []string{
+		e.ID.String(),
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		e.OrganizationID.String(),
+		e.ActorID.String(),
+		e.Action,
+		e.TargetType,
+		e.TargetID.String(),
+		string(e.Before),
+		string(e.After),
+		e.IPAddress,
+		e.UserAgent,
+		e.RequestID,
+	}
+}
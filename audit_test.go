@@ -0,0 +1,347 @@
+package gordian_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"gorm.io/gorm"
+)
+
+func TestNewRequestMetadata(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		wantIP     string
+	}{
+		{name: "remote address", remoteAddr: "10.0.0.1:5123", wantIP: "10.0.0.1"},
+		{name: "forwarded by trusted proxies", remoteAddr: "10.0.0.1:5123", forwarded: "203.0.113.7, 10.0.0.2", wantIP: "203.0.113.7"},
+		{name: "forwarded for spoofed by the client", remoteAddr: "10.0.0.1:5123", forwarded: "192.0.2.1, 203.0.113.7, 10.0.0.2", wantIP: "203.0.113.7"},
+		{name: "forwarded by untrusted client", remoteAddr: "198.51.100.4:5123", forwarded: "203.0.113.7", wantIP: "198.51.100.4"},
+		{name: "forwarded only by trusted proxies", remoteAddr: "10.0.0.1:5123", forwarded: "10.0.0.3, 10.0.0.2", wantIP: "10.0.0.3"},
+		{name: "remote address without port", remoteAddr: "10.0.0.1", wantIP: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			r.Header.Set("User-Agent", "curl/8.0")
+			r.Header.Set("X-Request-ID", "req-1")
+			md := gordian.NewRequestMetadata(r, proxies...)
+			if md.IPAddress != tt.wantIP || md.UserAgent != "curl/8.0" || md.RequestID != "req-1" {
+				t.Errorf("NewRequestMetadata = %+v, want IP %s", md, tt.wantIP)
+			}
+		})
+	}
+}
+
+func TestNewRequestMetadataWithoutTrustedProxies(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:5123"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if md := gordian.NewRequestMetadata(r); md.IPAddress != "10.0.0.1" {
+		t.Errorf("NewRequestMetadata without trusted proxies = %s, want the remote address 10.0.0.1", md.IPAddress)
+	}
+}
+
+func TestAuditRecordsActorAndRequest(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	user := env.member(org, "user@example.com", "member")
+
+	ctx := context.WithValue(env.ctx, "user_id", owner.ID)
+	ctx = gordian.WithRequestMetadata(ctx, gordian.RequestMetadata{IPAddress: "203.0.113.7", UserAgent: "test", RequestID: "req-1"})
+	membership, err := env.svc.ChangeMemberRole(ctx, org.ID, user.ID, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := env.svc.ListAuditEntries(env.ctx, gordian.AuditFilter{OrganizationID: org.ID, Action: gordian.AuditRoleChanged})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d role change entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.ActorID != owner.ID || e.TargetID != membership.ID || e.TargetType != "membership" {
+		t.Errorf("entry = actor %s target %s %s, want actor %s target membership %s", e.ActorID, e.TargetType, e.TargetID, owner.ID, membership.ID)
+	}
+	if e.IPAddress != "203.0.113.7" || e.UserAgent != "test" || e.RequestID != "req-1" {
+		t.Errorf("entry request = %s %s %s, want the request metadata", e.IPAddress, e.UserAgent, e.RequestID)
+	}
+	var before, after struct{ Role string }
+	if json.Unmarshal(e.Before, &before) != nil || json.Unmarshal(e.After, &after) != nil || before.Role != "member" || after.Role != "admin" {
+		t.Errorf("snapshots = %s -> %s, want member -> admin", e.Before, e.After)
+	}
+}
+
+func TestAuditFilter(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	other := env.org("Other", owner)
+	env.member(org, "a@example.com", "member")
+	env.member(org, "b@example.com", "member")
+
+	tests := []struct {
+		name   string
+		filter gordian.AuditFilter
+		want   int
+	}{
+		{name: "organization", filter: gordian.AuditFilter{OrganizationID: org.ID}, want: 3},
+		{name: "action", filter: gordian.AuditFilter{OrganizationID: org.ID, Action: gordian.AuditMemberAdded}, want: 2},
+		{name: "other organization", filter: gordian.AuditFilter{OrganizationID: other.ID}, want: 1},
+		{name: "page", filter: gordian.AuditFilter{OrganizationID: org.ID, Limit: 2, Offset: 2}, want: 1},
+		{name: "future", filter: gordian.AuditFilter{OrganizationID: org.ID, Since: time.Now().Add(time.Hour)}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := env.svc.ListAuditEntries(env.ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.want {
+				t.Errorf("got %d entries, want %d", len(entries), tt.want)
+			}
+		})
+	}
+
+	if _, err := env.svc.ListAuditEntries(env.ctx, gordian.AuditFilter{}); !errors.Is(err, gordian.ErrInvalidArgument) {
+		t.Errorf("ListAuditEntries without organization = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestAuditSnapshotsLeaveOutSecrets(t *testing.T) {
+	env := newTestEnv(t, func(db *gorm.DB) gordian.Option {
+		return gordian.WithAPIKeys(gormadapter.NewAPIKeyStore(db))
+	}, withDomains)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	invite, err := env.svc.CreateInvitation(env.ctx, org.ID, owner.ID, "new@example.com", "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, plaintext, err := env.svc.CreateAPIKey(env.ctx, org.ID, "ci", "member", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim, err := env.svc.ClaimDomain(env.ctx, org.ID, "acme.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := env.svc.ExportAuditLog(env.ctx, gordian.AuditFilter{OrganizationID: org.ID}, gordian.AuditFormatJSONL, &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{invite.Token, plaintext, claim.VerificationToken} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("audit log contains a secret: %s", buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "SecretHash") || strings.Contains(buf.String(), "secret_hash") {
+		t.Errorf("audit log contains the API key hash: %s", buf.String())
+	}
+}
+
+func TestAuditSnapshots(t *testing.T) {
+	env := newTestEnv(t,
+		func(db *gorm.DB) gordian.Option { return gordian.WithTeams(gormadapter.NewTeamStore(db)) },
+		withDomains, withJoinRequests, withInviteLinks, withPlans,
+		func(db *gorm.DB) gordian.Option { return gordian.WithBilling(gordian.NewMemoryBillingProvider()) },
+	)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	if _, err := env.svc.ChangePlan(env.ctx, org.ID, proPlan.Name); err != nil {
+		t.Fatal(err)
+	}
+	member := env.member(org, "a@example.com", "member")
+	if _, err := env.svc.ChangeMemberRole(env.ctx, org.ID, member.ID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	team, err := env.svc.CreateTeam(env.ctx, org.ID, "Platform", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.AddTeamMember(env.ctx, org.ID, team.ID, member.ID, gordian.TeamRoleMember); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.ChangeOrganizationSlug(env.ctx, org.ID, "acme-inc"); err != nil {
+		t.Fatal(err)
+	}
+	claim, err := env.svc.ClaimDomain(env.ctx, org.ID, "acme.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.SetDomainPolicy(env.ctx, org.ID, claim.ID, gordian.DomainPolicyAutoJoin, "member"); err != nil {
+		t.Fatal(err)
+	}
+	request, err := env.svc.RequestToJoin(env.ctx, org.ID, env.user("b@example.com").ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.ApproveJoinRequest(env.ctx, org.ID, request.ID, owner.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	link, err := env.svc.CreateInviteLink(env.ctx, org.ID, owner.ID, "member", 0, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.RedeemInviteLink(env.ctx, link.Token, env.user("c@example.com").ID); err != nil {
+		t.Fatal(err)
+	}
+	holding := env.org("Acme Holding", owner)
+	if _, err := env.svc.MoveOrganization(env.ctx, org.ID, &holding.ID); err != nil {
+		t.Fatal(err)
+	}
+	env.svc.Events().Wait() // for the subscription created by an after-hook
+
+	tests := []struct {
+		action  string
+		wantKey string // in the snake_case snapshot of the record
+	}{
+		{action: gordian.AuditOrganizationCreated, wantKey: "owner_id"},
+		{action: gordian.AuditMemberAdded, wantKey: "user_id"},
+		{action: gordian.AuditRoleChanged, wantKey: "organization_id"},
+		{action: gordian.AuditTeamCreated, wantKey: "permissions"},
+		{action: gordian.AuditTeamMemberAdded, wantKey: "team_id"},
+		{action: gordian.AuditOrganizationMoved, wantKey: "parent_id"},
+		{action: gordian.AuditOrganizationSlugChanged, wantKey: "slug"},
+		{action: gordian.AuditDomainClaimed, wantKey: "domain"},
+		{action: gordian.AuditDomainPolicyChanged, wantKey: "default_role"},
+		{action: gordian.AuditJoinRequestCreated, wantKey: "user_id"},
+		{action: gordian.AuditJoinRequestApproved, wantKey: "decider_id"},
+		{action: gordian.AuditInviteLinkRedeemed, wantKey: "joined_at"},
+		{action: gordian.AuditOrganizationPlanChanged, wantKey: "plan"},
+		{action: gordian.AuditOrganizationSubscriptionChanged, wantKey: "subscription_status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			entries, err := env.svc.ListAuditEntries(env.ctx, gordian.AuditFilter{OrganizationID: org.ID, Action: tt.action})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) == 0 {
+				t.Fatalf("no %s entry", tt.action)
+			}
+			for _, snapshot := range []json.RawMessage{entries[0].Before, entries[0].After} {
+				var fields map[string]any
+				if snapshot == nil || string(snapshot) == "null" {
+					continue
+				}
+				if err := json.Unmarshal(snapshot, &fields); err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := fields[tt.wantKey]; !ok {
+					t.Errorf("snapshot has no %q: %s", tt.wantKey, snapshot)
+				}
+			}
+		})
+	}
+}
+
+func TestExportAuditLog(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	env.member(org, "a@example.com", "member")
+
+	tests := []struct {
+		format gordian.AuditFormat
+		parse  func(t *testing.T, out []byte) []string // returns the action of each entry
+	}{
+		{format: gordian.AuditFormatJSONL, parse: func(t *testing.T, out []byte) []string {
+			var actions []string
+			sc := bufio.NewScanner(bytes.NewReader(out))
+			for sc.Scan() {
+				var e struct {
+					Action string          `json:"action"`
+					After  json.RawMessage `json:"after"`
+				}
+				if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+					t.Fatalf("invalid JSON line %q: %v", sc.Text(), err)
+				}
+				if !json.Valid(e.After) || e.After[0] != '{' {
+					t.Errorf("after snapshot is not embedded as JSON: %s", e.After)
+				}
+				actions = append(actions, e.Action)
+			}
+			return actions
+		}},
+		{format: gordian.AuditFormatCSV, parse: func(t *testing.T, out []byte) []string {
+			records, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) == 0 || records[0][0] != "id" || records[0][4] != "action" {
+				t.Fatalf("missing CSV header: %v", records)
+			}
+			var actions []string
+			for _, r := range records[1:] {
+				actions = append(actions, r[4])
+			}
+			return actions
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := env.svc.ExportAuditLog(env.ctx, gordian.AuditFilter{OrganizationID: org.ID}, tt.format, &buf); err != nil {
+				t.Fatal(err)
+			}
+			actions := tt.parse(t, buf.Bytes())
+			if len(actions) != 2 {
+				t.Fatalf("exported %v, want the organization and member entries", actions)
+			}
+		})
+	}
+
+	if err := env.svc.ExportAuditLog(env.ctx, gordian.AuditFilter{OrganizationID: org.ID}, "xml", &bytes.Buffer{}); err == nil {
+		t.Error("ExportAuditLog accepted an unknown format")
+	}
+}
+
+func TestOptionalFeaturesNotConfigured(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"webhooks", func() error { _, err := env.svc.ListWebhookEndpoints(env.ctx, org.ID); return err }},
+		{"api keys", func() error { _, err := env.svc.ListAPIKeys(env.ctx, org.ID); return err }},
+		{"teams", func() error { _, err := env.svc.ListTeams(env.ctx, org.ID); return err }},
+		{"outbox", func() error { _, err := env.svc.ListFailedDeliveries(env.ctx, 10); return err }},
+		{"invite links", func() error { _, err := env.svc.ListInviteLinks(env.ctx, org.ID); return err }},
+		{"join requests", func() error { _, err := env.svc.ListJoinRequests(env.ctx, org.ID, ""); return err }},
+		{"domains", func() error { _, err := env.svc.ListDomains(env.ctx, org.ID); return err }},
+		{"plans", func() error { _, err := env.svc.GetUsage(env.ctx, org.ID); return err }},
+		{"settings", func() error { _, err := env.svc.GetSettings(env.ctx, org.ID); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, gordian.ErrNotConfigured) {
+				t.Errorf("err = %v, want ErrNotConfigured", err)
+			}
+		})
+	}
+}
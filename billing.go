@@ -0,0 +1,292 @@
+package gordian
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionStatus is the state of an organization's subscription at its BillingProvider.
+type SubscriptionStatus string
+
+const (
+	SubscriptionNone     SubscriptionStatus = "" // No subscription was created yet
+	SubscriptionTrialing SubscriptionStatus = "trialing"
+	SubscriptionActive   SubscriptionStatus = "active"
+	SubscriptionPastDue  SubscriptionStatus = "past_due"
+	SubscriptionCanceled SubscriptionStatus = "canceled"
+)
+
+// Subscription is what a BillingProvider created for an organization.
+type Subscription struct {
+	CustomerID string
+	ID         string
+	Plan       string // Name of the Plan subscribed to, "" to keep the organization's plan
+	Status     SubscriptionStatus
+	Seats      int
+}
+
+// BillingEvent is a change reported by a BillingProvider webhook, e.g. a paid upgrade or a
+// failed payment.
+type BillingEvent struct {
+	OrganizationID uuid.UUID          `json:"organization_id"`
+	Plan           string             `json:"plan,omitempty"` // "" leaves the plan unchanged
+	Status         SubscriptionStatus `json:"status"`
+}
+
+// BillingProvider connects organizations to a billing system such as Stripe. The Service calls
+// it after an organization is created or deleted and after its members change; a seat is one
+// membership.
+type BillingProvider interface {
+	// CreateSubscription creates a customer and subscription for org. Record org.ID with the
+	// customer so webhooks can be mapped back to the organization.
+	CreateSubscription(ctx context.Context, org *Organization, seats int) (*Subscription, error)
+	// UpdateSeats sets the number of billed seats of org's subscription.
+	UpdateSeats(ctx context.Context, org *Organization, seats int) error
+	// CancelSubscription ends the subscription of org, which has been deleted.
+	CancelSubscription(ctx context.Context, org *Organization) error
+	// ParseWebhook verifies and decodes a webhook request of the provider. It returns a nil
+	// event for notifications that don't change an organization.
+	ParseWebhook(r *http.Request) (*BillingEvent, error)
+}
+
+// subscribeBilling keeps the BillingProvider up to date with organizations and their seats.
+func (s *Service) subscribeBilling() {
+	syncBilling := func(ctx context.Context, orgID uuid.UUID) {
+		if _, err := s.SyncBilling(ctx, orgID); err != nil && !errors.Is(err, ErrNotFound) {
+			log.Printf("ERROR: failed to sync billing of organization %s: %v", orgID, err)
+		}
+	}
+	OnAfter(s.events, func(ctx context.Context, e OrganizationCreated) {
+		syncBilling(ctx, e.Organization.ID)
+	})
+	OnAfter(s.events, func(ctx context.Context, e MemberAdded) {
+		syncBilling(ctx, e.Membership.OrganizationID)
+	})
+	OnAfter(s.events, func(ctx context.Context, e MemberRemoved) {
+		syncBilling(ctx, e.Membership.OrganizationID)
+	})
+	OnAfter(s.events, func(ctx context.Context, e OrganizationDeleted) {
+		if e.Organization.BillingCustomerID == "" {
+			return
+		}
+		if err := s.billing.CancelSubscription(ctx, e.Organization); err != nil {
+			log.Printf("ERROR: failed to cancel subscription of organization %s: %v", e.Organization.ID, err)
+		}
+	})
+}
+
+// SyncBilling creates the subscription of orgID if it has none yet, and otherwise sets its seats
+// to the current number of members. It runs automatically; call it to catch up after the
+// BillingProvider was unavailable.
+func (s *Service) SyncBilling(ctx context.Context, orgID uuid.UUID) (*Organization, error) {
+	if s.billing == nil {
+		return nil, notConfigured("billing")
+	}
+	var org *Organization
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Syncs of one organization must not interleave, or two subscriptions could be created.
+		// The row lock also covers other processes; it is held while the provider is called.
+		var err error
+		if org, err = s.lockedOrganization(ctx, orgID); err != nil {
+			return err
+		}
+		members, err := s.memStore.GetMembers(ctx, orgID)
+		if err != nil {
+			return fmt.Errorf("failed to get members: %w", err)
+		}
+		if org.BillingCustomerID != "" {
+			if err := s.billing.UpdateSeats(ctx, org, len(members)); err != nil {
+				return fmt.Errorf("failed to update seats: %w", err)
+			}
+			return nil
+		}
+
+		subscription, err := s.billing.CreateSubscription(ctx, org, len(members))
+		if err != nil {
+			return fmt.Errorf("failed to create subscription: %w", err)
+		}
+		before := snapshotOrganization(org)
+		org.BillingCustomerID = subscription.CustomerID
+		org.SubscriptionID = subscription.ID
+		org.SubscriptionStatus = subscription.Status
+		if subscription.Plan != "" {
+			org.Plan = subscription.Plan
+		}
+		return s.updateSubscription(ctx, before, org)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// ApplyBillingEvent stores the plan and subscription status reported by the BillingProvider.
+func (s *Service) ApplyBillingEvent(ctx context.Context, event *BillingEvent) (*Organization, error) {
+	if s.billing == nil {
+		return nil, notConfigured("billing")
+	}
+	switch event.Status {
+	case SubscriptionTrialing, SubscriptionActive, SubscriptionPastDue, SubscriptionCanceled:
+	default:
+		return nil, fmt.Errorf("unknown subscription status %q: %w", event.Status, ErrInvalidArgument)
+	}
+	if s.plans != nil && event.Plan != "" {
+		if _, ok := s.plans[event.Plan]; !ok {
+			return nil, fmt.Errorf("unknown plan %q: %w", event.Plan, ErrInvalidArgument)
+		}
+	}
+	var org *Organization
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Locked like SyncBilling, so a subscription it is storing is not written back empty.
+		var err error
+		if org, err = s.lockedOrganization(ctx, event.OrganizationID); err != nil {
+			return err
+		}
+		before := snapshotOrganization(org)
+		org.SubscriptionStatus = event.Status
+		if event.Plan != "" {
+			org.Plan = event.Plan
+		}
+		if org.SubscriptionStatus == before.SubscriptionStatus && org.Plan == before.Plan {
+			return nil
+		}
+		return s.updateSubscription(ctx, before, org)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// lockedOrganization locks orgID for the transaction in ctx and reads it.
+func (s *Service) lockedOrganization(ctx context.Context, orgID uuid.UUID) (*Organization, error) {
+	if err := s.orgStore.Lock(ctx, orgID); err != nil {
+		return nil, fmt.Errorf("failed to lock organization: %w", err)
+	}
+	org, err := s.orgStore.Get(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return org, nil
+}
+
+// updateSubscription stores the plan and billing fields of org. It runs in the transaction
+// that locked org.
+func (s *Service) updateSubscription(ctx context.Context, before *organizationSnapshot, org *Organization) error {
+	if err := s.orgStore.UpdateSubscription(ctx, org); err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+	entry := AuditEntry{OrganizationID: org.ID, Action: AuditOrganizationSubscriptionChanged, TargetType: "organization", TargetID: org.ID}
+	return s.audit(ctx, entry, before, snapshotOrganization(org))
+}
+
+// BillingWebhookHandler receives the webhooks of the BillingProvider and applies them with
+// ApplyBillingEvent. Mount it outside of authentication; ParseWebhook verifies the requests.
+// Failures the provider should retry are answered with a 500.
+func (s *Service) BillingWebhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.billing == nil {
+			http.Error(w, notConfigured("billing").Error(), http.StatusNotFound)
+			return
+		}
+		event, err := s.billing.ParseWebhook(r)
+		if err != nil {
+			http.Error(w, "Invalid billing webhook", http.StatusBadRequest)
+			return
+		}
+		if event == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_, err = s.ApplyBillingEvent(r.Context(), event)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			// The organization was deleted; retrying won't help.
+		case errors.Is(err, ErrInvalidArgument):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Printf("ERROR: failed to apply billing event: %v", err)
+			http.Error(w, "Failed to apply billing event", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// MemoryBillingProvider is an in-memory BillingProvider for tests and local development. New
+// subscriptions are active on Plan, and webhooks are unsigned JSON BillingEvents.
+type MemoryBillingProvider struct {
+	Plan string // Plan of new subscriptions, "" for the organization's current plan
+
+	mu            sync.Mutex
+	subscriptions map[uuid.UUID]*Subscription
+}
+
+func NewMemoryBillingProvider() *MemoryBillingProvider {
+	return &MemoryBillingProvider{subscriptions: map[uuid.UUID]*Subscription{}}
+}
+
+// CreateSubscription satisfies the BillingProvider interface.
+func (p *MemoryBillingProvider) CreateSubscription(ctx context.Context, org *Organization, seats int) (*Subscription, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	subscription := &Subscription{
+		CustomerID: "cus_" + org.ID.String(),
+		ID:         "sub_" + uuid.NewString(),
+		Plan:       p.Plan,
+		Status:     SubscriptionActive,
+		Seats:      seats,
+	}
+	p.subscriptions[org.ID] = subscription
+	created := *subscription
+	return &created, nil
+}
+
+func (p *MemoryBillingProvider) UpdateSeats(ctx context.Context, org *Organization, seats int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	subscription, ok := p.subscriptions[org.ID]
+	if !ok {
+		return fmt.Errorf("no subscription for organization %s: %w", org.ID, ErrNotFound)
+	}
+	subscription.Seats = seats
+	return nil
+}
+
+func (p *MemoryBillingProvider) CancelSubscription(ctx context.Context, org *Organization) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	subscription, ok := p.subscriptions[org.ID]
+	if !ok {
+		return fmt.Errorf("no subscription for organization %s: %w", org.ID, ErrNotFound)
+	}
+	subscription.Status = SubscriptionCanceled
+	return nil
+}
+
+func (p *MemoryBillingProvider) ParseWebhook(r *http.Request) (*BillingEvent, error) {
+	var event BillingEvent
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20)).Decode(&event); err != nil {
+		return nil, fmt.Errorf("invalid billing event: %w", err)
+	}
+	return &event, nil
+}
+
+// Subscription returns the subscription of orgID, e.g. to check its seats in a test.
+func (p *MemoryBillingProvider) Subscription(orgID uuid.UUID) (Subscription, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	subscription, ok := p.subscriptions[orgID]
+	if !ok {
+		return Subscription{}, false
+	}
+	return *subscription, true
+}
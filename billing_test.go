@@ -0,0 +1,268 @@
+package gordian_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// recordingBilling is a MemoryBillingProvider that records the seats of each organization and
+// fails while err is set. Creating a subscription is slow, so concurrent syncs overlap.
+type recordingBilling struct {
+	*gordian.MemoryBillingProvider
+
+	mu       sync.Mutex
+	err      error
+	created  int
+	canceled int
+	seats    map[uuid.UUID]int
+}
+
+func newRecordingBilling() *recordingBilling {
+	return &recordingBilling{MemoryBillingProvider: gordian.NewMemoryBillingProvider(), seats: map[uuid.UUID]int{}}
+}
+
+func (b *recordingBilling) CreateSubscription(ctx context.Context, org *gordian.Organization, seats int) (*gordian.Subscription, error) {
+	time.Sleep(10 * time.Millisecond)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return nil, b.err
+	}
+	b.created++
+	b.seats[org.ID] = seats
+	return b.MemoryBillingProvider.CreateSubscription(ctx, org, seats)
+}
+
+func (b *recordingBilling) UpdateSeats(ctx context.Context, org *gordian.Organization, seats int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seats[org.ID] = seats
+	return b.MemoryBillingProvider.UpdateSeats(ctx, org, seats)
+}
+
+func (b *recordingBilling) CancelSubscription(ctx context.Context, org *gordian.Organization) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.canceled++
+	return b.MemoryBillingProvider.CancelSubscription(ctx, org)
+}
+
+func (b *recordingBilling) setErr(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+func (b *recordingBilling) stats(orgID uuid.UUID) (created, canceled, seats int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.created, b.canceled, b.seats[orgID]
+}
+
+func newBillingEnv(t *testing.T) (*testEnv, *recordingBilling) {
+	billing := newRecordingBilling()
+	withBilling := func(db *gorm.DB) gordian.Option {
+		return gordian.WithBilling(billing)
+	}
+	return newTestEnv(t, withPlans, withBilling), billing
+}
+
+func TestBillingFollowsOrganization(t *testing.T) {
+	env, billing := newBillingEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	var alice *gordian.User
+
+	// The cases run in order against one organization.
+	tests := []struct {
+		name         string
+		do           func() error
+		wantSeats    int
+		wantCanceled int
+	}{
+		{name: "created", do: func() error { return nil }, wantSeats: 1},
+		{name: "member added", do: func() error {
+			alice = env.member(org, "alice@example.com", "member")
+			return nil
+		}, wantSeats: 2},
+		{name: "renamed", do: func() error {
+			_, err := env.svc.RenameOrganization(env.ctx, org.ID, "Acme Inc")
+			return err
+		}, wantSeats: 2},
+		{name: "member removed", do: func() error {
+			return env.svc.RemoveMember(env.ctx, org.ID, alice.ID)
+		}, wantSeats: 1},
+		{name: "deleted", do: func() error {
+			return env.svc.DeleteOrganization(env.ctx, org.ID)
+		}, wantSeats: 1, wantCanceled: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.do(); err != nil {
+				t.Fatal(err)
+			}
+			env.svc.Events().Wait()
+			created, canceled, seats := billing.stats(org.ID)
+			if created != 1 || canceled != tt.wantCanceled || seats != tt.wantSeats {
+				t.Errorf("%d subscriptions created, %d canceled, %d seats, want 1, %d, %d", created, canceled, seats, tt.wantCanceled, tt.wantSeats)
+			}
+			if tt.wantCanceled > 0 {
+				return
+			}
+			got, err := env.svc.GetOrganization(env.ctx, org.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.BillingCustomerID == "" || got.SubscriptionID == "" || got.SubscriptionStatus != gordian.SubscriptionActive {
+				t.Errorf("subscription was not kept: %+v", got)
+			}
+		})
+	}
+}
+
+func TestSyncBilling(t *testing.T) {
+	env, billing := newBillingEnv(t)
+	owner := env.user("owner@example.com")
+	billing.setErr(errors.New("provider unavailable"))
+	acme, globex := env.org("Acme", owner), env.org("Globex", owner)
+	env.svc.Events().Wait()
+	if created, _, _ := billing.stats(acme.ID); created != 0 {
+		t.Fatalf("%d subscriptions created while the provider failed", created)
+	}
+	billing.setErr(nil)
+
+	// A second Service on the same database, like another replica of the app.
+	replica := gordian.New(
+		gormadapter.NewOrganizationStore(env.db),
+		gormadapter.NewUserStore(env.db),
+		gormadapter.NewMembershipStore(env.db),
+		gormadapter.NewInviteStore(env.db),
+		env.emailer,
+		gordian.WithTransactor(gormadapter.NewTransactor(env.db)),
+		gordian.WithBilling(billing),
+	)
+
+	// Catch up both organizations at once, several times each from both Services.
+	var wg sync.WaitGroup
+	errs := make(chan error, 12)
+	for range 3 {
+		for _, svc := range []*gordian.Service{env.svc, replica} {
+			for _, org := range []*gordian.Organization{acme, globex} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := svc.SyncBilling(env.ctx, org.ID); err != nil {
+						errs <- err
+					}
+				}()
+			}
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if created, _, _ := billing.stats(acme.ID); created != 2 {
+		t.Errorf("%d subscriptions created, want one per organization", created)
+	}
+	for _, org := range []*gordian.Organization{acme, globex} {
+		got, err := env.svc.GetOrganization(env.ctx, org.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.BillingCustomerID != "cus_"+org.ID.String() {
+			t.Errorf("%s: BillingCustomerID = %q", org.Name, got.BillingCustomerID)
+		}
+	}
+}
+
+func TestApplyBillingEvent(t *testing.T) {
+	env, _ := newBillingEnv(t)
+	org := env.org("Acme", env.user("owner@example.com"))
+	env.svc.Events().Wait()
+
+	// The cases run in order: each starts from the state the one before left.
+	tests := []struct {
+		name       string
+		event      gordian.BillingEvent
+		wantPlan   string
+		wantStatus gordian.SubscriptionStatus
+		wantErr    error
+	}{
+		{name: "upgrade", event: gordian.BillingEvent{OrganizationID: org.ID, Plan: proPlan.Name, Status: gordian.SubscriptionActive}, wantPlan: proPlan.Name, wantStatus: gordian.SubscriptionActive},
+		{name: "payment failed", event: gordian.BillingEvent{OrganizationID: org.ID, Status: gordian.SubscriptionPastDue}, wantPlan: proPlan.Name, wantStatus: gordian.SubscriptionPastDue},
+		{name: "unchanged", event: gordian.BillingEvent{OrganizationID: org.ID, Status: gordian.SubscriptionPastDue}, wantPlan: proPlan.Name, wantStatus: gordian.SubscriptionPastDue},
+		{name: "unknown plan", event: gordian.BillingEvent{OrganizationID: org.ID, Plan: "enterprise", Status: gordian.SubscriptionActive}, wantPlan: proPlan.Name, wantStatus: gordian.SubscriptionPastDue, wantErr: gordian.ErrInvalidArgument},
+		{name: "unknown status", event: gordian.BillingEvent{OrganizationID: org.ID, Status: "paused"}, wantPlan: proPlan.Name, wantStatus: gordian.SubscriptionPastDue, wantErr: gordian.ErrInvalidArgument},
+		{name: "no status", event: gordian.BillingEvent{OrganizationID: org.ID, Plan: freePlan.Name}, wantPlan: proPlan.Name, wantStatus: gordian.SubscriptionPastDue, wantErr: gordian.ErrInvalidArgument},
+		{name: "canceled", event: gordian.BillingEvent{OrganizationID: org.ID, Plan: freePlan.Name, Status: gordian.SubscriptionCanceled}, wantPlan: freePlan.Name, wantStatus: gordian.SubscriptionCanceled},
+		{name: "unknown organization", event: gordian.BillingEvent{OrganizationID: uuid.New(), Status: gordian.SubscriptionActive}, wantPlan: freePlan.Name, wantStatus: gordian.SubscriptionCanceled, wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.svc.ApplyBillingEvent(env.ctx, &tt.event)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApplyBillingEvent = %v, want %v", err, tt.wantErr)
+			}
+			got, err := env.svc.GetOrganization(env.ctx, org.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Plan != tt.wantPlan || got.SubscriptionStatus != tt.wantStatus || got.BillingCustomerID == "" {
+				t.Errorf("organization = %+v, want plan %q and status %q", got, tt.wantPlan, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestBillingWebhookHandler(t *testing.T) {
+	env, _ := newBillingEnv(t)
+	org := env.org("Acme", env.user("owner@example.com"))
+	env.svc.Events().Wait()
+	handler := env.svc.BillingWebhookHandler()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "applied", body: `{"organization_id":"` + org.ID.String() + `","plan":"pro","status":"active"}`, wantStatus: http.StatusNoContent},
+		{name: "deleted organization", body: `{"organization_id":"` + uuid.NewString() + `","status":"active"}`, wantStatus: http.StatusNoContent},
+		{name: "unknown plan", body: `{"organization_id":"` + org.ID.String() + `","plan":"enterprise","status":"active"}`, wantStatus: http.StatusBadRequest},
+		{name: "malformed", body: `{`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/billing/webhook", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestBillingNotConfigured(t *testing.T) {
+	env := newTestEnv(t)
+	org := env.org("Acme", env.user("owner@example.com"))
+	if _, err := env.svc.SyncBilling(env.ctx, org.ID); !errors.Is(err, gordian.ErrNotConfigured) {
+		t.Errorf("SyncBilling = %v, want ErrNotConfigured", err)
+	}
+	if _, err := env.svc.ApplyBillingEvent(env.ctx, &gordian.BillingEvent{OrganizationID: org.ID}); !errors.Is(err, gordian.ErrNotConfigured) {
+		t.Errorf("ApplyBillingEvent = %v, want ErrNotConfigured", err)
+	}
+}
@@ -0,0 +1,243 @@
+package gordian
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// MaxBulkInviteRows limits how many rows one BulkInvite call accepts.
+const MaxBulkInviteRows = 1000
+
+// DefaultBulkInviteConcurrency is how many invitations BulkInvite sends at once, see
+// WithBulkInviteConcurrency.
+const DefaultBulkInviteConcurrency = 8
+
+// BulkInviteRow is one invitation requested from BulkInvite. An empty Role means the
+// organization's SettingDefaultInviteRole, "member" unless changed.
+type BulkInviteRow struct {
+	Email string
+	Role  string
+}
+
+// BulkInviteStatus is the outcome of one BulkInviteRow.
+type BulkInviteStatus string
+
+const (
+	BulkInviteInvited BulkInviteStatus = "invited"
+	BulkInviteSkipped BulkInviteStatus = "skipped" // Already a member, already invited, or repeated in the input
+	BulkInviteFailed  BulkInviteStatus = "failed"  // Invalid row, or the invitation could not be created or sent
+)
+
+// BulkInviteResult reports what happened to one row.
+type BulkInviteResult struct {
+	Row    int // 1-based position in the input; for CSV input, the line number
+	Email  string
+	Role   string
+	Status BulkInviteStatus
+	Invite *Invite // Set for BulkInviteInvited
+	Err    error   // Why the row was skipped or failed
+}
+
+// BulkInviteReport holds a result per input row, in input order.
+type BulkInviteReport struct {
+	Results []BulkInviteResult
+	Invited int
+	Skipped int
+	Failed  int
+}
+
+// BulkInvite invites every row to orgID on behalf of inviterID. Rows are validated and checked
+// against existing members, pending invitations and earlier rows first; the remaining ones are
+// invited in parallel. A bad row never stops the others: every outcome is in the report, and the
+// error is only set when the whole call failed.
+//
+// When ctx carries a transaction, the rows are invited one after another on its connection, and
+// the first invitation that fails is returned as the error instead: on PostgreSQL a failed
+// statement aborts the transaction, so the caller has to roll it back.
+func (s *Service) BulkInvite(ctx context.Context, orgID, inviterID uuid.UUID, rows []BulkInviteRow) (*BulkInviteReport, error) {
+	results := make([]BulkInviteResult, len(rows))
+	for i, row := range rows {
+		results[i] = BulkInviteResult{Row: i + 1, Email: strings.TrimSpace(row.Email), Role: strings.TrimSpace(row.Role)}
+	}
+	return s.bulkInvite(ctx, orgID, inviterID, results)
+}
+
+// BulkInviteCSV reads "email,role" records from r and invites them like BulkInvite. The role
+// column is optional, and a first record of "email" or "email,role" is treated as a header.
+func (s *Service) BulkInviteCSV(ctx context.Context, orgID, inviterID uuid.UUID, r io.Reader) (*BulkInviteReport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	var results []BulkInviteResult
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			results = append(results, BulkInviteResult{Row: parseErr.Line, Status: BulkInviteFailed, Err: fmt.Errorf("%w: %w", ErrInvalidArgument, err)})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(results) == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "email") {
+			continue
+		}
+		result := BulkInviteResult{Row: line, Email: strings.TrimSpace(record[0])}
+		if len(record) > 1 {
+			result.Role = strings.TrimSpace(record[1])
+		}
+		if len(record) > 2 {
+			result.Status = BulkInviteFailed
+			result.Err = fmt.Errorf("expected email and role, got %d columns: %w", len(record), ErrInvalidArgument)
+		}
+		results = append(results, result)
+		if len(results) > MaxBulkInviteRows {
+			break
+		}
+	}
+	return s.bulkInvite(ctx, orgID, inviterID, results)
+}
+
+func (s *Service) bulkInvite(ctx context.Context, orgID, inviterID uuid.UUID, results []BulkInviteResult) (*BulkInviteReport, error) {
+	if len(results) > MaxBulkInviteRows {
+		return nil, fmt.Errorf("cannot invite more than %d rows at once: %w", MaxBulkInviteRows, ErrInvalidArgument)
+	}
+	if _, err := s.orgStore.Get(ctx, orgID); err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	pending, err := s.invStore.ListPending(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	defaultRole, err := s.defaultInviteRole(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, invite := range pending {
+		seen[strings.ToLower(invite.InviteeEmail)] = true
+	}
+
+	var todo []*BulkInviteResult
+	for i := range results {
+		result := &results[i]
+		if result.Status != "" {
+			continue
+		}
+		if result.Role == "" {
+			result.Role = defaultRole
+		}
+		if err := s.checkBulkInviteRow(ctx, orgID, result, seen); err != nil {
+			return nil, err
+		}
+		if result.Status == "" {
+			todo = append(todo, result)
+		}
+	}
+
+	if InTransaction(ctx) {
+		for _, result := range todo {
+			invite, err := s.CreateInvitation(ctx, orgID, inviterID, result.Email, result.Role)
+			if err != nil {
+				return nil, fmt.Errorf("failed to invite %s in row %d: %w", result.Email, result.Row, err)
+			}
+			result.Status, result.Invite = BulkInviteInvited, invite
+		}
+	} else {
+		s.inviteConcurrently(ctx, orgID, inviterID, todo)
+	}
+
+	report := &BulkInviteReport{Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case BulkInviteInvited:
+			report.Invited++
+		case BulkInviteSkipped:
+			report.Skipped++
+		case BulkInviteFailed:
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// inviteConcurrently creates the invitations of todo with up to s.bulkInviteConcurrency at once
+// and records each outcome in its result.
+func (s *Service) inviteConcurrently(ctx context.Context, orgID, inviterID uuid.UUID, todo []*BulkInviteResult) {
+	jobs := make(chan *BulkInviteResult)
+	var wg sync.WaitGroup
+	for range min(s.bulkInviteConcurrency, len(todo)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for result := range jobs {
+				if err := ctx.Err(); err != nil {
+					result.Status, result.Err = BulkInviteFailed, err
+					continue
+				}
+				invite, err := s.CreateInvitation(ctx, orgID, inviterID, result.Email, result.Role)
+				if err != nil {
+					result.Status, result.Err = BulkInviteFailed, err
+					continue
+				}
+				result.Status, result.Invite = BulkInviteInvited, invite
+			}
+		}()
+	}
+	for _, result := range todo {
+		jobs <- result
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// checkBulkInviteRow validates result and marks it skipped or failed when it should not be
+// invited. seen holds the lower-cased emails already invited or queued. Only store failures are returned.
+func (s *Service) checkBulkInviteRow(ctx context.Context, orgID uuid.UUID, result *BulkInviteResult, seen map[string]bool) error {
+	address, err := mail.ParseAddress(result.Email)
+	if err != nil || address.Address != result.Email {
+		result.Status, result.Err = BulkInviteFailed, fmt.Errorf("invalid email %q: %w", result.Email, ErrInvalidArgument)
+		return nil
+	}
+	if result.Role == "owner" {
+		result.Status, result.Err = BulkInviteFailed, fmt.Errorf("invitations cannot grant role %q: %w", result.Role, ErrInvalidArgument)
+		return nil
+	}
+	key := strings.ToLower(result.Email)
+	if seen[key] {
+		result.Status, result.Err = BulkInviteSkipped, fmt.Errorf("%s is already invited: %w", result.Email, ErrConflict)
+		return nil
+	}
+	seen[key] = true
+
+	user, err := s.userStore.FindByEmail(ctx, result.Email)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	_, err = s.memStore.GetMembership(ctx, user.ID, orgID)
+	if err == nil {
+		result.Status, result.Err = BulkInviteSkipped, fmt.Errorf("%s is already a member: %w", result.Email, ErrConflict)
+		return nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to get membership: %w", err)
+	}
+	return nil
+}
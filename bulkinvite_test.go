@@ -0,0 +1,278 @@
+package gordian_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/internal/sqlitetest"
+)
+
+func TestBulkInvite(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	env.member(org, "member@example.com", "member")
+	if _, err := env.svc.CreateInvitation(env.ctx, org.ID, owner.ID, "pending@example.com", "member"); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []gordian.BulkInviteRow{
+		{Email: "jane@example.com", Role: "admin"},
+		{Email: " john@example.com ", Role: ""},
+		{Email: "JANE@example.com", Role: "member"},
+		{Email: "Member@Example.com"},
+		{Email: "pending@example.com"},
+		{Email: "not an email"},
+		{Email: "Jim <jim@example.com>"},
+		{Email: "jim@example.com", Role: "owner"},
+	}
+	want := []struct {
+		email  string
+		role   string
+		status gordian.BulkInviteStatus
+		err    error
+	}{
+		{email: "jane@example.com", role: "admin", status: gordian.BulkInviteInvited},
+		{email: "john@example.com", role: "member", status: gordian.BulkInviteInvited},
+		{email: "JANE@example.com", role: "member", status: gordian.BulkInviteSkipped, err: gordian.ErrConflict},
+		{email: "Member@Example.com", role: "member", status: gordian.BulkInviteSkipped, err: gordian.ErrConflict},
+		{email: "pending@example.com", role: "member", status: gordian.BulkInviteSkipped, err: gordian.ErrConflict},
+		{email: "not an email", role: "member", status: gordian.BulkInviteFailed, err: gordian.ErrInvalidArgument},
+		{email: "Jim <jim@example.com>", role: "member", status: gordian.BulkInviteFailed, err: gordian.ErrInvalidArgument},
+		{email: "jim@example.com", role: "owner", status: gordian.BulkInviteFailed, err: gordian.ErrInvalidArgument},
+	}
+
+	report, err := env.svc.BulkInvite(env.ctx, org.ID, owner.ID, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != len(want) {
+		t.Fatalf("got %d results for %d rows", len(report.Results), len(rows))
+	}
+	for i, w := range want {
+		got := report.Results[i]
+		if got.Row != i+1 || got.Email != w.email || got.Role != w.role || got.Status != w.status || !errors.Is(got.Err, w.err) {
+			t.Errorf("row %d = %+v, want %s %s %s (%v)", i+1, got, w.email, w.role, w.status, w.err)
+		}
+		if (got.Invite != nil) != (w.status == gordian.BulkInviteInvited) {
+			t.Errorf("row %d: Invite = %v with status %s", i+1, got.Invite, got.Status)
+		}
+	}
+	if report.Invited != 2 || report.Skipped != 3 || report.Failed != 3 {
+		t.Errorf("report counts %d invited, %d skipped, %d failed", report.Invited, report.Skipped, report.Failed)
+	}
+	if sent := env.emailer.sentInvitations(); len(sent) != 3 {
+		t.Errorf("%d invitations sent, want 3 including the one made before", len(sent))
+	}
+}
+
+func TestBulkInviteInTransactionFails(t *testing.T) {
+	db := sqlitetest.Open(t)
+	transactor := gormadapter.NewTransactor(db)
+	env := &testEnv{t: t, ctx: context.Background(), db: db, emailer: &testEmailer{}}
+	env.svc = gordian.New(
+		gormadapter.NewOrganizationStore(db),
+		gormadapter.NewUserStore(db),
+		gormadapter.NewMembershipStore(db),
+		gormadapter.NewInviteStore(db),
+		env.emailer,
+		gordian.WithTransactor(transactor),
+	)
+	t.Cleanup(env.svc.Events().Wait)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	errBlocked := errors.New("blocked")
+	gordian.OnBefore(env.svc.Events(), func(ctx context.Context, e gordian.InvitationSent) error {
+		if e.Invite.InviteeEmail == "b@example.com" {
+			return errBlocked
+		}
+		return nil
+	})
+
+	rows := []gordian.BulkInviteRow{{Email: "a@example.com"}, {Email: "b@example.com"}, {Email: "c@example.com"}}
+	err := transactor.WithinTransaction(env.ctx, func(ctx context.Context) error {
+		report, err := env.svc.BulkInvite(ctx, org.ID, owner.ID, rows)
+		if report != nil {
+			t.Errorf("got a report with an error: %+v", report.Results)
+		}
+		return err
+	})
+	if !errors.Is(err, errBlocked) {
+		t.Fatalf("BulkInvite = %v, want the failing row's error", err)
+	}
+	pending, err := gormadapter.NewInviteStore(db).ListPending(env.ctx, org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("%d invitations left after the rollback", len(pending))
+	}
+}
+
+func TestBulkInviteCSV(t *testing.T) {
+	tests := []struct {
+		name       string
+		csv        string
+		wantRows   []int
+		wantStatus []gordian.BulkInviteStatus
+		wantRoles  []string
+	}{
+		{
+			name:       "header",
+			csv:        "email,role\njane@example.com,admin\njohn@example.com\n",
+			wantRows:   []int{2, 3},
+			wantStatus: []gordian.BulkInviteStatus{gordian.BulkInviteInvited, gordian.BulkInviteInvited},
+			wantRoles:  []string{"admin", "member"},
+		},
+		{
+			name:       "no header",
+			csv:        "jane@example.com, admin\n\njohn@example.com,\n",
+			wantRows:   []int{1, 3},
+			wantStatus: []gordian.BulkInviteStatus{gordian.BulkInviteInvited, gordian.BulkInviteInvited},
+			wantRoles:  []string{"admin", "member"},
+		},
+		{
+			name:       "email-only header",
+			csv:        "Email\njane@example.com\n",
+			wantRows:   []int{2},
+			wantStatus: []gordian.BulkInviteStatus{gordian.BulkInviteInvited},
+			wantRoles:  []string{"member"},
+		},
+		{
+			name:       "extra column",
+			csv:        "jane@example.com,admin,yes\njohn@example.com\n",
+			wantRows:   []int{1, 2},
+			wantStatus: []gordian.BulkInviteStatus{gordian.BulkInviteFailed, gordian.BulkInviteInvited},
+			wantRoles:  []string{"admin", "member"},
+		},
+		{
+			name:       "malformed line",
+			csv:        "jane@example.com\n\"john@example.com\n",
+			wantRows:   []int{1, 2},
+			wantStatus: []gordian.BulkInviteStatus{gordian.BulkInviteInvited, gordian.BulkInviteFailed},
+			wantRoles:  []string{"member", ""},
+		},
+		{
+			name:       "quoted",
+			csv:        "\"jane@example.com\",\"admin\"\r\n",
+			wantRows:   []int{1},
+			wantStatus: []gordian.BulkInviteStatus{gordian.BulkInviteInvited},
+			wantRoles:  []string{"admin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			owner := env.user("owner@example.com")
+			org := env.org("Acme", owner)
+			report, err := env.svc.BulkInviteCSV(env.ctx, org.ID, owner.ID, strings.NewReader(tt.csv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Results) != len(tt.wantRows) {
+				t.Fatalf("results = %+v, want %d", report.Results, len(tt.wantRows))
+			}
+			for i, got := range report.Results {
+				if got.Row != tt.wantRows[i] || got.Status != tt.wantStatus[i] || got.Role != tt.wantRoles[i] {
+					t.Errorf("result %d = %+v, want line %d %s %q", i, got, tt.wantRows[i], tt.wantStatus[i], tt.wantRoles[i])
+				}
+				if got.Status == gordian.BulkInviteFailed && !errors.Is(got.Err, gordian.ErrInvalidArgument) {
+					t.Errorf("result %d: Err = %v, want ErrInvalidArgument", i, got.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestBulkInviteTooManyRows(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	var csv strings.Builder
+	for i := range gordian.MaxBulkInviteRows + 1 {
+		fmt.Fprintf(&csv, "user%d@example.com\n", i)
+	}
+	if _, err := env.svc.BulkInviteCSV(env.ctx, org.ID, owner.ID, strings.NewReader(csv.String())); !errors.Is(err, gordian.ErrInvalidArgument) {
+		t.Errorf("BulkInviteCSV = %v, want ErrInvalidArgument", err)
+	}
+	if sent := env.emailer.sentInvitations(); len(sent) != 0 {
+		t.Errorf("%d invitations sent", len(sent))
+	}
+}
+
+// overlapEmailer records how many invitations were being sent at the same time.
+type overlapEmailer struct {
+	*testEmailer
+	inFlight, maxInFlight atomic.Int32
+}
+
+func (e *overlapEmailer) SendInvitation(ctx context.Context, invite *gordian.Invite) error {
+	n := e.inFlight.Add(1)
+	defer e.inFlight.Add(-1)
+	for m := e.maxInFlight.Load(); n > m && !e.maxInFlight.CompareAndSwap(m, n); m = e.maxInFlight.Load() {
+	}
+	time.Sleep(10 * time.Millisecond)
+	return e.testEmailer.SendInvitation(ctx, invite)
+}
+
+func TestBulkInviteConcurrency(t *testing.T) {
+	tests := []struct {
+		name          string
+		inTransaction bool
+		wantParallel  bool
+	}{
+		{name: "parallel", wantParallel: true},
+		{name: "sequential in a transaction", inTransaction: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sqlitetest.Open(t)
+			emailer := &overlapEmailer{testEmailer: &testEmailer{}}
+			transactor := gormadapter.NewTransactor(db)
+			env := &testEnv{t: t, ctx: context.Background(), db: db, emailer: emailer.testEmailer}
+			env.svc = gordian.New(
+				gormadapter.NewOrganizationStore(db),
+				gormadapter.NewUserStore(db),
+				gormadapter.NewMembershipStore(db),
+				gormadapter.NewInviteStore(db),
+				emailer,
+				gordian.WithTransactor(transactor),
+				gordian.WithBulkInviteConcurrency(4),
+			)
+			t.Cleanup(env.svc.Events().Wait)
+			owner := env.user("owner@example.com")
+			org := env.org("Acme", owner)
+			var rows []gordian.BulkInviteRow
+			for i := range 8 {
+				rows = append(rows, gordian.BulkInviteRow{Email: fmt.Sprintf("user%d@example.com", i)})
+			}
+
+			var report *gordian.BulkInviteReport
+			var err error
+			if tt.inTransaction {
+				err = transactor.WithinTransaction(env.ctx, func(ctx context.Context) error {
+					report, err = env.svc.BulkInvite(ctx, org.ID, owner.ID, rows)
+					return err
+				})
+			} else {
+				report, err = env.svc.BulkInvite(env.ctx, org.ID, owner.ID, rows)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Invited != len(rows) {
+				t.Errorf("%d of %d rows invited: %+v", report.Invited, len(rows), report.Results)
+			}
+			if parallel := emailer.maxInFlight.Load() > 1; parallel != tt.wantParallel {
+				t.Errorf("up to %d invitations sent at once, want parallel %v", emailer.maxInFlight.Load(), tt.wantParallel)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package gordian
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheStats counts the lookups served by a CachingMembershipStore.
+type CacheStats struct {
+	Hits         uint64 // lookups answered with a cached membership
+	NegativeHits uint64 // lookups answered with a cached "not a member"
+	Misses       uint64 // lookups not answered from the cache; concurrent misses share one resolution
+	Evictions    uint64 // entries dropped because the cache was full
+	Size         int    // entries currently cached
+}
+
+// CachingMembershipStore caches the memberships Service.GetMemberships resolves, the lookup
+// TenancyMiddleware runs on every request, in an in-process LRU. Roles inherited from parent
+// organizations and "not a member" answers are cached too, and concurrent lookups of the same
+// membership share one resolution. Lookups inside a transaction bypass the cache. Writes through
+// the store invalidate the affected user; call Subscribe so changes are also invalidated once
+// their transaction has committed. Each process has its own cache: changes made elsewhere,
+// including to the roles inherited from parent organizations, show up once the entries expire.
+//
+//	memStore := gordian.NewCachingMembershipStore(gormadapter.NewMembershipStore(db))
+//	svc := gordian.New(orgStore, userStore, memStore, invStore, emailer, gordian.WithMembershipCache(memStore))
+//	memStore.Subscribe(svc.Events())
+type CachingMembershipStore struct {
+	MembershipStore
+
+	Size        int           // Maximum number of cached lookups
+	TTL         time.Duration // Lifetime of cached memberships
+	NegativeTTL time.Duration // Lifetime of cached "not a member" answers
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[membershipKey]*list.Element
+	epoch   uint64 // bumped by every invalidation, so lookups in flight don't cache stale rows
+	stats   CacheStats
+	group   singleflight.Group
+}
+
+type membershipKey struct {
+	userID uuid.UUID
+	orgID  uuid.UUID
+}
+
+type cacheEntry struct {
+	key        membershipKey
+	membership *Membership // nil for a negative entry
+	expiresAt  time.Time
+}
+
+func NewCachingMembershipStore(store MembershipStore) *CachingMembershipStore {
+	return &CachingMembershipStore{
+		MembershipStore: store,
+		Size:            10000,
+		TTL:             time.Minute,
+		NegativeTTL:     10 * time.Second,
+		lru:             list.New(),
+		entries:         map[membershipKey]*list.Element{},
+	}
+}
+
+// resolve returns the cached membership of userID in orgID, or caches the one load returns.
+// load runs without the cancellation of ctx, since concurrent callers share its result.
+func (c *CachingMembershipStore) resolve(ctx context.Context, userID, orgID uuid.UUID, load func(ctx context.Context) (Membership, error)) (Membership, error) {
+	if InTransaction(ctx) {
+		return load(ctx)
+	}
+	key := membershipKey{userID: userID, orgID: orgID}
+	if entry, ok := c.lookup(key); ok {
+		if entry.membership == nil {
+			return Membership{}, fmt.Errorf("no membership found: %w", ErrNotFound)
+		}
+		return *entry.membership, nil
+	}
+
+	c.mu.Lock()
+	epoch := c.epoch
+	c.mu.Unlock()
+	result := c.group.DoChan(userID.String()+orgID.String(), func() (any, error) {
+		membership, err := load(context.WithoutCancel(ctx))
+		switch {
+		case err == nil:
+			c.store(key, &membership, c.TTL, epoch)
+		case errors.Is(err, ErrNotFound):
+			c.store(key, nil, c.NegativeTTL, epoch)
+		}
+		return membership, err
+	})
+	select {
+	case r := <-result:
+		if r.Err != nil {
+			return Membership{}, r.Err
+		}
+		return r.Val.(Membership), nil
+	case <-ctx.Done():
+		return Membership{}, ctx.Err()
+	}
+}
+
+// Create satisfies the gordian.MembershipStore interface.
+func (c *CachingMembershipStore) Create(ctx context.Context, membership *Membership) error {
+	err := c.MembershipStore.Create(ctx, membership)
+	c.InvalidateUser(membership.UserID)
+	return err
+}
+
+func (c *CachingMembershipStore) Update(ctx context.Context, membership *Membership) error {
+	err := c.MembershipStore.Update(ctx, membership)
+	c.InvalidateUser(membership.UserID)
+	return err
+}
+
+func (c *CachingMembershipStore) Delete(ctx context.Context, id uuid.UUID) error {
+	err := c.MembershipStore.Delete(ctx, id)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Lookups that didn't resolve to the deleted membership cannot change.
+	for key, elem := range c.entries {
+		if entry := elem.Value.(*cacheEntry); entry.membership != nil && entry.membership.ID == id {
+			c.removeUser(key.userID)
+			break
+		}
+	}
+	c.epoch++
+	return err
+}
+
+// InvalidateUser drops the cached lookups of userID, e.g. when another process reports a change
+// to one of the user's memberships. A membership also decides the roles the user inherits in
+// the organization's descendants, so every organization of the user is dropped.
+func (c *CachingMembershipStore) InvalidateUser(userID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeUser(userID)
+	c.epoch++
+}
+
+// Purge drops every cached lookup.
+func (c *CachingMembershipStore) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Init()
+	clear(c.entries)
+	c.epoch++
+}
+
+// Subscribe invalidates memberships again after the Service has committed a change to them,
+// so a lookup racing with the change's transaction cannot leave a stale entry behind.
+func (c *CachingMembershipStore) Subscribe(bus *EventBus) {
+	OnAfter(bus, func(ctx context.Context, e MemberAdded) {
+		c.InvalidateUser(e.Membership.UserID)
+	})
+	OnAfter(bus, func(ctx context.Context, e MemberRemoved) {
+		c.InvalidateUser(e.Membership.UserID)
+	})
+	OnAfter(bus, func(ctx context.Context, e RoleChanged) {
+		c.InvalidateUser(e.Membership.UserID)
+	})
+	OnAfter(bus, func(ctx context.Context, e OwnershipTransferred) {
+		c.InvalidateUser(e.Organization.OwnerID)
+		c.InvalidateUser(e.PreviousOwnerID)
+	})
+	OnAfter(bus, func(ctx context.Context, e OrganizationDeleted) {
+		c.InvalidateOrganization(e.Organization.ID)
+	})
+}
+
+// InvalidateOrganization drops every cached lookup in orgID.
+func (c *CachingMembershipStore) InvalidateOrganization(orgID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.orgID == orgID {
+			c.remove(key)
+		}
+	}
+	c.epoch++
+}
+
+// Stats returns the counters since the store was created.
+func (c *CachingMembershipStore) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
+
+func (c *CachingMembershipStore) lookup(key membershipKey) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(key)
+		c.stats.Misses++
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	if entry.membership == nil {
+		c.stats.NegativeHits++
+	} else {
+		c.stats.Hits++
+	}
+	return entry, true
+}
+
+func (c *CachingMembershipStore) store(key membershipKey, membership *Membership, ttl time.Duration, epoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if epoch != c.epoch || ttl <= 0 {
+		return
+	}
+	c.remove(key)
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, membership: membership, expiresAt: time.Now().Add(ttl)})
+	for c.lru.Len() > max(c.Size, 1) {
+		c.remove(c.lru.Back().Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+// remove drops key from the cache. c.mu must be held.
+func (c *CachingMembershipStore) remove(key membershipKey) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.lru.Remove(elem)
+	delete(c.entries, key)
+}
+
+// removeUser drops every key of userID from the cache. c.mu must be held.
+func (c *CachingMembershipStore) removeUser(userID uuid.UUID) {
+	for key := range c.entries {
+		if key.userID == userID {
+			c.remove(key)
+		}
+	}
+}
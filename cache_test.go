@@ -0,0 +1,242 @@
+package gordian_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/internal/sqlitetest"
+	"github.com/google/uuid"
+)
+
+// countingMemberships counts the GetMembership calls that reach the database. While block is
+// set, each call waits for it to be closed and then reports its error on loaded.
+type countingMemberships struct {
+	gordian.MembershipStore
+	calls  atomic.Int64
+	block  chan struct{}
+	loaded chan error
+}
+
+func (m *countingMemberships) GetMembership(ctx context.Context, userID, orgID uuid.UUID) (gordian.Membership, error) {
+	m.calls.Add(1)
+	if m.block == nil {
+		return m.MembershipStore.GetMembership(ctx, userID, orgID)
+	}
+	<-m.block
+	membership, err := m.MembershipStore.GetMembership(ctx, userID, orgID)
+	m.loaded <- err
+	return membership, err
+}
+
+// cacheEnv is a testEnv whose Service resolves memberships through a CachingMembershipStore.
+type cacheEnv struct {
+	*testEnv
+	cache      *gordian.CachingMembershipStore
+	store      *countingMemberships
+	transactor gordian.Transactor
+}
+
+func newCacheEnv(t *testing.T) *cacheEnv {
+	t.Helper()
+	db := sqlitetest.Open(t)
+	store := &countingMemberships{MembershipStore: gormadapter.NewMembershipStore(db)}
+	env := &cacheEnv{
+		testEnv:    &testEnv{t: t, ctx: context.Background(), db: db, emailer: &testEmailer{}},
+		cache:      gordian.NewCachingMembershipStore(store),
+		store:      store,
+		transactor: gormadapter.NewTransactor(db),
+	}
+	env.svc = gordian.New(
+		gormadapter.NewOrganizationStore(db),
+		gormadapter.NewUserStore(db),
+		env.cache,
+		gormadapter.NewInviteStore(db),
+		env.emailer,
+		gordian.WithTransactor(env.transactor),
+		gordian.WithMembershipCache(env.cache),
+	)
+	env.cache.Subscribe(env.svc.Events())
+	t.Cleanup(env.svc.Events().Wait)
+	return env
+}
+
+// hierarchy creates parent and child organizations with an admin of the parent.
+func (env *cacheEnv) hierarchy() (parent, child *gordian.Organization, admin *gordian.User) {
+	env.t.Helper()
+	parent = env.org("Acme", env.user("owner@example.com"))
+	admin = env.member(parent, "admin@example.com", "admin")
+	child, err := env.svc.CreateChildOrganization(env.ctx, parent.ID, "Europe", env.user("europe@example.com").ID)
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	env.svc.Events().Wait()
+	env.cache.Purge()
+	return parent, child, admin
+}
+
+func TestMembershipCacheResolves(t *testing.T) {
+	env := newCacheEnv(t)
+	parent, child, admin := env.hierarchy()
+
+	tests := []struct {
+		name     string
+		orgID    uuid.UUID
+		wantRole string // "" when ErrNotFound is expected
+		wantHit  gordian.CacheStats
+	}{
+		{name: "direct", orgID: parent.ID, wantRole: "admin", wantHit: gordian.CacheStats{Hits: 1}},
+		{name: "inherited", orgID: child.ID, wantRole: "admin", wantHit: gordian.CacheStats{Hits: 1}},
+		{name: "not a member", orgID: uuid.New(), wantHit: gordian.CacheStats{NegativeHits: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env.cache.Purge()
+			for i := range 2 {
+				before, calls := env.cache.Stats(), env.store.calls.Load()
+				_, role, err := env.svc.GetMemberships(env.ctx, admin.ID, tt.orgID)
+				switch {
+				case tt.wantRole == "" && !errors.Is(err, gordian.ErrNotFound):
+					t.Fatalf("GetMemberships = %q, %v, want ErrNotFound", role, err)
+				case tt.wantRole != "" && (err != nil || role != tt.wantRole):
+					t.Fatalf("GetMemberships = %q, %v, want %q", role, err, tt.wantRole)
+				}
+				after := env.cache.Stats()
+				if i == 0 {
+					if after.Misses != before.Misses+1 || env.store.calls.Load() == calls {
+						t.Errorf("first lookup: stats %+v -> %+v, %d store calls", before, after, env.store.calls.Load()-calls)
+					}
+					continue
+				}
+				if after.Hits-before.Hits != tt.wantHit.Hits || after.NegativeHits-before.NegativeHits != tt.wantHit.NegativeHits {
+					t.Errorf("second lookup: stats %+v -> %+v", before, after)
+				}
+				if n := env.store.calls.Load() - calls; n != 0 {
+					t.Errorf("second lookup made %d store calls", n)
+				}
+			}
+		})
+	}
+}
+
+func TestMembershipCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(env *cacheEnv, parent *gordian.Organization, admin *gordian.User) error
+		wantRole string // role of the admin in the child after the change, "" for ErrNotFound
+	}{
+		{
+			name: "removed from the parent",
+			change: func(env *cacheEnv, parent *gordian.Organization, admin *gordian.User) error {
+				return env.svc.RemoveMember(env.ctx, parent.ID, admin.ID)
+			},
+		},
+		{
+			name: "child moved out of the parent",
+			change: func(env *cacheEnv, parent *gordian.Organization, admin *gordian.User) error {
+				children, err := env.svc.ListOrganizationDescendants(env.ctx, parent.ID)
+				if err != nil {
+					return err
+				}
+				_, err = env.svc.MoveOrganization(env.ctx, children[0].ID, nil)
+				return err
+			},
+		},
+		{
+			name: "demoted in the parent",
+			change: func(env *cacheEnv, parent *gordian.Organization, admin *gordian.User) error {
+				_, err := env.svc.ChangeMemberRole(env.ctx, parent.ID, admin.ID, "member")
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newCacheEnv(t)
+			parent, child, admin := env.hierarchy()
+			if role := env.role(admin.ID, child.ID); role != "admin" {
+				t.Fatalf("inherited role = %q, want admin", role)
+			}
+			if err := tt.change(env, parent, admin); err != nil {
+				t.Fatal(err)
+			}
+			env.svc.Events().Wait()
+			_, role, err := env.svc.GetMemberships(env.ctx, admin.ID, child.ID)
+			if tt.wantRole == "" && !errors.Is(err, gordian.ErrNotFound) || tt.wantRole != "" && role != tt.wantRole {
+				t.Errorf("after the change GetMemberships = %q, %v, want %q", role, err, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestMembershipCacheBypassedInTransaction(t *testing.T) {
+	env := newCacheEnv(t)
+	org := env.org("Acme", env.user("owner@example.com"))
+	user := env.user("alice@example.com")
+	env.svc.Events().Wait()
+	if _, _, err := env.svc.GetMemberships(env.ctx, user.ID, org.ID); !errors.Is(err, gordian.ErrNotFound) {
+		t.Fatalf("GetMemberships = %v, want ErrNotFound", err)
+	}
+
+	errRollback := errors.New("rollback")
+	err := env.transactor.WithinTransaction(env.ctx, func(ctx context.Context) error {
+		// Written past the cache, so only bypassing it finds the membership.
+		if err := env.store.MembershipStore.Create(ctx, gordian.NewMembership(user.ID, org.ID, "member")); err != nil {
+			return err
+		}
+		if _, role, err := env.svc.GetMemberships(ctx, user.ID, org.ID); err != nil || role != "member" {
+			t.Errorf("in the transaction GetMemberships = %q, %v, want member", role, err)
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+	if _, role, err := env.svc.GetMemberships(env.ctx, user.ID, org.ID); !errors.Is(err, gordian.ErrNotFound) {
+		t.Errorf("after the rollback GetMemberships = %q, %v, want ErrNotFound", role, err)
+	}
+	if stats := env.cache.Stats(); stats.Size != 1 || stats.NegativeHits != 1 {
+		t.Errorf("stats = %+v, want the negative entry only", stats)
+	}
+}
+
+func TestMembershipCacheLoadOutlivesCanceledCaller(t *testing.T) {
+	env := newCacheEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	env.svc.Events().Wait()
+	env.cache.Purge()
+	env.store.block, env.store.loaded = make(chan struct{}), make(chan error, 1)
+
+	ctx, cancel := context.WithCancel(env.ctx)
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := env.svc.GetMemberships(ctx, owner.ID, org.ID)
+		done <- err
+	}()
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled GetMemberships = %v, want context.Canceled", err)
+	}
+	close(env.store.block)
+	if err := <-env.store.loaded; err != nil {
+		t.Fatalf("load failed with the caller's cancellation: %v", err)
+	}
+	env.store.block = nil
+	// The load caches its result right after returning it.
+	for deadline := time.Now().Add(5 * time.Second); env.cache.Stats().Size == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the load was not cached")
+		}
+	}
+	if role := env.role(owner.ID, org.ID); role != "owner" {
+		t.Errorf("role = %q, want owner", role)
+	}
+	if stats := env.cache.Stats(); stats.Hits != 1 {
+		t.Errorf("stats = %+v, want the load of the canceled caller cached", stats)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
 	"github.com/Robotech-Org/gordian/cmd/example-server/mailtrap"
 	"github.com/Robotech-Org/gordian/cmd/example-server/models"
+	"github.com/Robotech-Org/gordian/migrations"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -42,11 +43,26 @@ func main() {
 		FromAddr: "noreply@diagramly.com",
 	}
 	emailer := mailtrap.NewEmailer(mailtrapCfg)
-	err = db.AutoMigrate(&models.User{}, &gordian.Organization{}, &gordian.Membership{}, &gordian.Invite{})
-
-	//	err = db.AutoMigrate(&gordian.User{}, &gordian.Organization{}, &gordian.Membership{}, &gordian.Invite{})
+	// Gordian's tables come from the versioned migrations (go run ./cmd/gordian-migrate up).
+	// Refuse to start against an older schema instead of failing on the first query.
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("failed to get database handle: %v", err)
+	}
+	migrator, err := migrations.New(sqlDB, migrations.Postgres)
 	if err != nil {
-		log.Printf("Error migrating the structure: %v", err)
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		log.Fatalf("%v, run gordian-migrate up", err)
+	}
+	// Only the columns this app adds to Gordian's users table are migrated here.
+	for _, column := range []string{"PhoneNumber"} {
+		if !db.Migrator().HasColumn(&models.User{}, column) {
+			if err := db.Migrator().AddColumn(&models.User{}, column); err != nil {
+				log.Printf("Error migrating the structure: %v", err)
+			}
+		}
 	}
 	log.Println("Database migration complete.")
 
@@ -31,6 +31,36 @@ func NewEmailer(cfg Config) *Emailer {
 
 // SendInvitation satisfies the interface.
 func (e *Emailer) SendInvitation(ctx context.Context, invite *gordian.Invite) error {
+	// Create a simple invitation link for the email body
+	invitationLink := fmt.Sprintf("https://app.diagramly.com/accept-invite?token=%s", invite.Token)
+
+	// Set the email body (both HTML and plain text for compatibility)
+	body := fmt.Sprintf("Hello! You have been invited to join an organization. Please click the link to accept: %s", invitationLink)
+	htmlBody := fmt.Sprintf("<h1>Hello!</h1><p>You have been invited to join an organization. Please click the link below to accept:</p><a href=\"%s\">Accept Invitation</a>", invitationLink)
+	return e.send("You're invited to join an organization!", body, htmlBody, invite.InviteeEmail)
+}
+
+// SendJoinRequest satisfies the gordian.JoinRequestEmailer interface.
+func (e *Emailer) SendJoinRequest(ctx context.Context, request *gordian.JoinRequest, admins []*gordian.User) error {
+	reviewLink := fmt.Sprintf("https://app.diagramly.com/join-requests/%s", request.ID)
+
+	body := fmt.Sprintf("Hello! Someone asked to join your organization. Please review the request: %s", reviewLink)
+	htmlBody := fmt.Sprintf("<h1>Hello!</h1><p>Someone asked to join your organization.</p><a href=\"%s\">Review Request</a>", reviewLink)
+	to := make([]string, len(admins))
+	for i, admin := range admins {
+		to[i] = admin.Email
+	}
+	return e.send("New request to join your organization", body, htmlBody, to...)
+}
+
+// SendJoinRequestDecision satisfies the gordian.JoinRequestEmailer interface.
+func (e *Emailer) SendJoinRequestDecision(ctx context.Context, request *gordian.JoinRequest, requester *gordian.User) error {
+	body := fmt.Sprintf("Hello! Your request to join the organization was %s.", request.Status)
+	htmlBody := fmt.Sprintf("<h1>Hello!</h1><p>Your request to join the organization was %s.</p>", request.Status)
+	return e.send("Your request to join an organization", body, htmlBody, requester.Email)
+}
+
+func (e *Emailer) send(subject, body, htmlBody string, to ...string) error {
 	// --- Connect to the SMTP Server ---
 	server := mail.NewSMTPClient()
 	server.Host = e.config.Host
@@ -51,15 +81,8 @@ func (e *Emailer) SendInvitation(ctx context.Context, invite *gordian.Invite) er
 	// --- Create the Email ---
 	email := mail.NewMSG()
 	email.SetFrom(e.config.FromAddr).
-		AddTo(invite.InviteeEmail).
-		SetSubject("You're invited to join an organization!")
-
-	// Create a simple invitation link for the email body
-	invitationLink := fmt.Sprintf("https://app.diagramly.com/accept-invite?token=%s", invite.Token)
-
-	// Set the email body (both HTML and plain text for compatibility)
-	body := fmt.Sprintf("Hello! You have been invited to join an organization. Please click the link to accept: %s", invitationLink)
-	htmlBody := fmt.Sprintf("<h1>Hello!</h1><p>You have been invited to join an organization. Please click the link below to accept:</p><a href=\"%s\">Accept Invitation</a>", invitationLink)
+		AddTo(to...).
+		SetSubject(subject)
 	email.SetBody(mail.TextPlain, body)
 	email.AddAlternative(mail.TextHTML, htmlBody)
 
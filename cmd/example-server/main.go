@@ -11,6 +11,7 @@ import (
 	"github.com/Robotech-Org/gordian/cmd/example-server/mailtrap"
 	"github.com/Robotech-Org/gordian/cmd/example-server/models"
 	"github.com/Robotech-Org/gordian/cmd/example-server/services"
+	"github.com/Robotech-Org/gordian/migrations"
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
@@ -46,11 +47,26 @@ func main() {
 	}
 	emailer := mailtrap.NewEmailer(mailtrapCfg)
 
-	err = db.AutoMigrate(&models.User{}, &gordian.Organization{}, &gordian.Membership{}, &gordian.Invite{})
-
-	//	err = db.AutoMigrate(&gordian.User{}, &gordian.Organization{}, &gordian.Membership{}, &gordian.Invite{})
+	// Gordian's tables come from the versioned migrations (go run ./cmd/gordian-migrate up).
+	// Refuse to start against an older schema instead of failing on the first query.
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("failed to get database handle: %v", err)
+	}
+	migrator, err := migrations.New(sqlDB, migrations.Postgres)
 	if err != nil {
-		log.Printf("Error migrating the structure: %v", err)
+		log.Fatalf("failed to load migrations: %v", err)
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		log.Fatalf("%v, run gordian-migrate up", err)
+	}
+	// Only the columns this app adds to Gordian's users table are migrated here.
+	for _, column := range []string{"PhoneNumber"} {
+		if !db.Migrator().HasColumn(&models.User{}, column) {
+			if err := db.Migrator().AddColumn(&models.User{}, column); err != nil {
+				log.Printf("Error migrating the structure: %v", err)
+			}
+		}
 	}
 
 	log.Println("Database migration complete.")
@@ -61,8 +77,16 @@ func main() {
 	memStore := gormadapter.NewMembershipStore(db)
 	invStore := gormadapter.NewInviteStore(db)
 
-	gordianService := gordian.New(orgStore, userStore, memStore, invStore, emailer)
+	// Organizations are billed per seat. The in-memory provider stands in for Stripe; its webhooks
+	// would be received with http.Handle("/billing/webhook", gordianService.BillingWebhookHandler()).
+	billing := gordian.NewMemoryBillingProvider()
+	gordianService := gordian.New(orgStore, userStore, memStore, invStore, emailer, gordian.WithBilling(billing))
 	log.Println("Gordian service initialized.")
+
+	// React to lifecycle changes without touching Gordian's tables, e.g. to provision resources.
+	gordian.OnAfter(gordianService.Events(), func(ctx context.Context, e gordian.MemberAdded) {
+		log.Printf("EVENT: user %s joined organization %s as %s", e.Membership.UserID, e.Membership.OrganizationID, e.Membership.Role)
+	})
 	userService := services.NewUserService(gordianService, db)
 	log.Println("Application user service initialized.")
 
@@ -72,12 +96,11 @@ func main() {
 
 	// Create a user first
 
-	user, err := userService.CreateUserWithStripe(context.Background(), "carol.extended@example.com", "Carol Extended")
+	user, err := userService.CreateUser(context.Background(), "carol.extended@example.com", "Carol Extended")
 	if err != nil {
-		log.Fatalf("ERROR: Failed to create user with stripe: %v", err)
+		log.Fatalf("ERROR: Failed to create user: %v", err)
 	}
 	log.Printf("SUCCESS: Created user with ID: %s", user.ID)
-	log.Printf("SUCCESS: User has custom Stripe Customer ID: %s", user.StripeCustomerID)
 
 
 	// Now create an organization with that user as the owner
@@ -93,6 +116,15 @@ func main() {
 	}
 	log.Printf("SUCCESS: Retrieved organization '%s' with ID: %s", retrievedOrg.Name, retrievedOrg.ID)
 
+	// The subscription is created by an after-hook; wait for it before reading it back.
+	gordianService.Events().Wait()
+	billedOrg, err := gordianService.GetOrganization(context.Background(), org.ID)
+	if err != nil {
+		log.Fatalf("ERROR: Failed to get organization: %v", err)
+	}
+	subscription, _ := billing.Subscription(org.ID)
+	log.Printf("SUCCESS: Organization has billing customer %s (%s, %d seats)", billedOrg.BillingCustomerID, billedOrg.SubscriptionStatus, subscription.Seats)
+
 	retrievedUser, err := gordianService.GetUser(context.Background(), user.ID)
 	if err != nil {
 		log.Fatalf("ERROR: Failed to get user: %v", err)
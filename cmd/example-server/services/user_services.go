@@ -20,8 +20,8 @@ func NewUserService(gordianService *gordian.Service, db *gorm.DB) *UserService {
 	}
 }
 
-// CreateUserWithStripe is Carol's custom method. It wraps Gordian's method.
-func (s *UserService) CreateUserWithStripe(ctx context.Context, email, name string) (*models.User, error) {
+// CreateUser is Carol's custom method. It wraps Gordian's method.
+func (s *UserService) CreateUser(ctx context.Context, email, name string) (*models.User, error) {
 	// 1. Use Gordian to create the base user.
 	// This handles the core logic of creating the user record.
 	baseUser, err := s.gordian.CreateUser(ctx, email, name)
@@ -29,8 +29,7 @@ func (s *UserService) CreateUserWithStripe(ctx context.Context, email, name stri
 		return nil, err
 	}
 
-	// 2. Retrieve the full user model (including the Stripe ID created by the hook).
-	// We need to fetch the record again to get the value set by the AfterCreate hook.
+	// 2. Retrieve the full user model, including the columns only this app knows about.
 	var fullUser models.User
 	if err := s.db.First(&fullUser, baseUser.ID).Error; err != nil {
 		return nil, err
@@ -0,0 +1,76 @@
+// gordian-migrate applies the versioned Gordian schema to a PostgreSQL or SQLite database.
+//
+//	DATABASE_URL=postgres://... gordian-migrate up
+//	DATABASE_DRIVER=sqlite DATABASE_URL=gordian.db gordian-migrate up
+//	gordian-migrate down [steps]
+//	gordian-migrate status
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/Robotech-Org/gordian/migrations"
+	_ "github.com/glebarez/go-sqlite"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	_ = godotenv.Load()
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: gordian-migrate up | down [steps] | status")
+		os.Exit(2)
+	}
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		dsn = "host=localhost user=postgres password=password dbname=gordian_test port=5432 sslmode=disable"
+	}
+	driver, dialect := "pgx", migrations.Postgres
+	if os.Getenv("DATABASE_DRIVER") == "sqlite" {
+		driver, dialect = "sqlite", migrations.SQLite
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	m, err := migrations.New(db, dialect)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			if steps, err = strconv.Atoi(os.Args[2]); err != nil {
+				log.Fatalf("invalid step count %q", os.Args[2])
+			}
+		}
+		err = m.Down(ctx, steps)
+	case "status":
+		var statuses []migrations.Status
+		statuses, err = m.Status(ctx)
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s %s\n", st.Version, st.Name, applied)
+		}
+	default:
+		log.Fatalf("unknown command %q", os.Args[1])
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,307 @@
+package gordian
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DomainPolicy decides what happens when a user with a verified email address in a verified
+// domain of an organization signs up or verifies their address.
+type DomainPolicy string
+
+const (
+	DomainPolicyNone        DomainPolicy = "none"         // Nothing happens
+	DomainPolicyAutoJoin    DomainPolicy = "auto_join"    // The user becomes a member with the domain's DefaultRole
+	DomainPolicyJoinRequest DomainPolicy = "join_request" // A JoinRequest is created for an admin to decide
+)
+
+// Names and values of the DNS TXT record that proves control of a domain.
+const (
+	DomainChallengeLabel       = "_gordian-challenge"
+	DomainChallengeValuePrefix = "gordian-domain-verification="
+)
+
+// OrganizationDomain is an email domain claimed by an organization. A domain can be claimed by
+// several organizations, but only one of them can verify it.
+type OrganizationDomain struct {
+	ID                uuid.UUID
+	OrganizationID    uuid.UUID
+	Domain            string // Lower-case, e.g. "acme.com"
+	VerificationToken string // Expected in the TXT record named by ChallengeName
+	Policy            DomainPolicy
+	DefaultRole       string     // Role given by DomainPolicyAutoJoin
+	VerifiedAt        *time.Time // nil until the TXT record was found
+	CreatedAt         time.Time
+}
+
+func NewOrganizationDomain(organizationID uuid.UUID, domain, verificationToken string) *OrganizationDomain {
+	return &OrganizationDomain{
+		ID:                uuid.New(),
+		OrganizationID:    organizationID,
+		Domain:            domain,
+		VerificationToken: verificationToken,
+		Policy:            DomainPolicyNone,
+		DefaultRole:       "member",
+		CreatedAt:         time.Now(),
+	}
+}
+
+// ChallengeName is the DNS name the verification TXT record must be published at.
+func (d *OrganizationDomain) ChallengeName() string {
+	return DomainChallengeLabel + "." + d.Domain
+}
+
+// ChallengeValue is the content of the verification TXT record.
+func (d *OrganizationDomain) ChallengeValue() string {
+	return DomainChallengeValuePrefix + d.VerificationToken
+}
+
+// TXTResolver looks up DNS TXT records. net.DefaultResolver satisfies it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// StaticTXTResolver answers TXT lookups from a map keyed by record name, for tests and
+// local development.
+type StaticTXTResolver map[string][]string
+
+func (r StaticTXTResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return r[name], nil
+}
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9-]{2,63}$`)
+
+// NormalizeDomain lower-cases domain and strips a trailing dot, and checks that the result is
+// a valid host name with at least two labels. IDNs must be given in their "xn--" form.
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if len(domain) > 253 || !domainPattern.MatchString(domain) {
+		return "", fmt.Errorf("invalid domain %q: %w", domain, ErrInvalidArgument)
+	}
+	return domain, nil
+}
+
+// EmailDomain returns the lower-case domain of an email address, or "" if it has none.
+func EmailDomain(email string) string {
+	i := strings.LastIndexByte(email, '@')
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(email[i+1:])
+}
+
+// ClaimDomain adds domain to orgID, unverified and with DomainPolicyNone. Publish the TXT record
+// described by ChallengeName and ChallengeValue, then call VerifyDomain.
+func (s *Service) ClaimDomain(ctx context.Context, orgID uuid.UUID, domain string) (*OrganizationDomain, error) {
+	if s.domainStore == nil {
+		return nil, notConfigured("organization domains")
+	}
+	domain, err := NormalizeDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.orgStore.Get(ctx, orgID); err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	if err := s.checkDomainUnverified(ctx, domain, orgID); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate verification token: %w", err)
+	}
+	claim := NewOrganizationDomain(orgID, domain, hex.EncodeToString(raw))
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.domainStore.Create(ctx, claim); err != nil {
+			return fmt.Errorf("failed to create domain: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditDomainClaimed, TargetType: "domain", TargetID: claim.ID}
+		return s.audit(ctx, entry, nil, snapshotOrganizationDomain(claim))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// VerifyDomain looks up the TXT record of a claimed domain and marks the domain verified when it
+// holds the expected value. It fails with ErrInvalidArgument while the record is missing.
+func (s *Service) VerifyDomain(ctx context.Context, orgID, domainID uuid.UUID) (*OrganizationDomain, error) {
+	claim, err := s.organizationDomain(ctx, orgID, domainID)
+	if err != nil {
+		return nil, err
+	}
+	if claim.VerifiedAt != nil {
+		return claim, nil
+	}
+	if err := s.checkDomainUnverified(ctx, claim.Domain, orgID); err != nil {
+		return nil, err
+	}
+	records, err := s.txtResolver.LookupTXT(ctx, claim.ChallengeName())
+	var dnsErr *net.DNSError
+	if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		return nil, fmt.Errorf("failed to look up %s: %w", claim.ChallengeName(), err)
+	}
+	if !slices.Contains(records, claim.ChallengeValue()) {
+		return nil, fmt.Errorf("no TXT record %q found at %s: %w", claim.ChallengeValue(), claim.ChallengeName(), ErrInvalidArgument)
+	}
+
+	before := snapshotOrganizationDomain(claim)
+	verifiedAt := time.Now()
+	claim.VerifiedAt = &verifiedAt
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.domainStore.Update(ctx, claim); err != nil {
+			return fmt.Errorf("failed to update domain: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditDomainVerified, TargetType: "domain", TargetID: claim.ID}
+		return s.audit(ctx, entry, before, snapshotOrganizationDomain(claim))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// SetDomainPolicy changes what happens to users of a domain of orgID. defaultRole is the role
+// DomainPolicyAutoJoin gives; it cannot be "owner". The policy only takes effect once the domain
+// is verified, and only for users who sign up or verify their address afterwards.
+func (s *Service) SetDomainPolicy(ctx context.Context, orgID, domainID uuid.UUID, policy DomainPolicy, defaultRole string) (*OrganizationDomain, error) {
+	switch policy {
+	case DomainPolicyNone, DomainPolicyAutoJoin, DomainPolicyJoinRequest:
+	default:
+		return nil, fmt.Errorf("unknown domain policy %q: %w", policy, ErrInvalidArgument)
+	}
+	if defaultRole == "" || defaultRole == "owner" {
+		return nil, fmt.Errorf("domains cannot grant role %q: %w", defaultRole, ErrInvalidArgument)
+	}
+	if policy == DomainPolicyJoinRequest && s.joinRequestStore == nil {
+		return nil, notConfigured("join requests")
+	}
+	claim, err := s.organizationDomain(ctx, orgID, domainID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := snapshotOrganizationDomain(claim)
+	claim.Policy = policy
+	claim.DefaultRole = defaultRole
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.domainStore.Update(ctx, claim); err != nil {
+			return fmt.Errorf("failed to update domain: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditDomainPolicyChanged, TargetType: "domain", TargetID: claim.ID}
+		return s.audit(ctx, entry, before, snapshotOrganizationDomain(claim))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// ListDomains returns the verified and unverified domains of orgID.
+func (s *Service) ListDomains(ctx context.Context, orgID uuid.UUID) ([]*OrganizationDomain, error) {
+	if s.domainStore == nil {
+		return nil, notConfigured("organization domains")
+	}
+	domains, err := s.domainStore.List(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+	return domains, nil
+}
+
+// RemoveDomain deletes a domain of orgID. Members who joined through it stay members.
+func (s *Service) RemoveDomain(ctx context.Context, orgID, domainID uuid.UUID) error {
+	claim, err := s.organizationDomain(ctx, orgID, domainID)
+	if err != nil {
+		return err
+	}
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.domainStore.Delete(ctx, claim.ID); err != nil {
+			return fmt.Errorf("failed to delete domain: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditDomainRemoved, TargetType: "domain", TargetID: claim.ID}
+		return s.audit(ctx, entry, snapshotOrganizationDomain(claim), nil)
+	})
+}
+
+// organizationDomain returns domainID if it belongs to orgID.
+func (s *Service) organizationDomain(ctx context.Context, orgID, domainID uuid.UUID) (*OrganizationDomain, error) {
+	if s.domainStore == nil {
+		return nil, notConfigured("organization domains")
+	}
+	claim, err := s.domainStore.Get(ctx, domainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain: %w", err)
+	}
+	if claim.OrganizationID != orgID {
+		return nil, fmt.Errorf("domain does not belong to organization: %w", ErrNotFound)
+	}
+	return claim, nil
+}
+
+// checkDomainUnverified fails with ErrConflict if an organization other than orgID has verified domain.
+func (s *Service) checkDomainUnverified(ctx context.Context, domain string, orgID uuid.UUID) error {
+	verified, err := s.domainStore.GetVerified(ctx, domain)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up domain: %w", err)
+	}
+	if verified.OrganizationID != orgID {
+		return fmt.Errorf("domain %q is verified by another organization: %w", domain, ErrConflict)
+	}
+	return nil
+}
+
+// applyDomainPolicy runs the policy of the verified domain of user's email address, if any.
+// The user already exists at this point, so failures are logged rather than returned.
+func (s *Service) applyDomainPolicy(ctx context.Context, user *User) {
+	if s.domainStore == nil || user.EmailVerifiedAt == nil {
+		return
+	}
+	claim, err := s.domainStore.GetVerified(ctx, EmailDomain(user.Email))
+	if errors.Is(err, ErrNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: failed to look up domain of user %s: %v", user.ID, err)
+		return
+	}
+
+	switch claim.Policy {
+	case DomainPolicyAutoJoin:
+		_, err := s.memStore.GetMembership(ctx, user.ID, claim.OrganizationID)
+		if err == nil {
+			return
+		}
+		if errors.Is(err, ErrNotFound) {
+			err = s.addMember(ctx, NewMembership(user.ID, claim.OrganizationID, claim.DefaultRole))
+		}
+		if err != nil {
+			log.Printf("ERROR: failed to add user %s to organization %s by domain: %v", user.ID, claim.OrganizationID, err)
+		}
+	case DomainPolicyJoinRequest:
+		if s.joinRequestStore == nil {
+			log.Printf("ERROR: domain %s asks for join requests, but they are not configured", claim.Domain)
+			return
+		}
+		request := NewJoinRequest(claim.OrganizationID, user.ID, "", JoinRequestSourceDomain, claim.DefaultRole)
+		if _, err := s.createJoinRequest(ctx, request); err != nil && !errors.Is(err, ErrConflict) {
+			log.Printf("ERROR: failed to request joining organization %s for user %s: %v", claim.OrganizationID, user.ID, err)
+		}
+	}
+}
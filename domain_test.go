@@ -0,0 +1,277 @@
+package gordian_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// txtFunc adapts a function to gordian.TXTResolver.
+type txtFunc func(name string) ([]string, error)
+
+func (f txtFunc) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return f(name)
+}
+
+func withDomains(db *gorm.DB) gordian.Option {
+	return gordian.WithDomains(gormadapter.NewDomainStore(db))
+}
+
+func withJoinRequests(db *gorm.DB) gordian.Option {
+	return gordian.WithJoinRequests(gormadapter.NewJoinRequestStore(db))
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string // "" when ErrInvalidArgument is expected
+	}{
+		{domain: "acme.com", want: "acme.com"},
+		{domain: " Mail.ACME.co.uk. ", want: "mail.acme.co.uk"},
+		{domain: "xn--bcher-kva.de", want: "xn--bcher-kva.de"},
+		{domain: "acme"},
+		{domain: "acme..com"},
+		{domain: "-acme.com"},
+		{domain: "acme_corp.com"},
+		{domain: "bücher.de"},
+		{domain: "user@acme.com"},
+		{domain: ""},
+	}
+	for _, tt := range tests {
+		got, err := gordian.NormalizeDomain(tt.domain)
+		if tt.want == "" && !errors.Is(err, gordian.ErrInvalidArgument) || tt.want != "" && (err != nil || got != tt.want) {
+			t.Errorf("NormalizeDomain(%q) = %q, %v, want %q", tt.domain, got, err, tt.want)
+		}
+	}
+}
+
+func TestEmailDomain(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{email: "alice@acme.com", want: "acme.com"},
+		{email: "Alice@ACME.com", want: "acme.com"},
+		{email: `"a@b"@acme.com`, want: "acme.com"},
+		{email: "alice", want: ""},
+	}
+	for _, tt := range tests {
+		if got := gordian.EmailDomain(tt.email); got != tt.want {
+			t.Errorf("EmailDomain(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestClaimAndVerifyDomain(t *testing.T) {
+	records := map[string][]string{}
+	var lookupErr error
+	resolver := txtFunc(func(name string) ([]string, error) { return records[name], lookupErr })
+	env := newTestEnv(t, withDomains, func(*gorm.DB) gordian.Option { return gordian.WithTXTResolver(resolver) })
+	owner := env.user("owner@example.com")
+	acme, globex := env.org("Acme", owner), env.org("Globex", owner)
+
+	if _, err := env.svc.ClaimDomain(env.ctx, acme.ID, "not a domain"); !errors.Is(err, gordian.ErrInvalidArgument) {
+		t.Errorf("ClaimDomain of an invalid domain = %v, want ErrInvalidArgument", err)
+	}
+	if _, err := env.svc.ClaimDomain(env.ctx, uuid.New(), "acme.com"); !errors.Is(err, gordian.ErrNotFound) {
+		t.Errorf("ClaimDomain for an unknown organization = %v, want ErrNotFound", err)
+	}
+	claim, err := env.svc.ClaimDomain(env.ctx, acme.ID, "ACME.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rival, err := env.svc.ClaimDomain(env.ctx, globex.ID, "acme.com")
+	if err != nil {
+		t.Fatalf("a second unverified claim = %v, want it allowed", err)
+	}
+	errLookup := errors.New("lookup timed out")
+
+	// The cases run in order: the domain is verified by "published" and stays verified.
+	tests := []struct {
+		name      string
+		orgID     uuid.UUID
+		domainID  uuid.UUID
+		publish   []string
+		lookupErr error
+		wantErr   error
+	}{
+		{name: "no record", orgID: acme.ID, domainID: claim.ID, wantErr: gordian.ErrInvalidArgument},
+		{name: "name not found", orgID: acme.ID, domainID: claim.ID, lookupErr: &net.DNSError{IsNotFound: true}, wantErr: gordian.ErrInvalidArgument},
+		{name: "lookup failure", orgID: acme.ID, domainID: claim.ID, lookupErr: errLookup, wantErr: errLookup},
+		{name: "wrong value", orgID: acme.ID, domainID: claim.ID, publish: []string{gordian.DomainChallengeValuePrefix + "wrong"}, wantErr: gordian.ErrInvalidArgument},
+		{name: "other organization", orgID: globex.ID, domainID: claim.ID, publish: []string{claim.ChallengeValue()}, wantErr: gordian.ErrNotFound},
+		{name: "published", orgID: acme.ID, domainID: claim.ID, publish: []string{"v=spf1 -all", claim.ChallengeValue()}},
+		{name: "already verified", orgID: acme.ID, domainID: claim.ID},
+		{name: "verified by another organization", orgID: globex.ID, domainID: rival.ID, publish: []string{rival.ChallengeValue()}, wantErr: gordian.ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records[claim.ChallengeName()], lookupErr = tt.publish, tt.lookupErr
+			got, err := env.svc.VerifyDomain(env.ctx, tt.orgID, tt.domainID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerifyDomain = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.VerifiedAt == nil {
+				t.Error("VerifiedAt was not set")
+			}
+		})
+	}
+
+	if _, err := env.svc.ClaimDomain(env.ctx, globex.ID, "acme.com"); !errors.Is(err, gordian.ErrConflict) {
+		t.Errorf("ClaimDomain of a domain verified by another organization = %v, want ErrConflict", err)
+	}
+}
+
+func TestSetDomainPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		joinRequests bool
+		policy       gordian.DomainPolicy
+		role         string
+		wantErr      error
+	}{
+		{name: "auto join", policy: gordian.DomainPolicyAutoJoin, role: "member"},
+		{name: "none", policy: gordian.DomainPolicyNone, role: "member"},
+		{name: "join request", joinRequests: true, policy: gordian.DomainPolicyJoinRequest, role: "admin"},
+		{name: "join request without join requests", policy: gordian.DomainPolicyJoinRequest, role: "member", wantErr: gordian.ErrNotConfigured},
+		{name: "unknown policy", policy: "invite", role: "member", wantErr: gordian.ErrInvalidArgument},
+		{name: "owner role", policy: gordian.DomainPolicyAutoJoin, role: "owner", wantErr: gordian.ErrInvalidArgument},
+		{name: "no role", policy: gordian.DomainPolicyAutoJoin, wantErr: gordian.ErrInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []func(*gorm.DB) gordian.Option{withDomains}
+			if tt.joinRequests {
+				opts = append(opts, withJoinRequests)
+			}
+			env := newTestEnv(t, opts...)
+			org := env.org("Acme", env.user("owner@example.com"))
+			claim, err := env.svc.ClaimDomain(env.ctx, org.ID, "acme.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := env.svc.SetDomainPolicy(env.ctx, org.ID, claim.ID, tt.policy, tt.role)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("SetDomainPolicy = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Policy != tt.policy || got.DefaultRole != tt.role {
+				t.Errorf("domain = %+v", got)
+			}
+		})
+	}
+}
+
+func TestDomainPolicyOnSignUp(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      gordian.DomainPolicy
+		verify      bool // whether the domain is verified
+		email       string
+		confirm     bool // create the user unverified, then confirm the address
+		wantRole    string
+		wantRequest bool
+	}{
+		{name: "auto join", policy: gordian.DomainPolicyAutoJoin, verify: true, email: "alice@acme.com", wantRole: "admin"},
+		{name: "auto join on confirmation", policy: gordian.DomainPolicyAutoJoin, verify: true, email: "alice@acme.com", confirm: true, wantRole: "admin"},
+		{name: "upper-case address", policy: gordian.DomainPolicyAutoJoin, verify: true, email: "Alice@ACME.com", wantRole: "admin"},
+		{name: "join request", policy: gordian.DomainPolicyJoinRequest, verify: true, email: "alice@acme.com", wantRequest: true},
+		{name: "no policy", policy: gordian.DomainPolicyNone, verify: true, email: "alice@acme.com"},
+		{name: "unverified domain", policy: gordian.DomainPolicyAutoJoin, email: "alice@acme.com"},
+		{name: "other domain", policy: gordian.DomainPolicyAutoJoin, verify: true, email: "alice@mail.acme.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := gordian.StaticTXTResolver{}
+			env := newTestEnv(t, withDomains, withJoinRequests, func(*gorm.DB) gordian.Option { return gordian.WithTXTResolver(resolver) })
+			org := env.org("Acme", env.user("owner@example.com"))
+			claim, err := env.svc.ClaimDomain(env.ctx, org.ID, "acme.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.verify {
+				resolver[claim.ChallengeName()] = []string{claim.ChallengeValue()}
+				if _, err := env.svc.VerifyDomain(env.ctx, org.ID, claim.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := env.svc.SetDomainPolicy(env.ctx, org.ID, claim.ID, tt.policy, "admin"); err != nil {
+				t.Fatal(err)
+			}
+
+			var user *gordian.User
+			if tt.confirm {
+				if user, err = env.svc.CreateUser(env.ctx, tt.email, "Alice"); err != nil {
+					t.Fatal(err)
+				}
+				if _, _, err := env.svc.GetMemberships(env.ctx, user.ID, org.ID); !errors.Is(err, gordian.ErrNotFound) {
+					t.Fatalf("unconfirmed address joined: %v", err)
+				}
+				if _, err := env.svc.ConfirmUserEmail(env.ctx, user.ID); err != nil {
+					t.Fatal(err)
+				}
+			} else if user, err = env.svc.CreateUser(env.ctx, tt.email, "Alice", gordian.EmailVerified()); err != nil {
+				t.Fatal(err)
+			}
+
+			_, role, err := env.svc.GetMemberships(env.ctx, user.ID, org.ID)
+			if tt.wantRole == "" && !errors.Is(err, gordian.ErrNotFound) || tt.wantRole != "" && role != tt.wantRole {
+				t.Errorf("GetMemberships = %q, %v, want %q", role, err, tt.wantRole)
+			}
+			requests, err := env.svc.ListJoinRequests(env.ctx, org.ID, gordian.JoinRequestStatusPending)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(requests) == 1 && requests[0].UserID == user.ID; got != tt.wantRequest {
+				t.Errorf("join requests = %+v, want one of the user: %v", requests, tt.wantRequest)
+			}
+		})
+	}
+}
+
+func TestRemoveDomain(t *testing.T) {
+	env := newTestEnv(t, withDomains)
+	owner := env.user("owner@example.com")
+	acme, globex := env.org("Acme", owner), env.org("Globex", owner)
+	claim, err := env.svc.ClaimDomain(env.ctx, acme.ID, "acme.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cases run in order: the domain is removed by "remove".
+	tests := []struct {
+		name     string
+		orgID    uuid.UUID
+		domainID uuid.UUID
+		wantErr  error
+	}{
+		{name: "other organization", orgID: globex.ID, domainID: claim.ID, wantErr: gordian.ErrNotFound},
+		{name: "remove", orgID: acme.ID, domainID: claim.ID},
+		{name: "already removed", orgID: acme.ID, domainID: claim.ID, wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := env.svc.RemoveDomain(env.ctx, tt.orgID, tt.domainID)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("RemoveDomain = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
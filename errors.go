@@ -0,0 +1,22 @@
+package gordian
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors wrapped by the Service and the adapters so callers can branch with errors.Is.
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrConflict        = errors.New("conflict")
+	ErrForbidden       = errors.New("forbidden")
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrQuotaExceeded   = errors.New("quota exceeded") // Wrapped by QuotaExceededError
+	ErrNotConfigured   = errors.New("not configured") // The option enabling the feature was not passed to New
+)
+
+// notConfigured is returned by the methods of an optional feature, e.g. "webhooks", when it is disabled.
+func notConfigured(feature string) error {
+	return fmt.Errorf("%s: %w", feature, ErrNotConfigured)
+}
@@ -0,0 +1,205 @@
+package gordian
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// ErrVetoed is returned by Service methods when a before-hook rejected the action.
+var ErrVetoed = errors.New("action vetoed")
+
+// Event is a lifecycle change published by the Service.
+type Event interface {
+	EventName() string
+}
+
+// OrganizationCreated is published when a new organization and its owner membership are created.
+type OrganizationCreated struct {
+	Organization *Organization
+}
+
+func (OrganizationCreated) EventName() string { return "organization.created" }
+
+// OrganizationDeleted is published when an organization is deleted.
+type OrganizationDeleted struct {
+	Organization *Organization
+}
+
+func (OrganizationDeleted) EventName() string { return "organization.deleted" }
+
+// OrganizationMoved is published when an organization is placed below another parent or made
+// a top-level organization.
+type OrganizationMoved struct {
+	Organization     *Organization
+	PreviousParentID *uuid.UUID
+}
+
+func (OrganizationMoved) EventName() string { return "organization.moved" }
+
+// MemberAdded is published whenever a user gains a membership in an organization.
+type MemberAdded struct {
+	Membership *Membership
+}
+
+func (MemberAdded) EventName() string { return "member.added" }
+
+// MemberRemoved is published when a user's membership in an organization is deleted.
+type MemberRemoved struct {
+	Membership *Membership
+}
+
+func (MemberRemoved) EventName() string { return "member.removed" }
+
+// RoleChanged is published when a member's role is updated. Membership holds the new role.
+type RoleChanged struct {
+	Membership *Membership
+	OldRole    string
+}
+
+func (RoleChanged) EventName() string { return "member.role_changed" }
+
+// OwnershipTransferred is published when an organization changes owner.
+// The previous owner is demoted to "admin".
+type OwnershipTransferred struct {
+	Organization    *Organization
+	PreviousOwnerID uuid.UUID
+}
+
+func (OwnershipTransferred) EventName() string { return "organization.ownership_transferred" }
+
+// InvitationSent is published when an invitation is created and its email sent or queued.
+type InvitationSent struct {
+	Invite *Invite
+}
+
+func (InvitationSent) EventName() string { return "invitation.sent" }
+
+// InvitationRevoked is published when a pending invitation is cancelled.
+type InvitationRevoked struct {
+	Invite *Invite
+}
+
+func (InvitationRevoked) EventName() string { return "invitation.revoked" }
+
+// InvitationAccepted is published when an invitee redeems their token and joins the organization.
+type InvitationAccepted struct {
+	Invite     *Invite
+	Membership *Membership
+	UserID     uuid.UUID
+}
+
+func (InvitationAccepted) EventName() string { return "invitation.accepted" }
+
+// InviteLinkRedeemed is published when a user joins an organization through an invite link.
+type InviteLinkRedeemed struct {
+	Link       *InviteLink
+	Membership *Membership
+}
+
+func (InviteLinkRedeemed) EventName() string { return "invite_link.redeemed" }
+
+// JoinRequested is published when a user asks to join an organization.
+type JoinRequested struct {
+	Request *JoinRequest
+}
+
+func (JoinRequested) EventName() string { return "join_request.created" }
+
+// JoinRequestApproved is published when an admin approves a join request and the user becomes a member.
+type JoinRequestApproved struct {
+	Request    *JoinRequest
+	Membership *Membership
+}
+
+func (JoinRequestApproved) EventName() string { return "join_request.approved" }
+
+// JoinRequestDenied is published when an admin denies a join request.
+type JoinRequestDenied struct {
+	Request *JoinRequest
+}
+
+func (JoinRequestDenied) EventName() string { return "join_request.denied" }
+
+// EventBus dispatches Service events to registered hooks.
+// Before-hooks run synchronously ahead of the change and can veto it by returning an error.
+// After-hooks run asynchronously once the change has been persisted.
+type EventBus struct {
+	mu     sync.RWMutex
+	before map[string][]func(ctx context.Context, e Event) error
+	after  map[string][]func(ctx context.Context, e Event)
+	wg     sync.WaitGroup
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		before: map[string][]func(ctx context.Context, e Event) error{},
+		after:  map[string][]func(ctx context.Context, e Event){},
+	}
+}
+
+// OnBefore registers a hook that runs before events of type E are committed.
+// Returning an error aborts the action with ErrVetoed.
+func OnBefore[E Event](bus *EventBus, hook func(ctx context.Context, e E) error) {
+	var zero E
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.before[zero.EventName()] = append(bus.before[zero.EventName()], func(ctx context.Context, e Event) error {
+		return hook(ctx, e.(E))
+	})
+}
+
+// OnAfter registers a hook that runs in its own goroutine after events of type E are committed.
+func OnAfter[E Event](bus *EventBus, hook func(ctx context.Context, e E)) {
+	var zero E
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.after[zero.EventName()] = append(bus.after[zero.EventName()], func(ctx context.Context, e Event) {
+		hook(ctx, e.(E))
+	})
+}
+
+// Before runs the before-hooks registered for e, stopping at the first veto.
+func (b *EventBus) Before(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	hooks := b.before[e.EventName()]
+	b.mu.RUnlock()
+
+	for _, hook := range hooks {
+		if err := hook(ctx, e); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrVetoed, e.EventName(), err)
+		}
+	}
+	return nil
+}
+
+// After starts the after-hooks registered for e. The hooks outlive the caller's
+// cancellation, use Wait to drain them on shutdown.
+func (b *EventBus) After(ctx context.Context, e Event) {
+	b.mu.RLock()
+	hooks := b.after[e.EventName()]
+	b.mu.RUnlock()
+
+	ctx = context.WithoutCancel(ctx)
+	for _, hook := range hooks {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("ERROR: %s hook panicked: %v", e.EventName(), r)
+				}
+			}()
+			hook(ctx, e)
+		}()
+	}
+}
+
+// Wait blocks until every after-hook started so far has returned.
+func (b *EventBus) Wait() {
+	b.wg.Wait()
+}
@@ -0,0 +1,143 @@
+package gordian_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	"github.com/google/uuid"
+)
+
+func TestEventBusBefore(t *testing.T) {
+	errNo := errors.New("no")
+	tests := []struct {
+		name    string
+		hooks   []error // one before-hook per entry, returning that error
+		wantErr error
+		wantRan int
+	}{
+		{name: "no hooks"},
+		{name: "allowed", hooks: []error{nil, nil}, wantRan: 2},
+		{name: "vetoed", hooks: []error{errNo}, wantErr: errNo, wantRan: 1},
+		{name: "first veto stops the chain", hooks: []error{nil, errNo, nil}, wantErr: errNo, wantRan: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := gordian.NewEventBus()
+			ran := 0
+			for _, hookErr := range tt.hooks {
+				gordian.OnBefore(bus, func(ctx context.Context, e gordian.MemberAdded) error {
+					ran++
+					return hookErr
+				})
+			}
+			gordian.OnBefore(bus, func(ctx context.Context, e gordian.RoleChanged) error {
+				t.Error("hook for another event ran")
+				return nil
+			})
+
+			err := bus.Before(context.Background(), gordian.MemberAdded{Membership: &gordian.Membership{}})
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Before = %v, want nil", err)
+			}
+			if tt.wantErr != nil && (!errors.Is(err, gordian.ErrVetoed) || !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("Before = %v, want ErrVetoed wrapping %v", err, tt.wantErr)
+			}
+			if ran != tt.wantRan {
+				t.Errorf("%d hooks ran, want %d", ran, tt.wantRan)
+			}
+		})
+	}
+}
+
+func TestEventBusAfter(t *testing.T) {
+	bus := gordian.NewEventBus()
+	var mu sync.Mutex
+	var got []uuid.UUID
+	gordian.OnAfter(bus, func(ctx context.Context, e gordian.MemberAdded) {
+		panic("hook bug")
+	})
+	gordian.OnAfter(bus, func(ctx context.Context, e gordian.MemberAdded) {
+		if ctx.Err() != nil {
+			t.Errorf("after-hook context is done: %v", ctx.Err())
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, e.Membership.UserID)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	userID := uuid.New()
+	bus.After(ctx, gordian.MemberAdded{Membership: &gordian.Membership{UserID: userID}})
+	cancel()
+	bus.Wait()
+
+	if len(got) != 1 || got[0] != userID {
+		t.Fatalf("after-hook saw %v, want [%s]", got, userID)
+	}
+}
+
+func TestServiceBeforeHookVetoesChange(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	user := env.user("user@example.com")
+
+	errBlocked := errors.New("blocked")
+	gordian.OnBefore(env.svc.Events(), func(ctx context.Context, e gordian.MemberAdded) error {
+		return errBlocked
+	})
+	var mu sync.Mutex
+	added := 0
+	gordian.OnAfter(env.svc.Events(), func(ctx context.Context, e gordian.MemberAdded) {
+		mu.Lock()
+		defer mu.Unlock()
+		added++
+	})
+
+	if _, err := env.svc.CreateMembership(env.ctx, user.ID, org.ID, "member"); !errors.Is(err, gordian.ErrVetoed) || !errors.Is(err, errBlocked) {
+		t.Fatalf("CreateMembership = %v, want ErrVetoed", err)
+	}
+	env.svc.Events().Wait()
+	if _, _, err := env.svc.GetMemberships(env.ctx, user.ID, org.ID); !errors.Is(err, gordian.ErrNotFound) {
+		t.Errorf("GetMemberships after veto = %v, want ErrNotFound", err)
+	}
+	if added != 0 {
+		t.Errorf("after-hook ran %d times for a vetoed change", added)
+	}
+}
+
+func TestServicePublishesAfterCommit(t *testing.T) {
+	env := newTestEnv(t)
+	var mu sync.Mutex
+	var names []string
+	record := func(ctx context.Context, e gordian.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		names = append(names, e.EventName())
+	}
+	gordian.OnAfter(env.svc.Events(), func(ctx context.Context, e gordian.OrganizationCreated) { record(ctx, e) })
+	gordian.OnAfter(env.svc.Events(), func(ctx context.Context, e gordian.MemberAdded) { record(ctx, e) })
+	gordian.OnAfter(env.svc.Events(), func(ctx context.Context, e gordian.RoleChanged) { record(ctx, e) })
+
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	user := env.member(org, "user@example.com", "member")
+	if _, err := env.svc.ChangeMemberRole(env.ctx, org.ID, user.ID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	env.svc.Events().Wait()
+
+	want := map[string]int{"organization.created": 1, "member.added": 2, "member.role_changed": 1}
+	got := map[string]int{}
+	for _, name := range names {
+		got[name]++
+	}
+	for name, n := range want {
+		if got[name] != n {
+			t.Errorf("%s published %d times, want %d (all: %v)", name, got[name], n, names)
+		}
+	}
+}
@@ -4,16 +4,265 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Service struct {
-	orgStore  OrganizationStore
-	userStore UserStore
-	memStore  MembershipStore
-	invStore  InvitationStore
-	emailer   Emailer
+	orgStore    OrganizationStore
+	userStore   UserStore
+	memStore    MembershipStore
+	memCache    *CachingMembershipStore
+	invStore    InvitationStore
+	emailer     Emailer
+	outboxStore OutboxStore
+	tx          Transactor
+	events      *EventBus
+
+	auditStore     AuditStore
+	trustedProxies []netip.Prefix
+	apiKeyStore    APIKeyStore
+
+	teamStore       TeamStore
+	rolePermissions map[string][]string
+	inheritedRoles  map[string]string
+	tenantResolver  TenantResolver
+
+	domainStore      DomainStore
+	txtResolver      TXTResolver
+	joinRequestStore JoinRequestStore
+	inviteLinkStore  InviteLinkStore
+
+	bulkInviteConcurrency int
+
+	usageStore  UsageStore
+	plans       map[string]Plan
+	defaultPlan Plan
+
+	billing BillingProvider
+
+	settingsStore     SettingsStore
+	settingDefs       map[string]SettingDefinition
+	featureFlags      map[string]*FeatureFlag
+	settingsTTL       time.Duration
+	settingsCacheSize int
+	settingsMu        sync.Mutex
+	settingsCache     map[uuid.UUID]cachedSettings
+	settingsEpoch     uint64 // bumped by every change, so loads in flight don't cache stale settings
+
+	webhookStore     WebhookStore
+	webhookClient    *http.Client
+	insecureWebhooks bool
+}
+
+// Option configures optional collaborators of the Service.
+type Option func(*Service)
+
+// WithTransactor makes the Service run multi-step writes inside a single transaction.
+func WithTransactor(tx Transactor) Option {
+	return func(s *Service) {
+		s.tx = tx
+	}
+}
+
+// WithMembershipCache makes GetMemberships, and so TenancyMiddleware, serve lookups from
+// cache. Pass the same store to New as the MembershipStore, so the Service's writes go
+// through it and invalidate the affected entries.
+func WithMembershipCache(cache *CachingMembershipStore) Option {
+	return func(s *Service) {
+		s.memCache = cache
+	}
+}
+
+// WithEventBus makes the Service publish lifecycle events to bus instead of a private one.
+func WithEventBus(bus *EventBus) Option {
+	return func(s *Service) {
+		s.events = bus
+	}
+}
+
+// WithWebhooks enables per-organization webhook endpoints. Events are queued in store, in the
+// transaction of the change that caused them, and sent by a WebhookDispatcher.
+func WithWebhooks(store WebhookStore) Option {
+	return func(s *Service) {
+		s.webhookStore = store
+	}
+}
+
+// WithInsecureWebhooks allows http webhook URLs and endpoints on loopback, private and
+// link-local addresses, for local development. Give WebhookDispatchers the same freedom with
+// NewWebhookClient(true). Never use it where tenants register endpoints in production.
+func WithInsecureWebhooks() Option {
+	return func(s *Service) {
+		s.insecureWebhooks = true
+		s.webhookClient = NewWebhookClient(true)
+	}
+}
+
+// WithAuditLog makes every mutating Service method record an AuditEntry in store,
+// in the same transaction as the change when a Transactor is configured.
+func WithAuditLog(store AuditStore) Option {
+	return func(s *Service) {
+		s.auditStore = store
+	}
+}
+
+// WithTrustedProxies lists the reverse proxies whose X-Forwarded-For header is believed when
+// recording the client address of a request. Without it the connection's address is used.
+func WithTrustedProxies(proxies ...netip.Prefix) Option {
+	return func(s *Service) {
+		s.trustedProxies = proxies
+	}
+}
+
+// WithAPIKeys enables organization-scoped API keys for machine clients, see APIKeyMiddleware.
+func WithAPIKeys(store APIKeyStore) Option {
+	return func(s *Service) {
+		s.apiKeyStore = store
+	}
+}
+
+// WithTeams enables teams within organizations.
+func WithTeams(store TeamStore) Option {
+	return func(s *Service) {
+		s.teamStore = store
+	}
+}
+
+// WithRolePermissions replaces DefaultRolePermissions, the permissions HasPermission grants
+// to each organization role.
+func WithRolePermissions(permissions map[string][]string) Option {
+	return func(s *Service) {
+		s.rolePermissions = permissions
+	}
+}
+
+// WithInheritedRoles replaces DefaultInheritedRoles. It maps a role held in an organization
+// to the role the user gets in all of its descendants; pass an empty map to disable inheritance.
+func WithInheritedRoles(roles map[string]string) Option {
+	return func(s *Service) {
+		s.inheritedRoles = roles
+	}
+}
+
+// WithTenantResolver changes how TenancyMiddleware determines the organization of a request,
+// e.g. to SlugTenantResolver. The default reads the X-Tenant-ID header.
+func WithTenantResolver(resolver TenantResolver) Option {
+	return func(s *Service) {
+		s.tenantResolver = resolver
+	}
+}
+
+// WithDomains enables verified email domains, see ClaimDomain.
+func WithDomains(store DomainStore) Option {
+	return func(s *Service) {
+		s.domainStore = store
+	}
+}
+
+// WithTXTResolver replaces net.DefaultResolver for domain verification, e.g. with a
+// StaticTXTResolver in tests.
+func WithTXTResolver(resolver TXTResolver) Option {
+	return func(s *Service) {
+		s.txtResolver = resolver
+	}
+}
+
+// WithJoinRequests lets users ask to join organizations, see RequestToJoin.
+func WithJoinRequests(store JoinRequestStore) Option {
+	return func(s *Service) {
+		s.joinRequestStore = store
+	}
+}
+
+// WithInviteLinks enables shareable multi-use invite links, see CreateInviteLink.
+func WithInviteLinks(store InviteLinkStore) Option {
+	return func(s *Service) {
+		s.inviteLinkStore = store
+	}
+}
+
+// WithBulkInviteConcurrency changes how many invitations BulkInvite creates and sends at once
+// outside a transaction.
+func WithBulkInviteConcurrency(n int) Option {
+	return func(s *Service) {
+		s.bulkInviteConcurrency = max(n, 1)
+	}
+}
+
+// WithPlans limits organizations to the plans listed, counting their usage with store.
+// Organizations without a plan get defaultPlan. Pair it with WithTransactor so limits hold
+// under concurrent changes.
+func WithPlans(store UsageStore, defaultPlan Plan, plans ...Plan) Option {
+	return func(s *Service) {
+		s.usageStore = store
+		s.defaultPlan = defaultPlan
+		s.plans = map[string]Plan{defaultPlan.Name: defaultPlan}
+		for _, plan := range plans {
+			s.plans[plan.Name] = plan
+		}
+	}
+}
+
+// WithBilling keeps provider informed of organizations and their seats, see BillingProvider.
+func WithBilling(provider BillingProvider) Option {
+	return func(s *Service) {
+		s.billing = provider
+	}
+}
+
+// WithSettings enables per-organization settings stored in store. The DefaultSettings are
+// always registered; settings lists the application's own.
+func WithSettings(store SettingsStore, settings ...SettingDefinition) Option {
+	return func(s *Service) {
+		s.settingsStore = store
+		s.settingDefs = map[string]SettingDefinition{}
+		for _, def := range slices.Concat(DefaultSettings, settings) {
+			s.settingDefs[def.SettingKey()] = def
+		}
+	}
+}
+
+// WithFeatureFlags registers feature flags whose rollout organizations can override. It
+// requires WithSettings.
+func WithFeatureFlags(flags ...*FeatureFlag) Option {
+	return func(s *Service) {
+		s.featureFlags = map[string]*FeatureFlag{}
+		for _, flag := range flags {
+			s.featureFlags[flag.Key] = flag
+		}
+	}
+}
+
+// WithSettingsCacheTTL changes how long GetSettings serves settings from memory, which bounds
+// how long other processes take to see a change. 0 disables the cache.
+func WithSettingsCacheTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.settingsTTL = ttl
+	}
+}
+
+// WithSettingsCacheSize changes how many organizations GetSettings keeps settings of in memory.
+// When the cache is full, an arbitrary entry makes room for the next one.
+func WithSettingsCacheSize(size int) Option {
+	return func(s *Service) {
+		s.settingsCacheSize = max(size, 1)
+	}
+}
+
+// WithOutbox makes the Service queue emails in the outbox instead of sending them inline.
+// Pair it with WithTransactor so the message is written atomically with the record it refers to.
+func WithOutbox(store OutboxStore) Option {
+	return func(s *Service) {
+		s.outboxStore = store
+	}
 }
 
 func New(
@@ -22,38 +271,218 @@ func New(
 	membershipStore MembershipStore,
 	invitationStore InvitationStore,
 	emailer Emailer,
-
+	opts ...Option,
 ) *Service {
-	return &Service{
+	s := &Service{
 		orgStore:  orgStore,
 		userStore: userStore,
 		memStore:  membershipStore,
 		invStore:  invitationStore,
 		emailer:   emailer,
+		tx:        noopTransactor{},
+		events:    NewEventBus(),
+
+		rolePermissions: DefaultRolePermissions,
+		inheritedRoles:  DefaultInheritedRoles,
+		tenantResolver:  HeaderTenantResolver("X-Tenant-ID"),
+		txtResolver:     net.DefaultResolver,
+
+		bulkInviteConcurrency: DefaultBulkInviteConcurrency,
+
+		settingsTTL:       DefaultSettingsCacheTTL,
+		settingsCacheSize: DefaultSettingsCacheSize,
+		settingsCache:     map[uuid.UUID]cachedSettings{},
+
+		webhookClient: NewWebhookClient(false),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.billing != nil {
+		s.subscribeBilling()
+	}
+	if s.settingsStore != nil {
+		OnAfter(s.events, func(ctx context.Context, e OrganizationDeleted) {
+			s.invalidateSettings(e.Organization.ID)
+		})
+	}
+	return s
+}
+
+// Events returns the bus the Service publishes to, for registering hooks.
+func (s *Service) Events() *EventBus {
+	return s.events
+}
+
+// before runs the before-hooks of every event, stopping at the first veto.
+func (s *Service) before(ctx context.Context, events ...Event) error {
+	for _, e := range events {
+		if err := s.events.Before(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// after hands every event to the asynchronous after-hooks.
+func (s *Service) after(ctx context.Context, events ...Event) {
+	for _, e := range events {
+		s.events.After(ctx, e)
 	}
 }
 
 func (s *Service) CreateOrganization(ctx context.Context, name string, ownerID uuid.UUID) (*Organization, error) {
+	return s.createOrganization(ctx, NewOrganization(ownerID, name))
+}
+
+// createOrganization stores org together with the membership of its owner.
+func (s *Service) createOrganization(ctx context.Context, org *Organization) (*Organization, error) {
 	// 1. Validation Step
-	if len(name) < 3 {
-		return nil, fmt.Errorf("invalid organization name")
+	if len(org.Name) < 3 {
+		return nil, fmt.Errorf("invalid organization name: %w", ErrInvalidArgument)
 	}
 
-	// 2. Create the organization
-	org := NewOrganization(ownerID, name)
-	if err := s.orgStore.Create(ctx, org); err != nil {
-		return nil, fmt.Errorf("failed to create organization: %w", err)
+	generated := org.Slug == ""
+	if generated {
+		slug, err := s.availableSlug(ctx, org.Name)
+		if err != nil {
+			return nil, err
+		}
+		org.Slug = slug
+	} else if err := ValidateSlug(org.Slug); err != nil {
+		return nil, err
+	} else if taken, err := s.slugTaken(ctx, org.Slug, org.ID); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, fmt.Errorf("slug %q is already taken: %w", org.Slug, ErrConflict)
 	}
 
-	// 3. Create the owner membership[the user that created the organization is the owner / has the role of owner]
+	// 2. Create the owner membership[the user that created the organization is the owner / has the role of owner]
+	ownerID := org.OwnerID
 	ownerMembership := NewMembership(ownerID, org.ID, "owner")
-	if err := s.memStore.Create(ctx, ownerMembership); err != nil {
-		return nil, fmt.Errorf("failed to create membership: %w", err)
+
+	events := []Event{OrganizationCreated{Organization: org}, MemberAdded{Membership: ownerMembership}}
+	if err := s.before(ctx, events...); err != nil {
+		return nil, err
+	}
+	err := s.storeWithSlug(ctx, org, generated, func() error {
+		return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := s.orgStore.Create(ctx, org); err != nil {
+				return fmt.Errorf("failed to create organization: %w", err)
+			}
+			if err := s.memStore.Create(ctx, ownerMembership); err != nil {
+				return fmt.Errorf("failed to create membership: %w", err)
+			}
+			if err := s.queueWebhooks(ctx, events...); err != nil {
+				return err
+			}
+			entry := AuditEntry{OrganizationID: org.ID, ActorID: ownerID, Action: AuditOrganizationCreated, TargetType: "organization", TargetID: org.ID}
+			return s.audit(ctx, entry, nil, snapshotOrganization(org))
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.after(ctx, events...)
+
+	return org, nil
+}
+
+// TransferOwnership makes newOwnerID, who must already be a member, the owner of orgID.
+// The previous owner stays in the organization as an admin.
+func (s *Service) TransferOwnership(ctx context.Context, orgID, newOwnerID uuid.UUID) (*Organization, error) {
+	org, err := s.orgStore.Get(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	if org.OwnerID == newOwnerID {
+		return org, nil
+	}
+	newOwner, err := s.memStore.GetMembership(ctx, newOwnerID, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("new owner must be a member of the organization: %w", err)
+	}
+	oldOwner, err := s.memStore.GetMembership(ctx, org.OwnerID, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get owner membership: %w", err)
+	}
+
+	before := snapshotOrganization(org)
+	events := []Event{
+		OwnershipTransferred{Organization: org, PreviousOwnerID: org.OwnerID},
+		RoleChanged{Membership: &newOwner, OldRole: newOwner.Role},
+		RoleChanged{Membership: &oldOwner, OldRole: oldOwner.Role},
+	}
+	org.OwnerID = newOwnerID
+	newOwner.Role = "owner"
+	oldOwner.Role = "admin"
+	if err := s.before(ctx, events...); err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orgStore.Update(ctx, org); err != nil {
+			return fmt.Errorf("failed to update organization: %w", err)
+		}
+		if err := s.memStore.Update(ctx, &newOwner); err != nil {
+			return fmt.Errorf("failed to update membership: %w", err)
+		}
+		if err := s.memStore.Update(ctx, &oldOwner); err != nil {
+			return fmt.Errorf("failed to update membership: %w", err)
+		}
+		if err := s.queueWebhooks(ctx, events...); err != nil {
+			return err
+		}
+		entry := AuditEntry{OrganizationID: orgID, ActorID: before.OwnerID, Action: AuditOwnershipTransferred, TargetType: "organization", TargetID: orgID}
+		return s.audit(ctx, entry, before, snapshotOrganization(org))
+	})
+	if err != nil {
+		return nil, err
 	}
+	s.after(ctx, events...)
 
 	return org, nil
 }
 
+// DeleteOrganization deletes orgID with its memberships, invitations, teams and other data.
+// Organizations with child organizations cannot be deleted; move or delete the children first.
+func (s *Service) DeleteOrganization(ctx context.Context, orgID uuid.UUID) error {
+	org, err := s.orgStore.Get(ctx, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get organization: %w", err)
+	}
+	children, err := s.orgStore.ListDescendants(ctx, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to list descendants: %w", err)
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("organization has %d child organizations: %w", len(children), ErrConflict)
+	}
+
+	events := []Event{OrganizationDeleted{Organization: org}}
+	if err := s.before(ctx, events...); err != nil {
+		return err
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Re-read under the lock SyncBilling takes, so the event carries a subscription it
+		// stored meanwhile and the after-hook cancels it.
+		current, err := s.lockedOrganization(ctx, orgID)
+		if err != nil {
+			return err
+		}
+		*org = *current
+		if err := s.orgStore.Delete(ctx, orgID); err != nil {
+			return fmt.Errorf("failed to delete organization: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditOrganizationDeleted, TargetType: "organization", TargetID: orgID}
+		return s.audit(ctx, entry, snapshotOrganization(org), nil)
+	})
+	if err != nil {
+		return err
+	}
+	s.after(ctx, events...)
+	return nil
+}
+
 func (s *Service) FindUserByEmail(ctx context.Context, email string) (User, error) {
 	user, err := s.userStore.FindByEmail(ctx, email)
 	if err != nil {
@@ -70,11 +499,48 @@ func (s *Service) GetOrganization(ctx context.Context, id uuid.UUID) (*Organizat
 	return org, nil
 }
 
-func (s *Service) CreateUser(ctx context.Context, email, name string) (*User, error) {
+// UserOption configures a user created by CreateUser.
+type UserOption func(*User)
+
+// EmailVerified marks the email address of a new user as verified, e.g. because the identity
+// provider vouched for it.
+func EmailVerified() UserOption {
+	return func(u *User) {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+}
+
+// CreateUser creates a user. If the email address is verified, the policy of its verified
+// organization domain is applied, see SetDomainPolicy.
+func (s *Service) CreateUser(ctx context.Context, email, name string, opts ...UserOption) (*User, error) {
 	user := NewUser(email, name)
+	for _, opt := range opts {
+		opt(user)
+	}
 	if err := s.userStore.Create(ctx, user); err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
+	s.applyDomainPolicy(ctx, user)
+	return user, nil
+}
+
+// ConfirmUserEmail records that userID proved they own their email address, and applies the
+// policy of its verified organization domain. Confirming an address twice does nothing.
+func (s *Service) ConfirmUserEmail(ctx context.Context, userID uuid.UUID) (*User, error) {
+	user, err := s.userStore.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.EmailVerifiedAt != nil {
+		return user, nil
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := s.userStore.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	s.applyDomainPolicy(ctx, user)
 	return user, nil
 }
 
@@ -94,14 +560,124 @@ func (s *Service) GetUser(ctx context.Context, id uuid.UUID) (*User, error) {
 // 	return userRole, nil
 // }
 
+// CreateMembership adds userID to orgID with role, which cannot be "owner"; use TransferOwnership.
 func (s *Service) CreateMembership(ctx context.Context, userID, orgID uuid.UUID, role string) (*Membership, error) {
+	if role == "" {
+		return nil, fmt.Errorf("role cannot be empty: %w", ErrInvalidArgument)
+	}
+	if role == "owner" {
+		return nil, fmt.Errorf("use TransferOwnership to change the owner: %w", ErrInvalidArgument)
+	}
 	membership := NewMembership(userID, orgID, role)
-	if err := s.memStore.Create(ctx, membership); err != nil {
-		return nil, fmt.Errorf("failed to create membership: %w", err)
+	if err := s.addMember(ctx, membership); err != nil {
+		return nil, err
 	}
 	return membership, nil
 }
 
+// addMember creates membership and records it, publishing MemberAdded.
+func (s *Service) addMember(ctx context.Context, membership *Membership) error {
+	if err := s.before(ctx, MemberAdded{Membership: membership}); err != nil {
+		return err
+	}
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.checkQuota(ctx, membership.OrganizationID, QuotaMembers); err != nil {
+			return err
+		}
+		if err := s.memStore.Create(ctx, membership); err != nil {
+			return fmt.Errorf("failed to create membership: %w", err)
+		}
+		if err := s.queueWebhooks(ctx, MemberAdded{Membership: membership}); err != nil {
+			return err
+		}
+		entry := AuditEntry{OrganizationID: membership.OrganizationID, Action: AuditMemberAdded, TargetType: "membership", TargetID: membership.ID}
+		return s.audit(ctx, entry, nil, snapshotMembership(membership))
+	})
+	if err != nil {
+		return err
+	}
+	s.after(ctx, MemberAdded{Membership: membership})
+	return nil
+}
+
+// ChangeMemberRole updates the role of userID in orgID.
+func (s *Service) ChangeMemberRole(ctx context.Context, orgID, userID uuid.UUID, role string) (*Membership, error) {
+	if role == "" {
+		return nil, fmt.Errorf("role cannot be empty: %w", ErrInvalidArgument)
+	}
+	if role == "owner" {
+		return nil, fmt.Errorf("use TransferOwnership to change the owner: %w", ErrInvalidArgument)
+	}
+	membership, err := s.memStore.GetMembership(ctx, userID, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+	if membership.Role == role {
+		return &membership, nil
+	}
+	if membership.Role == "owner" {
+		return nil, fmt.Errorf("the owner's role can only change through TransferOwnership: %w", ErrConflict)
+	}
+
+	before := snapshotMembership(&membership)
+	event := RoleChanged{Membership: &membership, OldRole: membership.Role}
+	membership.Role = role
+	if err := s.before(ctx, event); err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.memStore.Update(ctx, &membership); err != nil {
+			return fmt.Errorf("failed to update membership: %w", err)
+		}
+		if err := s.queueWebhooks(ctx, event); err != nil {
+			return err
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditRoleChanged, TargetType: "membership", TargetID: membership.ID}
+		return s.audit(ctx, entry, before, snapshotMembership(&membership))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.after(ctx, event)
+	return &membership, nil
+}
+
+// RemoveMember deletes the membership of userID in orgID. The owner cannot be removed.
+func (s *Service) RemoveMember(ctx context.Context, orgID, userID uuid.UUID) error {
+	membership, err := s.memStore.GetMembership(ctx, userID, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get membership: %w", err)
+	}
+	if membership.Role == "owner" {
+		return fmt.Errorf("the organization owner cannot be removed: %w", ErrConflict)
+	}
+
+	event := MemberRemoved{Membership: &membership}
+	if err := s.before(ctx, event); err != nil {
+		return err
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.memStore.Delete(ctx, membership.ID); err != nil {
+			return fmt.Errorf("failed to delete membership: %w", err)
+		}
+		if s.teamStore != nil {
+			if err := s.teamStore.RemoveUser(ctx, orgID, userID); err != nil {
+				return fmt.Errorf("failed to remove user from teams: %w", err)
+			}
+		}
+		if err := s.queueWebhooks(ctx, event); err != nil {
+			return err
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditMemberRemoved, TargetType: "membership", TargetID: membership.ID}
+		return s.audit(ctx, entry, snapshotMembership(&membership), nil)
+	})
+	if err != nil {
+		return err
+	}
+	s.after(ctx, event)
+	return nil
+}
+
 func (s *Service) GetMembers(ctx context.Context, userID, orgID uuid.UUID) ([]*Membership, error) {
 	// userID := ctx.Value("userID").(uuid.UUID)
 	userRole, err := s.userStore.GetUserRole(ctx, userID)
@@ -109,7 +685,7 @@ func (s *Service) GetMembers(ctx context.Context, userID, orgID uuid.UUID) ([]*M
 		return nil, fmt.Errorf("failed to get user role: %w", err)
 	}
 	if userRole != "admin" {
-		return nil, fmt.Errorf("user is not authorized")
+		return nil, fmt.Errorf("user is not authorized: %w", ErrForbidden)
 	}
 	memberships, err := s.memStore.GetMembers(ctx, orgID)
 	if err != nil {
@@ -118,19 +694,91 @@ func (s *Service) GetMembers(ctx context.Context, userID, orgID uuid.UUID) ([]*M
 	return memberships, nil
 }
 
+// ListMembers returns every membership of orgID. Callers are expected to have authorized the request.
+func (s *Service) ListMembers(ctx context.Context, orgID uuid.UUID) ([]*Membership, error) {
+	memberships, err := s.memStore.GetMembers(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memberships: %w", err)
+	}
+	return memberships, nil
+}
+
+// ListUserOrganizations returns the organizations userID belongs to.
+func (s *Service) ListUserOrganizations(ctx context.Context, userID uuid.UUID) ([]*Organization, error) {
+	memberships, err := s.memStore.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memberships: %w", err)
+	}
+	orgs := make([]*Organization, 0, len(memberships))
+	for _, membership := range memberships {
+		org, err := s.orgStore.Get(ctx, membership.OrganizationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, nil
+}
+
+// GetMemberships resolves the role of userID in orgID. Users without a membership of their own
+// inherit a role from the nearest ancestor organization they hold an inherited role in (see
+// WithInheritedRoles); membershipID is then the ID of that ancestor membership.
 func (s *Service) GetMemberships(ctx context.Context, userID uuid.UUID, orgID uuid.UUID) (membershipID uuid.UUID, role string, err error) {
-	// Look for the user's membership in the organization
-	membership, err := s.memStore.GetMembership(ctx, userID, orgID)
+	var membership Membership
+	if s.memCache != nil {
+		membership, err = s.memCache.resolve(ctx, userID, orgID, func(ctx context.Context) (Membership, error) {
+			return s.resolveMembership(ctx, userID, orgID)
+		})
+	} else {
+		membership, err = s.resolveMembership(ctx, userID, orgID)
+	}
 	if err != nil {
 		return uuid.Nil, "", fmt.Errorf("failed to get membership: %w", err)
 	}
 	return membership.ID, membership.Role, nil
 }
 
+// resolveMembership returns the membership of userID in orgID, or the inherited one from an
+// ancestor when it grants a higher role.
+func (s *Service) resolveMembership(ctx context.Context, userID, orgID uuid.UUID) (Membership, error) {
+	membership, err := s.memStore.GetMembership(ctx, userID, orgID)
+	direct := err == nil
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return Membership{}, err
+	}
+	// Skip the ancestors when none of them could grant a higher role.
+	highest := 0
+	for _, role := range s.inheritedRoles {
+		highest = max(highest, roleRanks[role])
+	}
+	if len(s.inheritedRoles) == 0 || direct && roleRanks[membership.Role] >= highest {
+		return membership, err
+	}
+	inherited, err := s.inheritedMembership(ctx, userID, orgID)
+	switch {
+	case errors.Is(err, ErrNotFound) && direct:
+		return membership, nil
+	case err != nil:
+		return Membership{}, err
+	case direct && roleRanks[membership.Role] >= roleRanks[inherited.Role]:
+		return membership, nil
+	}
+	return inherited, nil
+}
+
 func (s *Service) CreateInvitation(ctx context.Context, organizationID, inviterID uuid.UUID, inviteeEmail, role string) (*Invite, error) {
 	// 1. Validate input
 	if inviteeEmail == "" {
-		return nil, errors.New("invitee email cannot be empty")
+		return nil, fmt.Errorf("invitee email cannot be empty: %w", ErrInvalidArgument)
+	}
+	if role == "" {
+		var err error
+		if role, err = s.defaultInviteRole(ctx, organizationID); err != nil {
+			return nil, err
+		}
+	}
+	if role == "owner" {
+		return nil, fmt.Errorf("invitations cannot grant role %q, use TransferOwnership: %w", role, ErrInvalidArgument)
 	}
 
 	//2. Create Token
@@ -138,19 +786,91 @@ func (s *Service) CreateInvitation(ctx context.Context, organizationID, inviterI
 
 	// 3. Create the invitation
 	invitation := NewInvite(organizationID, inviterID, inviteeEmail, role, token)
-	if err := s.invStore.Create(ctx, invitation); err != nil {
-		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	if err := s.before(ctx, InvitationSent{Invite: invitation}); err != nil {
+		return nil, err
 	}
 
-	// I will have to research on how to securily send email to invitee with token
-	err := s.emailer.SendInvitation(ctx, invitation)
+	// 4. With an outbox the email is queued in the same transaction and delivered by the Dispatcher
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.checkQuota(ctx, organizationID, QuotaPendingInvites); err != nil {
+			return err
+		}
+		if err := s.invStore.Create(ctx, invitation); err != nil {
+			return fmt.Errorf("failed to create invitation: %w", err)
+		}
+		if s.outboxStore != nil {
+			msg, err := NewOutboxMessage(OutboxKindInvitation, invitation)
+			if err != nil {
+				return err
+			}
+			if err := s.outboxStore.Create(ctx, msg); err != nil {
+				return fmt.Errorf("failed to queue invitation email: %w", err)
+			}
+		}
+		if err := s.queueWebhooks(ctx, InvitationSent{Invite: invitation}); err != nil {
+			return err
+		}
+		entry := AuditEntry{OrganizationID: organizationID, ActorID: inviterID, Action: AuditInvitationCreated, TargetType: "invite", TargetID: invitation.ID}
+		return s.audit(ctx, entry, nil, snapshotInvite(invitation))
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to send invitation email: %w", err)
+		return nil, err
+	}
+
+	if s.outboxStore == nil {
+		// I will have to research on how to securily send email to invitee with token
+		err := s.emailer.SendInvitation(ctx, invitation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send invitation email: %w", err)
+		}
 	}
+	s.after(ctx, InvitationSent{Invite: invitation})
 
 	return invitation, nil
 }
 
+// ListInvitations returns the pending invitations of orgID.
+func (s *Service) ListInvitations(ctx context.Context, orgID uuid.UUID) ([]*Invite, error) {
+	invites, err := s.invStore.ListPending(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	return invites, nil
+}
+
+// RevokeInvitation cancels a pending invitation of orgID so its token can no longer be accepted.
+func (s *Service) RevokeInvitation(ctx context.Context, orgID, inviteID uuid.UUID) error {
+	invite, err := s.invStore.Get(ctx, inviteID)
+	if err != nil {
+		return fmt.Errorf("failed to get invitation: %w", err)
+	}
+	if invite.OrganizationID != orgID {
+		return fmt.Errorf("invitation does not belong to organization: %w", ErrNotFound)
+	}
+	if invite.AcceptedAt != nil || invite.RevokedAt != nil {
+		return fmt.Errorf("invitation is no longer pending: %w", ErrConflict)
+	}
+
+	before := snapshotInvite(invite)
+	revokedAt := time.Now()
+	invite.RevokedAt = &revokedAt
+	if err := s.before(ctx, InvitationRevoked{Invite: invite}); err != nil {
+		return err
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.invStore.Update(ctx, invite); err != nil {
+			return fmt.Errorf("failed to update invitation: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditInvitationRevoked, TargetType: "invite", TargetID: invite.ID}
+		return s.audit(ctx, entry, before, snapshotInvite(invite))
+	})
+	if err != nil {
+		return err
+	}
+	s.after(ctx, InvitationRevoked{Invite: invite})
+	return nil
+}
+
 func (s *Service) VerifyInvitation(ctx context.Context, token string) (bool, error) {
 	valid, err := s.invStore.Verify(ctx, token)
 	if err != nil {
@@ -160,9 +880,65 @@ func (s *Service) VerifyInvitation(ctx context.Context, token string) (bool, err
 	return valid, nil
 }
 
-func (s *Service) AddMemberToOrganization(ctx context.Context, orgID, userID uuid.UUID) error {
-	if err := s.memStore.Create(ctx, NewMembership(userID, orgID, "member")); err != nil {
-		return fmt.Errorf("failed to create membership: %w", err)
+// AcceptInvitation redeems the invitation token for userID, whose email must match the invitee's,
+// and adds them to the organization with the invited role.
+func (s *Service) AcceptInvitation(ctx context.Context, token string, userID uuid.UUID) (*Membership, error) {
+	invite, err := s.invStore.GetByToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
 	}
-	return nil
+	if invite.AcceptedAt != nil {
+		return nil, fmt.Errorf("invitation has already been accepted: %w", ErrConflict)
+	}
+	if invite.RevokedAt != nil {
+		return nil, fmt.Errorf("invitation has been revoked: %w", ErrConflict)
+	}
+	if time.Now().After(invite.ExpiresAt) {
+		return nil, fmt.Errorf("invitation has expired: %w", ErrConflict)
+	}
+	user, err := s.userStore.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !strings.EqualFold(user.Email, invite.InviteeEmail) {
+		return nil, fmt.Errorf("invitation was issued to a different email: %w", ErrForbidden)
+	}
+
+	membership := NewMembership(userID, invite.OrganizationID, invite.Role)
+	acceptedAt := time.Now()
+	invite.AcceptedAt = &acceptedAt
+
+	events := []Event{
+		InvitationAccepted{Invite: invite, Membership: membership, UserID: userID},
+		MemberAdded{Membership: membership},
+	}
+	if err := s.before(ctx, events...); err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.checkQuota(ctx, invite.OrganizationID, QuotaMembers); err != nil {
+			return err
+		}
+		if err := s.memStore.Create(ctx, membership); err != nil {
+			return fmt.Errorf("failed to create membership: %w", err)
+		}
+		if err := s.invStore.Update(ctx, invite); err != nil {
+			return fmt.Errorf("failed to update invitation: %w", err)
+		}
+		if err := s.queueWebhooks(ctx, events...); err != nil {
+			return err
+		}
+		entry := AuditEntry{OrganizationID: invite.OrganizationID, ActorID: userID, Action: AuditInvitationAccepted, TargetType: "membership", TargetID: membership.ID}
+		return s.audit(ctx, entry, nil, snapshotMembership(membership))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.after(ctx, events...)
+
+	return membership, nil
+}
+
+func (s *Service) AddMemberToOrganization(ctx context.Context, orgID, userID uuid.UUID) error {
+	return s.addMember(ctx, NewMembership(userID, orgID, "member"))
 }
@@ -0,0 +1,122 @@
+package gordian_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/internal/sqlitetest"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// testEmailer records the emails the Service sends.
+type testEmailer struct {
+	mu          sync.Mutex
+	invitations []*gordian.Invite
+	requests    []*gordian.JoinRequest
+	decisions   []*gordian.JoinRequest
+	err         error // returned by every send when set
+}
+
+func (e *testEmailer) SendInvitation(ctx context.Context, invite *gordian.Invite) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	e.invitations = append(e.invitations, invite)
+	return nil
+}
+
+func (e *testEmailer) SendJoinRequest(ctx context.Context, request *gordian.JoinRequest, admins []*gordian.User) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests = append(e.requests, request)
+	return e.err
+}
+
+func (e *testEmailer) SendJoinRequestDecision(ctx context.Context, request *gordian.JoinRequest, requester *gordian.User) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.decisions = append(e.decisions, request)
+	return e.err
+}
+
+func (e *testEmailer) sentInvitations() []*gordian.Invite {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*gordian.Invite(nil), e.invitations...)
+}
+
+// testEnv is a Service on a fresh SQLite database with a transactor and audit log.
+type testEnv struct {
+	t       *testing.T
+	ctx     context.Context
+	db      *gorm.DB
+	svc     *gordian.Service
+	emailer *testEmailer
+}
+
+// newTestEnv builds the Service with opts, which may use db to create their stores.
+func newTestEnv(t *testing.T, opts ...func(db *gorm.DB) gordian.Option) *testEnv {
+	t.Helper()
+	db := sqlitetest.Open(t)
+	env := &testEnv{t: t, ctx: context.Background(), db: db, emailer: &testEmailer{}}
+	options := []gordian.Option{
+		gordian.WithTransactor(gormadapter.NewTransactor(db)),
+		gordian.WithAuditLog(gormadapter.NewAuditStore(db)),
+	}
+	for _, opt := range opts {
+		options = append(options, opt(db))
+	}
+	env.svc = gordian.New(
+		gormadapter.NewOrganizationStore(db),
+		gormadapter.NewUserStore(db),
+		gormadapter.NewMembershipStore(db),
+		gormadapter.NewInviteStore(db),
+		env.emailer,
+		options...,
+	)
+	t.Cleanup(env.svc.Events().Wait)
+	return env
+}
+
+func (env *testEnv) user(email string) *gordian.User {
+	env.t.Helper()
+	user, err := env.svc.CreateUser(env.ctx, email, email)
+	if err != nil {
+		env.t.Fatalf("CreateUser(%q): %v", email, err)
+	}
+	return user
+}
+
+func (env *testEnv) org(name string, owner *gordian.User) *gordian.Organization {
+	env.t.Helper()
+	org, err := env.svc.CreateOrganization(env.ctx, name, owner.ID)
+	if err != nil {
+		env.t.Fatalf("CreateOrganization(%q): %v", name, err)
+	}
+	return org
+}
+
+// member adds a new user with role to org.
+func (env *testEnv) member(org *gordian.Organization, email, role string) *gordian.User {
+	env.t.Helper()
+	user := env.user(email)
+	if _, err := env.svc.CreateMembership(env.ctx, user.ID, org.ID, role); err != nil {
+		env.t.Fatalf("CreateMembership(%q, %q): %v", email, role, err)
+	}
+	return user
+}
+
+func (env *testEnv) role(userID, orgID uuid.UUID) string {
+	env.t.Helper()
+	_, role, err := env.svc.GetMemberships(env.ctx, userID, orgID)
+	if err != nil {
+		env.t.Fatalf("GetMemberships: %v", err)
+	}
+	return role
+}
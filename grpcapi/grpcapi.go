@@ -0,0 +1,471 @@
+// Package grpcapi serves the gordian.Service over gRPC.
+//
+// The protobuf definitions live in proto/gordian/v1 and the generated code in gordianv1.
+// Like TenancyMiddleware, it expects an authentication interceptor to run first and store the
+// caller's uuid.UUID under the "user_id" context key. Install the interceptors in this order:
+//
+//	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+//		authInterceptor,
+//		grpcapi.TenantInterceptor(gordianService, grpcapi.DefaultMethodRoles),
+//		grpcapi.RoleInterceptor(grpcapi.DefaultMethodRoles),
+//	))
+//	gordianv1.RegisterGordianServiceServer(srv, grpcapi.NewServer(gordianService))
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	"github.com/Robotech-Org/gordian/grpcapi/gordianv1"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate buf generate proto
+
+// TenantMetadataKey is the request metadata carrying the active organization ID.
+const TenantMetadataKey = "x-tenant-id"
+
+var adminRoles = []string{"owner", "admin"}
+
+// DefaultMethodRoles lists the RPCs that act on the tenant from TenantMetadataKey,
+// mapped to the roles allowed to call them. A nil list admits every member.
+// RPCs missing from the map are not tenant scoped.
+var DefaultMethodRoles = map[string][]string{
+	gordianv1.GordianService_GetOrganization_FullMethodName:   nil,
+	gordianv1.GordianService_TransferOwnership_FullMethodName: {"owner"},
+	gordianv1.GordianService_ListMembers_FullMethodName:       nil,
+	gordianv1.GordianService_AddMember_FullMethodName:         adminRoles,
+	gordianv1.GordianService_UpdateMemberRole_FullMethodName:  adminRoles,
+	gordianv1.GordianService_RemoveMember_FullMethodName:      adminRoles,
+	gordianv1.GordianService_CreateInvitation_FullMethodName:  adminRoles,
+	gordianv1.GordianService_ListInvitations_FullMethodName:   adminRoles,
+	gordianv1.GordianService_RevokeInvitation_FullMethodName:  adminRoles,
+}
+
+// TenantInterceptor resolves the organization of tenant scoped RPCs from the "x-tenant-id"
+// metadata and checks that the caller is a member of it, storing the result with
+// gordian.WithActiveTenant and the organization's settings with gordian.WithActiveSettings.
+// It is the gRPC counterpart of TenancyMiddleware.
+func TenantInterceptor(svc *gordian.Service, methodRoles map[string][]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx = gordian.WithRequestMetadata(ctx, requestMetadata(ctx))
+		if _, scoped := methodRoles[info.FullMethod]; !scoped {
+			return handler(ctx, req)
+		}
+
+		userID, ok := ctx.Value("user_id").(uuid.UUID)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "user_id not found in context")
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(TenantMetadataKey)
+		if len(values) == 0 {
+			return nil, status.Error(codes.InvalidArgument, "missing x-tenant-id metadata")
+		}
+		orgID, err := uuid.Parse(values[0])
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid x-tenant-id metadata")
+		}
+
+		membershipID, role, err := svc.GetMemberships(ctx, userID, orgID)
+		if errors.Is(err, gordian.ErrNotFound) {
+			return nil, status.Error(codes.PermissionDenied, "user is not a member of the organization")
+		}
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		ctx = gordian.WithActiveTenant(ctx, orgID, role, membershipID)
+		settings, err := svc.GetSettings(ctx, orgID)
+		switch {
+		case errors.Is(err, gordian.ErrNotConfigured):
+		case err != nil:
+			return nil, toStatus(err)
+		default:
+			ctx = gordian.WithActiveSettings(ctx, settings)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RoleInterceptor rejects tenant scoped RPCs when the active role resolved by
+// TenantInterceptor is not one of the roles listed for the method.
+func RoleInterceptor(methodRoles map[string][]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		roles, scoped := methodRoles[info.FullMethod]
+		if !scoped || len(roles) == 0 {
+			return handler(ctx, req)
+		}
+		if role := gordian.ActiveRole(ctx); !slices.Contains(roles, role) {
+			return nil, status.Errorf(codes.PermissionDenied, "role %q is not allowed to call %s", role, info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Server implements gordianv1.GordianServiceServer on top of a gordian.Service.
+type Server struct {
+	gordianv1.UnimplementedGordianServiceServer
+	svc *gordian.Service
+}
+
+func NewServer(svc *gordian.Service) *Server {
+	return &Server{svc: svc}
+}
+
+// --- Organizations ---
+
+func (s *Server) CreateOrganization(ctx context.Context, req *gordianv1.CreateOrganizationRequest) (*gordianv1.Organization, error) {
+	userID, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	org, err := s.svc.CreateOrganization(ctx, req.GetName(), userID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toOrganization(org), nil
+}
+
+func (s *Server) GetOrganization(ctx context.Context, req *gordianv1.GetOrganizationRequest) (*gordianv1.Organization, error) {
+	orgID, err := activeOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	org, err := s.svc.GetOrganization(ctx, orgID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toOrganization(org), nil
+}
+
+func (s *Server) ListMyOrganizations(ctx context.Context, req *gordianv1.ListMyOrganizationsRequest) (*gordianv1.ListOrganizationsResponse, error) {
+	userID, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orgs, err := s.svc.ListUserOrganizations(ctx, userID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &gordianv1.ListOrganizationsResponse{Organizations: mapSlice(orgs, toOrganization)}, nil
+}
+
+func (s *Server) TransferOwnership(ctx context.Context, req *gordianv1.TransferOwnershipRequest) (*gordianv1.Organization, error) {
+	orgID, err := activeOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	newOwnerID, err := parseID("new_owner_id", req.GetNewOwnerId())
+	if err != nil {
+		return nil, err
+	}
+	org, err := s.svc.TransferOwnership(ctx, orgID, newOwnerID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toOrganization(org), nil
+}
+
+// --- Users ---
+
+func (s *Server) GetUser(ctx context.Context, req *gordianv1.GetUserRequest) (*gordianv1.User, error) {
+	callerID, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseID("id", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if id != callerID {
+		shared, err := s.sharesOrganization(ctx, callerID, id)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		if !shared {
+			// Answer like for a missing user so callers cannot probe for user IDs.
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+	}
+	user, err := s.svc.GetUser(ctx, id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toUser(user), nil
+}
+
+// sharesOrganization reports whether userID is a member of one of the organizations of callerID.
+func (s *Server) sharesOrganization(ctx context.Context, callerID, userID uuid.UUID) (bool, error) {
+	orgs, err := s.svc.ListUserOrganizations(ctx, callerID)
+	if err != nil {
+		return false, err
+	}
+	for _, org := range orgs {
+		_, _, err := s.svc.GetMemberships(ctx, userID, org.ID)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, gordian.ErrNotFound) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// --- Memberships ---
+
+func (s *Server) ListMembers(ctx context.Context, req *gordianv1.ListMembersRequest) (*gordianv1.ListMembersResponse, error) {
+	orgID, err := activeOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.svc.ListMembers(ctx, orgID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &gordianv1.ListMembersResponse{Memberships: mapSlice(members, toMembership)}, nil
+}
+
+func (s *Server) AddMember(ctx context.Context, req *gordianv1.AddMemberRequest) (*gordianv1.Membership, error) {
+	orgID, err := activeOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := parseID("user_id", req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	switch req.GetRole() {
+	case "":
+		return nil, status.Error(codes.InvalidArgument, "role is required")
+	case "owner":
+		return nil, status.Error(codes.InvalidArgument, "use TransferOwnership to change the owner")
+	}
+	membership, err := s.svc.CreateMembership(ctx, userID, orgID, req.GetRole())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toMembership(membership), nil
+}
+
+func (s *Server) UpdateMemberRole(ctx context.Context, req *gordianv1.UpdateMemberRoleRequest) (*gordianv1.Membership, error) {
+	orgID, err := activeOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := parseID("user_id", req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	membership, err := s.svc.ChangeMemberRole(ctx, orgID, userID, req.GetRole())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toMembership(membership), nil
+}
+
+func (s *Server) RemoveMember(ctx context.Context, req *gordianv1.RemoveMemberRequest) (*gordianv1.RemoveMemberResponse, error) {
+	orgID, err := activeOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := parseID("user_id", req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.svc.RemoveMember(ctx, orgID, userID); err != nil {
+		return nil, toStatus(err)
+	}
+	return &gordianv1.RemoveMemberResponse{}, nil
+}
+
+// --- Invitations ---
+
+func (s *Server) CreateInvitation(ctx context.Context, req *gordianv1.CreateInvitationRequest) (*gordianv1.Invitation, error) {
+	orgID, err := activeOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	invite, err := s.svc.CreateInvitation(ctx, orgID, userID, req.GetEmail(), req.GetRole())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toInvitation(invite), nil
+}
+
+func (s *Server) ListInvitations(ctx context.Context, req *gordianv1.ListInvitationsRequest) (*gordianv1.ListInvitationsResponse, error) {
+	orgID, err := activeOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	invites, err := s.svc.ListInvitations(ctx, orgID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &gordianv1.ListInvitationsResponse{Invitations: mapSlice(invites, toInvitation)}, nil
+}
+
+func (s *Server) RevokeInvitation(ctx context.Context, req *gordianv1.RevokeInvitationRequest) (*gordianv1.RevokeInvitationResponse, error) {
+	orgID, err := activeOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inviteID, err := parseID("id", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.svc.RevokeInvitation(ctx, orgID, inviteID); err != nil {
+		return nil, toStatus(err)
+	}
+	return &gordianv1.RevokeInvitationResponse{}, nil
+}
+
+func (s *Server) AcceptInvitation(ctx context.Context, req *gordianv1.AcceptInvitationRequest) (*gordianv1.Membership, error) {
+	userID, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+	membership, err := s.svc.AcceptInvitation(ctx, req.GetToken(), userID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toMembership(membership), nil
+}
+
+// --- Helpers ---
+
+func currentUser(ctx context.Context) (uuid.UUID, error) {
+	userID, ok := ctx.Value("user_id").(uuid.UUID)
+	if !ok {
+		return uuid.Nil, status.Error(codes.Unauthenticated, "user_id not found in context")
+	}
+	return userID, nil
+}
+
+func activeOrg(ctx context.Context) (uuid.UUID, error) {
+	orgID, ok := gordian.ActiveOrgID(ctx)
+	if !ok {
+		return uuid.Nil, status.Error(codes.FailedPrecondition, "no active tenant, is TenantInterceptor installed?")
+	}
+	return orgID, nil
+}
+
+func parseID(field, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "invalid %s", field)
+	}
+	return id, nil
+}
+
+func requestMetadata(ctx context.Context) gordian.RequestMetadata {
+	var reqMD gordian.RequestMetadata
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		reqMD.IPAddress = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			reqMD.UserAgent = values[0]
+		}
+		if values := md.Get("x-request-id"); len(values) > 0 {
+			reqMD.RequestID = values[0]
+		}
+	}
+	return reqMD
+}
+
+// toStatus maps the gordian sentinel errors to gRPC status codes.
+// Unknown errors are logged and reported as Internal without details.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, gordian.ErrInvalidArgument):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, gordian.ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, gordian.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, gordian.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, gordian.ErrConflict), errors.Is(err, gordian.ErrVetoed):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, gordian.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	log.Printf("ERROR: %v", err)
+	return status.Error(codes.Internal, "internal server error")
+}
+
+func mapSlice[S any, T any](items []S, fn func(S) T) []T {
+	out := make([]T, len(items))
+	for i, item := range items {
+		out[i] = fn(item)
+	}
+	return out
+}
+
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func toOrganization(org *gordian.Organization) *gordianv1.Organization {
+	return &gordianv1.Organization{
+		Id:        org.ID.String(),
+		Name:      org.Name,
+		Slug:      org.Slug,
+		OwnerId:   org.OwnerID.String(),
+		CreatedAt: timestamppb.New(org.CreatedAt),
+	}
+}
+
+func toUser(user *gordian.User) *gordianv1.User {
+	return &gordianv1.User{
+		Id:        user.ID.String(),
+		Email:     user.Email,
+		Name:      user.Name,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+	}
+}
+
+func toMembership(m *gordian.Membership) *gordianv1.Membership {
+	return &gordianv1.Membership{
+		Id:             m.ID.String(),
+		OrganizationId: m.OrganizationID.String(),
+		UserId:         m.UserID.String(),
+		Role:           m.Role,
+		JoinedAt:       timestamppb.New(m.JoinedAt),
+	}
+}
+
+func toInvitation(invite *gordian.Invite) *gordianv1.Invitation {
+	return &gordianv1.Invitation{
+		Id:             invite.ID.String(),
+		OrganizationId: invite.OrganizationID.String(),
+		InviterId:      invite.InviterID.String(),
+		InviteeEmail:   invite.InviteeEmail,
+		Role:           invite.Role,
+		ExpiresAt:      timestamppb.New(invite.ExpiresAt),
+		CreatedAt:      timestamppb.New(invite.CreatedAt),
+		AcceptedAt:     timestamp(invite.AcceptedAt),
+		RevokedAt:      timestamp(invite.RevokedAt),
+	}
+}
@@ -0,0 +1,242 @@
+package grpcapi_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/grpcapi"
+	"github.com/Robotech-Org/gordian/grpcapi/gordianv1"
+	"github.com/Robotech-Org/gordian/internal/sqlitetest"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type noopEmailer struct{}
+
+func (noopEmailer) SendInvitation(context.Context, *gordian.Invite) error { return nil }
+func (noopEmailer) SendJoinRequest(context.Context, *gordian.JoinRequest, []*gordian.User) error {
+	return nil
+}
+func (noopEmailer) SendJoinRequestDecision(context.Context, *gordian.JoinRequest, *gordian.User) error {
+	return nil
+}
+
+// authInterceptor trusts the "x-user-id" metadata, standing in for the application's authentication.
+func authInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("x-user-id"); len(values) > 0 {
+		if id, err := uuid.Parse(values[0]); err == nil {
+			ctx = context.WithValue(ctx, "user_id", id)
+		}
+	}
+	return handler(ctx, req)
+}
+
+type fixture struct {
+	client                         gordianv1.GordianServiceClient
+	org                            *gordian.Organization
+	owner, admin, member, stranger *gordian.User
+	other                          *gordian.User // member of the organization of stranger
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	db := sqlitetest.Open(t)
+	svc := gordian.New(
+		gormadapter.NewOrganizationStore(db),
+		gormadapter.NewUserStore(db),
+		gormadapter.NewMembershipStore(db),
+		gormadapter.NewInviteStore(db),
+		noopEmailer{},
+		gordian.WithTransactor(gormadapter.NewTransactor(db)),
+	)
+	t.Cleanup(svc.Events().Wait)
+
+	user := func(email string) *gordian.User {
+		u, err := svc.CreateUser(ctx, email, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	f := &fixture{
+		owner:    user("owner@example.com"),
+		admin:    user("admin@example.com"),
+		member:   user("member@example.com"),
+		stranger: user("stranger@example.com"),
+		other:    user("other@example.com"),
+	}
+	var err error
+	if f.org, err = svc.CreateOrganization(ctx, "Acme", f.owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	otherOrg, err := svc.CreateOrganization(ctx, "Other", f.stranger.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []struct {
+		user  *gordian.User
+		orgID uuid.UUID
+		role  string
+	}{
+		{f.admin, f.org.ID, "admin"},
+		{f.member, f.org.ID, "member"},
+		{f.other, otherOrg.ID, "member"},
+	} {
+		if _, err := svc.CreateMembership(ctx, m.user.ID, m.orgID, m.role); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		authInterceptor,
+		grpcapi.TenantInterceptor(svc, grpcapi.DefaultMethodRoles),
+		grpcapi.RoleInterceptor(grpcapi.DefaultMethodRoles),
+	))
+	gordianv1.RegisterGordianServiceServer(srv, grpcapi.NewServer(svc))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	f.client = gordianv1.NewGordianServiceClient(conn)
+	return f
+}
+
+// as returns a context calling as user, with the fixture's organization as the active tenant.
+func (f *fixture) as(user *gordian.User) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(),
+		"x-user-id", user.ID.String(),
+		grpcapi.TenantMetadataKey, f.org.ID.String(),
+	)
+}
+
+func TestGetUser(t *testing.T) {
+	f := newFixture(t)
+	tests := []struct {
+		name   string
+		caller *gordian.User
+		target *gordian.User
+		want   codes.Code
+	}{
+		{name: "self", caller: f.stranger, target: f.stranger, want: codes.OK},
+		{name: "co-member", caller: f.member, target: f.admin, want: codes.OK},
+		{name: "owner sees member", caller: f.owner, target: f.member, want: codes.OK},
+		{name: "stranger", caller: f.member, target: f.stranger, want: codes.NotFound},
+		{name: "member of another organization", caller: f.owner, target: f.other, want: codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := f.client.GetUser(f.as(tt.caller), &gordianv1.GetUserRequest{Id: tt.target.ID.String()})
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("GetUser = %v, want %v", err, tt.want)
+			}
+			if err == nil && user.GetEmail() != tt.target.Email {
+				t.Errorf("GetUser returned %s, want %s", user.GetEmail(), tt.target.Email)
+			}
+		})
+	}
+
+	if _, err := f.client.GetUser(context.Background(), &gordianv1.GetUserRequest{Id: f.member.ID.String()}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("GetUser without a caller = %v, want Unauthenticated", err)
+	}
+}
+
+func TestAddMember(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller func(f *fixture) *gordian.User
+		role   string
+		want   codes.Code
+	}{
+		{name: "member", caller: func(f *fixture) *gordian.User { return f.admin }, role: "member", want: codes.OK},
+		{name: "admin", caller: func(f *fixture) *gordian.User { return f.owner }, role: "admin", want: codes.OK},
+		{name: "owner role", caller: func(f *fixture) *gordian.User { return f.owner }, role: "owner", want: codes.InvalidArgument},
+		{name: "empty role", caller: func(f *fixture) *gordian.User { return f.admin }, role: "", want: codes.InvalidArgument},
+		{name: "by member", caller: func(f *fixture) *gordian.User { return f.member }, role: "member", want: codes.PermissionDenied},
+		{name: "by stranger", caller: func(f *fixture) *gordian.User { return f.stranger }, role: "member", want: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			m, err := f.client.AddMember(f.as(tt.caller(f)), &gordianv1.AddMemberRequest{UserId: f.other.ID.String(), Role: tt.role})
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("AddMember = %v, want %v", err, tt.want)
+			}
+			if err == nil && m.GetRole() != tt.role {
+				t.Errorf("role = %q, want %q", m.GetRole(), tt.role)
+			}
+		})
+	}
+}
+
+func TestGetOrganization(t *testing.T) {
+	f := newFixture(t)
+	org, err := f.client.GetOrganization(f.as(f.member), &gordianv1.GetOrganizationRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if org.GetId() != f.org.ID.String() || org.GetSlug() == "" || org.GetSlug() != f.org.Slug {
+		t.Errorf("GetOrganization = %v, want id %s and slug %q", org, f.org.ID, f.org.Slug)
+	}
+
+	if _, err := f.client.GetOrganization(f.as(f.stranger), &gordianv1.GetOrganizationRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("GetOrganization by a stranger = %v, want PermissionDenied", err)
+	}
+}
+
+func TestTenantInterceptorLoadsSettings(t *testing.T) {
+	ctx := context.Background()
+	db := sqlitetest.Open(t)
+	svc := gordian.New(
+		gormadapter.NewOrganizationStore(db),
+		gormadapter.NewUserStore(db),
+		gormadapter.NewMembershipStore(db),
+		gormadapter.NewInviteStore(db),
+		noopEmailer{},
+		gordian.WithTransactor(gormadapter.NewTransactor(db)),
+		gordian.WithSettings(gormadapter.NewSettingsStore(db)),
+	)
+	t.Cleanup(svc.Events().Wait)
+	owner, err := svc.CreateUser(ctx, "owner@example.com", "Owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	org, err := svc.CreateOrganization(ctx, "Acme", owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.UpdateSettings(ctx, org.ID, map[string]any{gordian.SettingSSOOnly.Key: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	method := gordianv1.GordianService_GetOrganization_FullMethodName
+	ctx = context.WithValue(ctx, "user_id", owner.ID)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(grpcapi.TenantMetadataKey, org.ID.String()))
+	interceptor := grpcapi.TenantInterceptor(svc, grpcapi.DefaultMethodRoles)
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+		if !gordian.SettingSSOOnly.FromContext(ctx) {
+			t.Error("settings of the tenant were not loaded")
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
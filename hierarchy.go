@@ -0,0 +1,199 @@
+package gordian
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// MaxOrganizationDepth limits how deep organizations can be nested.
+const MaxOrganizationDepth = 16
+
+// DefaultInheritedRoles makes owners and admins of an organization admins of all of its descendants.
+var DefaultInheritedRoles = map[string]string{
+	"owner": "admin",
+	"admin": "admin",
+}
+
+// roleRanks orders roles when a user holds several in one organization, e.g. a direct and an
+// inherited one. Other roles rank below "member".
+var roleRanks = map[string]int{"member": 1, "admin": 2, "owner": 3}
+
+// CreateChildOrganization creates an organization below parentID, owned by ownerID.
+func (s *Service) CreateChildOrganization(ctx context.Context, parentID uuid.UUID, name string, ownerID uuid.UUID) (*Organization, error) {
+	ancestors, err := s.orgStore.ListAncestors(ctx, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ancestors: %w", err)
+	}
+	if len(ancestors)+1 >= MaxOrganizationDepth {
+		return nil, fmt.Errorf("organizations cannot be nested more than %d levels deep: %w", MaxOrganizationDepth, ErrInvalidArgument)
+	}
+	if _, err := s.orgStore.Get(ctx, parentID); err != nil {
+		return nil, fmt.Errorf("failed to get parent organization: %w", err)
+	}
+
+	org := NewOrganization(ownerID, name)
+	org.ParentID = &parentID
+	return s.createOrganization(ctx, org)
+}
+
+// MoveOrganization places orgID below parentID, or makes it a top-level organization when
+// parentID is nil. Its descendants move with it.
+func (s *Service) MoveOrganization(ctx context.Context, orgID uuid.UUID, parentID *uuid.UUID) (*Organization, error) {
+	if parentID != nil && *parentID == orgID {
+		return nil, fmt.Errorf("an organization cannot be its own parent: %w", ErrInvalidArgument)
+	}
+	org, err := s.orgStore.Get(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	event := OrganizationMoved{Organization: org, PreviousParentID: org.ParentID}
+	org.ParentID = parentID
+	if err := s.before(ctx, event); err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Lock both rows in a fixed order and check the move against fresh copies, so two
+		// concurrent moves cannot both pass the cycle and depth checks.
+		locks := []uuid.UUID{orgID}
+		if parentID != nil {
+			locks = append(locks, *parentID)
+			slices.SortFunc(locks, func(a, b uuid.UUID) int { return bytes.Compare(a[:], b[:]) })
+		}
+		for _, id := range locks {
+			if err := s.orgStore.Lock(ctx, id); err != nil {
+				return fmt.Errorf("failed to lock organization: %w", err)
+			}
+		}
+		current, err := s.orgStore.Get(ctx, orgID)
+		if err != nil {
+			return fmt.Errorf("failed to get organization: %w", err)
+		}
+		if parentID != nil {
+			if err := s.checkMove(ctx, orgID, *parentID); err != nil {
+				return err
+			}
+		}
+
+		before := snapshotOrganization(current)
+		event.PreviousParentID = current.ParentID
+		current.ParentID = parentID
+		event.Organization = current
+		if err := s.orgStore.Update(ctx, current); err != nil {
+			return fmt.Errorf("failed to update organization: %w", err)
+		}
+		if err := s.queueWebhooks(ctx, event); err != nil {
+			return err
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditOrganizationMoved, TargetType: "organization", TargetID: orgID}
+		return s.audit(ctx, entry, before, snapshotOrganization(current))
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Moving changes the roles inherited below orgID, which may be cached for any user.
+	if s.memCache != nil {
+		s.memCache.Purge()
+	}
+	s.after(ctx, event)
+
+	return event.Organization, nil
+}
+
+// checkMove fails unless orgID can be placed below parentID: the parent must exist, must not be
+// orgID's descendant and must leave room for orgID's subtree below MaxOrganizationDepth.
+func (s *Service) checkMove(ctx context.Context, orgID, parentID uuid.UUID) error {
+	ancestors, err := s.orgStore.ListAncestors(ctx, parentID)
+	if err != nil {
+		return fmt.Errorf("failed to list ancestors: %w", err)
+	}
+	for _, ancestor := range ancestors {
+		if ancestor.ID == orgID {
+			return fmt.Errorf("an organization cannot be moved below its own descendant: %w", ErrInvalidArgument)
+		}
+	}
+	if _, err := s.orgStore.Get(ctx, parentID); err != nil {
+		return fmt.Errorf("failed to get parent organization: %w", err)
+	}
+	depth, err := s.subtreeDepth(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if len(ancestors)+1+depth >= MaxOrganizationDepth {
+		return fmt.Errorf("organizations cannot be nested more than %d levels deep: %w", MaxOrganizationDepth, ErrInvalidArgument)
+	}
+	return nil
+}
+
+// subtreeDepth returns how many levels of descendants orgID has.
+func (s *Service) subtreeDepth(ctx context.Context, orgID uuid.UUID) (int, error) {
+	descendants, err := s.orgStore.ListDescendants(ctx, orgID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list descendants: %w", err)
+	}
+	parents := map[uuid.UUID]uuid.UUID{}
+	for _, d := range descendants {
+		parents[d.ID] = *d.ParentID
+	}
+	var depth int
+	for _, d := range descendants {
+		level := 1
+		for id := *d.ParentID; id != orgID; id = parents[id] {
+			level++
+		}
+		depth = max(depth, level)
+	}
+	return depth, nil
+}
+
+// ListOrganizationAncestors returns the parent chain of orgID, nearest first.
+func (s *Service) ListOrganizationAncestors(ctx context.Context, orgID uuid.UUID) ([]*Organization, error) {
+	orgs, err := s.orgStore.ListAncestors(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ancestors: %w", err)
+	}
+	return orgs, nil
+}
+
+// ListOrganizationDescendants returns every organization below orgID.
+func (s *Service) ListOrganizationDescendants(ctx context.Context, orgID uuid.UUID) ([]*Organization, error) {
+	orgs, err := s.orgStore.ListDescendants(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list descendants: %w", err)
+	}
+	return orgs, nil
+}
+
+// inheritedMembership returns the membership of userID in an ancestor of orgID that grants the
+// highest inherited role, the nearest one on ties, with Role set to the inherited role.
+// It fails with ErrNotFound when no ancestor grants a role.
+func (s *Service) inheritedMembership(ctx context.Context, userID, orgID uuid.UUID) (Membership, error) {
+	ancestors, err := s.orgStore.ListAncestors(ctx, orgID)
+	if err != nil {
+		return Membership{}, fmt.Errorf("failed to list ancestors: %w", err)
+	}
+	var inherited Membership
+	for _, ancestor := range ancestors {
+		membership, err := s.memStore.GetMembership(ctx, userID, ancestor.ID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return Membership{}, err
+		}
+		role, ok := s.inheritedRoles[membership.Role]
+		if ok && (inherited.Role == "" || roleRanks[role] > roleRanks[inherited.Role]) {
+			membership.Role = role
+			inherited = membership
+		}
+	}
+	if inherited.Role == "" {
+		return Membership{}, fmt.Errorf("no membership found: %w", ErrNotFound)
+	}
+	return inherited, nil
+}
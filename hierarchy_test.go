@@ -0,0 +1,202 @@
+package gordian_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/internal/sqlitetest"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestGetMembershipsInheritance(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	acme := env.org("Acme", owner)
+	child := func(parent *gordian.Organization, name string, owner *gordian.User) *gordian.Organization {
+		t.Helper()
+		org, err := env.svc.CreateChildOrganization(env.ctx, parent.ID, name, owner.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return org
+	}
+	europe := child(acme, "Europe", owner)
+	admin := env.member(acme, "admin@example.com", "admin")
+	member := env.member(acme, "member@example.com", "member")
+	regional := env.member(europe, "regional@example.com", "admin")
+	paris := child(child(europe, "France", owner), "Paris", regional)
+	if _, err := env.svc.CreateMembership(env.ctx, admin.ID, paris.ID, "member"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		userID   uuid.UUID
+		orgID    uuid.UUID
+		wantRole string // "" when ErrNotFound is expected
+	}{
+		{name: "direct", userID: admin.ID, orgID: acme.ID, wantRole: "admin"},
+		{name: "inherited from the parent", userID: admin.ID, orgID: europe.ID, wantRole: "admin"},
+		{name: "inherited above a lower direct role", userID: admin.ID, orgID: paris.ID, wantRole: "admin"},
+		{name: "direct above the inherited role", userID: regional.ID, orgID: paris.ID, wantRole: "owner"},
+		{name: "owner inherits admin", userID: owner.ID, orgID: europe.ID, wantRole: "owner"},
+		{name: "members inherit nothing", userID: member.ID, orgID: europe.ID},
+		{name: "nothing inherited upward", userID: regional.ID, orgID: acme.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, role, err := env.svc.GetMemberships(env.ctx, tt.userID, tt.orgID)
+			if tt.wantRole == "" {
+				if !errors.Is(err, gordian.ErrNotFound) {
+					t.Fatalf("GetMemberships = %q, %v, want ErrNotFound", role, err)
+				}
+				return
+			}
+			if err != nil || role != tt.wantRole {
+				t.Errorf("GetMemberships = %q, %v, want %q", role, err, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestGetMembershipsInheritanceDisabled(t *testing.T) {
+	env := newTestEnv(t, func(*gorm.DB) gordian.Option { return gordian.WithInheritedRoles(nil) })
+	owner := env.user("owner@example.com")
+	acme := env.org("Acme", owner)
+	europe, err := env.svc.CreateChildOrganization(env.ctx, acme.ID, "Europe", env.user("europe@example.com").ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := env.svc.GetMemberships(env.ctx, owner.ID, europe.ID); !errors.Is(err, gordian.ErrNotFound) {
+		t.Errorf("GetMemberships = %v, want ErrNotFound", err)
+	}
+}
+
+// failingDescendants fails ListDescendants with err.
+type failingDescendants struct {
+	gordian.OrganizationStore
+	err error
+}
+
+func (s failingDescendants) ListDescendants(ctx context.Context, orgID uuid.UUID) ([]*gordian.Organization, error) {
+	return nil, s.err
+}
+
+func TestMoveOrganization(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	acme, globex := env.org("Acme", owner), env.org("Globex", owner)
+	europe, err := env.svc.CreateChildOrganization(env.ctx, acme.ID, "Europe", owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A chain of MaxOrganizationDepth-2 organizations below Globex leaves room for one more level.
+	deepest := globex
+	for range gordian.MaxOrganizationDepth - 2 {
+		if deepest, err = env.svc.CreateChildOrganization(env.ctx, deepest.ID, "Level", owner.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		orgID    uuid.UUID
+		parentID *uuid.UUID
+		wantErr  error
+	}{
+		{name: "below itself", orgID: acme.ID, parentID: &acme.ID, wantErr: gordian.ErrInvalidArgument},
+		{name: "below its descendant", orgID: acme.ID, parentID: &europe.ID, wantErr: gordian.ErrInvalidArgument},
+		{name: "below an unknown organization", orgID: europe.ID, parentID: new(uuid.UUID), wantErr: gordian.ErrNotFound},
+		{name: "subtree too deep", orgID: acme.ID, parentID: &deepest.ID, wantErr: gordian.ErrInvalidArgument},
+		{name: "leaf at the depth limit", orgID: europe.ID, parentID: &deepest.ID},
+		{name: "to the top level", orgID: europe.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org, err := env.svc.MoveOrganization(env.ctx, tt.orgID, tt.parentID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("MoveOrganization = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.parentID == nil && org.ParentID != nil || tt.parentID != nil && (org.ParentID == nil || *org.ParentID != *tt.parentID) {
+				t.Errorf("ParentID = %v, want %v", org.ParentID, tt.parentID)
+			}
+		})
+	}
+}
+
+func TestMoveOrganizationPublishesEvent(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	acme, globex := env.org("Acme", owner), env.org("Globex", owner)
+	europe, err := env.svc.CreateChildOrganization(env.ctx, acme.ID, "Europe", owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A rename racing with the move must survive it: the move writes a fresh copy of the row.
+	gordian.OnBefore(env.svc.Events(), func(ctx context.Context, e gordian.OrganizationMoved) error {
+		_, err := env.svc.RenameOrganization(ctx, e.Organization.ID, "Europe & Africa")
+		return err
+	})
+	var mu sync.Mutex
+	var moved []gordian.OrganizationMoved
+	gordian.OnAfter(env.svc.Events(), func(ctx context.Context, e gordian.OrganizationMoved) {
+		mu.Lock()
+		defer mu.Unlock()
+		moved = append(moved, e)
+	})
+
+	org, err := env.svc.MoveOrganization(env.ctx, europe.ID, &globex.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.svc.Events().Wait()
+	if org.Name != "Europe & Africa" {
+		t.Errorf("Name = %q, want the concurrent rename kept", org.Name)
+	}
+	if len(moved) != 1 {
+		t.Fatalf("OrganizationMoved published %d times, want 1", len(moved))
+	}
+	if e := moved[0]; e.PreviousParentID == nil || *e.PreviousParentID != acme.ID || *e.Organization.ParentID != globex.ID {
+		t.Errorf("OrganizationMoved = %+v, want a move from Acme to Globex", e)
+	}
+}
+
+func TestMoveOrganizationListDescendantsFails(t *testing.T) {
+	db := sqlitetest.Open(t)
+	errStore := errors.New("store unavailable")
+	orgStore := gormadapter.NewOrganizationStore(db)
+	env := &testEnv{t: t, ctx: context.Background(), db: db, emailer: &testEmailer{}}
+	env.svc = gordian.New(
+		failingDescendants{OrganizationStore: orgStore, err: errStore},
+		gormadapter.NewUserStore(db),
+		gormadapter.NewMembershipStore(db),
+		gormadapter.NewInviteStore(db),
+		env.emailer,
+		gordian.WithTransactor(gormadapter.NewTransactor(db)),
+	)
+	t.Cleanup(env.svc.Events().Wait)
+	owner := env.user("owner@example.com")
+	acme, globex := env.org("Acme", owner), env.org("Globex", owner)
+
+	if _, err := env.svc.MoveOrganization(env.ctx, acme.ID, &globex.ID); !errors.Is(err, errStore) {
+		t.Fatalf("MoveOrganization = %v, want the store's error", err)
+	}
+	org, err := orgStore.Get(env.ctx, acme.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if org.ParentID != nil {
+		t.Errorf("Acme was moved below %s", org.ParentID)
+	}
+}
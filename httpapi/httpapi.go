@@ -0,0 +1,548 @@
+// Package httpapi exposes the gordian.Service as a JSON REST API.
+//
+// The Handler only depends on net/http, so it can be mounted under any prefix of any router:
+//
+//	mux.Handle("/api/", http.StripPrefix("/api", httpapi.New(gordianService)))
+//
+// Like TenancyMiddleware, it expects a preceding authentication middleware to store the
+// current user's uuid.UUID under the "user_id" context key.
+//
+// The API is described by the OpenAPI 3 document in openapi.json, served unauthenticated at
+// GET /openapi.json. Keep it in sync with the routes below, TestOpenAPIConformance checks every
+// response against it; the client package is generated from it.
+package httpapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	"github.com/google/uuid"
+)
+
+// OpenAPISpec is the OpenAPI 3 description of the Handler.
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+// SpecPath is where the Handler serves OpenAPISpec.
+const SpecPath = "/openapi.json"
+
+// maxBodySize caps the size of JSON request bodies.
+const maxBodySize = 1 << 20
+
+// Organization is the JSON representation of a gordian.Organization.
+type Organization struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	OwnerID   uuid.UUID `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Usage is the JSON representation of a gordian.UsageSummary.
+type Usage struct {
+	Plan           string `json:"plan"`
+	Members        Quota  `json:"members"`
+	PendingInvites Quota  `json:"pending_invites"`
+	Teams          Quota  `json:"teams"`
+}
+
+// Quota is the usage and limit of one resource. A limit of 0 means unlimited.
+type Quota struct {
+	Used  int `json:"used"`
+	Limit int `json:"limit"`
+}
+
+// Settings is the JSON representation of gordian.Settings. Features tells which feature flags
+// the current user gets.
+type Settings struct {
+	Values   map[string]any  `json:"values"`
+	Features map[string]bool `json:"features"`
+}
+
+// Membership is the JSON representation of a gordian.Membership.
+type Membership struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Role           string    `json:"role"`
+	JoinedAt       time.Time `json:"joined_at"`
+}
+
+// Invitation is the JSON representation of a gordian.Invite. The token is never exposed.
+type Invitation struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	InviterID      uuid.UUID  `json:"inviter_id"`
+	InviteeEmail   string     `json:"invitee_email"`
+	Role           string     `json:"role"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
+
+// List wraps collection responses.
+type List[T any] struct {
+	Data []T `json:"data"`
+}
+
+// Error is the body of every non-2xx response.
+type Error struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code    string `json:"code"` // e.g. "not_found", "forbidden"
+	Message string `json:"message"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type TransferOwnershipRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+type CreateInvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"` // Defaults to the organization's default_invite_role setting, "member" unless changed
+}
+
+// UpdateSettingsRequest changes the listed settings; null resets a setting to its default.
+type UpdateSettingsRequest struct {
+	Values map[string]json.RawMessage `json:"values"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+// Handler serves the tenancy API.
+type Handler struct {
+	svc *gordian.Service
+	mux *http.ServeMux
+
+	// AdminRoles may manage members and invitations. Defaults to "owner" and "admin".
+	AdminRoles []string
+}
+
+func New(svc *gordian.Service) *Handler {
+	h := &Handler{
+		svc:        svc,
+		mux:        http.NewServeMux(),
+		AdminRoles: []string{"owner", "admin"},
+	}
+
+	h.mux.HandleFunc("GET /me/organizations", h.listMyOrganizations)
+	h.mux.HandleFunc("POST /organizations", h.createOrganization)
+	h.mux.HandleFunc("GET /organizations/{orgID}", h.getOrganization)
+	h.mux.HandleFunc("DELETE /organizations/{orgID}", h.deleteOrganization)
+	h.mux.HandleFunc("POST /organizations/{orgID}/transfer-ownership", h.transferOwnership)
+	h.mux.HandleFunc("GET /organizations/{orgID}/usage", h.getUsage)
+	h.mux.HandleFunc("GET /organizations/{orgID}/settings", h.getSettings)
+	h.mux.HandleFunc("PATCH /organizations/{orgID}/settings", h.updateSettings)
+	h.mux.HandleFunc("GET /organizations/{orgID}/members", h.listMembers)
+	h.mux.HandleFunc("PATCH /organizations/{orgID}/members/{userID}", h.updateMember)
+	h.mux.HandleFunc("DELETE /organizations/{orgID}/members/{userID}", h.removeMember)
+	h.mux.HandleFunc("GET /organizations/{orgID}/invitations", h.listInvitations)
+	h.mux.HandleFunc("POST /organizations/{orgID}/invitations", h.createInvitation)
+	h.mux.HandleFunc("DELETE /organizations/{orgID}/invitations/{inviteID}", h.revokeInvitation)
+	h.mux.HandleFunc("POST /invitations/accept", h.acceptInvitation)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == SpecPath && r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(OpenAPISpec)
+		return
+	}
+	if _, ok := r.Context().Value("user_id").(uuid.UUID); !ok {
+		writeError(w, http.StatusUnauthorized, "unauthenticated", "user_id not found in context")
+		return
+	}
+	ctx := gordian.WithRequestMetadata(r.Context(), h.svc.RequestMetadata(r))
+	h.mux.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// --- Organizations ---
+
+func (h *Handler) listMyOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := h.svc.ListUserOrganizations(r.Context(), currentUser(r))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, List[Organization]{Data: mapSlice(orgs, toOrganization)})
+}
+
+func (h *Handler) createOrganization(w http.ResponseWriter, r *http.Request) {
+	var req CreateOrganizationRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	org, err := h.svc.CreateOrganization(r.Context(), req.Name, currentUser(r))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toOrganization(org))
+}
+
+func (h *Handler) getOrganization(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	org, err := h.svc.GetOrganization(r.Context(), orgID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toOrganization(org))
+}
+
+func (h *Handler) deleteOrganization(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := h.authorize(w, r, "owner")
+	if !ok {
+		return
+	}
+	if err := h.svc.DeleteOrganization(r.Context(), orgID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) transferOwnership(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := h.authorize(w, r, "owner")
+	if !ok {
+		return
+	}
+	var req TransferOwnershipRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	org, err := h.svc.TransferOwnership(r.Context(), orgID, req.UserID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toOrganization(org))
+}
+
+func (h *Handler) getUsage(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := h.authorize(w, r, h.AdminRoles...)
+	if !ok {
+		return
+	}
+	summary, err := h.svc.GetUsage(r.Context(), orgID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toUsage(summary))
+}
+
+func (h *Handler) getSettings(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	settings, err := h.svc.GetSettings(r.Context(), orgID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toSettings(settings, currentUser(r)))
+}
+
+func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := h.authorize(w, r, h.AdminRoles...)
+	if !ok {
+		return
+	}
+	var req UpdateSettingsRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	values := make(map[string]any, len(req.Values))
+	for key, value := range req.Values {
+		values[key] = value
+	}
+	settings, err := h.svc.UpdateSettings(r.Context(), orgID, values)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toSettings(settings, currentUser(r)))
+}
+
+// --- Members ---
+
+func (h *Handler) listMembers(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	members, err := h.svc.ListMembers(r.Context(), orgID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, List[Membership]{Data: mapSlice(members, toMembership)})
+}
+
+func (h *Handler) updateMember(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := h.authorize(w, r, h.AdminRoles...)
+	if !ok {
+		return
+	}
+	userID, ok := pathUUID(w, r, "userID")
+	if !ok {
+		return
+	}
+	var req UpdateMemberRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	membership, err := h.svc.ChangeMemberRole(r.Context(), orgID, userID, req.Role)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toMembership(membership))
+}
+
+// removeMember lets admins remove anyone and every member remove themselves.
+func (h *Handler) removeMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathUUID(w, r, "userID")
+	if !ok {
+		return
+	}
+	roles := h.AdminRoles
+	if userID == currentUser(r) {
+		roles = nil
+	}
+	orgID, ok := h.authorize(w, r, roles...)
+	if !ok {
+		return
+	}
+	if err := h.svc.RemoveMember(r.Context(), orgID, userID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- Invitations ---
+
+func (h *Handler) listInvitations(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := h.authorize(w, r, h.AdminRoles...)
+	if !ok {
+		return
+	}
+	invites, err := h.svc.ListInvitations(r.Context(), orgID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, List[Invitation]{Data: mapSlice(invites, toInvitation)})
+}
+
+func (h *Handler) createInvitation(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := h.authorize(w, r, h.AdminRoles...)
+	if !ok {
+		return
+	}
+	var req CreateInvitationRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	invite, err := h.svc.CreateInvitation(r.Context(), orgID, currentUser(r), req.Email, req.Role)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toInvitation(invite))
+}
+
+func (h *Handler) revokeInvitation(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := h.authorize(w, r, h.AdminRoles...)
+	if !ok {
+		return
+	}
+	inviteID, ok := pathUUID(w, r, "inviteID")
+	if !ok {
+		return
+	}
+	if err := h.svc.RevokeInvitation(r.Context(), orgID, inviteID); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// acceptInvitation redeems the token of an invitation link, given either as the
+// "token" query parameter of the emailed link or in the JSON body.
+func (h *Handler) acceptInvitation(w http.ResponseWriter, r *http.Request) {
+	req := AcceptInvitationRequest{Token: r.URL.Query().Get("token")}
+	if req.Token == "" && !decode(w, r, &req) {
+		return
+	}
+	if req.Token == "" {
+		writeError(w, http.StatusBadRequest, "invalid_argument", "token is required")
+		return
+	}
+	membership, err := h.svc.AcceptInvitation(r.Context(), req.Token, currentUser(r))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toMembership(membership))
+}
+
+// --- Helpers ---
+
+// authorize parses the {orgID} path value and checks that the current user is a member of it,
+// holding one of roles when any are given. It writes the error response and returns false otherwise.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, roles ...string) (uuid.UUID, bool) {
+	orgID, ok := pathUUID(w, r, "orgID")
+	if !ok {
+		return uuid.Nil, false
+	}
+	_, role, err := h.svc.GetMemberships(r.Context(), currentUser(r), orgID)
+	if errors.Is(err, gordian.ErrNotFound) {
+		writeError(w, http.StatusForbidden, "forbidden", "user is not a member of the organization")
+		return uuid.Nil, false
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return uuid.Nil, false
+	}
+	if len(roles) > 0 && !slices.Contains(roles, role) {
+		writeError(w, http.StatusForbidden, "forbidden", fmt.Sprintf("role %q is not allowed to perform this action", role))
+		return uuid.Nil, false
+	}
+	return orgID, true
+}
+
+func currentUser(r *http.Request) uuid.UUID {
+	userID, _ := r.Context().Value("user_id").(uuid.UUID)
+	return userID
+}
+
+func pathUUID(w http.ResponseWriter, r *http.Request, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue(name))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_argument", fmt.Sprintf("invalid %s", name))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func decode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_argument", fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("ERROR: failed to encode response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, Error{Error: ErrorDetail{Code: code, Message: message}})
+}
+
+// writeServiceError maps the gordian sentinel errors to HTTP status codes.
+// Unknown errors are logged and reported as a 500 without details.
+func writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gordian.ErrInvalidArgument):
+		writeError(w, http.StatusBadRequest, "invalid_argument", err.Error())
+	case errors.Is(err, gordian.ErrUnauthenticated):
+		writeError(w, http.StatusUnauthorized, "unauthenticated", err.Error())
+	case errors.Is(err, gordian.ErrForbidden):
+		writeError(w, http.StatusForbidden, "forbidden", err.Error())
+	case errors.Is(err, gordian.ErrNotFound):
+		writeError(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, gordian.ErrConflict):
+		writeError(w, http.StatusConflict, "conflict", err.Error())
+	case errors.Is(err, gordian.ErrVetoed):
+		writeError(w, http.StatusUnprocessableEntity, "vetoed", err.Error())
+	case errors.Is(err, gordian.ErrQuotaExceeded):
+		writeError(w, http.StatusPaymentRequired, "quota_exceeded", err.Error())
+	default:
+		log.Printf("ERROR: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "internal server error")
+	}
+}
+
+func mapSlice[S any, T any](items []S, fn func(S) T) []T {
+	out := make([]T, len(items))
+	for i, item := range items {
+		out[i] = fn(item)
+	}
+	return out
+}
+
+func toOrganization(org *gordian.Organization) Organization {
+	return Organization{ID: org.ID, Name: org.Name, Slug: org.Slug, OwnerID: org.OwnerID, CreatedAt: org.CreatedAt}
+}
+
+func toUsage(summary *gordian.UsageSummary) Usage {
+	quota := func(resource gordian.QuotaResource) Quota {
+		return Quota{Used: summary.Usage.Of(resource), Limit: summary.Plan.Limit(resource)}
+	}
+	return Usage{
+		Plan:           summary.Plan.Name,
+		Members:        quota(gordian.QuotaMembers),
+		PendingInvites: quota(gordian.QuotaPendingInvites),
+		Teams:          quota(gordian.QuotaTeams),
+	}
+}
+
+func toSettings(settings *gordian.Settings, userID uuid.UUID) Settings {
+	return Settings{Values: settings.Values(), Features: settings.Features(userID)}
+}
+
+func toMembership(m *gordian.Membership) Membership {
+	return Membership{ID: m.ID, OrganizationID: m.OrganizationID, UserID: m.UserID, Role: m.Role, JoinedAt: m.JoinedAt}
+}
+
+func toInvitation(invite *gordian.Invite) Invitation {
+	return Invitation{
+		ID:             invite.ID,
+		OrganizationID: invite.OrganizationID,
+		InviterID:      invite.InviterID,
+		InviteeEmail:   invite.InviteeEmail,
+		Role:           invite.Role,
+		ExpiresAt:      invite.ExpiresAt,
+		CreatedAt:      invite.CreatedAt,
+		AcceptedAt:     invite.AcceptedAt,
+		RevokedAt:      invite.RevokedAt,
+	}
+}
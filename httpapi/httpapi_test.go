@@ -0,0 +1,233 @@
+package httpapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/httpapi"
+	"github.com/Robotech-Org/gordian/internal/sqlitetest"
+	"github.com/google/uuid"
+)
+
+type nopEmailer struct{}
+
+func (nopEmailer) SendInvitation(context.Context, *gordian.Invite) error { return nil }
+
+// fixture is an organization with an owner, an admin, a member and a pending invitation,
+// plus a user outside of it.
+type fixture struct {
+	t       *testing.T
+	handler http.Handler
+	svc     *gordian.Service
+
+	org                            *gordian.Organization
+	owner, admin, member, outsider *gordian.User
+	invite                         *gordian.Invite
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	db := sqlitetest.Open(t)
+	svc := gordian.New(
+		gormadapter.NewOrganizationStore(db),
+		gormadapter.NewUserStore(db),
+		gormadapter.NewMembershipStore(db),
+		gormadapter.NewInviteStore(db),
+		nopEmailer{},
+		gordian.WithTransactor(gormadapter.NewTransactor(db)),
+		gordian.WithPlans(gormadapter.NewUsageStore(db), gordian.Plan{Name: "free", MaxMembers: 10, MaxPendingInvites: 3}),
+		gordian.WithSettings(gormadapter.NewSettingsStore(db)),
+	)
+	t.Cleanup(svc.Events().Wait)
+	f := &fixture{t: t, handler: httpapi.New(svc), svc: svc}
+
+	user := func(email string) *gordian.User {
+		u, err := svc.CreateUser(ctx, email, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	f.owner, f.admin, f.member, f.outsider = user("owner@example.com"), user("admin@example.com"), user("member@example.com"), user("outsider@example.com")
+	var err error
+	if f.org, err = svc.CreateOrganization(ctx, "Acme", f.owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []struct {
+		user *gordian.User
+		role string
+	}{{f.admin, "admin"}, {f.member, "member"}} {
+		if _, err := svc.CreateMembership(ctx, m.user.ID, f.org.ID, m.role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.invite, err = svc.CreateInvitation(ctx, f.org.ID, f.owner.ID, "invitee@example.com", "member"); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// fillInviteQuota fills the pending invitation quota of the fixture's plan.
+func (f *fixture) fillInviteQuota() {
+	f.t.Helper()
+	for _, email := range []string{"second@example.com", "third@example.com"} {
+		if _, err := f.svc.CreateInvitation(context.Background(), f.org.ID, f.owner.ID, email, "member"); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+}
+
+// do sends the request as user, or unauthenticated when user is nil.
+func (f *fixture) do(user *gordian.User, method, path, body string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), "user_id", user.ID))
+	}
+	rec := httptest.NewRecorder()
+	f.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// routeTest is one request against a fresh fixture. path and body may reference the fixture
+// with the placeholders {org}, {owner}, {admin}, {member}, {invite} and {token}.
+type routeTest struct {
+	name     string
+	as       func(f *fixture) *gordian.User
+	method   string
+	path     string
+	body     string
+	want     int
+	wantCode string           // error code of non-2xx responses
+	setup    func(f *fixture) // optional changes to the fixture before the request
+}
+
+var (
+	asOwner    = func(f *fixture) *gordian.User { return f.owner }
+	asAdmin    = func(f *fixture) *gordian.User { return f.admin }
+	asMember   = func(f *fixture) *gordian.User { return f.member }
+	asOutsider = func(f *fixture) *gordian.User { return f.outsider }
+	anonymous  = func(f *fixture) *gordian.User { return nil }
+)
+
+var routeTests = []routeTest{
+	{name: "spec is public", as: anonymous, method: "GET", path: "/openapi.json", want: 200},
+	{name: "unauthenticated", as: anonymous, method: "GET", path: "/me/organizations", want: 401, wantCode: "unauthenticated"},
+	{name: "list my organizations", as: asMember, method: "GET", path: "/me/organizations", want: 200},
+	{name: "create organization", as: asOutsider, method: "POST", path: "/organizations", body: `{"name":"Globex"}`, want: 201},
+	{name: "create organization with short name", as: asOutsider, method: "POST", path: "/organizations", body: `{"name":"G"}`, want: 400, wantCode: "invalid_argument"},
+	{name: "unknown body field", as: asOutsider, method: "POST", path: "/organizations", body: `{"name":"Globex","plan":"pro"}`, want: 400, wantCode: "invalid_argument"},
+	{name: "get organization", as: asMember, method: "GET", path: "/organizations/{org}", want: 200},
+	{name: "get organization as outsider", as: asOutsider, method: "GET", path: "/organizations/{org}", want: 403, wantCode: "forbidden"},
+	{name: "malformed organization id", as: asMember, method: "GET", path: "/organizations/acme", want: 400, wantCode: "invalid_argument"},
+	{name: "delete organization as admin", as: asAdmin, method: "DELETE", path: "/organizations/{org}", want: 403, wantCode: "forbidden"},
+	{name: "delete organization", as: asOwner, method: "DELETE", path: "/organizations/{org}", want: 204},
+	{name: "transfer ownership as admin", as: asAdmin, method: "POST", path: "/organizations/{org}/transfer-ownership", body: `{"user_id":"{admin}"}`, want: 403, wantCode: "forbidden"},
+	{name: "transfer ownership", as: asOwner, method: "POST", path: "/organizations/{org}/transfer-ownership", body: `{"user_id":"{admin}"}`, want: 200},
+	{name: "transfer ownership to outsider", as: asOwner, method: "POST", path: "/organizations/{org}/transfer-ownership", body: `{"user_id":"` + uuid.NewString() + `"}`, want: 404, wantCode: "not_found"},
+	{name: "usage", as: asAdmin, method: "GET", path: "/organizations/{org}/usage", want: 200},
+	{name: "usage as member", as: asMember, method: "GET", path: "/organizations/{org}/usage", want: 403, wantCode: "forbidden"},
+	{name: "settings", as: asMember, method: "GET", path: "/organizations/{org}/settings", want: 200},
+	{name: "update settings", as: asAdmin, method: "PATCH", path: "/organizations/{org}/settings", body: `{"values":{"default_invite_role":"admin"}}`, want: 200},
+	{name: "update unknown setting", as: asAdmin, method: "PATCH", path: "/organizations/{org}/settings", body: `{"values":{"color":"red"}}`, want: 400, wantCode: "invalid_argument"},
+	{name: "update settings as member", as: asMember, method: "PATCH", path: "/organizations/{org}/settings", body: `{"values":{}}`, want: 403, wantCode: "forbidden"},
+	{name: "list members", as: asMember, method: "GET", path: "/organizations/{org}/members", want: 200},
+	{name: "change role", as: asAdmin, method: "PATCH", path: "/organizations/{org}/members/{member}", body: `{"role":"admin"}`, want: 200},
+	{name: "change role as member", as: asMember, method: "PATCH", path: "/organizations/{org}/members/{admin}", body: `{"role":"member"}`, want: 403, wantCode: "forbidden"},
+	{name: "change role to owner", as: asAdmin, method: "PATCH", path: "/organizations/{org}/members/{member}", body: `{"role":"owner"}`, want: 400, wantCode: "invalid_argument"},
+	{name: "change owner role", as: asAdmin, method: "PATCH", path: "/organizations/{org}/members/{owner}", body: `{"role":"member"}`, want: 409, wantCode: "conflict"},
+	{name: "remove member", as: asAdmin, method: "DELETE", path: "/organizations/{org}/members/{member}", want: 204},
+	{name: "leave organization", as: asMember, method: "DELETE", path: "/organizations/{org}/members/{member}", want: 204},
+	{name: "remove other member as member", as: asMember, method: "DELETE", path: "/organizations/{org}/members/{admin}", want: 403, wantCode: "forbidden"},
+	{name: "remove owner", as: asAdmin, method: "DELETE", path: "/organizations/{org}/members/{owner}", want: 409, wantCode: "conflict"},
+	{name: "list invitations", as: asAdmin, method: "GET", path: "/organizations/{org}/invitations", want: 200},
+	{name: "list invitations as member", as: asMember, method: "GET", path: "/organizations/{org}/invitations", want: 403, wantCode: "forbidden"},
+	{name: "invite", as: asAdmin, method: "POST", path: "/organizations/{org}/invitations", body: `{"email":"new@example.com","role":"member"}`, want: 201},
+	{name: "invite with default role", as: asAdmin, method: "POST", path: "/organizations/{org}/invitations", body: `{"email":"new@example.com"}`, want: 201},
+	{name: "invite as owner role", as: asAdmin, method: "POST", path: "/organizations/{org}/invitations", body: `{"email":"new@example.com","role":"owner"}`, want: 400, wantCode: "invalid_argument"},
+	{name: "invite beyond quota", as: asAdmin, method: "POST", path: "/organizations/{org}/invitations", body: `{"email":"new@example.com"}`, want: 402, wantCode: "quota_exceeded",
+		setup: func(f *fixture) { f.fillInviteQuota() }},
+	{name: "invite without email", as: asAdmin, method: "POST", path: "/organizations/{org}/invitations", body: `{"role":"member"}`, want: 400, wantCode: "invalid_argument"},
+	{name: "revoke invitation", as: asAdmin, method: "DELETE", path: "/organizations/{org}/invitations/{invite}", want: 204},
+	{name: "revoke unknown invitation", as: asAdmin, method: "DELETE", path: "/organizations/{org}/invitations/" + uuid.NewString(), want: 404, wantCode: "not_found"},
+	{name: "accept invitation with wrong email", as: asOutsider, method: "POST", path: "/invitations/accept", body: `{"token":"{token}"}`, want: 403, wantCode: "forbidden"},
+	{name: "accept unknown invitation", as: asOutsider, method: "POST", path: "/invitations/accept?token=" + uuid.NewString(), want: 404, wantCode: "not_found"},
+	{name: "accept without token", as: asOutsider, method: "POST", path: "/invitations/accept", body: `{}`, want: 400, wantCode: "invalid_argument"},
+}
+
+func (f *fixture) expand(s string) string {
+	return strings.NewReplacer(
+		"{org}", f.org.ID.String(),
+		"{owner}", f.owner.ID.String(),
+		"{admin}", f.admin.ID.String(),
+		"{member}", f.member.ID.String(),
+		"{invite}", f.invite.ID.String(),
+		"{token}", f.invite.Token,
+	).Replace(s)
+}
+
+func TestHandler(t *testing.T) {
+	for _, tt := range routeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			rec := f.do(tt.as(f), tt.method, f.expand(tt.path), f.expand(tt.body))
+			if rec.Code != tt.want {
+				t.Fatalf("%s %s = %d %s, want %d", tt.method, tt.path, rec.Code, rec.Body, tt.want)
+			}
+			if tt.wantCode != "" {
+				var body httpapi.Error
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Code != tt.wantCode {
+					t.Errorf("error body = %s, want code %q", rec.Body, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestInvitationCannotCreateSecondOwner(t *testing.T) {
+	f := newFixture(t)
+	rec := f.do(f.admin, "POST", f.expand("/organizations/{org}/invitations"), `{"email":"outsider@example.com","role":"owner"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("owner invitation = %d %s, want 400", rec.Code, rec.Body)
+	}
+	invites, err := f.svc.ListInvitations(context.Background(), f.org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, invite := range invites {
+		if invite.Role == "owner" {
+			t.Errorf("an invitation with role owner was stored")
+		}
+	}
+}
+
+func TestHandlerNeverExposesInvitationToken(t *testing.T) {
+	f := newFixture(t)
+	for _, path := range []string{"/organizations/{org}/invitations"} {
+		rec := f.do(f.admin, "GET", f.expand(path), "")
+		if strings.Contains(rec.Body.String(), f.invite.Token) {
+			t.Errorf("GET %s leaks the invitation token: %s", path, rec.Body)
+		}
+	}
+	rec := f.do(f.admin, "POST", f.expand("/organizations/{org}/invitations"), `{"email":"new@example.com"}`)
+	if strings.Contains(rec.Body.String(), `"token"`) {
+		t.Errorf("POST invitations returns the token: %s", rec.Body)
+	}
+}
@@ -0,0 +1,101 @@
+package httpapi_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Robotech-Org/gordian/httpapi"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+func loadSpec(t *testing.T) (*openapi3.T, routers.Router) {
+	t.Helper()
+	spec, err := openapi3.NewLoader().LoadFromData(httpapi.OpenAPISpec)
+	if err != nil {
+		t.Fatalf("failed to load openapi.json: %v", err)
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		t.Fatalf("openapi.json is invalid: %v", err)
+	}
+	router, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec, router
+}
+
+// TestOpenAPIConformance sends every request of routeTests through the Handler and validates
+// the response, including its status code, against openapi.json. Requests the tests expect to
+// succeed must be valid too.
+func TestOpenAPIConformance(t *testing.T) {
+	spec, router := loadSpec(t)
+	covered := map[string]bool{}
+
+	for _, tt := range routeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+			path, body := f.expand(tt.path), f.expand(tt.body)
+			if path == httpapi.SpecPath {
+				t.Skip("the spec does not describe itself")
+			}
+
+			req := httptest.NewRequest(tt.method, "http://localhost"+path, strings.NewReader(body))
+			if body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			route, pathParams, err := router.FindRoute(req)
+			if err != nil {
+				t.Fatalf("%s %s is not in openapi.json: %v", tt.method, tt.path, err)
+			}
+			covered[tt.method+" "+route.Path] = true
+			input := &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+			}
+			if err := openapi3filter.ValidateRequest(context.Background(), input); err != nil && tt.want < 300 {
+				t.Errorf("request does not match openapi.json: %v", err)
+			}
+
+			// The validator consumed the body, send a fresh copy.
+			rec := f.do(tt.as(f), tt.method, path, body)
+			err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 rec.Code,
+				Header:                 rec.Header(),
+				Body:                   io.NopCloser(rec.Body),
+				Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+			})
+			if err != nil {
+				t.Errorf("%s %s = %d does not match openapi.json: %v", tt.method, tt.path, rec.Code, err)
+			}
+		})
+	}
+
+	for path, item := range spec.Paths.Map() {
+		for method := range item.Operations() {
+			if !covered[method+" "+path] {
+				t.Errorf("%s %s is not covered by routeTests", method, path)
+			}
+		}
+	}
+}
+
+func TestOpenAPISpecServed(t *testing.T) {
+	f := newFixture(t)
+	rec := f.do(nil, http.MethodGet, httpapi.SpecPath, "")
+	if rec.Code != http.StatusOK || rec.Body.String() != string(httpapi.OpenAPISpec) {
+		t.Fatalf("GET %s = %d, want the embedded spec", httpapi.SpecPath, rec.Code)
+	}
+}
@@ -0,0 +1,41 @@
+// Package sqlitetest opens migrated SQLite databases for the tests of this module.
+package sqlitetest
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/Robotech-Org/gordian/migrations"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns a GORM connection to a new database file with every migration applied. Foreign
+// keys are enforced, and transactions take the write lock when they begin, so concurrent writers
+// queue up instead of failing. The database is closed when the test ends.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "gordian.db") +
+		"?_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	m, err := migrations.New(sqlDB, migrations.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatalf("failed to migrate sqlite: %v", err)
+	}
+	db, err := gorm.Open(sqlite.Dialector{Conn: sqlDB}, &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatalf("failed to open gorm: %v", err)
+	}
+	return db
+}
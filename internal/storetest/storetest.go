@@ -0,0 +1,1066 @@
+// Package storetest checks that implementations of the gordian store interfaces behave as the
+// interfaces document. Adapters run it against their own database:
+//
+//	storetest.Run(t, func(t *testing.T) storetest.Stores { return newStores(sqlitetest.Open(t)) })
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	"github.com/google/uuid"
+)
+
+// Stores is one implementation of every store, sharing a database.
+type Stores struct {
+	Transactor    gordian.Transactor
+	Organizations gordian.OrganizationStore
+	Users         gordian.UserStore
+	Memberships   gordian.MembershipStore
+	Invites       gordian.InvitationStore
+	Outbox        gordian.OutboxStore
+	Webhooks      gordian.WebhookStore
+	Audit         gordian.AuditStore
+	APIKeys       gordian.APIKeyStore
+	Teams         gordian.TeamStore
+	Domains       gordian.DomainStore
+	JoinRequests  gordian.JoinRequestStore
+	InviteLinks   gordian.InviteLinkStore
+	Usage         gordian.UsageStore
+	Settings      gordian.SettingsStore
+}
+
+// Run tests every store. open is called once per test and must return stores on a new,
+// migrated database.
+func Run(t *testing.T, open func(t *testing.T) Stores) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, env *env)
+	}{
+		{"Transactor", testTransactor},
+		{"Organizations", testOrganizations},
+		{"OrganizationHierarchy", testOrganizationHierarchy},
+		{"Users", testUsers},
+		{"Memberships", testMemberships},
+		{"Invites", testInvites},
+		{"Outbox", testOutbox},
+		{"Webhooks", testWebhooks},
+		{"Audit", testAudit},
+		{"APIKeys", testAPIKeys},
+		{"Teams", testTeams},
+		{"Domains", testDomains},
+		{"JoinRequests", testJoinRequests},
+		{"InviteLinks", testInviteLinks},
+		{"Usage", testUsage},
+		{"Settings", testSettings},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newEnv(t, open(t)))
+		})
+	}
+}
+
+// env holds the stores of a test with a user owning an organization.
+type env struct {
+	Stores
+	ctx   context.Context
+	owner *gordian.User
+	org   *gordian.Organization
+	now   time.Time
+}
+
+func newEnv(t *testing.T, stores Stores) *env {
+	t.Helper()
+	e := &env{Stores: stores, ctx: context.Background(), now: time.Now().UTC().Truncate(time.Second)}
+	e.owner = e.user(t, "owner@example.com")
+	e.org = e.organization(t, "Acme", nil)
+	return e
+}
+
+func (e *env) user(t *testing.T, email string) *gordian.User {
+	t.Helper()
+	user := gordian.NewUser(email, email)
+	user.CreatedAt = e.now
+	if err := e.Users.Create(e.ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return user
+}
+
+// organization creates an organization owned by e.owner, with its owner membership.
+func (e *env) organization(t *testing.T, name string, parent *gordian.Organization) *gordian.Organization {
+	t.Helper()
+	org := gordian.NewOrganization(e.owner.ID, name)
+	org.Slug = "org-" + org.ID.String()[:8]
+	org.CreatedAt = e.now
+	if parent != nil {
+		org.ParentID = &parent.ID
+	}
+	if err := e.Organizations.Create(e.ctx, org); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+	e.member(t, e.owner, org, "owner")
+	return org
+}
+
+func (e *env) member(t *testing.T, user *gordian.User, org *gordian.Organization, role string) *gordian.Membership {
+	t.Helper()
+	m := gordian.NewMembership(user.ID, org.ID, role)
+	m.JoinedAt = e.now
+	if err := e.Memberships.Create(e.ctx, m); err != nil {
+		t.Fatalf("failed to create membership: %v", err)
+	}
+	return m
+}
+
+func wantErr(t *testing.T, what string, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("%s = %v, want %v", what, err, want)
+	}
+}
+
+func must(t *testing.T, what string, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", what, err)
+	}
+}
+
+// names returns the Name of every element of items, in order.
+func names[T any](items []*T, name func(*T) string) []string {
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = name(item)
+	}
+	return out
+}
+
+func orgNames(orgs []*gordian.Organization) []string {
+	return names(orgs, func(o *gordian.Organization) string { return o.Name })
+}
+
+func testTransactor(t *testing.T, e *env) {
+	errRollback := errors.New("rollback")
+	tests := []struct {
+		name    string
+		fn      func(ctx context.Context, user *gordian.User) error
+		wantErr error
+		wantRow bool
+	}{
+		{
+			name:    "commit",
+			fn:      func(ctx context.Context, user *gordian.User) error { return e.Users.Create(ctx, user) },
+			wantRow: true,
+		},
+		{
+			name: "rollback",
+			fn: func(ctx context.Context, user *gordian.User) error {
+				if err := e.Users.Create(ctx, user); err != nil {
+					return err
+				}
+				return errRollback
+			},
+			wantErr: errRollback,
+		},
+		{
+			name: "nested transaction joins the outer one",
+			fn: func(ctx context.Context, user *gordian.User) error {
+				err := e.Transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+					return e.Users.Create(ctx, user)
+				})
+				if err != nil {
+					return err
+				}
+				return errRollback
+			},
+			wantErr: errRollback,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := gordian.NewUser(fmt.Sprintf("tx%d@example.com", i), "Tx")
+			committed := false
+			err := e.Transactor.WithinTransaction(e.ctx, func(ctx context.Context) error {
+				gordian.AfterCommit(ctx, func() { committed = true })
+				if committed {
+					t.Error("AfterCommit ran before the transaction ended")
+				}
+				return tt.fn(ctx, user)
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WithinTransaction = %v, want %v", err, tt.wantErr)
+			}
+			_, err = e.Users.Get(e.ctx, user.ID)
+			if found := err == nil; found != tt.wantRow {
+				t.Errorf("user stored = %v (%v), want %v", found, err, tt.wantRow)
+			}
+			if committed != tt.wantRow {
+				t.Errorf("AfterCommit ran = %v, want %v", committed, tt.wantRow)
+			}
+		})
+	}
+}
+
+func testOrganizations(t *testing.T, e *env) {
+	got, err := e.Organizations.Get(e.ctx, e.org.ID)
+	must(t, "Get", err)
+	if got.Name != "Acme" || got.Slug != e.org.Slug || got.OwnerID != e.owner.ID || got.ParentID != nil {
+		t.Errorf("Get = %+v, want %+v", got, e.org)
+	}
+	_, err = e.Organizations.Get(e.ctx, uuid.New())
+	wantErr(t, "Get of unknown organization", err, gordian.ErrNotFound)
+
+	taken := gordian.NewOrganization(e.owner.ID, "Taken")
+	taken.Slug = e.org.Slug
+	wantErr(t, "Create with a taken slug", e.Organizations.Create(e.ctx, taken), gordian.ErrConflict)
+
+	other := e.organization(t, "Globex", nil)
+	must(t, "AddSlugRedirect", e.Organizations.AddSlugRedirect(e.ctx, &gordian.OrganizationSlugRedirect{
+		Slug: "old-acme", OrganizationID: e.org.ID, CreatedAt: e.now}))
+	must(t, "AddSlugRedirect", e.Organizations.AddSlugRedirect(e.ctx, &gordian.OrganizationSlugRedirect{
+		Slug: "old-name", OrganizationID: e.org.ID, CreatedAt: e.now}))
+	// Globex takes over a former slug of Acme.
+	must(t, "AddSlugRedirect", e.Organizations.AddSlugRedirect(e.ctx, &gordian.OrganizationSlugRedirect{
+		Slug: "old-name", OrganizationID: other.ID, CreatedAt: e.now}))
+	slugs := []struct {
+		slug    string
+		want    uuid.UUID
+		wantErr error
+	}{
+		{slug: e.org.Slug, want: e.org.ID},
+		{slug: "old-acme", want: e.org.ID},
+		{slug: "old-name", want: other.ID},
+		{slug: "unknown", wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range slugs {
+		got, err := e.Organizations.GetBySlug(e.ctx, tt.slug)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("GetBySlug(%q) = %v, want %v", tt.slug, err, tt.wantErr)
+		} else if err == nil && got.ID != tt.want {
+			t.Errorf("GetBySlug(%q) = %s, want %s", tt.slug, got.ID, tt.want)
+		}
+	}
+
+	stale := *got
+	got.Name, got.Slug, got.Plan = "Acme Inc", "acme-inc", "pro"
+	must(t, "Update", e.Organizations.Update(e.ctx, got))
+	stale.Name = "Ignored"
+	stale.Plan = "enterprise"
+	stale.BillingCustomerID, stale.SubscriptionID, stale.SubscriptionStatus = "cus_1", "sub_1", gordian.SubscriptionActive
+	must(t, "UpdateSubscription", e.Organizations.UpdateSubscription(e.ctx, &stale))
+	got, err = e.Organizations.Get(e.ctx, e.org.ID)
+	must(t, "Get", err)
+	if got.Name != "Acme Inc" || got.Slug != "acme-inc" {
+		t.Errorf("UpdateSubscription changed name and slug to %q, %q", got.Name, got.Slug)
+	}
+	if got.Plan != "enterprise" || got.BillingCustomerID != "cus_1" || got.SubscriptionID != "sub_1" || got.SubscriptionStatus != gordian.SubscriptionActive {
+		t.Errorf("UpdateSubscription did not store the subscription: %+v", got)
+	}
+	got.Name = "Acme Corp"
+	got.BillingCustomerID, got.SubscriptionID, got.SubscriptionStatus = "", "", gordian.SubscriptionNone
+	must(t, "Update", e.Organizations.Update(e.ctx, got))
+	got, err = e.Organizations.Get(e.ctx, e.org.ID)
+	must(t, "Get", err)
+	if got.Name != "Acme Corp" || got.BillingCustomerID != "cus_1" || got.SubscriptionID != "sub_1" || got.SubscriptionStatus != gordian.SubscriptionActive {
+		t.Errorf("Update changed the subscription: %+v", got)
+	}
+
+	must(t, "Delete", e.Organizations.Delete(e.ctx, e.org.ID))
+	_, err = e.Organizations.Get(e.ctx, e.org.ID)
+	wantErr(t, "Get of deleted organization", err, gordian.ErrNotFound)
+	_, err = e.Memberships.GetMembership(e.ctx, e.owner.ID, e.org.ID)
+	wantErr(t, "GetMembership in deleted organization", err, gordian.ErrNotFound)
+	_, err = e.Organizations.GetBySlug(e.ctx, "old-acme")
+	wantErr(t, "GetBySlug of a former slug of a deleted organization", err, gordian.ErrNotFound)
+}
+
+func testOrganizationHierarchy(t *testing.T, e *env) {
+	// Acme
+	// ├── Europe
+	// │   └── France
+	// │       └── Paris
+	// └── Zambia
+	europe := e.organization(t, "Europe", e.org)
+	france := e.organization(t, "France", europe)
+	paris := e.organization(t, "Paris", france)
+	e.organization(t, "Zambia", e.org)
+
+	tests := []struct {
+		name string
+		list func(context.Context, uuid.UUID) ([]*gordian.Organization, error)
+		org  *gordian.Organization
+		want []string
+	}{
+		{"ancestors of a leaf, nearest first", e.Organizations.ListAncestors, paris, []string{"France", "Europe", "Acme"}},
+		{"ancestors of a root", e.Organizations.ListAncestors, e.org, []string{}},
+		{"descendants of a root, by depth and name", e.Organizations.ListDescendants, e.org, []string{"Europe", "Zambia", "France", "Paris"}},
+		{"descendants of an inner organization", e.Organizations.ListDescendants, europe, []string{"France", "Paris"}},
+		{"descendants of a leaf", e.Organizations.ListDescendants, paris, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orgs, err := tt.list(e.ctx, tt.org.ID)
+			must(t, "list", err)
+			if got := orgNames(orgs); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			for _, org := range orgs {
+				if org.OwnerID != e.owner.ID || org.Slug == "" {
+					t.Errorf("%s was not read completely: %+v", org.Name, org)
+				}
+			}
+		})
+	}
+
+	t.Run("depth limit", func(t *testing.T) {
+		chain := []*gordian.Organization{e.organization(t, "level 0", nil)}
+		for i := 1; i <= gordian.MaxOrganizationDepth+2; i++ {
+			chain = append(chain, e.organization(t, fmt.Sprintf("level %d", i), chain[i-1]))
+		}
+		ancestors, err := e.Organizations.ListAncestors(e.ctx, chain[len(chain)-1].ID)
+		must(t, "ListAncestors", err)
+		descendants, err := e.Organizations.ListDescendants(e.ctx, chain[0].ID)
+		must(t, "ListDescendants", err)
+		if len(ancestors) != gordian.MaxOrganizationDepth || len(descendants) != gordian.MaxOrganizationDepth {
+			t.Errorf("got %d ancestors and %d descendants, want %d each", len(ancestors), len(descendants), gordian.MaxOrganizationDepth)
+		}
+	})
+
+	err := e.Transactor.WithinTransaction(e.ctx, func(ctx context.Context) error {
+		return e.Organizations.Lock(ctx, paris.ID)
+	})
+	must(t, "Lock", err)
+	err = e.Transactor.WithinTransaction(e.ctx, func(ctx context.Context) error {
+		return e.Organizations.Lock(ctx, uuid.New())
+	})
+	wantErr(t, "Lock of an unknown organization", err, gordian.ErrNotFound)
+}
+
+func testUsers(t *testing.T, e *env) {
+	got, err := e.Users.Get(e.ctx, e.owner.ID)
+	must(t, "Get", err)
+	if got.Email != "owner@example.com" || got.EmailVerifiedAt != nil {
+		t.Errorf("Get = %+v", got)
+	}
+	_, err = e.Users.Get(e.ctx, uuid.New())
+	wantErr(t, "Get of unknown user", err, gordian.ErrNotFound)
+	wantErr(t, "Create with a taken email", e.Users.Create(e.ctx, gordian.NewUser("owner@example.com", "Copy")), gordian.ErrConflict)
+
+	for _, email := range []string{"owner@example.com", "Owner@Example.COM"} {
+		byEmail, err := e.Users.FindByEmail(e.ctx, email)
+		must(t, "FindByEmail", err)
+		if byEmail.ID != e.owner.ID {
+			t.Errorf("FindByEmail(%q) = %s, want %s", email, byEmail.ID, e.owner.ID)
+		}
+	}
+	_, err = e.Users.FindByEmail(e.ctx, "nobody@example.com")
+	wantErr(t, "FindByEmail of unknown email", err, gordian.ErrNotFound)
+
+	role, err := e.Users.GetUserRole(e.ctx, e.owner.ID)
+	must(t, "GetUserRole", err)
+	if role != "owner" {
+		t.Errorf("GetUserRole = %q, want owner", role)
+	}
+
+	got.Name = "Renamed"
+	got.EmailVerifiedAt = &e.now
+	must(t, "Update", e.Users.Update(e.ctx, got))
+	got, err = e.Users.Get(e.ctx, e.owner.ID)
+	must(t, "Get", err)
+	if got.Name != "Renamed" || got.EmailVerifiedAt == nil || !got.EmailVerifiedAt.Equal(e.now) {
+		t.Errorf("Update was not stored: %+v", got)
+	}
+}
+
+func testMemberships(t *testing.T, e *env) {
+	alice := e.user(t, "alice@example.com")
+	other := e.organization(t, "Globex", nil)
+	m := e.member(t, alice, e.org, "member")
+	e.member(t, alice, other, "admin")
+
+	dup := gordian.NewMembership(alice.ID, e.org.ID, "admin")
+	wantErr(t, "Create of a second membership", e.Memberships.Create(e.ctx, dup), gordian.ErrConflict)
+
+	got, err := e.Memberships.GetMembership(e.ctx, alice.ID, e.org.ID)
+	must(t, "GetMembership", err)
+	if got.ID != m.ID || got.Role != "member" {
+		t.Errorf("GetMembership = %+v, want %+v", got, m)
+	}
+	_, err = e.Memberships.GetMembership(e.ctx, uuid.New(), e.org.ID)
+	wantErr(t, "GetMembership of a stranger", err, gordian.ErrNotFound)
+
+	members, err := e.Memberships.GetMembers(e.ctx, e.org.ID)
+	must(t, "GetMembers", err)
+	roles := slices.Sorted(slices.Values(names(members, func(m *gordian.Membership) string { return m.Role })))
+	if !slices.Equal(roles, []string{"member", "owner"}) {
+		t.Errorf("GetMembers roles = %v", roles)
+	}
+	mine, err := e.Memberships.ListForUser(e.ctx, alice.ID)
+	must(t, "ListForUser", err)
+	if len(mine) != 2 {
+		t.Errorf("ListForUser = %d memberships, want 2", len(mine))
+	}
+
+	got.Role = "admin"
+	must(t, "Update", e.Memberships.Update(e.ctx, &got))
+	got, err = e.Memberships.GetMembership(e.ctx, alice.ID, e.org.ID)
+	must(t, "GetMembership", err)
+	if got.Role != "admin" {
+		t.Errorf("Update stored role %q, want admin", got.Role)
+	}
+
+	must(t, "Delete", e.Memberships.Delete(e.ctx, m.ID))
+	_, err = e.Memberships.GetMembership(e.ctx, alice.ID, e.org.ID)
+	wantErr(t, "GetMembership after Delete", err, gordian.ErrNotFound)
+}
+
+func testInvites(t *testing.T, e *env) {
+	past := e.now.Add(-time.Hour)
+	invites := []struct {
+		token       string
+		edit        func(*gordian.Invite)
+		wantPending bool
+	}{
+		{token: "pending", wantPending: true},
+		{token: "expired", edit: func(i *gordian.Invite) { i.ExpiresAt = past }},
+		{token: "accepted", edit: func(i *gordian.Invite) { i.AcceptedAt = &past }},
+		{token: "revoked", edit: func(i *gordian.Invite) { i.RevokedAt = &past }},
+	}
+	var wantPending []string
+	for _, tt := range invites {
+		invite := gordian.NewInvite(e.org.ID, e.owner.ID, tt.token+"@example.com", "member", tt.token)
+		if tt.edit != nil {
+			tt.edit(invite)
+		}
+		must(t, "Create", e.Invites.Create(e.ctx, invite))
+		if tt.wantPending {
+			wantPending = append(wantPending, tt.token)
+		}
+		ok, err := e.Invites.Verify(e.ctx, tt.token)
+		must(t, "Verify", err)
+		if ok != tt.wantPending {
+			t.Errorf("Verify(%q) = %v, want %v", tt.token, ok, tt.wantPending)
+		}
+	}
+	ok, err := e.Invites.Verify(e.ctx, "unknown")
+	if err != nil || ok {
+		t.Errorf("Verify of an unknown token = %v, %v", ok, err)
+	}
+	wantErr(t, "Create with a taken token",
+		e.Invites.Create(e.ctx, gordian.NewInvite(e.org.ID, e.owner.ID, "dup@example.com", "member", "pending")), gordian.ErrConflict)
+
+	pending, err := e.Invites.ListPending(e.ctx, e.org.ID)
+	must(t, "ListPending", err)
+	if got := names(pending, func(i *gordian.Invite) string { return i.Token }); !slices.Equal(got, wantPending) {
+		t.Errorf("ListPending = %v, want %v", got, wantPending)
+	}
+
+	invite, err := e.Invites.GetByToken(e.ctx, "pending")
+	must(t, "GetByToken", err)
+	_, err = e.Invites.GetByToken(e.ctx, "unknown")
+	wantErr(t, "GetByToken of an unknown token", err, gordian.ErrNotFound)
+	_, err = e.Invites.Get(e.ctx, uuid.New())
+	wantErr(t, "Get of an unknown invitation", err, gordian.ErrNotFound)
+
+	invite.AcceptedAt = &e.now
+	must(t, "Update", e.Invites.Update(e.ctx, invite))
+	got, err := e.Invites.Get(e.ctx, invite.ID)
+	must(t, "Get", err)
+	if got.AcceptedAt == nil || got.InviteeEmail != "pending@example.com" {
+		t.Errorf("Update was not stored: %+v", got)
+	}
+}
+
+func testOutbox(t *testing.T, e *env) {
+	messages := []struct {
+		kind   string
+		status gordian.OutboxStatus
+		due    time.Duration // NextAttemptAt relative to now
+	}{
+		{"due", gordian.OutboxPending, -time.Minute},
+		{"due later", gordian.OutboxPending, -time.Second},
+		{"not due", gordian.OutboxPending, time.Minute},
+		{"sent", gordian.OutboxSent, -time.Hour},
+		{"dead", gordian.OutboxDead, -time.Hour},
+	}
+	for i, m := range messages {
+		msg, err := gordian.NewOutboxMessage(m.kind, map[string]int{"n": i})
+		must(t, "NewOutboxMessage", err)
+		msg.Status = m.status
+		msg.NextAttemptAt = e.now.Add(m.due)
+		msg.CreatedAt = e.now.Add(time.Duration(i) * time.Second)
+		must(t, "Create", e.Outbox.Create(e.ctx, msg))
+	}
+
+	lease := time.Minute
+	claims := []struct {
+		name string
+		now  time.Time
+		want []string // in any order
+	}{
+		{"due messages", e.now, []string{"due", "due later"}},
+		{"claimed messages are leased", e.now, []string{}},
+		{"after the lease", e.now.Add(lease), []string{"due", "due later", "not due"}},
+	}
+	for _, tt := range claims {
+		msgs, err := e.Outbox.ClaimDue(e.ctx, tt.now, lease, 10)
+		must(t, "ClaimDue", err)
+		got := names(msgs, func(m *gordian.OutboxMessage) string { return m.Kind })
+		if slices.Sort(got); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: ClaimDue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	limited, err := e.Outbox.ClaimDue(e.ctx, e.now.Add(time.Hour), lease, 1)
+	must(t, "ClaimDue", err)
+	if len(limited) != 1 {
+		t.Fatalf("ClaimDue with limit 1 returned %d messages", len(limited))
+	}
+
+	msg := limited[0]
+	msg.Status, msg.Attempts, msg.SentAt = gordian.OutboxSent, 1, &e.now
+	must(t, "Update", e.Outbox.Update(e.ctx, msg))
+	got, err := e.Outbox.Get(e.ctx, msg.ID)
+	must(t, "Get", err)
+	if got.Status != gordian.OutboxSent || got.Attempts != 1 || got.SentAt == nil || string(got.Payload) != string(msg.Payload) {
+		t.Errorf("Update was not stored: %+v", got)
+	}
+	_, err = e.Outbox.Get(e.ctx, uuid.New())
+	wantErr(t, "Get of an unknown message", err, gordian.ErrNotFound)
+
+	sent, err := e.Outbox.ListByStatus(e.ctx, gordian.OutboxSent, 10)
+	must(t, "ListByStatus", err)
+	if len(sent) != 2 {
+		t.Errorf("ListByStatus(sent) = %d messages, want 2", len(sent))
+	}
+	dead, err := e.Outbox.ListByStatus(e.ctx, gordian.OutboxDead, 10)
+	must(t, "ListByStatus", err)
+	if got := names(dead, func(m *gordian.OutboxMessage) string { return m.Kind }); !slices.Equal(got, []string{"dead"}) {
+		t.Errorf("ListByStatus(dead) = %v", got)
+	}
+}
+
+func testWebhooks(t *testing.T, e *env) {
+	endpoint := gordian.NewWebhookEndpoint(e.org.ID, "https://example.com/hook", "secret", []string{"member.added", "member.removed"})
+	must(t, "CreateEndpoint", e.Webhooks.CreateEndpoint(e.ctx, endpoint))
+	all := gordian.NewWebhookEndpoint(e.org.ID, "https://example.com/all", "secret", nil)
+	all.CreatedAt = endpoint.CreatedAt.Add(time.Second)
+	must(t, "CreateEndpoint", e.Webhooks.CreateEndpoint(e.ctx, all))
+
+	got, err := e.Webhooks.GetEndpoint(e.ctx, endpoint.ID)
+	must(t, "GetEndpoint", err)
+	if !slices.Equal(got.EventTypes, endpoint.EventTypes) || got.Secret != "secret" {
+		t.Errorf("GetEndpoint = %+v, want %+v", got, endpoint)
+	}
+	endpoints, err := e.Webhooks.ListEndpoints(e.ctx, e.org.ID)
+	must(t, "ListEndpoints", err)
+	if got := names(endpoints, func(e *gordian.WebhookEndpoint) string { return e.URL }); !slices.Equal(got, []string{endpoint.URL, all.URL}) {
+		t.Errorf("ListEndpoints = %v", got)
+	}
+
+	deliveries := []struct {
+		event  string
+		status gordian.WebhookDeliveryStatus
+		due    time.Duration
+	}{
+		{"due", gordian.WebhookPending, -time.Minute},
+		{"not due", gordian.WebhookPending, time.Minute},
+		{"succeeded", gordian.WebhookSucceeded, -time.Hour},
+		{"failed", gordian.WebhookFailed, -time.Hour},
+	}
+	for i, d := range deliveries {
+		must(t, "CreateDelivery", e.Webhooks.CreateDelivery(e.ctx, &gordian.WebhookDelivery{
+			ID:             uuid.New(),
+			EndpointID:     endpoint.ID,
+			OrganizationID: e.org.ID,
+			EventType:      d.event,
+			Payload:        []byte(`{}`),
+			Status:         d.status,
+			NextAttemptAt:  e.now.Add(d.due),
+			CreatedAt:      e.now.Add(time.Duration(i) * time.Second),
+		}))
+	}
+	listed, err := e.Webhooks.ListDeliveries(e.ctx, endpoint.ID, 3)
+	must(t, "ListDeliveries", err)
+	if got := names(listed, func(d *gordian.WebhookDelivery) string { return d.EventType }); !slices.Equal(got, []string{"failed", "succeeded", "not due"}) {
+		t.Errorf("ListDeliveries = %v, want the newest 3", got)
+	}
+
+	lease := time.Minute
+	claim := func(now time.Time) []*gordian.WebhookDelivery {
+		t.Helper()
+		claimed, err := e.Webhooks.ClaimDueDeliveries(e.ctx, now, lease, 10)
+		must(t, "ClaimDueDeliveries", err)
+		return claimed
+	}
+	eventTypes := func(ds []*gordian.WebhookDelivery) []string {
+		return names(ds, func(d *gordian.WebhookDelivery) string { return d.EventType })
+	}
+	claimed := claim(e.now)
+	if got := eventTypes(claimed); !slices.Equal(got, []string{"due"}) {
+		t.Fatalf("ClaimDueDeliveries = %v, want [due]", got)
+	}
+	if got := eventTypes(claim(e.now)); len(got) != 0 {
+		t.Errorf("ClaimDueDeliveries during the lease = %v", got)
+	}
+	d := claimed[0]
+	d.Status, d.Attempts, d.ResponseStatus, d.DeliveredAt = gordian.WebhookSucceeded, 1, 200, &e.now
+	must(t, "UpdateDelivery", e.Webhooks.UpdateDelivery(e.ctx, d))
+	if got := eventTypes(claim(e.now.Add(lease))); !slices.Equal(got, []string{"not due"}) {
+		t.Errorf("ClaimDueDeliveries after the lease = %v, want [not due]", got)
+	}
+	listed, err = e.Webhooks.ListDeliveries(e.ctx, endpoint.ID, 10)
+	must(t, "ListDeliveries", err)
+	for _, got := range listed {
+		if got.ID == d.ID && (got.Status != gordian.WebhookSucceeded || got.ResponseStatus != 200 || got.DeliveredAt == nil) {
+			t.Errorf("UpdateDelivery was not stored: %+v", got)
+		}
+	}
+
+	must(t, "DeleteEndpoint", e.Webhooks.DeleteEndpoint(e.ctx, all.ID))
+	_, err = e.Webhooks.GetEndpoint(e.ctx, all.ID)
+	wantErr(t, "GetEndpoint after DeleteEndpoint", err, gordian.ErrNotFound)
+}
+
+func testAudit(t *testing.T, e *env) {
+	alice, bob := uuid.New(), uuid.New()
+	target := uuid.New()
+	entries := []struct {
+		actor  uuid.UUID
+		action string
+		target uuid.UUID
+		at     time.Duration
+	}{
+		{alice, gordian.AuditMemberAdded, target, -3 * time.Hour},
+		{alice, gordian.AuditOrganizationRenamed, target, -2 * time.Hour},
+		{bob, gordian.AuditMemberRemoved, target, -time.Hour},
+		{bob, gordian.AuditMemberAdded, uuid.New(), 0},
+	}
+	for _, entry := range entries {
+		must(t, "Create", e.Audit.Create(e.ctx, &gordian.AuditEntry{
+			ID:             uuid.New(),
+			OrganizationID: e.org.ID,
+			ActorID:        entry.actor,
+			Action:         entry.action,
+			TargetType:     "membership",
+			TargetID:       entry.target,
+			After:          []byte(`{"role":"member"}`),
+			CreatedAt:      e.now.Add(entry.at),
+		}))
+	}
+	must(t, "Create", e.Audit.Create(e.ctx, &gordian.AuditEntry{
+		ID: uuid.New(), OrganizationID: uuid.New(), Action: gordian.AuditMemberAdded, CreatedAt: e.now}))
+
+	tests := []struct {
+		name   string
+		filter gordian.AuditFilter
+		want   []time.Duration // CreatedAt of the entries, newest first
+	}{
+		{"organization", gordian.AuditFilter{}, []time.Duration{0, -time.Hour, -2 * time.Hour, -3 * time.Hour}},
+		{"actor", gordian.AuditFilter{ActorID: alice}, []time.Duration{-2 * time.Hour, -3 * time.Hour}},
+		{"action", gordian.AuditFilter{Action: gordian.AuditMemberAdded}, []time.Duration{0, -3 * time.Hour}},
+		{"target", gordian.AuditFilter{TargetID: target}, []time.Duration{-time.Hour, -2 * time.Hour, -3 * time.Hour}},
+		{"since and until", gordian.AuditFilter{Since: e.now.Add(-2 * time.Hour), Until: e.now}, []time.Duration{-time.Hour, -2 * time.Hour}},
+		{"page", gordian.AuditFilter{Limit: 2, Offset: 1}, []time.Duration{-time.Hour, -2 * time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.OrganizationID = e.org.ID
+			if tt.filter.Limit == 0 {
+				tt.filter.Limit = 50
+			}
+			got, err := e.Audit.List(e.ctx, tt.filter)
+			must(t, "List", err)
+			var at []time.Duration
+			for _, entry := range got {
+				at = append(at, entry.CreatedAt.Sub(e.now))
+			}
+			if !slices.Equal(at, tt.want) {
+				t.Errorf("List = %v, want %v", at, tt.want)
+			}
+		})
+	}
+}
+
+func testAPIKeys(t *testing.T, e *env) {
+	key := gordian.NewAPIKey(e.org.ID, "CI", "gk_ci", []byte("hash"), "member", nil)
+	key.CreatedAt = e.now
+	must(t, "Create", e.APIKeys.Create(e.ctx, key))
+	second := gordian.NewAPIKey(e.org.ID, "Deploy", "gk_deploy", []byte("hash"), "admin", nil)
+	second.CreatedAt = e.now.Add(time.Second)
+	must(t, "Create", e.APIKeys.Create(e.ctx, second))
+	wantErr(t, "Create with a taken prefix",
+		e.APIKeys.Create(e.ctx, gordian.NewAPIKey(e.org.ID, "Copy", "gk_ci", []byte("hash"), "member", nil)), gordian.ErrConflict)
+
+	got, err := e.APIKeys.GetByPrefix(e.ctx, "gk_ci")
+	must(t, "GetByPrefix", err)
+	if got.ID != key.ID || string(got.SecretHash) != "hash" || got.LastUsedAt != nil {
+		t.Errorf("GetByPrefix = %+v", got)
+	}
+	_, err = e.APIKeys.GetByPrefix(e.ctx, "gk_unknown")
+	wantErr(t, "GetByPrefix of an unknown prefix", err, gordian.ErrNotFound)
+	_, err = e.APIKeys.Get(e.ctx, uuid.New())
+	wantErr(t, "Get of an unknown key", err, gordian.ErrNotFound)
+
+	keys, err := e.APIKeys.List(e.ctx, e.org.ID)
+	must(t, "List", err)
+	if got := names(keys, func(k *gordian.APIKey) string { return k.Name }); !slices.Equal(got, []string{"CI", "Deploy"}) {
+		t.Errorf("List = %v", got)
+	}
+
+	got.RevokedAt = &e.now
+	must(t, "Update", e.APIKeys.Update(e.ctx, got))
+	used := e.now.Add(time.Minute)
+	must(t, "TouchLastUsed", e.APIKeys.TouchLastUsed(e.ctx, key.ID, used))
+	got, err = e.APIKeys.Get(e.ctx, key.ID)
+	must(t, "Get", err)
+	if got.RevokedAt == nil {
+		t.Error("TouchLastUsed undid the revocation")
+	}
+	if got.LastUsedAt == nil || !got.LastUsedAt.Equal(used) {
+		t.Errorf("LastUsedAt = %v, want %v", got.LastUsedAt, used)
+	}
+}
+
+func testTeams(t *testing.T, e *env) {
+	alice := e.user(t, "alice@example.com")
+	other := e.organization(t, "Globex", nil)
+	backend := gordian.NewTeam(e.org.ID, "Backend", "APIs")
+	backend.Permissions = gordian.Permissions{"deploy"}
+	frontend := gordian.NewTeam(e.org.ID, "Frontend", "")
+	elsewhere := gordian.NewTeam(other.ID, "Backend", "")
+	for _, team := range []*gordian.Team{frontend, backend, elsewhere} {
+		must(t, "CreateTeam", e.Teams.CreateTeam(e.ctx, team))
+	}
+	wantErr(t, "CreateTeam with a taken name", e.Teams.CreateTeam(e.ctx, gordian.NewTeam(e.org.ID, "Backend", "")), gordian.ErrConflict)
+
+	got, err := e.Teams.GetTeam(e.ctx, backend.ID)
+	must(t, "GetTeam", err)
+	if got.Description != "APIs" || !slices.Equal(got.Permissions, backend.Permissions) {
+		t.Errorf("GetTeam = %+v", got)
+	}
+	_, err = e.Teams.GetTeam(e.ctx, uuid.New())
+	wantErr(t, "GetTeam of an unknown team", err, gordian.ErrNotFound)
+	teams, err := e.Teams.ListTeams(e.ctx, e.org.ID)
+	must(t, "ListTeams", err)
+	if got := names(teams, func(t *gordian.Team) string { return t.Name }); !slices.Equal(got, []string{"Backend", "Frontend"}) {
+		t.Errorf("ListTeams = %v", got)
+	}
+
+	got.Name, got.Permissions = "Platform", gordian.Permissions{"deploy", "billing"}
+	must(t, "UpdateTeam", e.Teams.UpdateTeam(e.ctx, got))
+	got, err = e.Teams.GetTeam(e.ctx, backend.ID)
+	must(t, "GetTeam", err)
+	if got.Name != "Platform" || len(got.Permissions) != 2 {
+		t.Errorf("UpdateTeam was not stored: %+v", got)
+	}
+
+	for _, team := range []*gordian.Team{backend, frontend, elsewhere} {
+		must(t, "AddMember", e.Teams.AddMember(e.ctx, gordian.NewTeamMembership(team, alice.ID, gordian.TeamRoleMember)))
+	}
+	wantErr(t, "AddMember twice",
+		e.Teams.AddMember(e.ctx, gordian.NewTeamMembership(backend, alice.ID, gordian.TeamRoleMember)), gordian.ErrConflict)
+	must(t, "AddMember", e.Teams.AddMember(e.ctx, gordian.NewTeamMembership(backend, e.owner.ID, gordian.TeamRoleMaintainer)))
+
+	member, err := e.Teams.GetMember(e.ctx, backend.ID, alice.ID)
+	must(t, "GetMember", err)
+	member.Role = gordian.TeamRoleMaintainer
+	must(t, "UpdateMember", e.Teams.UpdateMember(e.ctx, member))
+	member, err = e.Teams.GetMember(e.ctx, backend.ID, alice.ID)
+	must(t, "GetMember", err)
+	if member.Role != gordian.TeamRoleMaintainer {
+		t.Errorf("UpdateMember stored role %q", member.Role)
+	}
+	members, err := e.Teams.ListMembers(e.ctx, backend.ID)
+	must(t, "ListMembers", err)
+	if len(members) != 2 {
+		t.Errorf("ListMembers = %d members, want 2", len(members))
+	}
+
+	listForUser := func() []string {
+		t.Helper()
+		teams, err := e.Teams.ListForUser(e.ctx, e.org.ID, alice.ID)
+		must(t, "ListForUser", err)
+		return names(teams, func(t *gordian.Team) string { return t.Name })
+	}
+	if got := listForUser(); !slices.Equal(got, []string{"Frontend", "Platform"}) {
+		t.Errorf("ListForUser = %v", got)
+	}
+	must(t, "RemoveMember", e.Teams.RemoveMember(e.ctx, member.ID))
+	if got := listForUser(); !slices.Equal(got, []string{"Frontend"}) {
+		t.Errorf("ListForUser after RemoveMember = %v", got)
+	}
+	must(t, "RemoveUser", e.Teams.RemoveUser(e.ctx, e.org.ID, alice.ID))
+	if got := listForUser(); len(got) != 0 {
+		t.Errorf("ListForUser after RemoveUser = %v", got)
+	}
+	_, err = e.Teams.GetMember(e.ctx, elsewhere.ID, alice.ID)
+	must(t, "RemoveUser removed a member of another organization", err)
+
+	must(t, "DeleteTeam", e.Teams.DeleteTeam(e.ctx, backend.ID))
+	_, err = e.Teams.GetTeam(e.ctx, backend.ID)
+	wantErr(t, "GetTeam after DeleteTeam", err, gordian.ErrNotFound)
+	_, err = e.Teams.GetMember(e.ctx, backend.ID, e.owner.ID)
+	wantErr(t, "GetMember after DeleteTeam", err, gordian.ErrNotFound)
+}
+
+func testDomains(t *testing.T, e *env) {
+	other := e.organization(t, "Globex", nil)
+	acme := gordian.NewOrganizationDomain(e.org.ID, "acme.com", "token-1")
+	rival := gordian.NewOrganizationDomain(other.ID, "acme.com", "token-2")
+	second := gordian.NewOrganizationDomain(e.org.ID, "acme.org", "token-3")
+	for _, d := range []*gordian.OrganizationDomain{acme, rival, second} {
+		must(t, "Create", e.Domains.Create(e.ctx, d))
+	}
+	wantErr(t, "Create of a second claim by the same organization",
+		e.Domains.Create(e.ctx, gordian.NewOrganizationDomain(e.org.ID, "acme.com", "token-4")), gordian.ErrConflict)
+
+	_, err := e.Domains.GetVerified(e.ctx, "acme.com")
+	wantErr(t, "GetVerified of an unverified domain", err, gordian.ErrNotFound)
+
+	acme.VerifiedAt, acme.Policy, acme.DefaultRole = &e.now, gordian.DomainPolicyAutoJoin, "admin"
+	must(t, "Update", e.Domains.Update(e.ctx, acme))
+	verified, err := e.Domains.GetVerified(e.ctx, "acme.com")
+	must(t, "GetVerified", err)
+	if verified.ID != acme.ID || verified.Policy != gordian.DomainPolicyAutoJoin || verified.DefaultRole != "admin" {
+		t.Errorf("GetVerified = %+v, want %+v", verified, acme)
+	}
+	rival.VerifiedAt = &e.now
+	wantErr(t, "Update verifying a domain verified by another organization", e.Domains.Update(e.ctx, rival), gordian.ErrConflict)
+
+	domains, err := e.Domains.List(e.ctx, e.org.ID)
+	must(t, "List", err)
+	if got := names(domains, func(d *gordian.OrganizationDomain) string { return d.Domain }); !slices.Equal(got, []string{"acme.com", "acme.org"}) {
+		t.Errorf("List = %v", got)
+	}
+	must(t, "Delete", e.Domains.Delete(e.ctx, second.ID))
+	_, err = e.Domains.Get(e.ctx, second.ID)
+	wantErr(t, "Get after Delete", err, gordian.ErrNotFound)
+}
+
+func testJoinRequests(t *testing.T, e *env) {
+	alice, bob := e.user(t, "alice@example.com"), e.user(t, "bob@example.com")
+	first := gordian.NewJoinRequest(e.org.ID, alice.ID, "hi", gordian.JoinRequestSourceDirect, "member")
+	first.CreatedAt = e.now.Add(-time.Minute)
+	second := gordian.NewJoinRequest(e.org.ID, bob.ID, "", gordian.JoinRequestSourceDomain, "member")
+	second.CreatedAt = e.now
+	for _, r := range []*gordian.JoinRequest{first, second} {
+		must(t, "Create", e.JoinRequests.Create(e.ctx, r))
+	}
+	wantErr(t, "Create of a second pending request",
+		e.JoinRequests.Create(e.ctx, gordian.NewJoinRequest(e.org.ID, alice.ID, "", gordian.JoinRequestSourceDirect, "member")), gordian.ErrConflict)
+
+	pending, err := e.JoinRequests.GetPending(e.ctx, e.org.ID, alice.ID)
+	must(t, "GetPending", err)
+	if pending.ID != first.ID || pending.Message != "hi" {
+		t.Errorf("GetPending = %+v", pending)
+	}
+
+	pending.Status, pending.DeciderID, pending.DecidedAt = gordian.JoinRequestStatusDenied, &e.owner.ID, &e.now
+	must(t, "Update", e.JoinRequests.Update(e.ctx, pending))
+	_, err = e.JoinRequests.GetPending(e.ctx, e.org.ID, alice.ID)
+	wantErr(t, "GetPending after the request was denied", err, gordian.ErrNotFound)
+	got, err := e.JoinRequests.Get(e.ctx, first.ID)
+	must(t, "Get", err)
+	if got.Status != gordian.JoinRequestStatusDenied || got.DeciderID == nil || *got.DeciderID != e.owner.ID {
+		t.Errorf("Update was not stored: %+v", got)
+	}
+	// Once the first is decided, the user can ask again.
+	must(t, "Create", e.JoinRequests.Create(e.ctx, gordian.NewJoinRequest(e.org.ID, alice.ID, "again", gordian.JoinRequestSourceDirect, "member")))
+
+	tests := []struct {
+		status gordian.JoinRequestStatus
+		want   []string
+	}{
+		{"", []string{"again", "", "hi"}},
+		{gordian.JoinRequestStatusPending, []string{"again", ""}},
+		{gordian.JoinRequestStatusDenied, []string{"hi"}},
+		{gordian.JoinRequestStatusApproved, []string{}},
+	}
+	for _, tt := range tests {
+		requests, err := e.JoinRequests.List(e.ctx, e.org.ID, tt.status)
+		must(t, "List", err)
+		if got := names(requests, func(r *gordian.JoinRequest) string { return r.Message }); !slices.Equal(got, tt.want) {
+			t.Errorf("List(%q) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func testInviteLinks(t *testing.T, e *env) {
+	past, future := e.now.Add(-time.Hour), e.now.Add(time.Hour)
+	links := map[string]*gordian.InviteLink{
+		"unlimited": gordian.NewInviteLink(e.org.ID, e.owner.ID, "unlimited", "member", 0, "", nil),
+		"single":    gordian.NewInviteLink(e.org.ID, e.owner.ID, "single", "member", 1, "", &future),
+		"expired":   gordian.NewInviteLink(e.org.ID, e.owner.ID, "expired", "member", 0, "", &past),
+		"revoked":   gordian.NewInviteLink(e.org.ID, e.owner.ID, "revoked", "member", 0, "", nil),
+	}
+	links["revoked"].RevokedAt = &past
+	for _, name := range []string{"unlimited", "single", "expired", "revoked"} {
+		links[name].CreatedAt = e.now.Add(time.Duration(len(name)) * time.Second)
+		must(t, "Create", e.InviteLinks.Create(e.ctx, links[name]))
+	}
+	wantErr(t, "Create with a taken token",
+		e.InviteLinks.Create(e.ctx, gordian.NewInviteLink(e.org.ID, e.owner.ID, "single", "member", 0, "", nil)), gordian.ErrConflict)
+
+	alice, bob := e.user(t, "alice@example.com"), e.user(t, "bob@example.com")
+	tests := []struct {
+		name    string
+		link    string
+		user    *gordian.User
+		wantErr error
+	}{
+		{"unlimited", "unlimited", alice, nil},
+		{"unlimited, another user", "unlimited", bob, nil},
+		{"same user again", "unlimited", alice, gordian.ErrConflict},
+		{"single use", "single", alice, nil},
+		{"used up", "single", bob, gordian.ErrConflict},
+		{"expired", "expired", alice, gordian.ErrConflict},
+		{"revoked", "revoked", alice, gordian.ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := links[tt.link]
+			err := e.InviteLinks.Redeem(e.ctx, &gordian.InviteLinkRedemption{
+				ID: uuid.New(), LinkID: link.ID, OrganizationID: e.org.ID, UserID: tt.user.ID,
+				MembershipID: uuid.New(), RedeemedAt: e.now,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Redeem = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	uses := map[string]int{"unlimited": 2, "single": 1, "expired": 0, "revoked": 0}
+	for name, want := range uses {
+		link, err := e.InviteLinks.GetByToken(e.ctx, name)
+		must(t, "GetByToken", err)
+		redemptions, err := e.InviteLinks.ListRedemptions(e.ctx, link.ID)
+		must(t, "ListRedemptions", err)
+		if link.Uses != want || len(redemptions) != want {
+			t.Errorf("%s: %d uses and %d redemptions, want %d", name, link.Uses, len(redemptions), want)
+		}
+	}
+	_, err := e.InviteLinks.GetByToken(e.ctx, "unknown")
+	wantErr(t, "GetByToken of an unknown token", err, gordian.ErrNotFound)
+
+	// links["unlimited"] still counts 0 uses; Update must not write them back.
+	stale := links["unlimited"]
+	stale.MaxUses, stale.RevokedAt = 5, &e.now
+	must(t, "Update", e.InviteLinks.Update(e.ctx, stale))
+	got, err := e.InviteLinks.Get(e.ctx, stale.ID)
+	must(t, "Get", err)
+	if got.Uses != 2 || got.MaxUses != 5 || got.RevokedAt == nil {
+		t.Errorf("Update stored %d uses of %d, revoked at %v", got.Uses, got.MaxUses, got.RevokedAt)
+	}
+
+	listed, err := e.InviteLinks.List(e.ctx, e.org.ID)
+	must(t, "List", err)
+	if got := names(listed, func(l *gordian.InviteLink) string { return l.Token }); !slices.Equal(got, []string{"single", "expired", "revoked", "unlimited"}) {
+		t.Errorf("List = %v, want oldest first", got)
+	}
+}
+
+func testUsage(t *testing.T, e *env) {
+	other := e.organization(t, "Globex", nil)
+	alice := e.user(t, "alice@example.com")
+	e.member(t, alice, e.org, "member")
+	past := e.now.Add(-time.Hour)
+	for i, edit := range []func(*gordian.Invite){
+		nil,
+		nil,
+		func(i *gordian.Invite) { i.ExpiresAt = past },
+		func(i *gordian.Invite) { i.AcceptedAt = &past },
+		func(i *gordian.Invite) { i.RevokedAt = &past },
+		func(i *gordian.Invite) { i.OrganizationID = other.ID },
+	} {
+		invite := gordian.NewInvite(e.org.ID, e.owner.ID, fmt.Sprintf("%d@example.com", i), "member", fmt.Sprintf("token-%d", i))
+		invite.CreatedAt, invite.ExpiresAt = past, e.now.Add(time.Hour)
+		if edit != nil {
+			edit(invite)
+		}
+		must(t, "Create invite", e.Invites.Create(e.ctx, invite))
+	}
+	must(t, "CreateTeam", e.Teams.CreateTeam(e.ctx, gordian.NewTeam(e.org.ID, "Backend", "")))
+
+	tests := []struct {
+		name string
+		org  uuid.UUID
+		at   time.Time
+		want gordian.Usage
+	}{
+		{"organization", e.org.ID, e.now, gordian.Usage{Members: 2, PendingInvites: 2, Teams: 1}},
+		{"after the invitations expired", e.org.ID, e.now.Add(2 * time.Hour), gordian.Usage{Members: 2, Teams: 1}},
+		{"other organization", other.ID, e.now, gordian.Usage{Members: 1, PendingInvites: 1}},
+		{"unknown organization", uuid.New(), e.now, gordian.Usage{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.Usage.GetUsage(e.ctx, tt.org, tt.at)
+			must(t, "GetUsage", err)
+			if got != tt.want {
+				t.Errorf("GetUsage = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	err := e.Transactor.WithinTransaction(e.ctx, func(ctx context.Context) error {
+		return e.Usage.LockUsage(ctx, e.org.ID)
+	})
+	must(t, "LockUsage", err)
+	err = e.Transactor.WithinTransaction(e.ctx, func(ctx context.Context) error {
+		return e.Usage.LockUsage(ctx, uuid.New())
+	})
+	wantErr(t, "LockUsage of an unknown organization", err, gordian.ErrNotFound)
+}
+
+func testSettings(t *testing.T, e *env) {
+	_, err := e.Settings.Get(e.ctx, e.org.ID)
+	wantErr(t, "Get before Save", err, gordian.ErrNotFound)
+
+	saves := []*gordian.OrganizationSettings{
+		{
+			OrganizationID: e.org.ID,
+			Overrides:      gordian.SettingValues{"theme": []byte(`"dark"`), "seats": []byte(`10`)},
+			Rollouts:       gordian.FeatureRollouts{"new-ui": 50},
+			UpdatedAt:      e.now,
+		},
+		{
+			OrganizationID: e.org.ID,
+			Overrides:      gordian.SettingValues{"theme": []byte(`"light"`)},
+			UpdatedAt:      e.now.Add(time.Minute),
+		},
+	}
+	for i, want := range saves {
+		must(t, "Save", e.Settings.Save(e.ctx, want))
+		got, err := e.Settings.Get(e.ctx, e.org.ID)
+		must(t, "Get", err)
+		if len(got.Overrides) != len(want.Overrides) || len(got.Rollouts) != len(want.Rollouts) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+			t.Errorf("save %d: Get = %+v, want %+v", i, got, want)
+		}
+		for key, value := range want.Overrides {
+			if string(got.Overrides[key]) != string(value) {
+				t.Errorf("save %d: %s = %s, want %s", i, key, got.Overrides[key], value)
+			}
+		}
+		for key, value := range want.Rollouts {
+			if got.Rollouts[key] != value {
+				t.Errorf("save %d: rollout of %s = %d, want %d", i, key, got.Rollouts[key], value)
+			}
+		}
+	}
+
+	err = e.Transactor.WithinTransaction(e.ctx, func(ctx context.Context) error {
+		return e.Settings.LockSettings(ctx, e.org.ID)
+	})
+	must(t, "LockSettings", err)
+	err = e.Transactor.WithinTransaction(e.ctx, func(ctx context.Context) error {
+		return e.Settings.LockSettings(ctx, uuid.New())
+	})
+	wantErr(t, "LockSettings of an unknown organization", err, gordian.ErrNotFound)
+}
@@ -0,0 +1,247 @@
+package gordian
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InviteLink is an organization-wide invitation that any user can redeem, unlike an Invite,
+// which is issued to one email address.
+type InviteLink struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	CreatorID      uuid.UUID
+	Token          string // A unique, secret token for the link URL
+	Role           string // The role users get when they redeem the link
+	MaxUses        int    // 0 for unlimited
+	Uses           int
+	AllowedDomain  string     // If set, only users with a verified email address in this domain can redeem
+	ExpiresAt      *time.Time // nil for links that never expire
+	CreatedAt      time.Time
+	RevokedAt      *time.Time
+}
+
+func NewInviteLink(organizationID, creatorID uuid.UUID, token, role string, maxUses int, allowedDomain string, expiresAt *time.Time) *InviteLink {
+	return &InviteLink{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+		CreatorID:      creatorID,
+		Token:          token,
+		Role:           role,
+		MaxUses:        maxUses,
+		AllowedDomain:  allowedDomain,
+		ExpiresAt:      expiresAt,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// Active reports whether the link can still be redeemed at now.
+func (l *InviteLink) Active(now time.Time) bool {
+	return l.RevokedAt == nil &&
+		(l.ExpiresAt == nil || now.Before(*l.ExpiresAt)) &&
+		(l.MaxUses == 0 || l.Uses < l.MaxUses)
+}
+
+// InviteLinkRedemption records a user who joined through an InviteLink.
+type InviteLinkRedemption struct {
+	ID             uuid.UUID
+	LinkID         uuid.UUID
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	MembershipID   uuid.UUID
+	RedeemedAt     time.Time
+}
+
+// CreateInviteLink creates a link to orgID granting role. maxUses of 0 allows unlimited
+// redemptions, a nil expiresAt never expires, and a non-empty allowedDomain restricts the link
+// to users with a verified email address in that domain.
+func (s *Service) CreateInviteLink(ctx context.Context, orgID, creatorID uuid.UUID, role string, maxUses int, expiresAt *time.Time, allowedDomain string) (*InviteLink, error) {
+	if s.inviteLinkStore == nil {
+		return nil, notConfigured("invite links")
+	}
+	if role == "" || role == "owner" {
+		return nil, fmt.Errorf("invite links cannot grant role %q: %w", role, ErrInvalidArgument)
+	}
+	if maxUses < 0 {
+		return nil, fmt.Errorf("max uses cannot be negative: %w", ErrInvalidArgument)
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("invite link expiry must be in the future: %w", ErrInvalidArgument)
+	}
+	if allowedDomain != "" {
+		var err error
+		if allowedDomain, err = NormalizeDomain(allowedDomain); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := s.orgStore.Get(ctx, orgID); err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate invite link token: %w", err)
+	}
+	link := NewInviteLink(orgID, creatorID, hex.EncodeToString(raw), role, maxUses, allowedDomain, expiresAt)
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.inviteLinkStore.Create(ctx, link); err != nil {
+			return fmt.Errorf("failed to create invite link: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, ActorID: creatorID, Action: AuditInviteLinkCreated, TargetType: "invite_link", TargetID: link.ID}
+		return s.audit(ctx, entry, nil, snapshotInviteLink(link))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// ListInviteLinks returns every link of orgID, including revoked, expired and used up ones.
+func (s *Service) ListInviteLinks(ctx context.Context, orgID uuid.UUID) ([]*InviteLink, error) {
+	if s.inviteLinkStore == nil {
+		return nil, notConfigured("invite links")
+	}
+	links, err := s.inviteLinkStore.List(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invite links: %w", err)
+	}
+	return links, nil
+}
+
+// GetInviteLink returns the link with token, e.g. to show the organization on the landing page
+// of the link. Check Active before offering to join.
+func (s *Service) GetInviteLink(ctx context.Context, token string) (*InviteLink, error) {
+	if s.inviteLinkStore == nil {
+		return nil, notConfigured("invite links")
+	}
+	link, err := s.inviteLinkStore.GetByToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite link: %w", err)
+	}
+	return link, nil
+}
+
+// RevokeInviteLink disables a link of orgID. Members who joined through it stay members.
+func (s *Service) RevokeInviteLink(ctx context.Context, orgID, linkID uuid.UUID) error {
+	link, err := s.organizationInviteLink(ctx, orgID, linkID)
+	if err != nil {
+		return err
+	}
+	if link.RevokedAt != nil {
+		return fmt.Errorf("invite link is already revoked: %w", ErrConflict)
+	}
+
+	before := snapshotInviteLink(link)
+	revokedAt := time.Now()
+	link.RevokedAt = &revokedAt
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.inviteLinkStore.Update(ctx, link); err != nil {
+			return fmt.Errorf("failed to update invite link: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditInviteLinkRevoked, TargetType: "invite_link", TargetID: link.ID}
+		return s.audit(ctx, entry, before, snapshotInviteLink(link))
+	})
+}
+
+// RedeemInviteLink adds userID to the organization of the link with token. It fails with
+// ErrConflict if the link is revoked, expired or used up, or the user is already a member, and
+// with ErrForbidden if the user's email address is outside the link's allowed domain.
+func (s *Service) RedeemInviteLink(ctx context.Context, token string, userID uuid.UUID) (*Membership, error) {
+	link, err := s.GetInviteLink(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !link.Active(now) {
+		return nil, fmt.Errorf("invite link is revoked, expired or used up: %w", ErrConflict)
+	}
+	user, err := s.userStore.Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if link.AllowedDomain != "" {
+		if EmailDomain(user.Email) != link.AllowedDomain {
+			return nil, fmt.Errorf("invite link is restricted to %s addresses: %w", link.AllowedDomain, ErrForbidden)
+		}
+		if user.EmailVerifiedAt == nil {
+			return nil, fmt.Errorf("email address must be verified to use this invite link: %w", ErrForbidden)
+		}
+	}
+	if _, err := s.memStore.GetMembership(ctx, userID, link.OrganizationID); err == nil {
+		return nil, fmt.Errorf("user is already a member: %w", ErrConflict)
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+
+	membership := NewMembership(userID, link.OrganizationID, link.Role)
+	redemption := &InviteLinkRedemption{
+		ID:             uuid.New(),
+		LinkID:         link.ID,
+		OrganizationID: link.OrganizationID,
+		UserID:         userID,
+		MembershipID:   membership.ID,
+		RedeemedAt:     now,
+	}
+	events := []Event{
+		InviteLinkRedeemed{Link: link, Membership: membership},
+		MemberAdded{Membership: membership},
+	}
+	if err := s.before(ctx, events...); err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.checkQuota(ctx, link.OrganizationID, QuotaMembers); err != nil {
+			return err
+		}
+		if err := s.inviteLinkStore.Redeem(ctx, redemption); err != nil {
+			return fmt.Errorf("failed to redeem invite link: %w", err)
+		}
+		if err := s.memStore.Create(ctx, membership); err != nil {
+			return fmt.Errorf("failed to create membership: %w", err)
+		}
+		if err := s.queueWebhooks(ctx, events...); err != nil {
+			return err
+		}
+		entry := AuditEntry{OrganizationID: link.OrganizationID, ActorID: userID, Action: AuditInviteLinkRedeemed, TargetType: "membership", TargetID: membership.ID}
+		return s.audit(ctx, entry, nil, snapshotMembership(membership))
+	})
+	if err != nil {
+		return nil, err
+	}
+	link.Uses++
+	s.after(ctx, events...)
+	return membership, nil
+}
+
+// ListInviteLinkRedemptions returns who joined through a link of orgID, oldest first.
+func (s *Service) ListInviteLinkRedemptions(ctx context.Context, orgID, linkID uuid.UUID) ([]*InviteLinkRedemption, error) {
+	if _, err := s.organizationInviteLink(ctx, orgID, linkID); err != nil {
+		return nil, err
+	}
+	redemptions, err := s.inviteLinkStore.ListRedemptions(ctx, linkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invite link redemptions: %w", err)
+	}
+	return redemptions, nil
+}
+
+// organizationInviteLink returns linkID if it belongs to orgID.
+func (s *Service) organizationInviteLink(ctx context.Context, orgID, linkID uuid.UUID) (*InviteLink, error) {
+	if s.inviteLinkStore == nil {
+		return nil, notConfigured("invite links")
+	}
+	link, err := s.inviteLinkStore.Get(ctx, linkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite link: %w", err)
+	}
+	if link.OrganizationID != orgID {
+		return nil, fmt.Errorf("invite link does not belong to organization: %w", ErrNotFound)
+	}
+	return link, nil
+}
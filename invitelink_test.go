@@ -0,0 +1,210 @@
+package gordian_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func withInviteLinks(db *gorm.DB) gordian.Option {
+	return gordian.WithInviteLinks(gormadapter.NewInviteLinkStore(db))
+}
+
+func TestInviteLinkActive(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	tests := []struct {
+		name string
+		link gordian.InviteLink
+		want bool
+	}{
+		{name: "unlimited", link: gordian.InviteLink{}, want: true},
+		{name: "uses left", link: gordian.InviteLink{MaxUses: 2, Uses: 1, ExpiresAt: &future}, want: true},
+		{name: "used up", link: gordian.InviteLink{MaxUses: 2, Uses: 2}, want: false},
+		{name: "expired", link: gordian.InviteLink{ExpiresAt: &past}, want: false},
+		{name: "expiring now", link: gordian.InviteLink{ExpiresAt: &now}, want: false},
+		{name: "revoked", link: gordian.InviteLink{RevokedAt: &past}, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.link.Active(now); got != tt.want {
+			t.Errorf("%s: Active = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCreateInviteLink(t *testing.T) {
+	env := newTestEnv(t, withInviteLinks)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		orgID      uuid.UUID
+		role       string
+		maxUses    int
+		expiresAt  *time.Time
+		domain     string
+		wantDomain string
+		wantErr    error
+	}{
+		{name: "unlimited", orgID: org.ID, role: "member"},
+		{name: "limited", orgID: org.ID, role: "admin", maxUses: 5, expiresAt: &future},
+		{name: "domain", orgID: org.ID, role: "member", domain: " ACME.com. ", wantDomain: "acme.com"},
+		{name: "owner role", orgID: org.ID, role: "owner", wantErr: gordian.ErrInvalidArgument},
+		{name: "no role", orgID: org.ID, wantErr: gordian.ErrInvalidArgument},
+		{name: "negative max uses", orgID: org.ID, role: "member", maxUses: -1, wantErr: gordian.ErrInvalidArgument},
+		{name: "expired", orgID: org.ID, role: "member", expiresAt: &past, wantErr: gordian.ErrInvalidArgument},
+		{name: "invalid domain", orgID: org.ID, role: "member", domain: "@acme", wantErr: gordian.ErrInvalidArgument},
+		{name: "unknown organization", orgID: uuid.New(), role: "member", wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := env.svc.CreateInviteLink(env.ctx, tt.orgID, owner.ID, tt.role, tt.maxUses, tt.expiresAt, tt.domain)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateInviteLink = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if link.Token == "" || link.Role != tt.role || link.MaxUses != tt.maxUses || link.AllowedDomain != tt.wantDomain {
+				t.Errorf("link = %+v", link)
+			}
+			got, err := env.svc.GetInviteLink(env.ctx, link.Token)
+			if err != nil || got.ID != link.ID {
+				t.Errorf("GetInviteLink = %v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestRedeemInviteLink(t *testing.T) {
+	env := newTestEnv(t, withInviteLinks)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	create := func(maxUses int, domain string) *gordian.InviteLink {
+		t.Helper()
+		link, err := env.svc.CreateInviteLink(env.ctx, org.ID, owner.ID, "member", maxUses, nil, domain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return link
+	}
+	unlimited, restricted := create(0, ""), create(0, "acme.com")
+	usedUp := create(1, "")
+	if _, err := env.svc.RedeemInviteLink(env.ctx, usedUp.Token, env.user("first@example.com").ID); err != nil {
+		t.Fatal(err)
+	}
+	revoked := create(0, "")
+	if err := env.svc.RevokeInviteLink(env.ctx, org.ID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	verified := func(email string) uuid.UUID {
+		t.Helper()
+		user, err := env.svc.CreateUser(env.ctx, email, email, gordian.EmailVerified())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.ID
+	}
+	member := env.member(org, "member@example.com", "member")
+
+	tests := []struct {
+		name    string
+		token   string
+		userID  uuid.UUID
+		wantErr error
+	}{
+		{name: "unlimited", token: unlimited.Token, userID: env.user("alice@example.com").ID},
+		{name: "allowed domain", token: restricted.Token, userID: verified("bob@acme.com")},
+		{name: "allowed domain, unverified address", token: restricted.Token, userID: env.user("carol@acme.com").ID, wantErr: gordian.ErrForbidden},
+		{name: "other domain", token: restricted.Token, userID: verified("dave@example.com"), wantErr: gordian.ErrForbidden},
+		{name: "used up", token: usedUp.Token, userID: env.user("erin@example.com").ID, wantErr: gordian.ErrConflict},
+		{name: "revoked", token: revoked.Token, userID: env.user("frank@example.com").ID, wantErr: gordian.ErrConflict},
+		{name: "already a member", token: unlimited.Token, userID: member.ID, wantErr: gordian.ErrConflict},
+		{name: "unknown user", token: unlimited.Token, userID: uuid.New(), wantErr: gordian.ErrNotFound},
+		{name: "unknown token", token: "nope", userID: env.user("grace@example.com").ID, wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			membership, err := env.svc.RedeemInviteLink(env.ctx, tt.token, tt.userID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("RedeemInviteLink = %v, want %v", err, tt.wantErr)
+				}
+				if _, _, err := env.svc.GetMemberships(env.ctx, tt.userID, org.ID); tt.userID != member.ID && !errors.Is(err, gordian.ErrNotFound) {
+					t.Errorf("user joined anyway: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if membership.Role != "member" || membership.UserID != tt.userID {
+				t.Errorf("membership = %+v", membership)
+			}
+		})
+	}
+
+	tallies := []struct {
+		link     *gordian.InviteLink
+		wantUses int
+	}{
+		{link: unlimited, wantUses: 1},
+		{link: restricted, wantUses: 1},
+		{link: usedUp, wantUses: 1},
+		{link: revoked, wantUses: 0},
+	}
+	for _, tt := range tallies {
+		link, err := env.svc.GetInviteLink(env.ctx, tt.link.Token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		redemptions, err := env.svc.ListInviteLinkRedemptions(env.ctx, org.ID, tt.link.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if link.Uses != tt.wantUses || len(redemptions) != tt.wantUses {
+			t.Errorf("link %s: %d uses, %d redemptions, want %d", link.ID, link.Uses, len(redemptions), tt.wantUses)
+		}
+	}
+}
+
+func TestRevokeInviteLink(t *testing.T) {
+	env := newTestEnv(t, withInviteLinks)
+	owner := env.user("owner@example.com")
+	org, other := env.org("Acme", owner), env.org("Globex", owner)
+	link, err := env.svc.CreateInviteLink(env.ctx, org.ID, owner.ID, "member", 0, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cases run in order: the link is revoked by "revoke".
+	tests := []struct {
+		name    string
+		orgID   uuid.UUID
+		linkID  uuid.UUID
+		wantErr error
+	}{
+		{name: "other organization", orgID: other.ID, linkID: link.ID, wantErr: gordian.ErrNotFound},
+		{name: "revoke", orgID: org.ID, linkID: link.ID},
+		{name: "already revoked", orgID: org.ID, linkID: link.ID, wantErr: gordian.ErrConflict},
+		{name: "unknown link", orgID: org.ID, linkID: uuid.New(), wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := env.svc.RevokeInviteLink(env.ctx, tt.orgID, tt.linkID)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("RevokeInviteLink = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
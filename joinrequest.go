@@ -0,0 +1,319 @@
+package gordian
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JoinRequestStatus is the state of a JoinRequest.
+type JoinRequestStatus string
+
+const (
+	JoinRequestStatusPending  JoinRequestStatus = "pending"
+	JoinRequestStatusApproved JoinRequestStatus = "approved"
+	JoinRequestStatusDenied   JoinRequestStatus = "denied"
+)
+
+// How a user came to ask to join an organization.
+const (
+	JoinRequestSourceDirect = "direct" // The user asked, e.g. after following a link to the organization
+	JoinRequestSourceDomain = "domain" // A verified domain with DomainPolicyJoinRequest matched their email
+)
+
+// MaxJoinRequestMessageLength limits the note a user can attach to a join request.
+const MaxJoinRequestMessageLength = 1000
+
+// JoinRequest is a user asking to become a member of an organization, pending until an admin
+// approves or denies it. A user can have one pending request per organization.
+type JoinRequest struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Message        string // Note from the user to the admins
+	Source         string // JoinRequestSourceDirect or JoinRequestSourceDomain
+	Role           string // Suggested role, used on approval unless the admin picks another
+	Status         JoinRequestStatus
+	DeciderID      *uuid.UUID // The admin who approved or denied the request
+	CreatedAt      time.Time
+	DecidedAt      *time.Time
+}
+
+func NewJoinRequest(organizationID, userID uuid.UUID, message, source, role string) *JoinRequest {
+	return &JoinRequest{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Message:        message,
+		Source:         source,
+		Role:           role,
+		Status:         JoinRequestStatusPending,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// joinRequestEmail is the outbox payload of OutboxKindJoinRequest and OutboxKindJoinRequestDecision.
+type joinRequestEmail struct {
+	Request    *JoinRequest
+	Recipients []*User
+}
+
+// RequestToJoin asks for userID to become a member of orgID. The admins of the organization are
+// notified. It fails with ErrConflict if the user is already a member or has a pending request.
+func (s *Service) RequestToJoin(ctx context.Context, orgID, userID uuid.UUID, message string) (*JoinRequest, error) {
+	if s.joinRequestStore == nil {
+		return nil, notConfigured("join requests")
+	}
+	message = strings.TrimSpace(message)
+	if len(message) > MaxJoinRequestMessageLength {
+		return nil, fmt.Errorf("message cannot be longer than %d bytes: %w", MaxJoinRequestMessageLength, ErrInvalidArgument)
+	}
+	if _, err := s.orgStore.Get(ctx, orgID); err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	if _, err := s.userStore.Get(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return s.createJoinRequest(ctx, NewJoinRequest(orgID, userID, message, JoinRequestSourceDirect, "member"))
+}
+
+// createJoinRequest records request and notifies the admins of its organization.
+func (s *Service) createJoinRequest(ctx context.Context, request *JoinRequest) (*JoinRequest, error) {
+	_, err := s.memStore.GetMembership(ctx, request.UserID, request.OrganizationID)
+	if err == nil {
+		return nil, fmt.Errorf("user is already a member: %w", ErrConflict)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+	if _, err := s.joinRequestStore.GetPending(ctx, request.OrganizationID, request.UserID); err == nil {
+		return nil, fmt.Errorf("user already has a pending join request: %w", ErrConflict)
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to get join request: %w", err)
+	}
+	admins, err := s.joinRequestApprovers(ctx, request.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	email := joinRequestEmail{Request: request, Recipients: admins}
+
+	if err := s.before(ctx, JoinRequested{Request: request}); err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.joinRequestStore.Create(ctx, request); err != nil {
+			return fmt.Errorf("failed to create join request: %w", err)
+		}
+		if err := s.queueJoinRequestEmail(ctx, OutboxKindJoinRequest, email); err != nil {
+			return err
+		}
+		if err := s.queueWebhooks(ctx, JoinRequested{Request: request}); err != nil {
+			return err
+		}
+		entry := AuditEntry{OrganizationID: request.OrganizationID, ActorID: request.UserID, Action: AuditJoinRequestCreated, TargetType: "join_request", TargetID: request.ID}
+		return s.audit(ctx, entry, nil, snapshotJoinRequest(request))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.sendJoinRequestEmail(ctx, OutboxKindJoinRequest, email)
+	s.after(ctx, JoinRequested{Request: request})
+	return request, nil
+}
+
+// ListJoinRequests returns the join requests of orgID with status, or all of them when status is empty.
+func (s *Service) ListJoinRequests(ctx context.Context, orgID uuid.UUID, status JoinRequestStatus) ([]*JoinRequest, error) {
+	if s.joinRequestStore == nil {
+		return nil, notConfigured("join requests")
+	}
+	requests, err := s.joinRequestStore.List(ctx, orgID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list join requests: %w", err)
+	}
+	return requests, nil
+}
+
+// ApproveJoinRequest adds the requester to orgID with role, or with the role suggested by the
+// request when role is empty, and notifies them.
+func (s *Service) ApproveJoinRequest(ctx context.Context, orgID, requestID, deciderID uuid.UUID, role string) (*Membership, error) {
+	request, err := s.pendingJoinRequest(ctx, orgID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		role = request.Role
+	}
+	if role == "owner" {
+		return nil, fmt.Errorf("join requests cannot grant role %q: %w", role, ErrInvalidArgument)
+	}
+	requester, err := s.userStore.Get(ctx, request.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	before := snapshotJoinRequest(request)
+	membership := NewMembership(request.UserID, orgID, role)
+	decideJoinRequest(request, JoinRequestStatusApproved, deciderID)
+	request.Role = role
+	email := joinRequestEmail{Request: request, Recipients: []*User{requester}}
+
+	events := []Event{
+		JoinRequestApproved{Request: request, Membership: membership},
+		MemberAdded{Membership: membership},
+	}
+	if err := s.before(ctx, events...); err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.checkQuota(ctx, request.OrganizationID, QuotaMembers); err != nil {
+			return err
+		}
+		if err := s.memStore.Create(ctx, membership); err != nil {
+			return fmt.Errorf("failed to create membership: %w", err)
+		}
+		if err := s.joinRequestStore.Update(ctx, request); err != nil {
+			return fmt.Errorf("failed to update join request: %w", err)
+		}
+		if err := s.queueJoinRequestEmail(ctx, OutboxKindJoinRequestDecision, email); err != nil {
+			return err
+		}
+		if err := s.queueWebhooks(ctx, events...); err != nil {
+			return err
+		}
+		entry := AuditEntry{OrganizationID: orgID, ActorID: deciderID, Action: AuditJoinRequestApproved, TargetType: "join_request", TargetID: request.ID}
+		return s.audit(ctx, entry, before, snapshotJoinRequest(request))
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.sendJoinRequestEmail(ctx, OutboxKindJoinRequestDecision, email)
+	s.after(ctx, events...)
+	return membership, nil
+}
+
+// DenyJoinRequest rejects a pending join request of orgID and notifies the requester.
+// The user can ask again afterwards.
+func (s *Service) DenyJoinRequest(ctx context.Context, orgID, requestID, deciderID uuid.UUID) error {
+	request, err := s.pendingJoinRequest(ctx, orgID, requestID)
+	if err != nil {
+		return err
+	}
+	requester, err := s.userStore.Get(ctx, request.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	before := snapshotJoinRequest(request)
+	decideJoinRequest(request, JoinRequestStatusDenied, deciderID)
+	email := joinRequestEmail{Request: request, Recipients: []*User{requester}}
+
+	if err := s.before(ctx, JoinRequestDenied{Request: request}); err != nil {
+		return err
+	}
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.joinRequestStore.Update(ctx, request); err != nil {
+			return fmt.Errorf("failed to update join request: %w", err)
+		}
+		if err := s.queueJoinRequestEmail(ctx, OutboxKindJoinRequestDecision, email); err != nil {
+			return err
+		}
+		if err := s.queueWebhooks(ctx, JoinRequestDenied{Request: request}); err != nil {
+			return err
+		}
+		entry := AuditEntry{OrganizationID: orgID, ActorID: deciderID, Action: AuditJoinRequestDenied, TargetType: "join_request", TargetID: request.ID}
+		return s.audit(ctx, entry, before, snapshotJoinRequest(request))
+	})
+	if err != nil {
+		return err
+	}
+	s.sendJoinRequestEmail(ctx, OutboxKindJoinRequestDecision, email)
+	s.after(ctx, JoinRequestDenied{Request: request})
+	return nil
+}
+
+// pendingJoinRequest returns requestID if it belongs to orgID and has not been decided yet.
+func (s *Service) pendingJoinRequest(ctx context.Context, orgID, requestID uuid.UUID) (*JoinRequest, error) {
+	if s.joinRequestStore == nil {
+		return nil, notConfigured("join requests")
+	}
+	request, err := s.joinRequestStore.Get(ctx, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get join request: %w", err)
+	}
+	if request.OrganizationID != orgID {
+		return nil, fmt.Errorf("join request does not belong to organization: %w", ErrNotFound)
+	}
+	if request.Status != JoinRequestStatusPending {
+		return nil, fmt.Errorf("join request has already been %s: %w", request.Status, ErrConflict)
+	}
+	return request, nil
+}
+
+func decideJoinRequest(request *JoinRequest, status JoinRequestStatus, deciderID uuid.UUID) {
+	now := time.Now()
+	request.Status = status
+	request.DecidedAt = &now
+	if deciderID != uuid.Nil {
+		request.DeciderID = &deciderID
+	}
+}
+
+// joinRequestApprovers returns the owners and admins of orgID.
+func (s *Service) joinRequestApprovers(ctx context.Context, orgID uuid.UUID) ([]*User, error) {
+	memberships, err := s.memStore.GetMembers(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memberships: %w", err)
+	}
+	var admins []*User
+	for _, m := range memberships {
+		if m.Role != "owner" && m.Role != "admin" {
+			continue
+		}
+		user, err := s.userStore.Get(ctx, m.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		admins = append(admins, user)
+	}
+	return admins, nil
+}
+
+// queueJoinRequestEmail writes email to the outbox inside the caller's transaction. Without an
+// outbox, or with an Emailer that does not send join request emails, it does nothing.
+func (s *Service) queueJoinRequestEmail(ctx context.Context, kind string, email joinRequestEmail) error {
+	if _, ok := s.emailer.(JoinRequestEmailer); !ok || s.outboxStore == nil || len(email.Recipients) == 0 {
+		return nil
+	}
+	msg, err := NewOutboxMessage(kind, email)
+	if err != nil {
+		return err
+	}
+	if err := s.outboxStore.Create(ctx, msg); err != nil {
+		return fmt.Errorf("failed to queue join request email: %w", err)
+	}
+	return nil
+}
+
+// sendJoinRequestEmail sends email right away when there is no outbox. The request has already
+// been saved, so failures are logged rather than returned.
+func (s *Service) sendJoinRequestEmail(ctx context.Context, kind string, email joinRequestEmail) {
+	emailer, ok := s.emailer.(JoinRequestEmailer)
+	if !ok || s.outboxStore != nil || len(email.Recipients) == 0 {
+		return
+	}
+	if err := deliverJoinRequestEmail(ctx, emailer, kind, email); err != nil {
+		log.Printf("ERROR: failed to send join request email for %s: %v", email.Request.ID, err)
+	}
+}
+
+func deliverJoinRequestEmail(ctx context.Context, emailer JoinRequestEmailer, kind string, email joinRequestEmail) error {
+	if kind == OutboxKindJoinRequest {
+		return emailer.SendJoinRequest(ctx, email.Request, email.Recipients)
+	}
+	return emailer.SendJoinRequestDecision(ctx, email.Request, email.Recipients[0])
+}
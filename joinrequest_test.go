@@ -0,0 +1,152 @@
+package gordian_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	"github.com/google/uuid"
+)
+
+func TestRequestToJoin(t *testing.T) {
+	env := newTestEnv(t, withJoinRequests)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	member := env.member(org, "member@example.com", "member")
+	alice := env.user("alice@example.com")
+
+	// The cases run in order: "pending" follows the request of "valid".
+	tests := []struct {
+		name    string
+		orgID   uuid.UUID
+		userID  uuid.UUID
+		message string
+		wantErr error
+	}{
+		{name: "valid", orgID: org.ID, userID: alice.ID, message: "  Hi, I'm on the platform team.  "},
+		{name: "pending", orgID: org.ID, userID: alice.ID, wantErr: gordian.ErrConflict},
+		{name: "already a member", orgID: org.ID, userID: member.ID, wantErr: gordian.ErrConflict},
+		{name: "message too long", orgID: org.ID, userID: member.ID, message: strings.Repeat("a", gordian.MaxJoinRequestMessageLength+1), wantErr: gordian.ErrInvalidArgument},
+		{name: "unknown organization", orgID: uuid.New(), userID: alice.ID, wantErr: gordian.ErrNotFound},
+		{name: "unknown user", orgID: org.ID, userID: uuid.New(), wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := env.svc.RequestToJoin(env.ctx, tt.orgID, tt.userID, tt.message)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("RequestToJoin = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if request.Status != gordian.JoinRequestStatusPending || request.Source != gordian.JoinRequestSourceDirect || request.Message != strings.TrimSpace(tt.message) {
+				t.Errorf("request = %+v", request)
+			}
+		})
+	}
+
+	env.emailer.mu.Lock()
+	defer env.emailer.mu.Unlock()
+	if len(env.emailer.requests) != 1 {
+		t.Errorf("admins were notified of %d requests, want 1", len(env.emailer.requests))
+	}
+}
+
+func TestDecideJoinRequest(t *testing.T) {
+	env := newTestEnv(t, withJoinRequests)
+	owner := env.user("owner@example.com")
+	org, other := env.org("Acme", owner), env.org("Globex", owner)
+	request := func(email string) *gordian.JoinRequest {
+		t.Helper()
+		request, err := env.svc.RequestToJoin(env.ctx, org.ID, env.user(email).ID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return request
+	}
+	decided := request("decided@example.com")
+	if err := env.svc.DenyJoinRequest(env.ctx, org.ID, decided.ID, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		request    *gordian.JoinRequest
+		orgID      uuid.UUID
+		approve    bool
+		role       string
+		wantStatus gordian.JoinRequestStatus
+		wantRole   string // role of the requester afterwards, "" for none
+		wantErr    error
+	}{
+		{name: "approve with the suggested role", request: request("a@example.com"), orgID: org.ID, approve: true, wantStatus: gordian.JoinRequestStatusApproved, wantRole: "member"},
+		{name: "approve with another role", request: request("b@example.com"), orgID: org.ID, approve: true, role: "admin", wantStatus: gordian.JoinRequestStatusApproved, wantRole: "admin"},
+		{name: "approve as owner", request: request("c@example.com"), orgID: org.ID, approve: true, role: "owner", wantStatus: gordian.JoinRequestStatusPending, wantErr: gordian.ErrInvalidArgument},
+		{name: "deny", request: request("d@example.com"), orgID: org.ID, wantStatus: gordian.JoinRequestStatusDenied},
+		{name: "approve in another organization", request: request("e@example.com"), orgID: other.ID, approve: true, wantStatus: gordian.JoinRequestStatusPending, wantErr: gordian.ErrNotFound},
+		{name: "deny in another organization", request: request("f@example.com"), orgID: other.ID, wantStatus: gordian.JoinRequestStatusPending, wantErr: gordian.ErrNotFound},
+		{name: "approve a decided request", request: decided, orgID: org.ID, approve: true, wantStatus: gordian.JoinRequestStatusDenied, wantErr: gordian.ErrConflict},
+		{name: "deny a decided request", request: decided, orgID: org.ID, wantStatus: gordian.JoinRequestStatusDenied, wantErr: gordian.ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.approve {
+				_, err = env.svc.ApproveJoinRequest(env.ctx, tt.orgID, tt.request.ID, owner.ID, tt.role)
+			} else {
+				err = env.svc.DenyJoinRequest(env.ctx, tt.orgID, tt.request.ID, owner.ID)
+			}
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			requests, err := env.svc.ListJoinRequests(env.ctx, org.ID, tt.wantStatus)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var found *gordian.JoinRequest
+			for _, r := range requests {
+				if r.ID == tt.request.ID {
+					found = r
+				}
+			}
+			if found == nil {
+				t.Fatalf("request is not %s", tt.wantStatus)
+			}
+			if tt.wantErr == nil && (found.DeciderID == nil || *found.DeciderID != owner.ID || found.DecidedAt == nil) {
+				t.Errorf("decision was not recorded: %+v", found)
+			}
+			_, role, err := env.svc.GetMemberships(env.ctx, tt.request.UserID, org.ID)
+			if tt.wantRole == "" && !errors.Is(err, gordian.ErrNotFound) || tt.wantRole != "" && role != tt.wantRole {
+				t.Errorf("GetMemberships = %q, %v, want %q", role, err, tt.wantRole)
+			}
+		})
+	}
+
+	env.emailer.mu.Lock()
+	defer env.emailer.mu.Unlock()
+	if len(env.emailer.decisions) != 4 {
+		t.Errorf("requesters were notified of %d decisions, want 4", len(env.emailer.decisions))
+	}
+}
+
+func TestRequestToJoinAgainAfterDenial(t *testing.T) {
+	env := newTestEnv(t, withJoinRequests)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	alice := env.user("alice@example.com")
+	request, err := env.svc.RequestToJoin(env.ctx, org.ID, alice.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.svc.DenyJoinRequest(env.ctx, org.ID, request.ID, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.RequestToJoin(env.ctx, org.ID, alice.ID, "Please reconsider"); err != nil {
+		t.Errorf("RequestToJoin after denial = %v", err)
+	}
+}
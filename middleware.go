@@ -2,6 +2,7 @@ package gordian
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 
@@ -12,8 +13,41 @@ const (
 	ActiveOrgIDKey        = "active_org_id"
 	ActiveRoleKey         = "active_role"
 	ActiveMembershipIDKey = "active_membership_id"
+	ActiveSettingsKey     = "active_settings"
 )
 
+// WithActiveTenant stores the resolved organization, role and membership in ctx under the Active*Key keys.
+func WithActiveTenant(ctx context.Context, orgID uuid.UUID, role string, membershipID uuid.UUID) context.Context {
+	ctx = context.WithValue(ctx, ActiveOrgIDKey, orgID)
+	ctx = context.WithValue(ctx, ActiveRoleKey, role)
+	ctx = context.WithValue(ctx, ActiveMembershipIDKey, membershipID)
+	return ctx
+}
+
+// ActiveOrgID returns the organization resolved for the request, if any.
+func ActiveOrgID(ctx context.Context) (uuid.UUID, bool) {
+	orgID, ok := ctx.Value(ActiveOrgIDKey).(uuid.UUID)
+	return orgID, ok
+}
+
+// ActiveRole returns the role of the current user in the active organization.
+func ActiveRole(ctx context.Context) string {
+	role, _ := ctx.Value(ActiveRoleKey).(string)
+	return role
+}
+
+// WithActiveSettings stores the settings of the active organization in ctx under ActiveSettingsKey.
+func WithActiveSettings(ctx context.Context, settings *Settings) context.Context {
+	return context.WithValue(ctx, ActiveSettingsKey, settings)
+}
+
+// ActiveSettings returns the settings of the organization resolved for the request, or nil
+// without WithSettings. Read them with Setting.FromContext and FeatureFlag.Enabled.
+func ActiveSettings(ctx context.Context) *Settings {
+	settings, _ := ctx.Value(ActiveSettingsKey).(*Settings)
+	return settings
+}
+
 func (s *Service) TenancyMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID_from_ctx := r.Context().Value("user_id") // Using a generic "user_id" key for example
@@ -29,18 +63,25 @@ func (s *Service) TenancyMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		tenantID_from_header := r.Header.Get("X-Tenant-ID")
-		if tenantID_from_header == "" {
-			http.Error(w, "Missing X-Tenant-ID header", http.StatusBadRequest)
+		orgID, err := s.tenantResolver(r)
+		switch {
+		case errors.Is(err, ErrInvalidArgument):
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
-		}
-		orgID, err := uuid.Parse(tenantID_from_header)
-		if err != nil {
-			http.Error(w, "Invalid X-Tenant-ID header", http.StatusBadRequest)
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, "Organization not found", http.StatusNotFound)
+			return
+		case err != nil:
+			log.Printf("ERROR: failed to resolve tenant: %v", err)
+			http.Error(w, "Failed to resolve organization", http.StatusInternalServerError)
 			return
 		}
 
 		membershipID, role, err := s.GetMemberships(r.Context(), userID, orgID)
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "User not a member of organization", http.StatusForbidden)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to get membership", http.StatusInternalServerError)
 			return
@@ -51,11 +92,18 @@ func (s *Service) TenancyMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, ActiveOrgIDKey, orgID)
-		ctx = context.WithValue(ctx, ActiveRoleKey, role)
-		ctx = context.WithValue(ctx, ActiveMembershipIDKey, membershipID)
+		ctx := WithActiveTenant(r.Context(), orgID, role, membershipID)
+		if s.settingsStore != nil {
+			settings, err := s.GetSettings(r.Context(), orgID)
+			if err != nil {
+				log.Printf("ERROR: failed to load settings of %s: %v", orgID, err)
+				http.Error(w, "Failed to load organization settings", http.StatusInternalServerError)
+				return
+			}
+			ctx = WithActiveSettings(ctx, settings)
+		}
+		ctx = WithRequestMetadata(ctx, s.RequestMetadata(r))
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
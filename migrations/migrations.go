@@ -0,0 +1,291 @@
+// package migrations ships the versioned SQL schema of the Gordian tables and an embedded runner for it.
+//
+//	m, err := migrations.New(sqlDB, migrations.Postgres)
+//	err = m.Up(ctx)    // apply pending migrations
+//	err = m.Check(ctx) // at startup: fail with ErrSchemaBehind when migrations are pending
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Dialect selects the set of SQL files matching the database.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// Table records the applied migrations.
+const Table = "gordian_schema_migrations"
+
+// ErrSchemaBehind is returned by Check when the database misses migrations known to this build.
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // nil while pending
+}
+
+// Migrator applies and rolls back the embedded migrations of one dialect.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+func New(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	migrations, err := load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// Migrations returns the embedded migrations in version order.
+func (m *Migrator) Migrations() []Migration {
+	return slices.Clone(m.migrations)
+}
+
+// Up applies every pending migration, each in its own transaction.
+func (m *Migrator) Up(ctx context.Context) error {
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+	for _, mig := range m.migrations {
+		err := m.inTx(ctx, func(tx *sql.Tx) error {
+			applied, err := isApplied(ctx, tx, mig.Version)
+			if err != nil || applied {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (%d, '%s', '%s')",
+				Table, mig.Version, mig.Name, time.Now().UTC().Format(time.RFC3339)))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s failed: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return nil
+}
+
+// Down rolls back the last steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+	for _, mig := range slices.Backward(m.migrations) {
+		if steps <= 0 {
+			return nil
+		}
+		var rolledBack bool
+		err := m.inTx(ctx, func(tx *sql.Tx) error {
+			applied, err := isApplied(ctx, tx, mig.Version)
+			if err != nil || !applied {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE version = %d", Table, mig.Version))
+			rolledBack = err == nil
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("rollback of %04d_%s failed: %w", mig.Version, mig.Name, err)
+		}
+		if rolledBack {
+			steps--
+		}
+	}
+	return nil
+}
+
+// Status lists every embedded migration with the time it was applied. It only reads, so it
+// can run with a role that may not change the schema; on a new database every migration is pending.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Check returns ErrSchemaBehind when any embedded migration has not been applied. Like
+// Status, it never changes the database.
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	var pending int
+	for _, st := range statuses {
+		if st.AppliedAt == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d of %d migrations pending", ErrSchemaBehind, pending, len(statuses))
+	}
+	return nil
+}
+
+// applied returns when each applied migration was applied, nothing if the table is missing.
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	query := "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = '" + Table + "'"
+	if m.dialect == SQLite {
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = '" + Table + "'"
+	}
+	var tables int
+	if err := m.db.QueryRowContext(ctx, query).Scan(&tables); err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", Table, err)
+	}
+	applied := map[int]time.Time{}
+	if tables == 0 {
+		return applied, nil
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM "+Table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+		applied[version], _ = time.Parse(time.RFC3339, appliedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// ensureTable creates the migrations table. Concurrent CREATE TABLE IF NOT EXISTS statements
+// can still collide on PostgreSQL, so runners serialize on an advisory lock held until commit.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if m.dialect == Postgres {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('"+Table+"'))"); err != nil {
+			return fmt.Errorf("failed to lock %s: %w", Table, err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+Table+
+		" (version BIGINT PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL)")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", Table, err)
+	}
+	return tx.Commit()
+}
+
+// inTx runs fn in a transaction that holds the migration lock, so concurrent runners
+// apply each migration once. On SQLite the lock is the database's write lock, taken by a
+// write before anything is read: a transaction that reads first cannot wait for it and
+// fails with SQLITE_BUSY instead.
+func (m *Migrator) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	lock := "LOCK TABLE " + Table + " IN EXCLUSIVE MODE"
+	if m.dialect == SQLite {
+		lock = "DELETE FROM " + Table + " WHERE 0"
+	}
+	if _, err := tx.ExecContext(ctx, lock); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func isApplied(ctx context.Context, tx *sql.Tx, version int) (bool, error) {
+	var count int
+	err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE version = %d", Table, version)).Scan(&count)
+	return count > 0, err
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+func load(dialect Dialect) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, string(dialect))
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q", dialect)
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		body, err := files.ReadFile(path.Join(string(dialect), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		version, _ := strconv.Atoi(match[1])
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+		if match[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
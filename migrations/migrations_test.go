@@ -0,0 +1,314 @@
+package migrations_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Robotech-Org/gordian/internal/pgtest"
+	"github.com/Robotech-Org/gordian/migrations"
+	_ "github.com/glebarez/go-sqlite"
+)
+
+// database opens an empty database of one dialect and lists its tables.
+type database struct {
+	dialect migrations.Dialect
+	open    func(t *testing.T) *sql.DB
+	tables  string // query returning the table names
+}
+
+var databases = []database{
+	{
+		dialect: migrations.SQLite,
+		open: func(t *testing.T) *sql.DB {
+			dsn := "file:" + filepath.Join(t.TempDir(), "gordian.db") + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)"
+			return openDB(t, "sqlite", dsn)
+		},
+		tables: "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'",
+	},
+	{
+		dialect: migrations.Postgres,
+		open: func(t *testing.T) *sql.DB {
+			dsn, _ := pgtest.Schema(t)
+			return openDB(t, "pgx", dsn)
+		},
+		tables: "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema()",
+	},
+}
+
+func openDB(t *testing.T, driver, dsn string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newMigrator(t *testing.T, db *sql.DB, dialect migrations.Dialect) *migrations.Migrator {
+	t.Helper()
+	m, err := migrations.New(db, dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func tableNames(t *testing.T, db *sql.DB, query string) []string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestDialectsHaveTheSameMigrations(t *testing.T) {
+	var want []string
+	for _, d := range databases {
+		m, err := migrations.New(nil, d.dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for i, mig := range m.Migrations() {
+			if mig.Version != i+1 {
+				t.Errorf("%s: migration %d has version %d, want consecutive versions", d.dialect, i, mig.Version)
+			}
+			got = append(got, mig.Name)
+		}
+		if want == nil {
+			want = got
+		} else if !slices.Equal(got, want) {
+			t.Errorf("%s migrations = %v, want %v", d.dialect, got, want)
+		}
+	}
+
+	if _, err := migrations.New(nil, "mysql"); err == nil {
+		t.Error("New accepted an unknown dialect")
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	for _, d := range databases {
+		t.Run(string(d.dialect), func(t *testing.T) {
+			db := d.open(t)
+			m := newMigrator(t, db, d.dialect)
+			total := len(m.Migrations())
+
+			if err := m.Check(ctx); !errors.Is(err, migrations.ErrSchemaBehind) {
+				t.Fatalf("Check on an empty database = %v, want ErrSchemaBehind", err)
+			}
+			if tables := tableNames(t, db, d.tables); len(tables) != 0 {
+				t.Fatalf("Check created tables %v", tables)
+			}
+			if err := m.Up(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Up(ctx); err != nil {
+				t.Fatalf("second Up: %v", err)
+			}
+			if err := m.Check(ctx); err != nil {
+				t.Fatalf("Check after Up = %v", err)
+			}
+			migrated := tableNames(t, db, d.tables)
+
+			steps := []struct {
+				name        string
+				run         func() error
+				wantPending int
+			}{
+				{name: "down one", run: func() error { return m.Down(ctx, 1) }, wantPending: 1},
+				{name: "down all", run: func() error { return m.Down(ctx, total) }, wantPending: total},
+				{name: "down beyond the first", run: func() error { return m.Down(ctx, 1) }, wantPending: total},
+				{name: "up again", run: func() error { return m.Up(ctx) }, wantPending: 0},
+			}
+			for _, step := range steps {
+				if err := step.run(); err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				statuses, err := m.Status(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var pending int
+				for _, st := range statuses {
+					if st.AppliedAt == nil {
+						pending++
+					}
+				}
+				if pending != step.wantPending {
+					t.Errorf("%s: %d migrations pending, want %d", step.name, pending, step.wantPending)
+				}
+				if step.wantPending == total {
+					if tables := tableNames(t, db, d.tables); !slices.Equal(tables, []string{migrations.Table}) {
+						t.Errorf("%s: tables %v remain", step.name, tables)
+					}
+				}
+			}
+			if tables := tableNames(t, db, d.tables); !slices.Equal(tables, migrated) {
+				t.Errorf("tables after down and up = %v, want %v", tables, migrated)
+			}
+		})
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	ctx := context.Background()
+	const (
+		user  = "11111111-1111-1111-1111-111111111111"
+		other = "22222222-2222-2222-2222-222222222222"
+		org   = "33333333-3333-3333-3333-333333333333"
+		at    = "2024-01-01T00:00:00Z"
+	)
+	for _, d := range databases {
+		t.Run(string(d.dialect), func(t *testing.T) {
+			db := d.open(t)
+			if err := newMigrator(t, db, d.dialect).Up(ctx); err != nil {
+				t.Fatal(err)
+			}
+			exec := func(query string, args ...any) error {
+				if d.dialect == migrations.Postgres {
+					for i := range args {
+						query = strings.Replace(query, "?", fmt.Sprintf("$%d", i+1), 1)
+					}
+				}
+				_, err := db.Exec(query, args...)
+				return err
+			}
+			for _, stmt := range []struct {
+				query string
+				args  []any
+			}{
+				{"INSERT INTO users (id, email, created_at) VALUES (?, ?, ?)", []any{user, "a@example.com", at}},
+				{"INSERT INTO organizations (id, name, slug, owner_id, created_at) VALUES (?, ?, ?, ?, ?)", []any{org, "Acme", "acme", user, at}},
+				{"INSERT INTO memberships (id, organization_id, user_id, role, joined_at) VALUES (?, ?, ?, ?, ?)", []any{other, org, user, "owner", at}},
+			} {
+				if err := exec(stmt.query, stmt.args...); err != nil {
+					t.Fatalf("%s: %v", stmt.query, err)
+				}
+			}
+
+			tests := []struct {
+				name  string
+				query string
+				args  []any
+			}{
+				{"duplicate email", "INSERT INTO users (id, email, created_at) VALUES (?, ?, ?)", []any{other, "a@example.com", at}},
+				{"duplicate membership", "INSERT INTO memberships (id, organization_id, user_id, role, joined_at) VALUES (?, ?, ?, ?, ?)", []any{user, org, user, "admin", at}},
+				{"duplicate slug", "INSERT INTO organizations (id, name, slug, owner_id, created_at) VALUES (?, ?, ?, ?, ?)", []any{other, "Other", "acme", user, at}},
+				{"unknown owner", "INSERT INTO organizations (id, name, slug, owner_id, created_at) VALUES (?, ?, ?, ?, ?)", []any{other, "Other", "other", other, at}},
+				{"membership of unknown organization", "INSERT INTO memberships (id, organization_id, user_id, role, joined_at) VALUES (?, ?, ?, ?, ?)", []any{org, other, user, "member", at}},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					if err := exec(tt.query, tt.args...); err == nil {
+						t.Errorf("the schema accepted the row")
+					}
+				})
+			}
+
+			if err := exec("DELETE FROM organizations WHERE id = ?", org); err != nil {
+				t.Fatal(err)
+			}
+			var memberships int
+			if err := db.QueryRow("SELECT COUNT(*) FROM memberships").Scan(&memberships); err != nil {
+				t.Fatal(err)
+			}
+			if memberships != 0 {
+				t.Errorf("deleting the organization left %d memberships", memberships)
+			}
+		})
+	}
+}
+
+// TestSlugMigrationBackfills applies the migrations up to 0008, adds an organization the way an
+// older release would, and checks that 0009 gives it its ID as slug.
+func TestSlugMigrationBackfills(t *testing.T) {
+	ctx := context.Background()
+	const org = "33333333-3333-3333-3333-333333333333"
+	for _, d := range databases {
+		t.Run(string(d.dialect), func(t *testing.T) {
+			db := d.open(t)
+			m := newMigrator(t, db, d.dialect)
+			if err := m.Up(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.Down(ctx, len(m.Migrations())-8); err != nil {
+				t.Fatal(err)
+			}
+			for _, query := range []string{
+				"INSERT INTO users (id, email, created_at) VALUES ('" + org + "', 'a@example.com', '2024-01-01T00:00:00Z')",
+				"INSERT INTO organizations (id, name, owner_id, created_at) VALUES ('" + org + "', 'Acme', '" + org + "', '2024-01-01T00:00:00Z')",
+			} {
+				if _, err := db.Exec(query); err != nil {
+					t.Fatalf("%s: %v", query, err)
+				}
+			}
+			if err := m.Up(ctx); err != nil {
+				t.Fatal(err)
+			}
+			var slug string
+			if err := db.QueryRow("SELECT slug FROM organizations").Scan(&slug); err != nil {
+				t.Fatal(err)
+			}
+			if slug != org {
+				t.Errorf("slug = %q, want the organization ID", slug)
+			}
+		})
+	}
+}
+
+func TestConcurrentUp(t *testing.T) {
+	ctx := context.Background()
+	for _, d := range databases {
+		t.Run(string(d.dialect), func(t *testing.T) {
+			db := d.open(t)
+			runners := make([]*migrations.Migrator, 4)
+			for i := range runners {
+				runners[i] = newMigrator(t, db, d.dialect)
+			}
+			var wg sync.WaitGroup
+			errs := make([]error, len(runners))
+			for i, m := range runners {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs[i] = m.Up(ctx)
+				}()
+			}
+			wg.Wait()
+			if err := errors.Join(errs...); err != nil {
+				t.Fatal(err)
+			}
+			var applied int
+			if err := db.QueryRow("SELECT COUNT(*) FROM " + migrations.Table).Scan(&applied); err != nil {
+				t.Fatal(err)
+			}
+			if want := len(runners[0].Migrations()); applied != want {
+				t.Errorf("%d migrations recorded, want %d", applied, want)
+			}
+		})
+	}
+}
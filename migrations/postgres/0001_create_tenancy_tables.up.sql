@@ -0,0 +1,40 @@
+CREATE TABLE users (
+    id         UUID PRIMARY KEY,
+    email      TEXT NOT NULL,
+    name       TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+
+CREATE TABLE organizations (
+    id         UUID PRIMARY KEY,
+    name       TEXT NOT NULL,
+    owner_id   UUID NOT NULL REFERENCES users (id),
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_organizations_owner_id ON organizations (owner_id);
+
+CREATE TABLE memberships (
+    id              UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role            TEXT NOT NULL,
+    joined_at       TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX idx_memberships_user_org ON memberships (user_id, organization_id);
+CREATE INDEX idx_memberships_organization_id ON memberships (organization_id);
+
+CREATE TABLE invites (
+    id              UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    inviter_id      UUID NOT NULL REFERENCES users (id),
+    invitee_email   TEXT NOT NULL,
+    role            TEXT NOT NULL,
+    token           TEXT NOT NULL,
+    expires_at      TIMESTAMPTZ NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL,
+    accepted_at     TIMESTAMPTZ,
+    revoked_at      TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX idx_invites_token ON invites (token);
+CREATE INDEX idx_invites_organization_id ON invites (organization_id);
@@ -0,0 +1,12 @@
+CREATE TABLE outbox_messages (
+    id              UUID PRIMARY KEY,
+    kind            TEXT NOT NULL,
+    payload         BYTEA NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        BIGINT NOT NULL DEFAULT 0,
+    last_error      TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL,
+    sent_at         TIMESTAMPTZ
+);
+CREATE INDEX idx_outbox_messages_due ON outbox_messages (status, next_attempt_at);
@@ -0,0 +1,16 @@
+-- No foreign keys: the audit log outlives the organizations and users it mentions.
+CREATE TABLE audit_entries (
+    id              UUID PRIMARY KEY,
+    organization_id UUID NOT NULL,
+    actor_id        UUID NOT NULL,
+    action          TEXT NOT NULL,
+    target_type     TEXT NOT NULL,
+    target_id       UUID NOT NULL,
+    before          BYTEA,
+    after           BYTEA,
+    ip_address      TEXT NOT NULL DEFAULT '',
+    user_agent      TEXT NOT NULL DEFAULT '',
+    request_id      TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_audit_entries_org_created ON audit_entries (organization_id, created_at);
@@ -0,0 +1,13 @@
+CREATE TABLE api_keys (
+    id              UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    prefix          TEXT NOT NULL UNIQUE,
+    secret_hash     BYTEA NOT NULL,
+    role            TEXT NOT NULL,
+    expires_at      TIMESTAMPTZ,
+    last_used_at    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL,
+    revoked_at      TIMESTAMPTZ
+);
+CREATE INDEX idx_api_keys_organization_id ON api_keys (organization_id);
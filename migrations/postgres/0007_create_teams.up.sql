@@ -0,0 +1,20 @@
+CREATE TABLE teams (
+    id              UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    description     TEXT NOT NULL DEFAULT '',
+    permissions     TEXT NOT NULL DEFAULT '[]',
+    created_at      TIMESTAMPTZ NOT NULL,
+    UNIQUE (organization_id, name)
+);
+
+CREATE TABLE team_memberships (
+    id              UUID PRIMARY KEY,
+    team_id         UUID NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL,
+    user_id         UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role            TEXT NOT NULL,
+    joined_at       TIMESTAMPTZ NOT NULL,
+    UNIQUE (team_id, user_id)
+);
+CREATE INDEX idx_team_memberships_user ON team_memberships (organization_id, user_id);
@@ -0,0 +1,2 @@
+DROP INDEX idx_organizations_parent_id;
+ALTER TABLE organizations DROP COLUMN parent_id;
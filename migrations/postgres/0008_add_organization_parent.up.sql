@@ -0,0 +1,2 @@
+ALTER TABLE organizations ADD COLUMN parent_id UUID REFERENCES organizations (id) ON DELETE RESTRICT;
+CREATE INDEX idx_organizations_parent_id ON organizations (parent_id);
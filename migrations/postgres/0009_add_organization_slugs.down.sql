@@ -0,0 +1,3 @@
+DROP TABLE organization_slug_redirects;
+DROP INDEX idx_organizations_slug;
+ALTER TABLE organizations DROP COLUMN slug;
@@ -0,0 +1,11 @@
+-- Existing organizations get their ID as slug until it is changed.
+ALTER TABLE organizations ADD COLUMN slug TEXT NOT NULL DEFAULT '';
+UPDATE organizations SET slug = lower(id::text);
+CREATE UNIQUE INDEX idx_organizations_slug ON organizations (slug);
+
+CREATE TABLE organization_slug_redirects (
+    slug            TEXT PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    created_at      TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_organization_slug_redirects_organization_id ON organization_slug_redirects (organization_id);
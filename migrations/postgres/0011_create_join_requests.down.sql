@@ -0,0 +1 @@
+DROP TABLE join_requests;
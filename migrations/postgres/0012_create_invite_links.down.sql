@@ -0,0 +1,2 @@
+DROP TABLE invite_link_redemptions;
+DROP TABLE invite_links;
@@ -0,0 +1,24 @@
+CREATE TABLE invite_links (
+    id              UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    creator_id      UUID NOT NULL,
+    token           TEXT NOT NULL UNIQUE,
+    role            TEXT NOT NULL,
+    max_uses        INTEGER NOT NULL DEFAULT 0,
+    uses            INTEGER NOT NULL DEFAULT 0,
+    allowed_domain  TEXT NOT NULL DEFAULT '',
+    expires_at      TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL,
+    revoked_at      TIMESTAMPTZ
+);
+CREATE INDEX idx_invite_links_organization_id ON invite_links (organization_id);
+
+CREATE TABLE invite_link_redemptions (
+    id              UUID PRIMARY KEY,
+    link_id         UUID NOT NULL REFERENCES invite_links (id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL,
+    user_id         UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    membership_id   UUID NOT NULL,
+    redeemed_at     TIMESTAMPTZ NOT NULL,
+    UNIQUE (link_id, user_id)
+);
@@ -0,0 +1,4 @@
+DROP TABLE invites;
+DROP TABLE memberships;
+DROP TABLE organizations;
+DROP TABLE users;
@@ -0,0 +1,40 @@
+CREATE TABLE users (
+    id         TEXT PRIMARY KEY,
+    email      TEXT NOT NULL,
+    name       TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+
+CREATE TABLE organizations (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    owner_id   TEXT NOT NULL REFERENCES users (id),
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_organizations_owner_id ON organizations (owner_id);
+
+CREATE TABLE memberships (
+    id              TEXT PRIMARY KEY,
+    organization_id TEXT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id         TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role            TEXT NOT NULL,
+    joined_at       DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX idx_memberships_user_org ON memberships (user_id, organization_id);
+CREATE INDEX idx_memberships_organization_id ON memberships (organization_id);
+
+CREATE TABLE invites (
+    id              TEXT PRIMARY KEY,
+    organization_id TEXT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    inviter_id      TEXT NOT NULL REFERENCES users (id),
+    invitee_email   TEXT NOT NULL,
+    role            TEXT NOT NULL,
+    token           TEXT NOT NULL,
+    expires_at      DATETIME NOT NULL,
+    created_at      DATETIME NOT NULL,
+    accepted_at     DATETIME,
+    revoked_at      DATETIME
+);
+CREATE UNIQUE INDEX idx_invites_token ON invites (token);
+CREATE INDEX idx_invites_organization_id ON invites (organization_id);
@@ -0,0 +1,12 @@
+CREATE TABLE outbox_messages (
+    id              TEXT PRIMARY KEY,
+    kind            TEXT NOT NULL,
+    payload         BLOB NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT NOT NULL DEFAULT '',
+    next_attempt_at DATETIME NOT NULL,
+    created_at      DATETIME NOT NULL,
+    sent_at         DATETIME
+);
+CREATE INDEX idx_outbox_messages_due ON outbox_messages (status, next_attempt_at);
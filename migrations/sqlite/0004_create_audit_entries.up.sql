@@ -0,0 +1,16 @@
+-- No foreign keys: the audit log outlives the organizations and users it mentions.
+CREATE TABLE audit_entries (
+    id              TEXT PRIMARY KEY,
+    organization_id TEXT NOT NULL,
+    actor_id        TEXT NOT NULL,
+    action          TEXT NOT NULL,
+    target_type     TEXT NOT NULL,
+    target_id       TEXT NOT NULL,
+    before          BLOB,
+    after           BLOB,
+    ip_address      TEXT NOT NULL DEFAULT '',
+    user_agent      TEXT NOT NULL DEFAULT '',
+    request_id      TEXT NOT NULL DEFAULT '',
+    created_at      DATETIME NOT NULL
+);
+CREATE INDEX idx_audit_entries_org_created ON audit_entries (organization_id, created_at);
@@ -0,0 +1,8 @@
+-- Written by the GORM adapter's TenantRouter before the organization row exists, hence no foreign key.
+CREATE TABLE tenant_placements (
+    organization_id TEXT PRIMARY KEY,
+    mode            TEXT NOT NULL,
+    schema          TEXT NOT NULL DEFAULT '',
+    database        TEXT NOT NULL DEFAULT '',
+    created_at      DATETIME NOT NULL
+);
@@ -0,0 +1,13 @@
+CREATE TABLE api_keys (
+    id              TEXT PRIMARY KEY,
+    organization_id TEXT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    prefix          TEXT NOT NULL UNIQUE,
+    secret_hash     BLOB NOT NULL,
+    role            TEXT NOT NULL,
+    expires_at      DATETIME,
+    last_used_at    DATETIME,
+    created_at      DATETIME NOT NULL,
+    revoked_at      DATETIME
+);
+CREATE INDEX idx_api_keys_organization_id ON api_keys (organization_id);
@@ -0,0 +1,20 @@
+CREATE TABLE teams (
+    id              TEXT PRIMARY KEY,
+    organization_id TEXT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    description     TEXT NOT NULL DEFAULT '',
+    permissions     TEXT NOT NULL DEFAULT '[]',
+    created_at      DATETIME NOT NULL,
+    UNIQUE (organization_id, name)
+);
+
+CREATE TABLE team_memberships (
+    id              TEXT PRIMARY KEY,
+    team_id         TEXT NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    organization_id TEXT NOT NULL,
+    user_id         TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role            TEXT NOT NULL,
+    joined_at       DATETIME NOT NULL,
+    UNIQUE (team_id, user_id)
+);
+CREATE INDEX idx_team_memberships_user ON team_memberships (organization_id, user_id);
@@ -0,0 +1,3 @@
+-- SQLite cannot drop a column that is part of a foreign key, so parent_id has none here.
+ALTER TABLE organizations ADD COLUMN parent_id TEXT;
+CREATE INDEX idx_organizations_parent_id ON organizations (parent_id);
@@ -0,0 +1,2 @@
+DROP TABLE organization_domains;
+ALTER TABLE users DROP COLUMN email_verified_at;
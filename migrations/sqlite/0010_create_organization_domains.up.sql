@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME;
+
+CREATE TABLE organization_domains (
+    id                 TEXT PRIMARY KEY,
+    organization_id    TEXT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    domain             TEXT NOT NULL,
+    verification_token TEXT NOT NULL,
+    policy             TEXT NOT NULL DEFAULT 'none',
+    default_role       TEXT NOT NULL DEFAULT 'member',
+    verified_at        DATETIME,
+    created_at         DATETIME NOT NULL,
+    UNIQUE (organization_id, domain)
+);
+-- Only one organization can verify a domain.
+CREATE UNIQUE INDEX idx_organization_domains_verified ON organization_domains (domain) WHERE verified_at IS NOT NULL;
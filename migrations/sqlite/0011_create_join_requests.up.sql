@@ -0,0 +1,15 @@
+CREATE TABLE join_requests (
+    id              TEXT PRIMARY KEY,
+    organization_id TEXT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id         TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    message         TEXT NOT NULL DEFAULT '',
+    source          TEXT NOT NULL,
+    role            TEXT NOT NULL,
+    status          TEXT NOT NULL,
+    decider_id      TEXT REFERENCES users (id) ON DELETE SET NULL,
+    created_at      DATETIME NOT NULL,
+    decided_at      DATETIME
+);
+CREATE INDEX idx_join_requests_organization_id ON join_requests (organization_id, status, created_at);
+-- A user can have one pending request per organization.
+CREATE UNIQUE INDEX idx_join_requests_pending ON join_requests (organization_id, user_id) WHERE status = 'pending';
@@ -0,0 +1,24 @@
+CREATE TABLE invite_links (
+    id              TEXT PRIMARY KEY,
+    organization_id TEXT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    creator_id      TEXT NOT NULL,
+    token           TEXT NOT NULL UNIQUE,
+    role            TEXT NOT NULL,
+    max_uses        INTEGER NOT NULL DEFAULT 0,
+    uses            INTEGER NOT NULL DEFAULT 0,
+    allowed_domain  TEXT NOT NULL DEFAULT '',
+    expires_at      DATETIME,
+    created_at      DATETIME NOT NULL,
+    revoked_at      DATETIME
+);
+CREATE INDEX idx_invite_links_organization_id ON invite_links (organization_id);
+
+CREATE TABLE invite_link_redemptions (
+    id              TEXT PRIMARY KEY,
+    link_id         TEXT NOT NULL REFERENCES invite_links (id) ON DELETE CASCADE,
+    organization_id TEXT NOT NULL,
+    user_id         TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    membership_id   TEXT NOT NULL,
+    redeemed_at     DATETIME NOT NULL,
+    UNIQUE (link_id, user_id)
+);
@@ -0,0 +1,2 @@
+-- An empty plan means the default plan of the service.
+ALTER TABLE organizations ADD COLUMN plan TEXT NOT NULL DEFAULT '';
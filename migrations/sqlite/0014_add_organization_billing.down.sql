@@ -0,0 +1,3 @@
+ALTER TABLE organizations DROP COLUMN subscription_status;
+ALTER TABLE organizations DROP COLUMN subscription_id;
+ALTER TABLE organizations DROP COLUMN billing_customer_id;
@@ -0,0 +1,3 @@
+ALTER TABLE organizations ADD COLUMN billing_customer_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE organizations ADD COLUMN subscription_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE organizations ADD COLUMN subscription_status TEXT NOT NULL DEFAULT '';
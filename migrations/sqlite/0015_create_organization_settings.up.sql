@@ -0,0 +1,7 @@
+-- Only settings and feature rollouts that differ from their defaults are stored, as JSON objects.
+CREATE TABLE organization_settings (
+    organization_id TEXT PRIMARY KEY REFERENCES organizations (id) ON DELETE CASCADE,
+    overrides       TEXT NOT NULL DEFAULT '{}',
+    rollouts        TEXT NOT NULL DEFAULT '{}',
+    updated_at      DATETIME NOT NULL
+);
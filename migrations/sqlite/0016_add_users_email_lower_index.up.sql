@@ -0,0 +1,2 @@
+-- Lets UserStore.FindByEmail match addresses case-insensitively without a table scan.
+CREATE INDEX idx_users_email_lower ON users (LOWER(email));
@@ -0,0 +1,215 @@
+package gordian
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxStatus is the delivery state of an OutboxMessage.
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxSent    OutboxStatus = "sent"
+	OutboxDead    OutboxStatus = "dead" // Gave up after MaxAttempts, waiting for a replay
+)
+
+// Kinds of outbox messages understood by the Dispatcher out of the box.
+const (
+	OutboxKindInvitation          = "invitation"
+	OutboxKindJoinRequest         = "join_request"
+	OutboxKindJoinRequestDecision = "join_request_decision"
+)
+
+// OutboxMessage is an email (or other side effect) recorded in the same transaction
+// as the change that caused it, and delivered later by a Dispatcher.
+type OutboxMessage struct {
+	ID            uuid.UUID
+	Kind          string // Selects the handler used to deliver the payload
+	Payload       []byte // JSON encoded body, e.g. the Invite for OutboxKindInvitation
+	Status        OutboxStatus
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	SentAt        *time.Time
+}
+
+func NewOutboxMessage(kind string, payload any) (*OutboxMessage, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+	now := time.Now()
+	return &OutboxMessage{
+		ID:            uuid.New(),
+		Kind:          kind,
+		Payload:       body,
+		Status:        OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
+
+// OutboxHandler delivers the payload of one outbox message.
+type OutboxHandler func(ctx context.Context, payload []byte) error
+
+// Dispatcher delivers pending outbox messages in the background,
+// retrying failures with exponential backoff until MaxAttempts is reached.
+type Dispatcher struct {
+	store    OutboxStore
+	handlers map[string]OutboxHandler
+
+	PollInterval time.Duration // How often the store is polled for due messages
+	BatchSize    int           // Maximum messages claimed per poll
+	Lease        time.Duration // How long a claimed message is hidden from other dispatchers
+	BaseBackoff  time.Duration // Delay before the first retry, doubled on every attempt
+	MaxBackoff   time.Duration
+	MaxAttempts  int // Messages are moved to OutboxDead after this many failures
+}
+
+// NewDispatcher creates a Dispatcher that sends invitation messages through emailer, and join
+// request messages if emailer is a JoinRequestEmailer. Additional kinds can be registered with Handle.
+func NewDispatcher(store OutboxStore, emailer Emailer) *Dispatcher {
+	d := &Dispatcher{
+		store:        store,
+		handlers:     map[string]OutboxHandler{},
+		PollInterval: 5 * time.Second,
+		BatchSize:    50,
+		Lease:        time.Minute,
+		BaseBackoff:  10 * time.Second,
+		MaxBackoff:   time.Hour,
+		MaxAttempts:  8,
+	}
+	d.Handle(OutboxKindInvitation, func(ctx context.Context, payload []byte) error {
+		var invite Invite
+		if err := json.Unmarshal(payload, &invite); err != nil {
+			return fmt.Errorf("failed to decode invitation: %w", err)
+		}
+		return emailer.SendInvitation(ctx, &invite)
+	})
+	if emailer, ok := emailer.(JoinRequestEmailer); ok {
+		for _, kind := range []string{OutboxKindJoinRequest, OutboxKindJoinRequestDecision} {
+			d.Handle(kind, func(ctx context.Context, payload []byte) error {
+				var email joinRequestEmail
+				if err := json.Unmarshal(payload, &email); err != nil {
+					return fmt.Errorf("failed to decode join request email: %w", err)
+				}
+				return deliverJoinRequestEmail(ctx, emailer, kind, email)
+			})
+		}
+	}
+	return d
+}
+
+// Handle registers the handler used for messages of the given kind.
+func (d *Dispatcher) Handle(kind string, handler OutboxHandler) {
+	d.handlers[kind] = handler
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DispatchOnce(ctx); err != nil {
+			log.Printf("ERROR: outbox dispatch failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce claims one batch of due messages and tries to deliver each of them.
+// It returns the number of messages delivered successfully.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	msgs, err := d.store.ClaimDue(ctx, time.Now(), d.Lease, d.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+
+	sent := 0
+	for _, msg := range msgs {
+		deliveryErr := d.deliver(ctx, msg)
+		now := time.Now()
+		msg.Attempts++
+		if deliveryErr == nil {
+			msg.Status = OutboxSent
+			msg.SentAt = &now
+			msg.LastError = ""
+			sent++
+		} else {
+			msg.LastError = deliveryErr.Error()
+			if msg.Attempts >= d.MaxAttempts {
+				msg.Status = OutboxDead
+			} else {
+				msg.NextAttemptAt = now.Add(exponentialBackoff(d.BaseBackoff, d.MaxBackoff, msg.Attempts))
+			}
+		}
+		if err := d.store.Update(ctx, msg); err != nil {
+			return sent, fmt.Errorf("failed to update outbox message %s: %w", msg.ID, err)
+		}
+	}
+	return sent, nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, msg *OutboxMessage) error {
+	handler, ok := d.handlers[msg.Kind]
+	if !ok {
+		return fmt.Errorf("no handler registered for outbox kind %q", msg.Kind)
+	}
+	return handler(ctx, msg.Payload)
+}
+
+// exponentialBackoff returns base * 2^(attempts-1), capped at max.
+func exponentialBackoff(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
+
+// ListFailedDeliveries returns outbox messages that exhausted their retries.
+func (s *Service) ListFailedDeliveries(ctx context.Context, limit int) ([]*OutboxMessage, error) {
+	if s.outboxStore == nil {
+		return nil, notConfigured("outbox")
+	}
+	msgs, err := s.outboxStore.ListByStatus(ctx, OutboxDead, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list failed deliveries: %w", err)
+	}
+	return msgs, nil
+}
+
+// ReplayDelivery puts a dead outbox message back in the queue with a fresh retry budget.
+func (s *Service) ReplayDelivery(ctx context.Context, id uuid.UUID) error {
+	if s.outboxStore == nil {
+		return notConfigured("outbox")
+	}
+	msg, err := s.outboxStore.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get outbox message: %w", err)
+	}
+	if msg.Status != OutboxDead {
+		return fmt.Errorf("outbox message is %s, only dead messages can be replayed: %w", msg.Status, ErrConflict)
+	}
+	msg.Status = OutboxPending
+	msg.Attempts = 0
+	msg.NextAttemptAt = time.Now()
+	if err := s.outboxStore.Update(ctx, msg); err != nil {
+		return fmt.Errorf("failed to replay outbox message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package gordian_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/internal/sqlitetest"
+	"gorm.io/gorm"
+)
+
+func TestDispatcherDispatchOnce(t *testing.T) {
+	errDelivery := errors.New("smtp unavailable")
+	tests := []struct {
+		name         string
+		kind         string
+		handlerErr   error
+		maxAttempts  int
+		wantSent     int
+		wantStatus   gordian.OutboxStatus
+		wantError    bool
+		wantRetryGap time.Duration // expected NextAttemptAt - now for retried messages
+	}{
+		{name: "delivered", kind: "test", wantSent: 1, wantStatus: gordian.OutboxSent},
+		{name: "failure is retried", kind: "test", handlerErr: errDelivery, maxAttempts: 3, wantStatus: gordian.OutboxPending, wantError: true, wantRetryGap: time.Minute},
+		{name: "dead after max attempts", kind: "test", handlerErr: errDelivery, maxAttempts: 1, wantStatus: gordian.OutboxDead, wantError: true},
+		{name: "unknown kind is retried", kind: "unknown", maxAttempts: 3, wantStatus: gordian.OutboxPending, wantError: true, wantRetryGap: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := gormadapter.NewOutboxStore(sqlitetest.Open(t))
+			msg, err := gordian.NewOutboxMessage(tt.kind, map[string]string{"to": "a@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Create(ctx, msg); err != nil {
+				t.Fatal(err)
+			}
+
+			d := gordian.NewDispatcher(store, &testEmailer{})
+			d.BaseBackoff = time.Minute
+			if tt.maxAttempts > 0 {
+				d.MaxAttempts = tt.maxAttempts
+			}
+			var payload string
+			d.Handle("test", func(ctx context.Context, body []byte) error {
+				payload = string(body)
+				return tt.handlerErr
+			})
+
+			sent, err := d.DispatchOnce(ctx)
+			if err != nil {
+				t.Fatalf("DispatchOnce: %v", err)
+			}
+			if sent != tt.wantSent {
+				t.Errorf("sent = %d, want %d", sent, tt.wantSent)
+			}
+			got, err := store.Get(ctx, msg.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus || got.Attempts != 1 {
+				t.Errorf("status = %s after %d attempts, want %s after 1", got.Status, got.Attempts, tt.wantStatus)
+			}
+			if (got.LastError != "") != tt.wantError {
+				t.Errorf("last error = %q, want error: %v", got.LastError, tt.wantError)
+			}
+			if tt.kind == "test" && payload != `{"to":"a@example.com"}` {
+				t.Errorf("handler got payload %q", payload)
+			}
+			if tt.wantRetryGap > 0 {
+				if gap := time.Until(got.NextAttemptAt); gap < tt.wantRetryGap-5*time.Second || gap > tt.wantRetryGap {
+					t.Errorf("next attempt in %v, want %v", gap, tt.wantRetryGap)
+				}
+			}
+			if again, _ := d.DispatchOnce(ctx); again != 0 {
+				t.Errorf("second DispatchOnce delivered %d messages, want none before the retry is due", again)
+			}
+		})
+	}
+}
+
+func TestDispatcherBackoffDoublesUpToMax(t *testing.T) {
+	ctx := context.Background()
+	db := sqlitetest.Open(t)
+	store := gormadapter.NewOutboxStore(db)
+	msg, _ := gordian.NewOutboxMessage("test", nil)
+	if err := store.Create(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	d := gordian.NewDispatcher(store, &testEmailer{})
+	d.BaseBackoff = time.Minute
+	d.MaxBackoff = 5 * time.Minute
+	d.Handle("test", func(context.Context, []byte) error { return errors.New("down") })
+
+	for attempt, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		// Make the message due again.
+		if err := db.Model(&gordian.OutboxMessage{}).Where("id = ?", msg.ID).Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.DispatchOnce(ctx); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := store.Get(ctx, msg.ID)
+		if gap := time.Until(got.NextAttemptAt); gap < want-5*time.Second || gap > want {
+			t.Errorf("attempt %d: next attempt in %v, want %v", attempt+1, gap, want)
+		}
+	}
+}
+
+func TestCreateInvitationQueuesEmailInOutbox(t *testing.T) {
+	env := newTestEnv(t, func(db *gorm.DB) gordian.Option {
+		return gordian.WithOutbox(gormadapter.NewOutboxStore(db))
+	})
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+
+	invite, err := env.svc.CreateInvitation(env.ctx, org.ID, owner.ID, "new@example.com", "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := env.emailer.sentInvitations(); len(sent) != 0 {
+		t.Fatalf("invitation was sent inline, want it queued")
+	}
+
+	d := gordian.NewDispatcher(gormadapter.NewOutboxStore(env.db), env.emailer)
+	if sent, err := d.DispatchOnce(env.ctx); err != nil || sent != 1 {
+		t.Fatalf("DispatchOnce = %d, %v; want 1 message sent", sent, err)
+	}
+	sent := env.emailer.sentInvitations()
+	if len(sent) != 1 || sent[0].ID != invite.ID || sent[0].Token != invite.Token {
+		t.Fatalf("emailer got %+v, want the invitation with its token", sent)
+	}
+}
+
+func TestReplayDelivery(t *testing.T) {
+	env := newTestEnv(t, func(db *gorm.DB) gordian.Option {
+		return gordian.WithOutbox(gormadapter.NewOutboxStore(db))
+	})
+	store := gormadapter.NewOutboxStore(env.db)
+	dead, _ := gordian.NewOutboxMessage("test", nil)
+	dead.Status, dead.Attempts = gordian.OutboxDead, 8
+	pending, _ := gordian.NewOutboxMessage("test", nil)
+	for _, msg := range []*gordian.OutboxMessage{dead, pending} {
+		if err := store.Create(env.ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failed, err := env.svc.ListFailedDeliveries(env.ctx, 10)
+	if err != nil || len(failed) != 1 || failed[0].ID != dead.ID {
+		t.Fatalf("ListFailedDeliveries = %v, %v; want the dead message", failed, err)
+	}
+	if err := env.svc.ReplayDelivery(env.ctx, pending.ID); !errors.Is(err, gordian.ErrConflict) {
+		t.Errorf("replaying a pending message: err = %v, want ErrConflict", err)
+	}
+	if err := env.svc.ReplayDelivery(env.ctx, dead.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := store.Get(env.ctx, dead.ID)
+	if got.Status != gordian.OutboxPending || got.Attempts != 0 || got.NextAttemptAt.After(time.Now()) {
+		t.Errorf("replayed message = %s with %d attempts due %v, want pending, 0 attempts, due now", got.Status, got.Attempts, got.NextAttemptAt)
+	}
+}
@@ -0,0 +1,178 @@
+package gordian
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QuotaResource names something a Plan limits.
+type QuotaResource string
+
+const (
+	QuotaMembers        QuotaResource = "members"
+	QuotaPendingInvites QuotaResource = "pending_invites"
+	QuotaTeams          QuotaResource = "teams"
+)
+
+// Plan limits what an organization can have. A limit of 0 means unlimited.
+type Plan struct {
+	Name              string
+	MaxMembers        int
+	MaxPendingInvites int
+	MaxTeams          int
+}
+
+// Limit returns the limit of resource, 0 for unlimited.
+func (p Plan) Limit(resource QuotaResource) int {
+	switch resource {
+	case QuotaMembers:
+		return p.MaxMembers
+	case QuotaPendingInvites:
+		return p.MaxPendingInvites
+	case QuotaTeams:
+		return p.MaxTeams
+	}
+	return 0
+}
+
+// Usage counts what an organization has.
+type Usage struct {
+	Members        int
+	PendingInvites int // Invitations neither accepted, revoked nor expired
+	Teams          int
+}
+
+// Of returns the usage of resource.
+func (u Usage) Of(resource QuotaResource) int {
+	switch resource {
+	case QuotaMembers:
+		return u.Members
+	case QuotaPendingInvites:
+		return u.PendingInvites
+	case QuotaTeams:
+		return u.Teams
+	}
+	return 0
+}
+
+// UsageSummary describes the plan of an organization and how much of it is used,
+// e.g. for a billing page.
+type UsageSummary struct {
+	OrganizationID uuid.UUID
+	Plan           Plan
+	Usage          Usage
+}
+
+// Remaining returns how many more of resource the organization can have, or -1 if it is unlimited.
+// It is 0 when the organization is at or above its limit, e.g. after a downgrade.
+func (u *UsageSummary) Remaining(resource QuotaResource) int {
+	limit := u.Plan.Limit(resource)
+	if limit == 0 {
+		return -1
+	}
+	return max(limit-u.Usage.Of(resource), 0)
+}
+
+// QuotaExceededError is returned when a change would take an organization beyond a limit of its
+// plan. It wraps ErrQuotaExceeded.
+type QuotaExceededError struct {
+	OrganizationID uuid.UUID
+	Plan           string
+	Resource       QuotaResource
+	Limit          int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: plan %q allows %d %s", ErrQuotaExceeded, e.Plan, e.Limit, e.Resource)
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// plan returns the plan of org, the default plan if it has none or an unknown one.
+func (s *Service) plan(org *Organization) Plan {
+	if plan, ok := s.plans[org.Plan]; ok {
+		return plan
+	}
+	return s.defaultPlan
+}
+
+// checkQuota fails with a QuotaExceededError if orgID cannot have one more of resource. It must be
+// called inside the transaction that adds the resource; the usage of orgID stays locked until it ends.
+func (s *Service) checkQuota(ctx context.Context, orgID uuid.UUID, resource QuotaResource) error {
+	if s.usageStore == nil {
+		return nil
+	}
+	org, err := s.orgStore.Get(ctx, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get organization: %w", err)
+	}
+	plan := s.plan(org)
+	limit := plan.Limit(resource)
+	if limit == 0 {
+		return nil
+	}
+	if err := s.usageStore.LockUsage(ctx, orgID); err != nil {
+		return fmt.Errorf("failed to lock usage: %w", err)
+	}
+	usage, err := s.usageStore.GetUsage(ctx, orgID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to get usage: %w", err)
+	}
+	if usage.Of(resource) >= limit {
+		return &QuotaExceededError{OrganizationID: orgID, Plan: plan.Name, Resource: resource, Limit: limit}
+	}
+	return nil
+}
+
+// GetUsage returns the plan of orgID and its current usage.
+func (s *Service) GetUsage(ctx context.Context, orgID uuid.UUID) (*UsageSummary, error) {
+	if s.usageStore == nil {
+		return nil, notConfigured("plans")
+	}
+	org, err := s.orgStore.Get(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	usage, err := s.usageStore.GetUsage(ctx, orgID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return &UsageSummary{OrganizationID: orgID, Plan: s.plan(org), Usage: usage}, nil
+}
+
+// ChangePlan moves orgID to the plan named planName. Downgrading below the current usage is
+// allowed; nothing is removed, but nothing more can be added until usage is below the limits.
+func (s *Service) ChangePlan(ctx context.Context, orgID uuid.UUID, planName string) (*Organization, error) {
+	if s.usageStore == nil {
+		return nil, notConfigured("plans")
+	}
+	if _, ok := s.plans[planName]; !ok {
+		return nil, fmt.Errorf("unknown plan %q: %w", planName, ErrInvalidArgument)
+	}
+	org, err := s.orgStore.Get(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	if org.Plan == planName {
+		return org, nil
+	}
+
+	before := snapshotOrganization(org)
+	org.Plan = planName
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orgStore.Update(ctx, org); err != nil {
+			return fmt.Errorf("failed to update organization: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditOrganizationPlanChanged, TargetType: "organization", TargetID: orgID}
+		return s.audit(ctx, entry, before, snapshotOrganization(org))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
@@ -0,0 +1,184 @@
+package gordian_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"gorm.io/gorm"
+)
+
+var (
+	freePlan = gordian.Plan{Name: "free", MaxMembers: 3, MaxPendingInvites: 1, MaxTeams: 1}
+	proPlan  = gordian.Plan{Name: "pro"}
+)
+
+func withPlans(db *gorm.DB) gordian.Option {
+	return gordian.WithPlans(gormadapter.NewUsageStore(db), freePlan, proPlan)
+}
+
+func TestUsageSummaryRemaining(t *testing.T) {
+	tests := []struct {
+		name     string
+		plan     gordian.Plan
+		usage    gordian.Usage
+		resource gordian.QuotaResource
+		want     int
+	}{
+		{name: "unlimited", plan: proPlan, usage: gordian.Usage{Members: 100}, resource: gordian.QuotaMembers, want: -1},
+		{name: "below the limit", plan: freePlan, usage: gordian.Usage{Members: 1}, resource: gordian.QuotaMembers, want: 2},
+		{name: "at the limit", plan: freePlan, usage: gordian.Usage{PendingInvites: 1}, resource: gordian.QuotaPendingInvites, want: 0},
+		{name: "above the limit", plan: freePlan, usage: gordian.Usage{Teams: 4}, resource: gordian.QuotaTeams, want: 0},
+		{name: "unknown resource", plan: freePlan, usage: gordian.Usage{Members: 1}, resource: "projects", want: -1},
+	}
+	for _, tt := range tests {
+		summary := gordian.UsageSummary{Plan: tt.plan, Usage: tt.usage}
+		if got := summary.Remaining(tt.resource); got != tt.want {
+			t.Errorf("%s: Remaining(%s) = %d, want %d", tt.name, tt.resource, got, tt.want)
+		}
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	env := newTestEnv(t, withPlans, withTeams, withInviteLinks)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	link, err := env.svc.CreateInviteLink(env.ctx, org.ID, owner.ID, "member", 0, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var invite *gordian.Invite
+
+	// The cases run in order against the free plan: each uses up what the ones before left.
+	tests := []struct {
+		name         string
+		do           func() error
+		wantResource gordian.QuotaResource // resource over its limit, "" for success
+	}{
+		{name: "add a member", do: func() error {
+			_, err := env.svc.CreateMembership(env.ctx, env.user("alice@example.com").ID, org.ID, "member")
+			return err
+		}},
+		{name: "invite", do: func() error {
+			var err error
+			invite, err = env.svc.CreateInvitation(env.ctx, org.ID, owner.ID, "bob@example.com", "member")
+			return err
+		}},
+		{name: "invite beyond the limit", do: func() error {
+			_, err := env.svc.CreateInvitation(env.ctx, org.ID, owner.ID, "carol@example.com", "member")
+			return err
+		}, wantResource: gordian.QuotaPendingInvites},
+		{name: "accept the last seat", do: func() error {
+			_, err := env.svc.AcceptInvitation(env.ctx, invite.Token, env.user("bob@example.com").ID)
+			return err
+		}},
+		{name: "add a member beyond the limit", do: func() error {
+			_, err := env.svc.CreateMembership(env.ctx, env.user("dave@example.com").ID, org.ID, "member")
+			return err
+		}, wantResource: gordian.QuotaMembers},
+		{name: "redeem a link beyond the limit", do: func() error {
+			_, err := env.svc.RedeemInviteLink(env.ctx, link.Token, env.user("erin@example.com").ID)
+			return err
+		}, wantResource: gordian.QuotaMembers},
+		{name: "create a team", do: func() error {
+			_, err := env.svc.CreateTeam(env.ctx, org.ID, "Engineering", "")
+			return err
+		}},
+		{name: "create a team beyond the limit", do: func() error {
+			_, err := env.svc.CreateTeam(env.ctx, org.ID, "Sales", "")
+			return err
+		}, wantResource: gordian.QuotaTeams},
+		{name: "upgrade", do: func() error {
+			_, err := env.svc.ChangePlan(env.ctx, org.ID, proPlan.Name)
+			return err
+		}},
+		{name: "add a member on the unlimited plan", do: func() error {
+			_, err := env.svc.CreateMembership(env.ctx, env.user("frank@example.com").ID, org.ID, "member")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.do()
+			if tt.wantResource == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var quotaErr *gordian.QuotaExceededError
+			if !errors.As(err, &quotaErr) || !errors.Is(err, gordian.ErrQuotaExceeded) {
+				t.Fatalf("err = %v, want a QuotaExceededError", err)
+			}
+			want := gordian.QuotaExceededError{OrganizationID: org.ID, Plan: freePlan.Name, Resource: tt.wantResource, Limit: freePlan.Limit(tt.wantResource)}
+			if *quotaErr != want {
+				t.Errorf("err = %+v, want %+v", *quotaErr, want)
+			}
+		})
+	}
+
+	summary, err := env.svc.GetUsage(env.ctx, org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (gordian.Usage{Members: 4, Teams: 1}); summary.Plan != proPlan || summary.Usage != want {
+		t.Errorf("GetUsage = %+v, want plan %q and %+v", summary, proPlan.Name, want)
+	}
+}
+
+func TestChangePlan(t *testing.T) {
+	env := newTestEnv(t, withPlans)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		env.member(org, email, "member")
+	}
+	if _, err := env.svc.ChangePlan(env.ctx, org.ID, proPlan.Name); err != nil {
+		t.Fatal(err)
+	}
+	env.member(org, "carol@example.com", "member")
+
+	// The cases run in order: "downgrade" leaves the organization above the member limit.
+	tests := []struct {
+		name          string
+		plan          string
+		wantPlan      gordian.Plan
+		wantRemaining int
+		wantErr       error
+	}{
+		{name: "unknown plan", plan: "enterprise", wantPlan: proPlan, wantRemaining: -1, wantErr: gordian.ErrInvalidArgument},
+		{name: "same plan", plan: proPlan.Name, wantPlan: proPlan, wantRemaining: -1},
+		{name: "downgrade below usage", plan: freePlan.Name, wantPlan: freePlan, wantRemaining: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.svc.ChangePlan(env.ctx, org.ID, tt.plan)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangePlan = %v, want %v", err, tt.wantErr)
+			}
+			summary, err := env.svc.GetUsage(env.ctx, org.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Plan != tt.wantPlan || summary.Remaining(gordian.QuotaMembers) != tt.wantRemaining {
+				t.Errorf("GetUsage = %+v, want plan %q with %d seats left", summary, tt.wantPlan.Name, tt.wantRemaining)
+			}
+		})
+	}
+
+	if _, err := env.svc.CreateMembership(env.ctx, env.user("dave@example.com").ID, org.ID, "member"); !errors.Is(err, gordian.ErrQuotaExceeded) {
+		t.Errorf("CreateMembership after the downgrade = %v, want ErrQuotaExceeded", err)
+	}
+}
+
+func TestQuotasNotConfigured(t *testing.T) {
+	env := newTestEnv(t)
+	org := env.org("Acme", env.user("owner@example.com"))
+	if _, err := env.svc.GetUsage(env.ctx, org.ID); !errors.Is(err, gordian.ErrNotConfigured) {
+		t.Errorf("GetUsage = %v, want ErrNotConfigured", err)
+	}
+	if _, err := env.svc.ChangePlan(env.ctx, org.ID, proPlan.Name); !errors.Is(err, gordian.ErrNotConfigured) {
+		t.Errorf("ChangePlan = %v, want ErrNotConfigured", err)
+	}
+}
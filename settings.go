@@ -0,0 +1,422 @@
+package gordian
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultSettingsCacheTTL is how long GetSettings serves settings from memory, see
+// WithSettingsCacheTTL.
+const DefaultSettingsCacheTTL = 30 * time.Second
+
+// DefaultSettingsCacheSize is how many organizations GetSettings keeps settings of in memory,
+// see WithSettingsCacheSize.
+const DefaultSettingsCacheSize = 10000
+
+// SettingDefinition is a registered setting. It is implemented by *Setting[T] for every T.
+type SettingDefinition interface {
+	SettingKey() string
+	defaultValue() any
+	// parse decodes and validates a stored or submitted value.
+	parse(raw json.RawMessage) (any, error)
+}
+
+// Setting is a typed per-organization setting. Register it with WithSettings, change it with
+// UpdateSettings and read it with Get or FromContext.
+type Setting[T any] struct {
+	Key      string
+	Default  T
+	Validate func(T) error // Optional; rejects invalid values in UpdateSettings
+}
+
+func NewSetting[T any](key string, def T, validate func(T) error) *Setting[T] {
+	return &Setting[T]{Key: key, Default: def, Validate: validate}
+}
+
+func (k *Setting[T]) SettingKey() string { return k.Key }
+
+func (k *Setting[T]) defaultValue() any { return k.Default }
+
+func (k *Setting[T]) parse(raw json.RawMessage) (any, error) {
+	var value T
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("invalid value for setting %q: %w", k.Key, ErrInvalidArgument)
+	}
+	if k.Validate != nil {
+		if err := k.Validate(value); err != nil {
+			return nil, fmt.Errorf("invalid value for setting %q: %w: %w", k.Key, ErrInvalidArgument, err)
+		}
+	}
+	return value, nil
+}
+
+// Get returns the value of the setting in settings, or its default.
+func (k *Setting[T]) Get(settings *Settings) T {
+	if settings != nil {
+		if value, ok := settings.values[k.Key].(T); ok {
+			return value
+		}
+	}
+	return k.Default
+}
+
+// FromContext returns the value of the setting for the organization of the request, see
+// TenancyMiddleware, or its default outside of one.
+func (k *Setting[T]) FromContext(ctx context.Context) T {
+	return k.Get(ActiveSettings(ctx))
+}
+
+var (
+	// SettingSSOOnly asks the application to let members sign in through single sign-on only.
+	// Gordian doesn't authenticate users; check it in your login flow.
+	SettingSSOOnly = NewSetting("sso_only", false, nil)
+	// SettingDefaultInviteRole is the role of invitations created without one.
+	SettingDefaultInviteRole = NewSetting("default_invite_role", "member", func(role string) error {
+		if role == "" || role == "owner" {
+			return fmt.Errorf("invitations cannot grant role %q", role)
+		}
+		return nil
+	})
+)
+
+// DefaultSettings are registered by WithSettings in addition to the application's settings.
+var DefaultSettings = []SettingDefinition{SettingSSOOnly, SettingDefaultInviteRole}
+
+// FeatureFlag is a feature rolled out to a percentage of the users of every organization.
+// Organizations can override the percentage with SetFeatureRollout, e.g. to give a beta
+// customer 100%. Register it with WithFeatureFlags.
+type FeatureFlag struct {
+	Key     string
+	Rollout int // Percentage of users who get the feature, 0 to 100
+}
+
+func NewFeatureFlag(key string, rollout int) *FeatureFlag {
+	return &FeatureFlag{Key: key, Rollout: rollout}
+}
+
+// EnabledFor reports whether subject, usually a user, gets the feature in the organization of
+// settings. Every subject keeps its place in the rollout, so raising the percentage only adds
+// subjects.
+func (f *FeatureFlag) EnabledFor(settings *Settings, subject uuid.UUID) bool {
+	percentage := f.Rollout
+	if settings != nil {
+		if p, ok := settings.rollouts[f.Key]; ok {
+			percentage = p
+		}
+	}
+	return rolloutBucket(f.Key, subject) < percentage
+}
+
+// Enabled reports whether the current user gets the feature in the organization of the request,
+// see TenancyMiddleware.
+func (f *FeatureFlag) Enabled(ctx context.Context) bool {
+	userID, _ := ctx.Value("user_id").(uuid.UUID)
+	return f.EnabledFor(ActiveSettings(ctx), userID)
+}
+
+// rolloutBucket places subject in one of 100 buckets, differently for every flag.
+func rolloutBucket(key string, subject uuid.UUID) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	h.Write(subject[:])
+	return int(h.Sum32() % 100)
+}
+
+// SettingValues maps setting keys to their values, stored as a JSON object.
+type SettingValues map[string]json.RawMessage
+
+func (v SettingValues) Value() (driver.Value, error) {
+	if v == nil {
+		return "{}", nil
+	}
+	body, err := json.Marshal(map[string]json.RawMessage(v))
+	return string(body), err
+}
+
+func (v *SettingValues) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(src), v)
+	case []byte:
+		return json.Unmarshal(src, v)
+	}
+	return fmt.Errorf("cannot scan %T into SettingValues", src)
+}
+
+// FeatureRollouts maps feature flag keys to percentages, stored as a JSON object.
+type FeatureRollouts map[string]int
+
+func (r FeatureRollouts) Value() (driver.Value, error) {
+	if r == nil {
+		return "{}", nil
+	}
+	body, err := json.Marshal(map[string]int(r))
+	return string(body), err
+}
+
+func (r *FeatureRollouts) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(src), r)
+	case []byte:
+		return json.Unmarshal(src, r)
+	}
+	return fmt.Errorf("cannot scan %T into FeatureRollouts", src)
+}
+
+// OrganizationSettings is what a SettingsStore keeps for an organization: the settings and
+// feature rollouts that differ from their defaults.
+type OrganizationSettings struct {
+	OrganizationID uuid.UUID
+	Overrides      SettingValues
+	Rollouts       FeatureRollouts
+	UpdatedAt      time.Time
+}
+
+// Settings is a snapshot of the settings and feature rollouts of an organization. Read it with
+// Setting.Get and FeatureFlag.EnabledFor.
+type Settings struct {
+	OrganizationID uuid.UUID
+
+	values   map[string]any
+	rollouts map[string]int
+}
+
+// Values returns every registered setting with its value, e.g. to render a settings page.
+func (s *Settings) Values() map[string]any {
+	return maps.Clone(s.values)
+}
+
+// Rollouts returns every registered feature flag with the percentage of users who get it.
+func (s *Settings) Rollouts() map[string]int {
+	return maps.Clone(s.rollouts)
+}
+
+// Features returns whether subject, usually a user, gets each registered feature flag, e.g. for
+// a frontend.
+func (s *Settings) Features(subject uuid.UUID) map[string]bool {
+	features := make(map[string]bool, len(s.rollouts))
+	for key, percentage := range s.rollouts {
+		features[key] = rolloutBucket(key, subject) < percentage
+	}
+	return features
+}
+
+type cachedSettings struct {
+	settings  *Settings
+	expiresAt time.Time
+}
+
+// GetSettings returns the settings of orgID. Results are cached for the TTL set with
+// WithSettingsCacheTTL; changes made through this Service are visible once they have committed.
+// Inside a transaction the settings are always read from the store.
+func (s *Service) GetSettings(ctx context.Context, orgID uuid.UUID) (*Settings, error) {
+	if s.settingsStore == nil {
+		return nil, notConfigured("settings")
+	}
+	cache := s.settingsTTL > 0 && !InTransaction(ctx)
+	now := time.Now()
+	s.settingsMu.Lock()
+	cached, ok := s.settingsCache[orgID]
+	epoch := s.settingsEpoch
+	s.settingsMu.Unlock()
+	if cache && ok && now.Before(cached.expiresAt) {
+		return cached.settings, nil
+	}
+
+	stored, err := s.settingsStore.Get(ctx, orgID)
+	if errors.Is(err, ErrNotFound) {
+		stored, err = &OrganizationSettings{OrganizationID: orgID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	settings := s.resolveSettings(stored)
+
+	if cache {
+		s.settingsMu.Lock()
+		// A change committed while loading bumped the epoch; don't cache what may predate it.
+		if s.settingsEpoch == epoch {
+			if _, ok := s.settingsCache[orgID]; !ok && len(s.settingsCache) >= s.settingsCacheSize {
+				// Make room by dropping an arbitrary entry; map iteration starts at a random one.
+				for key := range s.settingsCache {
+					delete(s.settingsCache, key)
+					break
+				}
+			}
+			s.settingsCache[orgID] = cachedSettings{settings: settings, expiresAt: now.Add(s.settingsTTL)}
+		}
+		s.settingsMu.Unlock()
+	}
+	return settings, nil
+}
+
+// resolveSettings applies the stored overrides to the defaults. Overrides of settings that are no
+// longer registered, or no longer valid, are ignored.
+func (s *Service) resolveSettings(stored *OrganizationSettings) *Settings {
+	settings := &Settings{
+		OrganizationID: stored.OrganizationID,
+		values:         make(map[string]any, len(s.settingDefs)),
+		rollouts:       make(map[string]int, len(s.featureFlags)),
+	}
+	for key, def := range s.settingDefs {
+		settings.values[key] = def.defaultValue()
+		if raw, ok := stored.Overrides[key]; ok {
+			if value, err := def.parse(raw); err == nil {
+				settings.values[key] = value
+			}
+		}
+	}
+	for key, flag := range s.featureFlags {
+		settings.rollouts[key] = flag.Rollout
+		if percentage, ok := stored.Rollouts[key]; ok {
+			settings.rollouts[key] = percentage
+		}
+	}
+	return settings
+}
+
+// UpdateSettings changes settings of orgID. values maps setting keys to their new value, either
+// as the Go value or as json.RawMessage; a nil value resets the setting to its default.
+//
+//	svc.UpdateSettings(ctx, orgID, map[string]any{gordian.SettingSSOOnly.Key: true})
+func (s *Service) UpdateSettings(ctx context.Context, orgID uuid.UUID, values map[string]any) (*Settings, error) {
+	if s.settingsStore == nil {
+		return nil, notConfigured("settings")
+	}
+	raws := make(map[string]json.RawMessage, len(values))
+	for key, value := range values {
+		def, ok := s.settingDefs[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown setting %q: %w", key, ErrInvalidArgument)
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for setting %q: %w", key, ErrInvalidArgument)
+		}
+		if string(raw) == "null" {
+			raws[key] = nil
+			continue
+		}
+		if _, err := def.parse(raw); err != nil {
+			return nil, err
+		}
+		raws[key] = raw
+	}
+	return s.updateSettings(ctx, orgID, func(stored *OrganizationSettings) {
+		for key, raw := range raws {
+			if raw == nil {
+				delete(stored.Overrides, key)
+			} else {
+				stored.Overrides[key] = raw
+			}
+		}
+	})
+}
+
+// SetFeatureRollout gives percentage of the users of orgID the feature flag key, regardless of
+// the flag's default rollout.
+func (s *Service) SetFeatureRollout(ctx context.Context, orgID uuid.UUID, key string, percentage int) (*Settings, error) {
+	if _, ok := s.featureFlags[key]; !ok {
+		return nil, fmt.Errorf("unknown feature flag %q: %w", key, ErrInvalidArgument)
+	}
+	if percentage < 0 || percentage > 100 {
+		return nil, fmt.Errorf("rollout must be between 0 and 100 percent: %w", ErrInvalidArgument)
+	}
+	return s.updateSettings(ctx, orgID, func(stored *OrganizationSettings) {
+		stored.Rollouts[key] = percentage
+	})
+}
+
+// ResetFeatureRollout makes orgID follow the default rollout of the feature flag key again.
+func (s *Service) ResetFeatureRollout(ctx context.Context, orgID uuid.UUID, key string) (*Settings, error) {
+	if _, ok := s.featureFlags[key]; !ok {
+		return nil, fmt.Errorf("unknown feature flag %q: %w", key, ErrInvalidArgument)
+	}
+	return s.updateSettings(ctx, orgID, func(stored *OrganizationSettings) {
+		delete(stored.Rollouts, key)
+	})
+}
+
+// updateSettings applies change to the stored settings of orgID and drops them from the cache
+// once the change has committed, which inside a caller's transaction is when that commits.
+func (s *Service) updateSettings(ctx context.Context, orgID uuid.UUID, change func(*OrganizationSettings)) (*Settings, error) {
+	if s.settingsStore == nil {
+		return nil, notConfigured("settings")
+	}
+	if _, err := s.orgStore.Get(ctx, orgID); err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Concurrent changes would otherwise each read the same settings and drop the other's.
+		if err := s.settingsStore.LockSettings(ctx, orgID); err != nil {
+			return fmt.Errorf("failed to lock settings: %w", err)
+		}
+		stored, err := s.settingsStore.Get(ctx, orgID)
+		var before *OrganizationSettings
+		switch {
+		case errors.Is(err, ErrNotFound):
+			stored = &OrganizationSettings{OrganizationID: orgID}
+		case err != nil:
+			return fmt.Errorf("failed to get settings: %w", err)
+		default:
+			before = &OrganizationSettings{
+				OrganizationID: orgID,
+				Overrides:      maps.Clone(stored.Overrides),
+				Rollouts:       maps.Clone(stored.Rollouts),
+				UpdatedAt:      stored.UpdatedAt,
+			}
+		}
+		if stored.Overrides == nil {
+			stored.Overrides = SettingValues{}
+		}
+		if stored.Rollouts == nil {
+			stored.Rollouts = FeatureRollouts{}
+		}
+		change(stored)
+		stored.UpdatedAt = time.Now()
+		if err := s.settingsStore.Save(ctx, stored); err != nil {
+			return fmt.Errorf("failed to save settings: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditSettingsChanged, TargetType: "organization", TargetID: orgID}
+		return s.audit(ctx, entry, before, stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	AfterCommit(ctx, func() { s.invalidateSettings(orgID) })
+	return s.GetSettings(ctx, orgID)
+}
+
+func (s *Service) invalidateSettings(orgID uuid.UUID) {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	delete(s.settingsCache, orgID)
+	s.settingsEpoch++
+}
+
+// defaultInviteRole returns SettingDefaultInviteRole of orgID, or "member" without settings.
+func (s *Service) defaultInviteRole(ctx context.Context, orgID uuid.UUID) (string, error) {
+	if s.settingsStore == nil {
+		return SettingDefaultInviteRole.Default, nil
+	}
+	settings, err := s.GetSettings(ctx, orgID)
+	if err != nil {
+		return "", err
+	}
+	return SettingDefaultInviteRole.Get(settings), nil
+}
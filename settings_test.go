@@ -0,0 +1,241 @@
+package gordian_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	maxUploadMB = gordian.NewSetting("max_upload_mb", 10, func(mb int) error {
+		if mb <= 0 {
+			return errors.New("must be positive")
+		}
+		return nil
+	})
+	newEditor = gordian.NewFeatureFlag("new-editor", 0)
+)
+
+func withSettings(db *gorm.DB) gordian.Option {
+	return gordian.WithSettings(gormadapter.NewSettingsStore(db), maxUploadMB)
+}
+
+func withFeatureFlags(db *gorm.DB) gordian.Option {
+	return gordian.WithFeatureFlags(newEditor)
+}
+
+func TestUpdateSettings(t *testing.T) {
+	env := newTestEnv(t, withSettings)
+	org := env.org("Acme", env.user("owner@example.com"))
+
+	// The cases run in order: each starts from the settings the one before left.
+	tests := []struct {
+		name       string
+		orgID      uuid.UUID
+		values     map[string]any
+		wantSSO    bool
+		wantUpload int
+		wantErr    error
+	}{
+		{name: "set", orgID: org.ID, values: map[string]any{gordian.SettingSSOOnly.Key: true, maxUploadMB.Key: 50}, wantSSO: true, wantUpload: 50},
+		{name: "raw JSON of the wrong type", orgID: org.ID, values: map[string]any{maxUploadMB.Key: json.RawMessage(`"nope"`)}, wantSSO: true, wantUpload: 50, wantErr: gordian.ErrInvalidArgument},
+		{name: "rejected by Validate", orgID: org.ID, values: map[string]any{maxUploadMB.Key: 0}, wantSSO: true, wantUpload: 50, wantErr: gordian.ErrInvalidArgument},
+		{name: "owner invite role", orgID: org.ID, values: map[string]any{gordian.SettingDefaultInviteRole.Key: "owner"}, wantSSO: true, wantUpload: 50, wantErr: gordian.ErrInvalidArgument},
+		{name: "wrong type", orgID: org.ID, values: map[string]any{gordian.SettingSSOOnly.Key: "yes"}, wantSSO: true, wantUpload: 50, wantErr: gordian.ErrInvalidArgument},
+		{name: "unknown setting", orgID: org.ID, values: map[string]any{"theme": "dark"}, wantSSO: true, wantUpload: 50, wantErr: gordian.ErrInvalidArgument},
+		{name: "reset", orgID: org.ID, values: map[string]any{gordian.SettingSSOOnly.Key: nil}, wantUpload: 50},
+		{name: "unknown organization", orgID: uuid.New(), values: map[string]any{maxUploadMB.Key: 20}, wantUpload: 50, wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.svc.UpdateSettings(env.ctx, tt.orgID, tt.values)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateSettings = %v, want %v", err, tt.wantErr)
+			}
+			settings, err := env.svc.GetSettings(env.ctx, org.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sso, upload := gordian.SettingSSOOnly.Get(settings), maxUploadMB.Get(settings); sso != tt.wantSSO || upload != tt.wantUpload {
+				t.Errorf("settings = %v, %d, want %v, %d", sso, upload, tt.wantSSO, tt.wantUpload)
+			}
+		})
+	}
+}
+
+func TestUpdateSettingsConcurrently(t *testing.T) {
+	var defs []gordian.SettingDefinition
+	for i := range 8 {
+		defs = append(defs, gordian.NewSetting(fmt.Sprintf("setting_%d", i), 0, nil))
+	}
+	env := newTestEnv(t, func(db *gorm.DB) gordian.Option {
+		return gordian.WithSettings(gormadapter.NewSettingsStore(db), defs...)
+	})
+	org := env.org("Acme", env.user("owner@example.com"))
+
+	var wg sync.WaitGroup
+	for _, def := range defs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := env.svc.UpdateSettings(env.ctx, org.ID, map[string]any{def.SettingKey(): 1}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	settings, err := env.svc.GetSettings(env.ctx, org.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, def := range defs {
+		if got := def.(*gordian.Setting[int]).Get(settings); got != 1 {
+			t.Errorf("%s = %d, want 1: a concurrent change was lost", def.SettingKey(), got)
+		}
+	}
+}
+
+func TestUpdateSettingsInTransaction(t *testing.T) {
+	env := newTestEnv(t, withSettings)
+	org := env.org("Acme", env.user("owner@example.com"))
+	transactor := gormadapter.NewTransactor(env.db)
+	upload := func(ctx context.Context) int {
+		t.Helper()
+		settings, err := env.svc.GetSettings(ctx, org.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return maxUploadMB.Get(settings)
+	}
+
+	err := transactor.WithinTransaction(env.ctx, func(ctx context.Context) error {
+		if _, err := env.svc.UpdateSettings(ctx, org.ID, map[string]any{maxUploadMB.Key: 50}); err != nil {
+			return err
+		}
+		if got := upload(ctx); got != 50 {
+			t.Errorf("inside the transaction: %d, want the uncommitted 50", got)
+		}
+		// Outside the transaction the change is not committed yet, and this caches the old value.
+		if got := upload(env.ctx); got != 10 {
+			t.Errorf("outside the transaction: %d, want the committed 10", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := upload(env.ctx); got != 50 {
+		t.Errorf("after the commit: %d, want 50", got)
+	}
+}
+
+func TestSetFeatureRollout(t *testing.T) {
+	env := newTestEnv(t, withSettings, withFeatureFlags)
+	org := env.org("Acme", env.user("owner@example.com"))
+	subject := uuid.New()
+
+	// The cases run in order: "reset" undoes "everyone".
+	tests := []struct {
+		name        string
+		key         string
+		percentage  int
+		reset       bool
+		wantRollout int
+		wantErr     error
+	}{
+		{name: "everyone", key: newEditor.Key, percentage: 100, wantRollout: 100},
+		{name: "above 100", key: newEditor.Key, percentage: 101, wantRollout: 100, wantErr: gordian.ErrInvalidArgument},
+		{name: "negative", key: newEditor.Key, percentage: -1, wantRollout: 100, wantErr: gordian.ErrInvalidArgument},
+		{name: "unknown flag", key: "dark-mode", percentage: 50, wantRollout: 100, wantErr: gordian.ErrInvalidArgument},
+		{name: "reset", key: newEditor.Key, reset: true, wantRollout: newEditor.Rollout},
+		{name: "reset an unknown flag", key: "dark-mode", reset: true, wantRollout: newEditor.Rollout, wantErr: gordian.ErrInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.reset {
+				_, err = env.svc.ResetFeatureRollout(env.ctx, org.ID, tt.key)
+			} else {
+				_, err = env.svc.SetFeatureRollout(env.ctx, org.ID, tt.key, tt.percentage)
+			}
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			settings, err := env.svc.GetSettings(env.ctx, org.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := settings.Rollouts()[newEditor.Key]; got != tt.wantRollout {
+				t.Errorf("rollout = %d, want %d", got, tt.wantRollout)
+			}
+			if got := newEditor.EnabledFor(settings, subject); got != (tt.wantRollout == 100) {
+				t.Errorf("EnabledFor = %v at %d%%", got, tt.wantRollout)
+			}
+		})
+	}
+}
+
+func TestSettingsNotConfigured(t *testing.T) {
+	env := newTestEnv(t)
+	org := env.org("Acme", env.user("owner@example.com"))
+	if _, err := env.svc.GetSettings(env.ctx, org.ID); !errors.Is(err, gordian.ErrNotConfigured) {
+		t.Errorf("GetSettings = %v, want ErrNotConfigured", err)
+	}
+	if _, err := env.svc.UpdateSettings(env.ctx, org.ID, map[string]any{gordian.SettingSSOOnly.Key: true}); !errors.Is(err, gordian.ErrNotConfigured) {
+		t.Errorf("UpdateSettings = %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestMiddlewareLoadsSettings(t *testing.T) {
+	env := newTestEnv(t, withSettings, withAPIKeys)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	admin := env.member(org, "admin@example.com", "admin")
+	if _, err := env.svc.UpdateSettings(env.ctx, org.ID, map[string]any{maxUploadMB.Key: 50}); err != nil {
+		t.Fatal(err)
+	}
+	_, plaintext, err := env.svc.CreateAPIKey(env.ctx, org.ID, "CI", "admin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := maxUploadMB.FromContext(r.Context()); got != 50 {
+			t.Errorf("max_upload_mb = %d, want 50", got)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		prepare func(r *http.Request) *http.Request
+	}{
+		{name: "tenancy", handler: env.svc.TenancyMiddleware(handler), prepare: func(r *http.Request) *http.Request {
+			r.Header.Set("X-Tenant-ID", org.ID.String())
+			return r.WithContext(context.WithValue(r.Context(), "user_id", admin.ID))
+		}},
+		{name: "API key", handler: env.svc.APIKeyMiddleware(handler), prepare: func(r *http.Request) *http.Request {
+			r.Header.Set("Authorization", "Bearer "+plaintext)
+			return r
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, tt.prepare(httptest.NewRequest(http.MethodGet, "/", nil)))
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
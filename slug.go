@@ -0,0 +1,282 @@
+package gordian
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Length limits of organization slugs.
+const (
+	MinSlugLength = 3
+	MaxSlugLength = 48
+)
+
+// ReservedSlugs cannot be used by organizations because they collide with routes of typical apps.
+var ReservedSlugs = map[string]bool{
+	"about": true, "account": true, "admin": true, "api": true, "app": true, "assets": true,
+	"auth": true, "billing": true, "blog": true, "dashboard": true, "docs": true, "help": true,
+	"invite": true, "invites": true, "login": true, "logout": true, "new": true, "oauth": true,
+	"org": true, "orgs": true, "organizations": true, "pricing": true, "register": true,
+	"settings": true, "signin": true, "signup": true, "static": true, "status": true,
+	"support": true, "system": true, "users": true, "www": true,
+}
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// OrganizationSlugRedirect keeps a former slug pointing at its organization after a slug change.
+// Former slugs stay reserved for the organization.
+type OrganizationSlugRedirect struct {
+	Slug           string
+	OrganizationID uuid.UUID
+	CreatedAt      time.Time
+}
+
+// ValidateSlug checks that slug is lower-case letters, digits and single hyphens, between
+// MinSlugLength and MaxSlugLength long and not in ReservedSlugs.
+func ValidateSlug(slug string) error {
+	if len(slug) < MinSlugLength || len(slug) > MaxSlugLength {
+		return fmt.Errorf("slug must be %d to %d characters long: %w", MinSlugLength, MaxSlugLength, ErrInvalidArgument)
+	}
+	if !slugPattern.MatchString(slug) {
+		return fmt.Errorf("slug may only contain lower-case letters, digits and single hyphens: %w", ErrInvalidArgument)
+	}
+	if ReservedSlugs[slug] {
+		return fmt.Errorf("slug %q is reserved: %w", slug, ErrInvalidArgument)
+	}
+	return nil
+}
+
+// Slugify turns name into a slug candidate, e.g. "Café Zürich GmbH" into "cafe-zurich-gmbh".
+// The result is not checked against ValidateSlug.
+func Slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFKD.String(strings.ToLower(name)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			hyphen = false
+		case unicode.Is(unicode.Mn, r):
+			// Drop accents left over by the decomposition.
+		case !hyphen && b.Len() > 0:
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > MaxSlugLength {
+		slug = strings.TrimSuffix(slug[:MaxSlugLength], "-")
+	}
+	return slug
+}
+
+// availableSlug returns a free slug for name: its Slugify form, then with "-2", "-3", ...
+// appended, then with a random suffix.
+func (s *Service) availableSlug(ctx context.Context, name string) (string, error) {
+	base := Slugify(name)
+	if len(base) < MinSlugLength || ReservedSlugs[base] {
+		base = strings.Trim("org-"+base, "-")
+	}
+	base = strings.TrimSuffix(base[:min(len(base), MaxSlugLength-7)], "-")
+	for i := 1; i <= 10; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, i)
+		}
+		if ReservedSlugs[candidate] {
+			continue
+		}
+		taken, err := s.slugTaken(ctx, candidate, uuid.Nil)
+		if err != nil || !taken {
+			return candidate, err
+		}
+	}
+	raw := make([]byte, 3)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate slug: %w", err)
+	}
+	return base + "-" + hex.EncodeToString(raw), nil
+}
+
+// maxSlugAttempts bounds how often a generated slug is replaced after another organization
+// took it between availableSlug and the write.
+const maxSlugAttempts = 3
+
+// storeWithSlug runs store, which writes org. When org's slug was generated and store fails
+// because another organization has taken it meanwhile, it picks the next available slug and
+// runs store again. Inside a caller's transaction the conflict is returned instead, as the
+// failed write may have aborted that transaction.
+func (s *Service) storeWithSlug(ctx context.Context, org *Organization, generated bool, store func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := store()
+		if !generated || attempt == maxSlugAttempts || !errors.Is(err, ErrConflict) || InTransaction(ctx) {
+			return err
+		}
+		if taken, lookupErr := s.slugTaken(ctx, org.Slug, org.ID); lookupErr != nil || !taken {
+			return err
+		}
+		slug, slugErr := s.availableSlug(ctx, org.Name)
+		if slugErr != nil {
+			return slugErr
+		}
+		org.Slug = slug
+	}
+}
+
+// slugTaken reports whether slug is the current or a former slug of an organization other than orgID.
+func (s *Service) slugTaken(ctx context.Context, slug string, orgID uuid.UUID) (bool, error) {
+	org, err := s.orgStore.GetBySlug(ctx, slug)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up slug: %w", err)
+	}
+	return org.ID != orgID, nil
+}
+
+// GetOrganizationBySlug returns the organization with the current or a former slug. Compare the
+// returned Slug with slug to redirect requests for former slugs.
+func (s *Service) GetOrganizationBySlug(ctx context.Context, slug string) (*Organization, error) {
+	org, err := s.orgStore.GetBySlug(ctx, strings.ToLower(slug))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return org, nil
+}
+
+// RenameOrganization changes the display name of orgID. The slug is kept; use
+// ChangeOrganizationSlug to change it too.
+func (s *Service) RenameOrganization(ctx context.Context, orgID uuid.UUID, name string) (*Organization, error) {
+	if len(name) < 3 {
+		return nil, fmt.Errorf("invalid organization name: %w", ErrInvalidArgument)
+	}
+	org, err := s.orgStore.Get(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	before := snapshotOrganization(org)
+	org.Name = name
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.orgStore.Update(ctx, org); err != nil {
+			return fmt.Errorf("failed to update organization: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditOrganizationRenamed, TargetType: "organization", TargetID: orgID}
+		return s.audit(ctx, entry, before, snapshotOrganization(org))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// ChangeOrganizationSlug gives orgID a new slug, generated from its name when slug is empty.
+// The previous slug keeps resolving to the organization.
+func (s *Service) ChangeOrganizationSlug(ctx context.Context, orgID uuid.UUID, slug string) (*Organization, error) {
+	org, err := s.orgStore.Get(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	generated := slug == ""
+	if generated {
+		if slug, err = s.availableSlug(ctx, org.Name); err != nil {
+			return nil, err
+		}
+	}
+	if err := ValidateSlug(slug); err != nil {
+		return nil, err
+	}
+	if slug == org.Slug {
+		return org, nil
+	}
+	if taken, err := s.slugTaken(ctx, slug, orgID); err != nil {
+		return nil, err
+	} else if taken {
+		return nil, fmt.Errorf("slug %q is already taken: %w", slug, ErrConflict)
+	}
+
+	before := snapshotOrganization(org)
+	org.Slug = slug
+	err = s.storeWithSlug(ctx, org, generated, func() error {
+		return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			if before.Slug != "" {
+				redirect := &OrganizationSlugRedirect{Slug: before.Slug, OrganizationID: orgID, CreatedAt: time.Now()}
+				if err := s.orgStore.AddSlugRedirect(ctx, redirect); err != nil {
+					return fmt.Errorf("failed to keep previous slug: %w", err)
+				}
+			}
+			if err := s.orgStore.Update(ctx, org); err != nil {
+				return fmt.Errorf("failed to update organization: %w", err)
+			}
+			entry := AuditEntry{OrganizationID: orgID, Action: AuditOrganizationSlugChanged, TargetType: "organization", TargetID: orgID}
+			return s.audit(ctx, entry, before, snapshotOrganization(org))
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// TenantResolver determines the organization a request acts on for TenancyMiddleware.
+// It returns an error wrapping ErrInvalidArgument when the request names no valid organization,
+// and ErrNotFound when the named organization does not exist.
+type TenantResolver func(r *http.Request) (uuid.UUID, error)
+
+// HeaderTenantResolver reads the organization ID from a header, X-Tenant-ID by default.
+func HeaderTenantResolver(header string) TenantResolver {
+	return func(r *http.Request) (uuid.UUID, error) {
+		value := r.Header.Get(header)
+		if value == "" {
+			return uuid.Nil, fmt.Errorf("missing %s header: %w", header, ErrInvalidArgument)
+		}
+		orgID, err := uuid.Parse(value)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("invalid %s header: %w", header, ErrInvalidArgument)
+		}
+		return orgID, nil
+	}
+}
+
+// SlugTenantResolver resolves the organization slug returned by slugOf, e.g.
+//
+//	gordian.SlugTenantResolver(orgStore, func(r *http.Request) string { return r.PathValue("org") })
+//
+// Former slugs resolve to their organization.
+func SlugTenantResolver(store OrganizationStore, slugOf func(r *http.Request) string) TenantResolver {
+	return func(r *http.Request) (uuid.UUID, error) {
+		slug := slugOf(r)
+		if slug == "" {
+			return uuid.Nil, fmt.Errorf("missing organization slug: %w", ErrInvalidArgument)
+		}
+		org, err := store.GetBySlug(r.Context(), strings.ToLower(slug))
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("failed to get organization: %w", err)
+		}
+		return org.ID, nil
+	}
+}
+
+// SubdomainSlug returns the first label of the request host below domain, so that
+// "acme.example.com" yields "acme" for domain "example.com". Use it with SlugTenantResolver.
+func SubdomainSlug(domain string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		host, _, _ := strings.Cut(r.Host, ":")
+		sub, ok := strings.CutSuffix(strings.ToLower(host), "."+domain)
+		if !ok || strings.Contains(sub, ".") {
+			return ""
+		}
+		return sub
+	}
+}
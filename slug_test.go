@@ -0,0 +1,225 @@
+package gordian_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/google/uuid"
+)
+
+func TestValidateSlug(t *testing.T) {
+	tests := []struct {
+		slug  string
+		valid bool
+	}{
+		{slug: "acme", valid: true},
+		{slug: "acme-inc-2", valid: true},
+		{slug: "abc", valid: true},
+		{slug: strings.Repeat("a", gordian.MaxSlugLength), valid: true},
+		{slug: "ab"},
+		{slug: strings.Repeat("a", gordian.MaxSlugLength+1)},
+		{slug: "Acme"},
+		{slug: "acme_inc"},
+		{slug: "acme--inc"},
+		{slug: "-acme"},
+		{slug: "acme-"},
+		{slug: "admin"},
+	}
+	for _, tt := range tests {
+		err := gordian.ValidateSlug(tt.slug)
+		if tt.valid && err != nil || !tt.valid && !errors.Is(err, gordian.ErrInvalidArgument) {
+			t.Errorf("ValidateSlug(%q) = %v, want valid %v", tt.slug, err, tt.valid)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Acme", want: "acme"},
+		{name: "Café Zürich GmbH", want: "cafe-zurich-gmbh"},
+		{name: "  Acme, Inc.  ", want: "acme-inc"},
+		{name: "ACME -- Labs", want: "acme-labs"},
+		{name: "東京", want: ""},
+		{name: strings.Repeat("ab-", 20), want: strings.TrimSuffix(strings.Repeat("ab-", 16), "-")},
+	}
+	for _, tt := range tests {
+		if got := gordian.Slugify(tt.name); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCreateOrganizationSlug(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+
+	// The cases run in order: later organizations find the slugs of earlier ones taken.
+	tests := []struct {
+		name     string
+		wantSlug string
+	}{
+		{name: "Acme", wantSlug: "acme"},
+		{name: "ACME", wantSlug: "acme-2"},
+		{name: "Acme!", wantSlug: "acme-3"},
+		{name: "Café Zürich", wantSlug: "cafe-zurich"},
+		{name: "Admin", wantSlug: "org-admin"},
+		{name: "Ab!", wantSlug: "org-ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org := env.org(tt.name, owner)
+			if org.Slug != tt.wantSlug {
+				t.Errorf("Slug = %q, want %q", org.Slug, tt.wantSlug)
+			}
+		})
+	}
+}
+
+func TestCreateOrganizationSlugRace(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	// Another Acme is created after the first one picked its slug but before it was stored.
+	raced := false
+	gordian.OnBefore(env.svc.Events(), func(ctx context.Context, e gordian.OrganizationCreated) error {
+		if raced {
+			return nil
+		}
+		raced = true
+		_, err := env.svc.CreateOrganization(ctx, "Acme", owner.ID)
+		return err
+	})
+
+	org, err := env.svc.CreateOrganization(env.ctx, "Acme", owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if org.Slug != "acme-2" {
+		t.Errorf("Slug = %q, want acme-2 after losing acme", org.Slug)
+	}
+}
+
+func TestChangeOrganizationSlug(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user("owner@example.com")
+	org, other := env.org("Acme", owner), env.org("Globex", owner)
+	if _, err := env.svc.RenameOrganization(env.ctx, other.ID, "Globex Labs"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cases run in order, each starting from the slug the previous one left.
+	tests := []struct {
+		name     string
+		orgID    uuid.UUID
+		slug     string
+		wantSlug string
+		wantErr  error
+	}{
+		{name: "new slug", orgID: org.ID, slug: "acme-inc", wantSlug: "acme-inc"},
+		{name: "unchanged", orgID: org.ID, slug: "acme-inc", wantSlug: "acme-inc"},
+		{name: "back to a former slug", orgID: org.ID, slug: "acme", wantSlug: "acme"},
+		{name: "current slug of another organization", orgID: org.ID, slug: "globex", wantErr: gordian.ErrConflict},
+		{name: "former slug of another organization", orgID: other.ID, slug: "acme-inc", wantErr: gordian.ErrConflict},
+		{name: "invalid", orgID: org.ID, slug: "Acme Inc", wantErr: gordian.ErrInvalidArgument},
+		{name: "reserved", orgID: org.ID, slug: "settings", wantErr: gordian.ErrInvalidArgument},
+		{name: "generated from the name", orgID: other.ID, wantSlug: "globex-labs"},
+		{name: "unknown organization", orgID: uuid.New(), slug: "initech", wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := env.svc.ChangeOrganizationSlug(env.ctx, tt.orgID, tt.slug)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ChangeOrganizationSlug = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Slug != tt.wantSlug {
+				t.Errorf("Slug = %q, want %q", got.Slug, tt.wantSlug)
+			}
+		})
+	}
+
+	for _, slug := range []string{"acme", "acme-inc", "ACME-INC"} {
+		got, err := env.svc.GetOrganizationBySlug(env.ctx, slug)
+		if err != nil || got.ID != org.ID || got.Slug != "acme" {
+			t.Errorf("GetOrganizationBySlug(%q) = %v, %v, want Acme with slug acme", slug, got, err)
+		}
+	}
+}
+
+func TestSubdomainSlug(t *testing.T) {
+	slugOf := gordian.SubdomainSlug("example.com")
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "acme.example.com", want: "acme"},
+		{host: "Acme.Example.com:8080", want: "acme"},
+		{host: "example.com", want: ""},
+		{host: "a.b.example.com", want: ""},
+		{host: "acme.example.org", want: ""},
+		{host: "acmeexample.com", want: ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = tt.host
+		if got := slugOf(r); got != tt.want {
+			t.Errorf("SubdomainSlug(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestTenantResolvers(t *testing.T) {
+	env := newTestEnv(t)
+	org := env.org("Acme", env.user("owner@example.com"))
+	header := gordian.HeaderTenantResolver("X-Tenant-ID")
+	slug := gordian.SlugTenantResolver(gormadapter.NewOrganizationStore(env.db), func(r *http.Request) string {
+		return r.URL.Query().Get("org")
+	})
+
+	tests := []struct {
+		name     string
+		resolver gordian.TenantResolver
+		header   string
+		query    string
+		wantErr  error
+	}{
+		{name: "header", resolver: header, header: org.ID.String()},
+		{name: "missing header", resolver: header, wantErr: gordian.ErrInvalidArgument},
+		{name: "malformed header", resolver: header, header: "acme", wantErr: gordian.ErrInvalidArgument},
+		{name: "slug", resolver: slug, query: "acme"},
+		{name: "upper-case slug", resolver: slug, query: "ACME"},
+		{name: "missing slug", resolver: slug, wantErr: gordian.ErrInvalidArgument},
+		{name: "unknown slug", resolver: slug, query: "globex", wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/?org="+tt.query, nil)
+			if tt.header != "" {
+				r.Header.Set("X-Tenant-ID", tt.header)
+			}
+			orgID, err := tt.resolver(r)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("resolver = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || orgID != org.ID {
+				t.Errorf("resolver = %s, %v, want %s", orgID, err, org.ID)
+			}
+		})
+	}
+}
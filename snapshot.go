@@ -0,0 +1,241 @@
+package gordian
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Snapshots are the JSON views of records written to the audit log and sent in webhook payloads.
+// They leave out secrets: invitation and invite link tokens, API key hashes, webhook secrets and
+// domain verification tokens.
+
+type organizationSnapshot struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Slug      string     `json:"slug"`
+	OwnerID   uuid.UUID  `json:"owner_id"`
+	ParentID  *uuid.UUID `json:"parent_id"`
+	Plan      string     `json:"plan"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	BillingCustomerID  string             `json:"billing_customer_id"`
+	SubscriptionID     string             `json:"subscription_id"`
+	SubscriptionStatus SubscriptionStatus `json:"subscription_status"`
+}
+
+func snapshotOrganization(org *Organization) *organizationSnapshot {
+	return &organizationSnapshot{
+		ID:        org.ID,
+		Name:      org.Name,
+		Slug:      org.Slug,
+		OwnerID:   org.OwnerID,
+		ParentID:  org.ParentID,
+		Plan:      org.Plan,
+		CreatedAt: org.CreatedAt,
+
+		BillingCustomerID:  org.BillingCustomerID,
+		SubscriptionID:     org.SubscriptionID,
+		SubscriptionStatus: org.SubscriptionStatus,
+	}
+}
+
+type membershipSnapshot struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Role           string    `json:"role"`
+	JoinedAt       time.Time `json:"joined_at"`
+}
+
+func snapshotMembership(m *Membership) *membershipSnapshot {
+	return &membershipSnapshot{
+		ID:             m.ID,
+		OrganizationID: m.OrganizationID,
+		UserID:         m.UserID,
+		Role:           m.Role,
+		JoinedAt:       m.JoinedAt,
+	}
+}
+
+type inviteSnapshot struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	InviterID      uuid.UUID  `json:"inviter_id"`
+	InviteeEmail   string     `json:"invitee_email"`
+	Role           string     `json:"role"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+}
+
+func snapshotInvite(invite *Invite) *inviteSnapshot {
+	return &inviteSnapshot{
+		ID:             invite.ID,
+		OrganizationID: invite.OrganizationID,
+		InviterID:      invite.InviterID,
+		InviteeEmail:   invite.InviteeEmail,
+		Role:           invite.Role,
+		ExpiresAt:      invite.ExpiresAt,
+		CreatedAt:      invite.CreatedAt,
+		AcceptedAt:     invite.AcceptedAt,
+		RevokedAt:      invite.RevokedAt,
+	}
+}
+
+type inviteLinkSnapshot struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	CreatorID      uuid.UUID  `json:"creator_id"`
+	Role           string     `json:"role"`
+	MaxUses        int        `json:"max_uses"`
+	Uses           int        `json:"uses"`
+	AllowedDomain  string     `json:"allowed_domain"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+}
+
+func snapshotInviteLink(link *InviteLink) *inviteLinkSnapshot {
+	return &inviteLinkSnapshot{
+		ID:             link.ID,
+		OrganizationID: link.OrganizationID,
+		CreatorID:      link.CreatorID,
+		Role:           link.Role,
+		MaxUses:        link.MaxUses,
+		Uses:           link.Uses,
+		AllowedDomain:  link.AllowedDomain,
+		ExpiresAt:      link.ExpiresAt,
+		CreatedAt:      link.CreatedAt,
+		RevokedAt:      link.RevokedAt,
+	}
+}
+
+type joinRequestSnapshot struct {
+	ID             uuid.UUID         `json:"id"`
+	OrganizationID uuid.UUID         `json:"organization_id"`
+	UserID         uuid.UUID         `json:"user_id"`
+	Message        string            `json:"message"`
+	Source         string            `json:"source"`
+	Role           string            `json:"role"`
+	Status         JoinRequestStatus `json:"status"`
+	DeciderID      *uuid.UUID        `json:"decider_id"`
+	CreatedAt      time.Time         `json:"created_at"`
+	DecidedAt      *time.Time        `json:"decided_at"`
+}
+
+func snapshotJoinRequest(request *JoinRequest) *joinRequestSnapshot {
+	return &joinRequestSnapshot{
+		ID:             request.ID,
+		OrganizationID: request.OrganizationID,
+		UserID:         request.UserID,
+		Message:        request.Message,
+		Source:         request.Source,
+		Role:           request.Role,
+		Status:         request.Status,
+		DeciderID:      request.DeciderID,
+		CreatedAt:      request.CreatedAt,
+		DecidedAt:      request.DecidedAt,
+	}
+}
+
+type apiKeySnapshot struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	Name           string     `json:"name"`
+	Prefix         string     `json:"prefix"`
+	Role           string     `json:"role"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+}
+
+func snapshotAPIKey(key *APIKey) *apiKeySnapshot {
+	return &apiKeySnapshot{
+		ID:             key.ID,
+		OrganizationID: key.OrganizationID,
+		Name:           key.Name,
+		Prefix:         key.Prefix,
+		Role:           key.Role,
+		ExpiresAt:      key.ExpiresAt,
+		RevokedAt:      key.RevokedAt,
+	}
+}
+
+type webhookEndpointSnapshot struct {
+	ID             uuid.UUID  `json:"id"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	URL            string     `json:"url"`
+	EventTypes     EventTypes `json:"event_types"`
+}
+
+func snapshotWebhookEndpoint(endpoint *WebhookEndpoint) *webhookEndpointSnapshot {
+	return &webhookEndpointSnapshot{
+		ID:             endpoint.ID,
+		OrganizationID: endpoint.OrganizationID,
+		URL:            endpoint.URL,
+		EventTypes:     endpoint.EventTypes,
+	}
+}
+
+type teamSnapshot struct {
+	ID             uuid.UUID   `json:"id"`
+	OrganizationID uuid.UUID   `json:"organization_id"`
+	Name           string      `json:"name"`
+	Description    string      `json:"description"`
+	Permissions    Permissions `json:"permissions"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+func snapshotTeam(team *Team) *teamSnapshot {
+	return &teamSnapshot{
+		ID:             team.ID,
+		OrganizationID: team.OrganizationID,
+		Name:           team.Name,
+		Description:    team.Description,
+		Permissions:    team.Permissions,
+		CreatedAt:      team.CreatedAt,
+	}
+}
+
+type teamMembershipSnapshot struct {
+	ID             uuid.UUID `json:"id"`
+	TeamID         uuid.UUID `json:"team_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Role           string    `json:"role"`
+	JoinedAt       time.Time `json:"joined_at"`
+}
+
+func snapshotTeamMembership(m *TeamMembership) *teamMembershipSnapshot {
+	return &teamMembershipSnapshot{
+		ID:             m.ID,
+		TeamID:         m.TeamID,
+		OrganizationID: m.OrganizationID,
+		UserID:         m.UserID,
+		Role:           m.Role,
+		JoinedAt:       m.JoinedAt,
+	}
+}
+
+type organizationDomainSnapshot struct {
+	ID             uuid.UUID    `json:"id"`
+	OrganizationID uuid.UUID    `json:"organization_id"`
+	Domain         string       `json:"domain"`
+	Policy         DomainPolicy `json:"policy"`
+	DefaultRole    string       `json:"default_role"`
+	VerifiedAt     *time.Time   `json:"verified_at"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+func snapshotOrganizationDomain(d *OrganizationDomain) *organizationDomainSnapshot {
+	return &organizationDomainSnapshot{
+		ID:             d.ID,
+		OrganizationID: d.OrganizationID,
+		Domain:         d.Domain,
+		Policy:         d.Policy,
+		DefaultRole:    d.DefaultRole,
+		VerifiedAt:     d.VerifiedAt,
+		CreatedAt:      d.CreatedAt,
+	}
+}
@@ -2,6 +2,8 @@ package gordian
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -10,6 +12,23 @@ import (
 type OrganizationStore interface {
 	Create(ctx context.Context, org *Organization) error
 	Get(ctx context.Context, id uuid.UUID) (*Organization, error)
+	// GetBySlug returns the organization whose current slug, or else former slug, is slug.
+	GetBySlug(ctx context.Context, slug string) (*Organization, error)
+	Update(ctx context.Context, org *Organization) error
+	// UpdateSubscription writes only the Plan and the billing fields of org, so it cannot undo a
+	// concurrent Update.
+	UpdateSubscription(ctx context.Context, org *Organization) error
+	// Delete removes the organization together with its memberships, invitations and other data.
+	Delete(ctx context.Context, id uuid.UUID) error
+	// AddSlugRedirect records a former slug, replacing an earlier redirect of the same slug.
+	AddSlugRedirect(ctx context.Context, redirect *OrganizationSlugRedirect) error
+	// ListAncestors returns the parent chain of id, nearest first, up to MaxOrganizationDepth levels.
+	ListAncestors(ctx context.Context, id uuid.UUID) ([]*Organization, error)
+	// ListDescendants returns the organizations below id at any depth, up to MaxOrganizationDepth levels.
+	ListDescendants(ctx context.Context, id uuid.UUID) ([]*Organization, error)
+	// Lock locks the organization row until the transaction in ctx ends, so that changes
+	// validated against it cannot interleave. It fails with ErrNotFound for an unknown id.
+	Lock(ctx context.Context, id uuid.UUID) error
 }
 
 // Defines contract for storing users.
@@ -17,23 +36,220 @@ type UserStore interface {
 	Create(ctx context.Context, user *User) error
 	Get(ctx context.Context, id uuid.UUID) (*User, error)
 	GetUserRole(ctx context.Context, userID uuid.UUID) (string, error)
+	// FindByEmail matches email case-insensitively.
 	FindByEmail(ctx context.Context, email string) (User, error)
+	Update(ctx context.Context, user *User) error
 }
 
 // Defines contract for storing memberships.
 type MembershipStore interface {
 	Create(ctx context.Context, membership *Membership) error
 	GetMembers(ctx context.Context, orgID uuid.UUID) ([]*Membership, error)
+	ListForUser(ctx context.Context, userID uuid.UUID) ([]*Membership, error)
 	GetMembership(ctx context.Context, userID uuid.UUID, orgID uuid.UUID) (Membership, error)
+	Update(ctx context.Context, membership *Membership) error
+	Delete(ctx context.Context, id uuid.UUID) error
 }
 
 // Defines contract for storing invitations.
 type InvitationStore interface {
 	Create(ctx context.Context, invite *Invite) error
 	Verify(ctx context.Context, token string) (bool, error)
+	Get(ctx context.Context, id uuid.UUID) (*Invite, error)
+	GetByToken(ctx context.Context, token string) (*Invite, error)
+	// ListPending returns invitations of orgID that are neither accepted, revoked nor expired.
+	ListPending(ctx context.Context, orgID uuid.UUID) ([]*Invite, error)
+	Update(ctx context.Context, invite *Invite) error
 }
 
 // Defines the contract for sending emails.
 type Emailer interface {
 	SendInvitation(ctx context.Context, invite *Invite) error
 }
+
+// Defines the emails sent about join requests. An Emailer that does not implement it sends none.
+type JoinRequestEmailer interface {
+	// SendJoinRequest tells the owners and admins of the organization about a new request.
+	SendJoinRequest(ctx context.Context, request *JoinRequest, admins []*User) error
+	// SendJoinRequestDecision tells the requester that their request was approved or denied.
+	SendJoinRequestDecision(ctx context.Context, request *JoinRequest, requester *User) error
+}
+
+// Defines contract for storing outbox messages awaiting delivery.
+type OutboxStore interface {
+	Create(ctx context.Context, msg *OutboxMessage) error
+	Get(ctx context.Context, id uuid.UUID) (*OutboxMessage, error)
+	Update(ctx context.Context, msg *OutboxMessage) error
+	// ClaimDue returns up to limit pending messages whose NextAttemptAt has passed and
+	// pushes their NextAttemptAt forward by lease so that concurrent dispatchers skip them.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*OutboxMessage, error)
+	ListByStatus(ctx context.Context, status OutboxStatus, limit int) ([]*OutboxMessage, error)
+}
+
+// Defines contract for storing the audit log. Entries are append-only.
+type AuditStore interface {
+	Create(ctx context.Context, entry *AuditEntry) error
+	List(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error)
+}
+
+// Defines the contract for running several store calls as one atomic unit.
+// Stores must use the transaction carried by the ctx passed to fn, and implementations mark
+// that ctx with WithTransaction, or with WithAfterCommit to support AfterCommit.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transactionContextKey struct{}
+
+// WithTransaction marks ctx as carrying a transaction, so that caches such as
+// CachingMembershipStore neither serve nor store rows its uncommitted writes may have changed.
+func WithTransaction(ctx context.Context) context.Context {
+	return context.WithValue(ctx, transactionContextKey{}, true)
+}
+
+// InTransaction reports whether ctx was marked by WithTransaction.
+func InTransaction(ctx context.Context) bool {
+	return ctx.Value(transactionContextKey{}) != nil
+}
+
+type afterCommitContextKey struct{}
+
+type afterCommitQueue struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// WithAfterCommit marks ctx like WithTransaction and returns the function the Transactor calls
+// once the transaction has committed, which runs the functions queued with AfterCommit.
+func WithAfterCommit(ctx context.Context) (context.Context, func()) {
+	queue := &afterCommitQueue{}
+	ctx = context.WithValue(WithTransaction(ctx), afterCommitContextKey{}, queue)
+	return ctx, func() {
+		queue.mu.Lock()
+		fns := queue.fns
+		queue.fns = nil
+		queue.mu.Unlock()
+		for _, fn := range fns {
+			fn()
+		}
+	}
+}
+
+// AfterCommit runs fn once the transaction carried by ctx has committed, and drops it when the
+// transaction rolls back. Without a transaction, or with one from a Transactor that doesn't use
+// WithAfterCommit, fn runs right away.
+func AfterCommit(ctx context.Context, fn func()) {
+	queue, ok := ctx.Value(afterCommitContextKey{}).(*afterCommitQueue)
+	if !ok {
+		fn()
+		return
+	}
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.fns = append(queue.fns, fn)
+}
+
+// noopTransactor runs fn directly, for stores without transaction support.
+type noopTransactor struct{}
+
+func (noopTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// Defines contract for storing webhook endpoints and their delivery log.
+type WebhookStore interface {
+	CreateEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error
+	GetEndpoint(ctx context.Context, id uuid.UUID) (*WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, orgID uuid.UUID) ([]*WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id uuid.UUID) error
+	CreateDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	UpdateDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	ListDeliveries(ctx context.Context, endpointID uuid.UUID, limit int) ([]*WebhookDelivery, error)
+	// ClaimDueDeliveries works like OutboxStore.ClaimDue for pending deliveries.
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*WebhookDelivery, error)
+}
+
+// Defines contract for storing API keys.
+type APIKeyStore interface {
+	Create(ctx context.Context, key *APIKey) error
+	Get(ctx context.Context, id uuid.UUID) (*APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	List(ctx context.Context, orgID uuid.UUID) ([]*APIKey, error)
+	Update(ctx context.Context, key *APIKey) error
+	// TouchLastUsed sets only LastUsedAt, so it cannot undo a concurrent revocation.
+	TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// Defines contract for storing teams and their members.
+type TeamStore interface {
+	CreateTeam(ctx context.Context, team *Team) error
+	GetTeam(ctx context.Context, id uuid.UUID) (*Team, error)
+	ListTeams(ctx context.Context, orgID uuid.UUID) ([]*Team, error)
+	UpdateTeam(ctx context.Context, team *Team) error
+	// DeleteTeam deletes the team and its memberships.
+	DeleteTeam(ctx context.Context, id uuid.UUID) error
+	AddMember(ctx context.Context, membership *TeamMembership) error
+	GetMember(ctx context.Context, teamID, userID uuid.UUID) (*TeamMembership, error)
+	ListMembers(ctx context.Context, teamID uuid.UUID) ([]*TeamMembership, error)
+	// ListForUser returns the teams of orgID that userID belongs to.
+	ListForUser(ctx context.Context, orgID, userID uuid.UUID) ([]*Team, error)
+	UpdateMember(ctx context.Context, membership *TeamMembership) error
+	RemoveMember(ctx context.Context, id uuid.UUID) error
+	// RemoveUser removes userID from every team of orgID.
+	RemoveUser(ctx context.Context, orgID, userID uuid.UUID) error
+}
+
+// Defines contract for storing the email domains claimed by organizations.
+type DomainStore interface {
+	Create(ctx context.Context, domain *OrganizationDomain) error
+	Get(ctx context.Context, id uuid.UUID) (*OrganizationDomain, error)
+	List(ctx context.Context, orgID uuid.UUID) ([]*OrganizationDomain, error)
+	// GetVerified returns the verified claim of domain. At most one organization can verify a domain.
+	GetVerified(ctx context.Context, domain string) (*OrganizationDomain, error)
+	Update(ctx context.Context, domain *OrganizationDomain) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// Defines contract for storing join requests.
+type JoinRequestStore interface {
+	Create(ctx context.Context, request *JoinRequest) error
+	Get(ctx context.Context, id uuid.UUID) (*JoinRequest, error)
+	// GetPending returns the pending request of userID for orgID.
+	GetPending(ctx context.Context, orgID, userID uuid.UUID) (*JoinRequest, error)
+	// List returns the requests of orgID with status, or all of them when status is empty, newest first.
+	List(ctx context.Context, orgID uuid.UUID, status JoinRequestStatus) ([]*JoinRequest, error)
+	Update(ctx context.Context, request *JoinRequest) error
+}
+
+// Defines contract for storing invite links and who redeemed them.
+type InviteLinkStore interface {
+	Create(ctx context.Context, link *InviteLink) error
+	Get(ctx context.Context, id uuid.UUID) (*InviteLink, error)
+	GetByToken(ctx context.Context, token string) (*InviteLink, error)
+	List(ctx context.Context, orgID uuid.UUID) ([]*InviteLink, error)
+	Update(ctx context.Context, link *InviteLink) error
+	// Redeem counts a use of the link and records redemption, atomically. It fails with
+	// ErrConflict if the link is revoked, expired or used up, or the user already redeemed it.
+	Redeem(ctx context.Context, redemption *InviteLinkRedemption) error
+	ListRedemptions(ctx context.Context, linkID uuid.UUID) ([]*InviteLinkRedemption, error)
+}
+
+// Defines contract for counting what organizations use, to enforce the limits of their Plan.
+type UsageStore interface {
+	// LockUsage serializes quota checks of orgID until the transaction in ctx ends,
+	// e.g. by locking the organization row.
+	LockUsage(ctx context.Context, orgID uuid.UUID) error
+	// GetUsage counts the memberships, pending invitations at now and teams of orgID.
+	GetUsage(ctx context.Context, orgID uuid.UUID, now time.Time) (Usage, error)
+}
+
+// Defines contract for storing the settings and feature rollouts of organizations.
+type SettingsStore interface {
+	// Get returns ErrNotFound if nothing was saved for orgID yet.
+	Get(ctx context.Context, orgID uuid.UUID) (*OrganizationSettings, error)
+	// Save creates or replaces the settings of settings.OrganizationID.
+	Save(ctx context.Context, settings *OrganizationSettings) error
+	// LockSettings serializes changes of the settings of orgID until the transaction in ctx
+	// ends, e.g. by locking the organization row, as nothing may have been saved yet.
+	LockSettings(ctx context.Context, orgID uuid.UUID) error
+}
@@ -0,0 +1,409 @@
+package gordian
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Roles of a user within a team.
+const (
+	TeamRoleMaintainer = "maintainer" // Can manage the team's members
+	TeamRoleMember     = "member"
+)
+
+// DefaultRolePermissions grants every permission to owners and admins. Members only get
+// the permissions of their teams.
+var DefaultRolePermissions = map[string][]string{
+	"owner": {"*"},
+	"admin": {"*"},
+}
+
+// Permissions is a list of permission names, e.g. "billing:read", stored as a JSON array.
+// "*" grants everything and "billing:*" everything starting with "billing:".
+type Permissions []string
+
+func (p Permissions) Value() (driver.Value, error) {
+	if p == nil {
+		return "[]", nil
+	}
+	body, err := json.Marshal([]string(p))
+	return string(body), err
+}
+
+func (p *Permissions) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	case []byte:
+		return json.Unmarshal(v, p)
+	}
+	return fmt.Errorf("cannot scan %T into Permissions", src)
+}
+
+// Allows reports whether one of the permissions covers permission.
+func (p Permissions) Allows(permission string) bool {
+	return slices.ContainsFunc(p, func(granted string) bool {
+		if granted == "*" || granted == permission {
+			return true
+		}
+		scope, ok := strings.CutSuffix(granted, "*")
+		return ok && strings.HasSuffix(scope, ":") && strings.HasPrefix(permission, scope)
+	})
+}
+
+// Team is a group of members inside an organization, e.g. "Engineering".
+type Team struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	Name           string // Unique within the organization
+	Description    string
+	Permissions    Permissions // Granted to every member of the team
+	CreatedAt      time.Time
+}
+
+func NewTeam(organizationID uuid.UUID, name, description string) *Team {
+	return &Team{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+		Name:           name,
+		Description:    description,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// TeamMembership links a member of an organization to one of its teams.
+type TeamMembership struct {
+	ID             uuid.UUID
+	TeamID         uuid.UUID
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Role           string // TeamRoleMaintainer or TeamRoleMember
+	JoinedAt       time.Time
+}
+
+func NewTeamMembership(team *Team, userID uuid.UUID, role string) *TeamMembership {
+	return &TeamMembership{
+		ID:             uuid.New(),
+		TeamID:         team.ID,
+		OrganizationID: team.OrganizationID,
+		UserID:         userID,
+		Role:           role,
+		JoinedAt:       time.Now(),
+	}
+}
+
+func validTeamRole(role string) bool {
+	return role == TeamRoleMaintainer || role == TeamRoleMember
+}
+
+// CreateTeam adds a team to orgID.
+func (s *Service) CreateTeam(ctx context.Context, orgID uuid.UUID, name, description string) (*Team, error) {
+	if s.teamStore == nil {
+		return nil, notConfigured("teams")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("team name is required: %w", ErrInvalidArgument)
+	}
+	if _, err := s.orgStore.Get(ctx, orgID); err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	team := NewTeam(orgID, name, description)
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.checkQuota(ctx, orgID, QuotaTeams); err != nil {
+			return err
+		}
+		if err := s.teamStore.CreateTeam(ctx, team); err != nil {
+			return fmt.Errorf("failed to create team: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditTeamCreated, TargetType: "team", TargetID: team.ID}
+		return s.audit(ctx, entry, nil, snapshotTeam(team))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (s *Service) GetTeam(ctx context.Context, orgID, teamID uuid.UUID) (*Team, error) {
+	if s.teamStore == nil {
+		return nil, notConfigured("teams")
+	}
+	team, err := s.teamStore.GetTeam(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team: %w", err)
+	}
+	if team.OrganizationID != orgID {
+		return nil, fmt.Errorf("team does not belong to organization: %w", ErrNotFound)
+	}
+	return team, nil
+}
+
+func (s *Service) ListTeams(ctx context.Context, orgID uuid.UUID) ([]*Team, error) {
+	if s.teamStore == nil {
+		return nil, notConfigured("teams")
+	}
+	teams, err := s.teamStore.ListTeams(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list teams: %w", err)
+	}
+	return teams, nil
+}
+
+// UpdateTeam renames a team of orgID and replaces its description.
+func (s *Service) UpdateTeam(ctx context.Context, orgID, teamID uuid.UUID, name, description string) (*Team, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("team name is required: %w", ErrInvalidArgument)
+	}
+	return s.updateTeam(ctx, orgID, teamID, AuditTeamUpdated, func(team *Team) {
+		team.Name = name
+		team.Description = description
+	})
+}
+
+// SetTeamPermissions replaces the permissions granted to every member of a team of orgID.
+func (s *Service) SetTeamPermissions(ctx context.Context, orgID, teamID uuid.UUID, permissions []string) (*Team, error) {
+	for _, p := range permissions {
+		if strings.TrimSpace(p) == "" {
+			return nil, fmt.Errorf("permissions cannot be empty: %w", ErrInvalidArgument)
+		}
+	}
+	return s.updateTeam(ctx, orgID, teamID, AuditTeamPermissionsChanged, func(team *Team) {
+		team.Permissions = slices.Compact(slices.Sorted(slices.Values(permissions)))
+	})
+}
+
+func (s *Service) updateTeam(ctx context.Context, orgID, teamID uuid.UUID, action string, change func(*Team)) (*Team, error) {
+	team, err := s.GetTeam(ctx, orgID, teamID)
+	if err != nil {
+		return nil, err
+	}
+	before := snapshotTeam(team)
+	change(team)
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.teamStore.UpdateTeam(ctx, team); err != nil {
+			return fmt.Errorf("failed to update team: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: action, TargetType: "team", TargetID: team.ID}
+		return s.audit(ctx, entry, before, snapshotTeam(team))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// DeleteTeam removes a team of orgID together with its memberships.
+func (s *Service) DeleteTeam(ctx context.Context, orgID, teamID uuid.UUID) error {
+	team, err := s.GetTeam(ctx, orgID, teamID)
+	if err != nil {
+		return err
+	}
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.teamStore.DeleteTeam(ctx, team.ID); err != nil {
+			return fmt.Errorf("failed to delete team: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditTeamDeleted, TargetType: "team", TargetID: team.ID}
+		return s.audit(ctx, entry, snapshotTeam(team), nil)
+	})
+}
+
+// AddTeamMember adds userID, who must be a member of orgID, to a team with the given team role.
+func (s *Service) AddTeamMember(ctx context.Context, orgID, teamID, userID uuid.UUID, role string) (*TeamMembership, error) {
+	if !validTeamRole(role) {
+		return nil, fmt.Errorf("invalid team role %q: %w", role, ErrInvalidArgument)
+	}
+	team, err := s.GetTeam(ctx, orgID, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.memStore.GetMembership(ctx, userID, orgID); errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("user is not a member of the organization: %w", ErrInvalidArgument)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+
+	membership := NewTeamMembership(team, userID, role)
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.teamStore.AddMember(ctx, membership); err != nil {
+			return fmt.Errorf("failed to add team member: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditTeamMemberAdded, TargetType: "team_membership", TargetID: membership.ID}
+		return s.audit(ctx, entry, nil, snapshotTeamMembership(membership))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// ChangeTeamMemberRole sets the team role of userID in a team of orgID.
+func (s *Service) ChangeTeamMemberRole(ctx context.Context, orgID, teamID, userID uuid.UUID, role string) (*TeamMembership, error) {
+	if !validTeamRole(role) {
+		return nil, fmt.Errorf("invalid team role %q: %w", role, ErrInvalidArgument)
+	}
+	membership, err := s.getTeamMember(ctx, orgID, teamID, userID)
+	if err != nil {
+		return nil, err
+	}
+	before := snapshotTeamMembership(membership)
+	membership.Role = role
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.teamStore.UpdateMember(ctx, membership); err != nil {
+			return fmt.Errorf("failed to update team member: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditTeamRoleChanged, TargetType: "team_membership", TargetID: membership.ID}
+		return s.audit(ctx, entry, before, snapshotTeamMembership(membership))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+func (s *Service) RemoveTeamMember(ctx context.Context, orgID, teamID, userID uuid.UUID) error {
+	membership, err := s.getTeamMember(ctx, orgID, teamID, userID)
+	if err != nil {
+		return err
+	}
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.teamStore.RemoveMember(ctx, membership.ID); err != nil {
+			return fmt.Errorf("failed to remove team member: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditTeamMemberRemoved, TargetType: "team_membership", TargetID: membership.ID}
+		return s.audit(ctx, entry, snapshotTeamMembership(membership), nil)
+	})
+}
+
+func (s *Service) ListTeamMembers(ctx context.Context, orgID, teamID uuid.UUID) ([]*TeamMembership, error) {
+	if _, err := s.GetTeam(ctx, orgID, teamID); err != nil {
+		return nil, err
+	}
+	members, err := s.teamStore.ListMembers(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list team members: %w", err)
+	}
+	return members, nil
+}
+
+// ListUserTeams returns the teams of orgID that userID belongs to.
+func (s *Service) ListUserTeams(ctx context.Context, orgID, userID uuid.UUID) ([]*Team, error) {
+	if s.teamStore == nil {
+		return nil, notConfigured("teams")
+	}
+	teams, err := s.teamStore.ListForUser(ctx, orgID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list teams of user: %w", err)
+	}
+	return teams, nil
+}
+
+func (s *Service) getTeamMember(ctx context.Context, orgID, teamID, userID uuid.UUID) (*TeamMembership, error) {
+	if _, err := s.GetTeam(ctx, orgID, teamID); err != nil {
+		return nil, err
+	}
+	membership, err := s.teamStore.GetMember(ctx, teamID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team member: %w", err)
+	}
+	return membership, nil
+}
+
+// HasPermission reports whether userID holds permission in orgID, either through the
+// permissions of their organization role, including an inherited one, or through any of their teams.
+func (s *Service) HasPermission(ctx context.Context, orgID, userID uuid.UUID, permission string) (bool, error) {
+	_, role, err := s.GetMemberships(ctx, userID, orgID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if Permissions(s.rolePermissions[role]).Allows(permission) {
+		return true, nil
+	}
+	if s.teamStore == nil {
+		return false, nil
+	}
+	teams, err := s.teamStore.ListForUser(ctx, orgID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to list teams of user: %w", err)
+	}
+	for _, team := range teams {
+		if team.Permissions.Allows(permission) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CanManageTeam reports whether userID may change a team's members: organization owners
+// and admins can manage every team, maintainers their own.
+func (s *Service) CanManageTeam(ctx context.Context, orgID, teamID, userID uuid.UUID) (bool, error) {
+	_, role, err := s.GetMemberships(ctx, userID, orgID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if role == "owner" || role == "admin" {
+		return true, nil
+	}
+	membership, err := s.getTeamMember(ctx, orgID, teamID, userID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return membership.Role == TeamRoleMaintainer, nil
+}
+
+// RequirePermission returns a middleware that answers 403 unless the caller holds permission
+// in the active organization. It must run after TenancyMiddleware or APIKeyMiddleware;
+// API keys only hold the permissions of their role.
+func (s *Service) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			orgID, ok := ActiveOrgID(r.Context())
+			if !ok {
+				log.Println("ERROR: no active organization in context. Is the tenancy middleware missing?")
+				http.Error(w, "Server Configuration Error", http.StatusInternalServerError)
+				return
+			}
+			var allowed bool
+			if key := APIKeyFromContext(r.Context()); key != nil {
+				allowed = Permissions(s.rolePermissions[key.Role]).Allows(permission)
+			} else {
+				userID, _ := r.Context().Value("user_id").(uuid.UUID)
+				var err error
+				if allowed, err = s.HasPermission(r.Context(), orgID, userID, permission); err != nil {
+					log.Printf("ERROR: %v", err)
+					http.Error(w, "Failed to check permission", http.StatusInternalServerError)
+					return
+				}
+			}
+			if !allowed {
+				http.Error(w, "Missing permission "+permission, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
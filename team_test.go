@@ -0,0 +1,222 @@
+package gordian_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func withTeams(db *gorm.DB) gordian.Option {
+	return gordian.WithTeams(gormadapter.NewTeamStore(db))
+}
+
+func TestPermissionsAllows(t *testing.T) {
+	tests := []struct {
+		granted    gordian.Permissions
+		permission string
+		want       bool
+	}{
+		{granted: nil, permission: "billing:read", want: false},
+		{granted: gordian.Permissions{"*"}, permission: "billing:read", want: true},
+		{granted: gordian.Permissions{"billing:read"}, permission: "billing:read", want: true},
+		{granted: gordian.Permissions{"billing:read"}, permission: "billing:write", want: false},
+		{granted: gordian.Permissions{"billing:*"}, permission: "billing:write", want: true},
+		{granted: gordian.Permissions{"billing:*"}, permission: "billingx", want: false},
+		{granted: gordian.Permissions{"bill*"}, permission: "billing:read", want: false},
+		{granted: gordian.Permissions{"projects:read", "billing:*"}, permission: "billing:read", want: true},
+	}
+	for _, tt := range tests {
+		if got := tt.granted.Allows(tt.permission); got != tt.want {
+			t.Errorf("%v.Allows(%q) = %v, want %v", tt.granted, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestCreateTeam(t *testing.T) {
+	env := newTestEnv(t, withTeams)
+	org := env.org("Acme", env.user("owner@example.com"))
+
+	// The cases run in order: "duplicate" reuses the name of the first.
+	tests := []struct {
+		name     string
+		orgID    uuid.UUID
+		teamName string
+		wantErr  error
+	}{
+		{name: "valid", orgID: org.ID, teamName: " Engineering "},
+		{name: "duplicate", orgID: org.ID, teamName: "Engineering", wantErr: gordian.ErrConflict},
+		{name: "blank name", orgID: org.ID, teamName: " ", wantErr: gordian.ErrInvalidArgument},
+		{name: "unknown organization", orgID: uuid.New(), teamName: "Sales", wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			team, err := env.svc.CreateTeam(env.ctx, tt.orgID, tt.teamName, "")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateTeam = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if team.Name != "Engineering" {
+				t.Errorf("Name = %q, want it trimmed", team.Name)
+			}
+		})
+	}
+}
+
+func TestAddTeamMember(t *testing.T) {
+	env := newTestEnv(t, withTeams)
+	owner := env.user("owner@example.com")
+	org, other := env.org("Acme", owner), env.org("Globex", owner)
+	member := env.member(org, "member@example.com", "member")
+	stranger := env.user("stranger@example.com")
+	team, err := env.svc.CreateTeam(env.ctx, org.ID, "Engineering", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cases run in order: "already in the team" follows "member".
+	tests := []struct {
+		name    string
+		orgID   uuid.UUID
+		teamID  uuid.UUID
+		userID  uuid.UUID
+		role    string
+		wantErr error
+	}{
+		{name: "member", orgID: org.ID, teamID: team.ID, userID: member.ID, role: gordian.TeamRoleMember},
+		{name: "already in the team", orgID: org.ID, teamID: team.ID, userID: member.ID, role: gordian.TeamRoleMember, wantErr: gordian.ErrConflict},
+		{name: "maintainer", orgID: org.ID, teamID: team.ID, userID: owner.ID, role: gordian.TeamRoleMaintainer},
+		{name: "invalid role", orgID: org.ID, teamID: team.ID, userID: member.ID, role: "admin", wantErr: gordian.ErrInvalidArgument},
+		{name: "not an organization member", orgID: org.ID, teamID: team.ID, userID: stranger.ID, role: gordian.TeamRoleMember, wantErr: gordian.ErrInvalidArgument},
+		{name: "team of another organization", orgID: other.ID, teamID: team.ID, userID: owner.ID, role: gordian.TeamRoleMember, wantErr: gordian.ErrNotFound},
+		{name: "unknown team", orgID: org.ID, teamID: uuid.New(), userID: member.ID, role: gordian.TeamRoleMember, wantErr: gordian.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			membership, err := env.svc.AddTeamMember(env.ctx, tt.orgID, tt.teamID, tt.userID, tt.role)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("AddTeamMember = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if membership.Role != tt.role || membership.OrganizationID != org.ID {
+				t.Errorf("membership = %+v", membership)
+			}
+		})
+	}
+
+	members, err := env.svc.ListTeamMembers(env.ctx, org.ID, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 {
+		t.Errorf("ListTeamMembers returned %d members, want 2", len(members))
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	env := newTestEnv(t, withTeams)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	child, err := env.svc.CreateChildOrganization(env.ctx, org.ID, "Europe", owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := env.member(org, "admin@example.com", "admin")
+	billing := env.member(org, "billing@example.com", "member")
+	member := env.member(org, "member@example.com", "member")
+	team, err := env.svc.CreateTeam(env.ctx, org.ID, "Finance", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.SetTeamPermissions(env.ctx, org.ID, team.ID, []string{"billing:*"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.AddTeamMember(env.ctx, org.ID, team.ID, billing.ID, gordian.TeamRoleMember); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		orgID      uuid.UUID
+		userID     uuid.UUID
+		permission string
+		want       bool
+	}{
+		{name: "owner", orgID: org.ID, userID: owner.ID, permission: "projects:delete", want: true},
+		{name: "admin", orgID: org.ID, userID: admin.ID, permission: "projects:delete", want: true},
+		{name: "inherited admin", orgID: child.ID, userID: admin.ID, permission: "projects:delete", want: true},
+		{name: "team permission", orgID: org.ID, userID: billing.ID, permission: "billing:read", want: true},
+		{name: "outside the team's permissions", orgID: org.ID, userID: billing.ID, permission: "projects:delete", want: false},
+		{name: "member without teams", orgID: org.ID, userID: member.ID, permission: "billing:read", want: false},
+		{name: "team permission in another organization", orgID: child.ID, userID: billing.ID, permission: "billing:read", want: false},
+		{name: "not a member", orgID: org.ID, userID: uuid.New(), permission: "billing:read", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := env.svc.HasPermission(env.ctx, tt.orgID, tt.userID, tt.permission)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("HasPermission(%q) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanManageTeam(t *testing.T) {
+	env := newTestEnv(t, withTeams)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	admin := env.member(org, "admin@example.com", "admin")
+	maintainer := env.member(org, "maintainer@example.com", "member")
+	member := env.member(org, "member@example.com", "member")
+	outsider := env.member(org, "outsider@example.com", "member")
+	team, err := env.svc.CreateTeam(env.ctx, org.ID, "Engineering", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.AddTeamMember(env.ctx, org.ID, team.ID, maintainer.ID, gordian.TeamRoleMaintainer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.AddTeamMember(env.ctx, org.ID, team.ID, member.ID, gordian.TeamRoleMember); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		want   bool
+	}{
+		{name: "owner", userID: owner.ID, want: true},
+		{name: "admin", userID: admin.ID, want: true},
+		{name: "maintainer", userID: maintainer.ID, want: true},
+		{name: "team member", userID: member.ID, want: false},
+		{name: "not in the team", userID: outsider.ID, want: false},
+		{name: "not a member", userID: uuid.New(), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := env.svc.CanManageTeam(env.ctx, org.ID, team.ID, tt.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CanManageTeam = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,10 +9,11 @@ import (
 // User represents a global user in the system.
 // A user is NOT a tenant. They are an identity who can join tenants.
 type User struct {
-	ID        uuid.UUID
-	Email     string
-	Name      string
-	CreatedAt time.Time
+	ID              uuid.UUID
+	Email           string
+	Name            string
+	EmailVerifiedAt *time.Time // nil until the user proved they own Email
+	CreatedAt       time.Time
 }
 
 func NewUser(email string, name string) *User {
@@ -24,13 +25,19 @@ func NewUser(email string, name string) *User {
 	}
 }
 
-
 // Organization is the Tenant. It is the top-level container for users and resources.
 type Organization struct {
 	ID        uuid.UUID
 	Name      string
-	OwnerID   uuid.UUID // The user who created and owns the organization
+	Slug      string     // Unique, URL-safe name, see ValidateSlug
+	OwnerID   uuid.UUID  // The user who created and owns the organization
+	ParentID  *uuid.UUID // The parent organization, nil for a top-level organization
+	Plan      string     // Name of the Plan limiting the organization, "" for the default plan
 	CreatedAt time.Time
+
+	BillingCustomerID  string             // ID of the customer at the BillingProvider, "" until it is created
+	SubscriptionID     string             // ID of the subscription at the BillingProvider
+	SubscriptionStatus SubscriptionStatus // Updated by the billing webhook
 }
 
 func NewOrganization(ownerID uuid.UUID, name string) *Organization {
@@ -42,7 +49,6 @@ func NewOrganization(ownerID uuid.UUID, name string) *Organization {
 	}
 }
 
-
 // Membership is the junction entity that links a User to an Organization.
 // This is the "User-under-Organization" block. It's the most important struct.
 type Membership struct {
@@ -53,7 +59,6 @@ type Membership struct {
 	JoinedAt       time.Time
 }
 
-
 func NewMembership(userID, organizationID uuid.UUID, role string) *Membership {
 	return &Membership{
 		ID:             uuid.New(),
@@ -74,6 +79,8 @@ type Invite struct {
 	Token          string    // A unique, secret token for the invite link
 	ExpiresAt      time.Time
 	CreatedAt      time.Time
+	AcceptedAt     *time.Time // Set once the invitee has joined; nil while pending
+	RevokedAt      *time.Time // Set when an admin cancelled the invitation
 }
 
 func NewInvite(organizationID, inviterID uuid.UUID, inviteeEmail, role, token string) *Invite {
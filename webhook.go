@@ -0,0 +1,496 @@
+package gordian
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers set on every webhook request.
+const (
+	WebhookEventHeader     = "X-Gordian-Event"
+	WebhookDeliveryHeader  = "X-Gordian-Delivery"
+	WebhookTimestampHeader = "X-Gordian-Timestamp"
+	WebhookSignatureHeader = "X-Gordian-Signature" // "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))
+)
+
+// WebhookPingEvent is the event type of deliveries sent by PingWebhookEndpoint.
+const WebhookPingEvent = "ping"
+
+// ErrInvalidSignature is returned by VerifyWebhookSignature when a request was not signed with the secret.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// EventTypes is a list of event names stored as a JSON array.
+type EventTypes []string
+
+func (t EventTypes) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	body, err := json.Marshal([]string(t))
+	return string(body), err
+}
+
+func (t *EventTypes) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	case []byte:
+		return json.Unmarshal(v, t)
+	}
+	return fmt.Errorf("cannot scan %T into EventTypes", src)
+}
+
+// WebhookEndpoint is a URL registered by an organization to be notified about its events.
+type WebhookEndpoint struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	URL            string
+	Secret         string     // Shared secret used to sign deliveries
+	EventTypes     EventTypes // Subscribed event names, e.g. "member.added"; empty means all
+	CreatedAt      time.Time
+}
+
+func NewWebhookEndpoint(organizationID uuid.UUID, url, secret string, eventTypes []string) *WebhookEndpoint {
+	return &WebhookEndpoint{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+		URL:            url,
+		Secret:         secret,
+		EventTypes:     eventTypes,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// Subscribed reports whether the endpoint wants events of the given type.
+func (e *WebhookEndpoint) Subscribed(eventType string) bool {
+	return len(e.EventTypes) == 0 || slices.Contains(e.EventTypes, eventType)
+}
+
+// WebhookDeliveryStatus is the state of a WebhookDelivery.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookPending   WebhookDeliveryStatus = "pending"
+	WebhookSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookFailed    WebhookDeliveryStatus = "failed" // Gave up after MaxAttempts
+)
+
+// WebhookDelivery is the log entry of one event sent (or to be sent) to an endpoint.
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	EndpointID     uuid.UUID
+	OrganizationID uuid.UUID
+	EventType      string
+	Payload        []byte
+	Status         WebhookDeliveryStatus
+	Attempts       int
+	ResponseStatus int // HTTP status of the last attempt, 0 if no response was received
+	LastError      string
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+// webhookPayload is the JSON body posted to endpoints.
+type webhookPayload struct {
+	ID             uuid.UUID `json:"id"`
+	Type           string    `json:"type"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Data           any       `json:"data"`
+}
+
+func newWebhookDelivery(endpoint *WebhookEndpoint, eventType string, data any) (*WebhookDelivery, error) {
+	now := time.Now()
+	delivery := &WebhookDelivery{
+		ID:             uuid.New(),
+		EndpointID:     endpoint.ID,
+		OrganizationID: endpoint.OrganizationID,
+		EventType:      eventType,
+		Status:         WebhookPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+	body, err := json.Marshal(webhookPayload{
+		ID:             delivery.ID,
+		Type:           eventType,
+		OrganizationID: endpoint.OrganizationID,
+		CreatedAt:      now,
+		Data:           data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	delivery.Payload = body
+	return delivery, nil
+}
+
+// SignWebhook returns the value of the signature header for body sent at timestamp.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the timestamp and signature headers of a received webhook.
+// Requests older than tolerance are rejected to prevent replays; a zero tolerance disables the check.
+func VerifyWebhookSignature(secret, timestampHeader, signatureHeader string, body []byte, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed timestamp", ErrInvalidSignature)
+	}
+	timestamp := time.Unix(unix, 0)
+	if tolerance > 0 && time.Since(timestamp).Abs() > tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	expected := SignWebhook(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signatureHeader)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyWebhookRequest reads the body of r and verifies its signature headers.
+// It returns the body so the receiver can decode it.
+func VerifyWebhookRequest(r *http.Request, secret string, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %w", err)
+	}
+	err = VerifyWebhookSignature(secret, r.Header.Get(WebhookTimestampHeader), r.Header.Get(WebhookSignatureHeader), body, tolerance)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// sendWebhook posts the delivery payload to the endpoint and returns the response status.
+func sendWebhook(ctx context.Context, client *http.Client, endpoint *WebhookEndpoint, delivery *WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID.String())
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(endpoint.Secret, now, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook endpoint responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip does not count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// NewWebhookClient returns an HTTP client for sending webhooks. Unless allowPrivate is set, it
+// refuses to connect to loopback, private, link-local and other non-public addresses, so
+// endpoint URLs can't reach internal services. The check runs when dialing, after DNS
+// resolution, so it also covers host names resolving to such addresses and redirects.
+// Proxies from the environment are not used, as they would be dialed instead of the endpoint.
+func NewWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("failed to parse webhook address %q: %w", address, err)
+			}
+			ip := addrPort.Addr().Unmap()
+			if !ip.IsGlobalUnicast() || ip.IsPrivate() || sharedAddressSpace.Contains(ip) {
+				return fmt.Errorf("webhook address %s is not public: %w", ip, ErrForbidden)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+// recordAttempt updates delivery with the outcome of one attempt.
+// retry decides whether a failure is retried and after how long.
+func recordAttempt(delivery *WebhookDelivery, status int, err error, retry func(attempts int) (time.Duration, bool)) {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	if err == nil {
+		delivery.Status = WebhookSucceeded
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		return
+	}
+	delivery.LastError = err.Error()
+	if delay, ok := retry(delivery.Attempts); ok {
+		delivery.NextAttemptAt = now.Add(delay)
+		return
+	}
+	delivery.Status = WebhookFailed
+}
+
+// WebhookDispatcher sends pending webhook deliveries in the background,
+// retrying failures with exponential backoff until MaxAttempts is reached.
+// Its Client is a NewWebhookClient that only connects to public addresses.
+type WebhookDispatcher struct {
+	store WebhookStore
+
+	Client       *http.Client
+	PollInterval time.Duration
+	BatchSize    int
+	Lease        time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	MaxAttempts  int
+}
+
+func NewWebhookDispatcher(store WebhookStore) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		store:        store,
+		Client:       NewWebhookClient(false),
+		PollInterval: 5 * time.Second,
+		BatchSize:    50,
+		Lease:        time.Minute,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   6 * time.Hour,
+		MaxAttempts:  10,
+	}
+}
+
+// Run polls for due deliveries until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DispatchOnce(ctx); err != nil {
+			log.Printf("ERROR: webhook dispatch failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce claims one batch of due deliveries and sends each of them.
+// It returns the number of deliveries that succeeded.
+func (d *WebhookDispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	deliveries, err := d.store.ClaimDueDeliveries(ctx, time.Now(), d.Lease, d.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	succeeded := 0
+	for _, delivery := range deliveries {
+		endpoint, err := d.store.GetEndpoint(ctx, delivery.EndpointID)
+		var status int
+		if err == nil {
+			status, err = sendWebhook(ctx, d.Client, endpoint, delivery)
+		}
+		recordAttempt(delivery, status, err, func(attempts int) (time.Duration, bool) {
+			return exponentialBackoff(d.BaseBackoff, d.MaxBackoff, attempts), attempts < d.MaxAttempts
+		})
+		if delivery.Status == WebhookSucceeded {
+			succeeded++
+		}
+		if err := d.store.UpdateDelivery(ctx, delivery); err != nil {
+			return succeeded, fmt.Errorf("failed to update webhook delivery %s: %w", delivery.ID, err)
+		}
+	}
+	return succeeded, nil
+}
+
+// webhookEvent returns the organization of e and the data sent for it in webhook payloads.
+// ok is false for events that are not delivered to webhooks.
+func webhookEvent(e Event) (orgID uuid.UUID, data any, ok bool) {
+	switch e := e.(type) {
+	case MemberAdded:
+		return e.Membership.OrganizationID, map[string]any{"membership": snapshotMembership(e.Membership)}, true
+	case MemberRemoved:
+		return e.Membership.OrganizationID, map[string]any{"membership": snapshotMembership(e.Membership)}, true
+	case RoleChanged:
+		return e.Membership.OrganizationID, map[string]any{"membership": snapshotMembership(e.Membership), "old_role": e.OldRole}, true
+	case InvitationSent:
+		return e.Invite.OrganizationID, map[string]any{"invite": snapshotInvite(e.Invite)}, true
+	case InvitationAccepted:
+		return e.Invite.OrganizationID, map[string]any{"invite": snapshotInvite(e.Invite), "membership": snapshotMembership(e.Membership)}, true
+	case InviteLinkRedeemed:
+		return e.Link.OrganizationID, map[string]any{"invite_link": snapshotInviteLink(e.Link), "membership": snapshotMembership(e.Membership)}, true
+	case JoinRequested:
+		return e.Request.OrganizationID, map[string]any{"join_request": snapshotJoinRequest(e.Request)}, true
+	case JoinRequestApproved:
+		return e.Request.OrganizationID, map[string]any{"join_request": snapshotJoinRequest(e.Request), "membership": snapshotMembership(e.Membership)}, true
+	case JoinRequestDenied:
+		return e.Request.OrganizationID, map[string]any{"join_request": snapshotJoinRequest(e.Request)}, true
+	}
+	return uuid.Nil, nil, false
+}
+
+// queueWebhooks records a delivery of each event for every subscribed endpoint of its organization.
+// It must be called inside the transaction of the change, so the deliveries commit or roll back with it.
+func (s *Service) queueWebhooks(ctx context.Context, events ...Event) error {
+	if s.webhookStore == nil {
+		return nil
+	}
+	for _, e := range events {
+		orgID, data, ok := webhookEvent(e)
+		if !ok {
+			continue
+		}
+		endpoints, err := s.webhookStore.ListEndpoints(ctx, orgID)
+		if err != nil {
+			return fmt.Errorf("failed to list webhook endpoints: %w", err)
+		}
+		for _, endpoint := range endpoints {
+			if !endpoint.Subscribed(e.EventName()) {
+				continue
+			}
+			delivery, err := newWebhookDelivery(endpoint, e.EventName(), data)
+			if err != nil {
+				return err
+			}
+			if err := s.webhookStore.CreateDelivery(ctx, delivery); err != nil {
+				return fmt.Errorf("failed to queue %s webhook: %w", e.EventName(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// CreateWebhookEndpoint registers a URL to receive the organization's events and generates its signing secret.
+// The URL must use https unless WithInsecureWebhooks is set.
+func (s *Service) CreateWebhookEndpoint(ctx context.Context, orgID uuid.UUID, endpointURL string, eventTypes []string) (*WebhookEndpoint, error) {
+	if s.webhookStore == nil {
+		return nil, notConfigured("webhooks")
+	}
+	parsed, err := url.Parse(endpointURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return nil, fmt.Errorf("webhook URL must be an absolute http(s) URL: %w", ErrInvalidArgument)
+	}
+	if parsed.Scheme != "https" && !s.insecureWebhooks {
+		return nil, fmt.Errorf("webhook URL must use https: %w", ErrInvalidArgument)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	endpoint := NewWebhookEndpoint(orgID, endpointURL, "whsec_"+hex.EncodeToString(raw), eventTypes)
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.webhookStore.CreateEndpoint(ctx, endpoint); err != nil {
+			return fmt.Errorf("failed to create webhook endpoint: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditWebhookCreated, TargetType: "webhook_endpoint", TargetID: endpoint.ID}
+		return s.audit(ctx, entry, nil, snapshotWebhookEndpoint(endpoint))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *Service) ListWebhookEndpoints(ctx context.Context, orgID uuid.UUID) ([]*WebhookEndpoint, error) {
+	if s.webhookStore == nil {
+		return nil, notConfigured("webhooks")
+	}
+	endpoints, err := s.webhookStore.ListEndpoints(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+// DeleteWebhookEndpoint removes an endpoint of orgID together with its delivery log.
+func (s *Service) DeleteWebhookEndpoint(ctx context.Context, orgID, endpointID uuid.UUID) error {
+	endpoint, err := s.getWebhookEndpoint(ctx, orgID, endpointID)
+	if err != nil {
+		return err
+	}
+	return s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.webhookStore.DeleteEndpoint(ctx, endpointID); err != nil {
+			return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+		}
+		entry := AuditEntry{OrganizationID: orgID, Action: AuditWebhookDeleted, TargetType: "webhook_endpoint", TargetID: endpoint.ID}
+		return s.audit(ctx, entry, snapshotWebhookEndpoint(endpoint), nil)
+	})
+}
+
+// ListWebhookDeliveries returns the most recent deliveries of an endpoint of orgID.
+func (s *Service) ListWebhookDeliveries(ctx context.Context, orgID, endpointID uuid.UUID, limit int) ([]*WebhookDelivery, error) {
+	if _, err := s.getWebhookEndpoint(ctx, orgID, endpointID); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.webhookStore.ListDeliveries(ctx, endpointID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// PingWebhookEndpoint sends a signed "ping" event to the endpoint right away and records the outcome.
+// The returned delivery is failed, not retried, when the endpoint does not answer with a 2xx status.
+func (s *Service) PingWebhookEndpoint(ctx context.Context, orgID, endpointID uuid.UUID) (*WebhookDelivery, error) {
+	endpoint, err := s.getWebhookEndpoint(ctx, orgID, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := newWebhookDelivery(endpoint, WebhookPingEvent, map[string]string{"endpoint_id": endpoint.ID.String()})
+	if err != nil {
+		return nil, err
+	}
+	status, sendErr := sendWebhook(ctx, s.webhookClient, endpoint, delivery)
+	recordAttempt(delivery, status, sendErr, func(int) (time.Duration, bool) { return 0, false })
+	if err := s.webhookStore.CreateDelivery(ctx, delivery); err != nil {
+		return nil, fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+func (s *Service) getWebhookEndpoint(ctx context.Context, orgID, endpointID uuid.UUID) (*WebhookEndpoint, error) {
+	if s.webhookStore == nil {
+		return nil, notConfigured("webhooks")
+	}
+	endpoint, err := s.webhookStore.GetEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+	if endpoint.OrganizationID != orgID {
+		return nil, fmt.Errorf("webhook endpoint does not belong to organization: %w", ErrNotFound)
+	}
+	return endpoint, nil
+}
@@ -0,0 +1,416 @@
+package gordian_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Robotech-Org/gordian"
+	gormadapter "github.com/Robotech-Org/gordian/adapter/gorm"
+	"github.com/Robotech-Org/gordian/internal/sqlitetest"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"type":"member.added"}`)
+	now := time.Now()
+	stamp := func(ts time.Time) string { return strconv.FormatInt(ts.Unix(), 10) }
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		tolerance time.Duration
+		wantErr   bool
+	}{
+		{name: "valid", secret: "s", timestamp: stamp(now), signature: gordian.SignWebhook("s", now, body), body: body, tolerance: time.Minute},
+		{name: "wrong secret", secret: "other", timestamp: stamp(now), signature: gordian.SignWebhook("s", now, body), body: body, tolerance: time.Minute, wantErr: true},
+		{name: "tampered body", secret: "s", timestamp: stamp(now), signature: gordian.SignWebhook("s", now, body), body: []byte(`{}`), tolerance: time.Minute, wantErr: true},
+		{name: "malformed timestamp", secret: "s", timestamp: "yesterday", signature: gordian.SignWebhook("s", now, body), body: body, tolerance: time.Minute, wantErr: true},
+		{name: "replayed timestamp", secret: "s", timestamp: stamp(now.Add(-time.Hour)), signature: gordian.SignWebhook("s", now.Add(-time.Hour), body), body: body, tolerance: time.Minute, wantErr: true},
+		{name: "no tolerance", secret: "s", timestamp: stamp(now.Add(-time.Hour)), signature: gordian.SignWebhook("s", now.Add(-time.Hour), body), body: body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := gordian.VerifyWebhookSignature(tt.secret, tt.timestamp, tt.signature, tt.body, tt.tolerance)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("VerifyWebhookSignature = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, gordian.ErrInvalidSignature) {
+				t.Errorf("err = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestWebhookEndpointSubscribed(t *testing.T) {
+	tests := []struct {
+		eventTypes []string
+		event      string
+		want       bool
+	}{
+		{nil, "member.added", true},
+		{[]string{"member.added"}, "member.added", true},
+		{[]string{"member.removed"}, "member.added", false},
+	}
+	for _, tt := range tests {
+		endpoint := gordian.NewWebhookEndpoint(uuid.New(), "https://example.com", "s", tt.eventTypes)
+		if got := endpoint.Subscribed(tt.event); got != tt.want {
+			t.Errorf("Subscribed(%q) with %v = %v, want %v", tt.event, tt.eventTypes, got, tt.want)
+		}
+	}
+}
+
+// webhookReceiver is an endpoint that verifies signatures and answers with the queued statuses.
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	secret   string
+	statuses []int
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	rcv := &webhookReceiver{statuses: statuses}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		body, err := gordian.VerifyWebhookRequest(r, rcv.secret, time.Minute)
+		if err != nil {
+			t.Errorf("received webhook with invalid signature: %v", err)
+		}
+		rcv.bodies = append(rcv.bodies, body)
+		status := http.StatusOK
+		if len(rcv.statuses) > 0 {
+			status, rcv.statuses = rcv.statuses[0], rcv.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func TestWebhookDispatcherRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxAttempts  int
+		wantStatuses []gordian.WebhookDeliveryStatus // after each DispatchOnce
+	}{
+		{name: "delivered", statuses: []int{http.StatusNoContent}, maxAttempts: 3, wantStatuses: []gordian.WebhookDeliveryStatus{gordian.WebhookSucceeded}},
+		{name: "retried until success", statuses: []int{500, 502, 200}, maxAttempts: 3, wantStatuses: []gordian.WebhookDeliveryStatus{gordian.WebhookPending, gordian.WebhookPending, gordian.WebhookSucceeded}},
+		{name: "gives up after max attempts", statuses: []int{500, 500}, maxAttempts: 2, wantStatuses: []gordian.WebhookDeliveryStatus{gordian.WebhookPending, gordian.WebhookFailed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := sqlitetest.Open(t)
+			store := gormadapter.NewWebhookStore(db)
+			rcv := newWebhookReceiver(t, tt.statuses...)
+			orgID := seedOrganization(t, db)
+			endpoint := gordian.NewWebhookEndpoint(orgID, rcv.URL, "whsec_test", nil)
+			rcv.secret = endpoint.Secret
+			if err := store.CreateEndpoint(ctx, endpoint); err != nil {
+				t.Fatal(err)
+			}
+			delivery := &gordian.WebhookDelivery{
+				ID: uuid.New(), EndpointID: endpoint.ID, OrganizationID: orgID, EventType: "member.added",
+				Payload: []byte(`{}`), Status: gordian.WebhookPending, NextAttemptAt: time.Now(), CreatedAt: time.Now(),
+			}
+			if err := store.CreateDelivery(ctx, delivery); err != nil {
+				t.Fatal(err)
+			}
+
+			d := gordian.NewWebhookDispatcher(store)
+			d.Client = gordian.NewWebhookClient(true)
+			d.MaxAttempts = tt.maxAttempts
+			d.BaseBackoff = time.Millisecond
+			d.MaxBackoff = time.Millisecond
+			d.Lease = time.Millisecond
+			for i, want := range tt.wantStatuses {
+				time.Sleep(5 * time.Millisecond)
+				if _, err := d.DispatchOnce(ctx); err != nil {
+					t.Fatal(err)
+				}
+				deliveries, err := store.ListDeliveries(ctx, endpoint.ID, 10)
+				if err != nil || len(deliveries) != 1 {
+					t.Fatalf("ListDeliveries = %v, %v", deliveries, err)
+				}
+				got := deliveries[0]
+				if got.Status != want || got.Attempts != i+1 {
+					t.Fatalf("attempt %d: status %s after %d attempts, want %s", i+1, got.Status, got.Attempts, want)
+				}
+				if got.ResponseStatus != tt.statuses[i] {
+					t.Errorf("attempt %d: response status %d, want %d", i+1, got.ResponseStatus, tt.statuses[i])
+				}
+			}
+		})
+	}
+}
+
+// seedOrganization inserts an organization and its owner directly, for tests of a single store.
+func seedOrganization(t *testing.T, db *gorm.DB) uuid.UUID {
+	t.Helper()
+	owner := gordian.NewUser(uuid.NewString()+"@example.com", "Owner")
+	if err := gormadapter.NewUserStore(db).Create(context.Background(), owner); err != nil {
+		t.Fatal(err)
+	}
+	org := gordian.NewOrganization(owner.ID, "Org "+owner.ID.String()[:8])
+	org.Slug = "org-" + owner.ID.String()[:8]
+	if err := gormadapter.NewOrganizationStore(db).Create(context.Background(), org); err != nil {
+		t.Fatal(err)
+	}
+	return org.ID
+}
+
+func newWebhookEnv(t *testing.T, opts ...func(db *gorm.DB) gordian.Option) *testEnv {
+	opts = append([]func(db *gorm.DB) gordian.Option{
+		func(db *gorm.DB) gordian.Option { return gordian.WithWebhooks(gormadapter.NewWebhookStore(db)) },
+		func(db *gorm.DB) gordian.Option { return gordian.WithInviteLinks(gormadapter.NewInviteLinkStore(db)) },
+	}, opts...)
+	return newTestEnv(t, opts...)
+}
+
+func TestWebhookPayloads(t *testing.T) {
+	env := newWebhookEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	endpoint, err := env.svc.CreateWebhookEndpoint(env.ctx, org.ID, "https://hooks.example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invite, err := env.svc.CreateInvitation(env.ctx, org.ID, owner.ID, "invitee@example.com", "member")
+	if err != nil {
+		t.Fatal(err)
+	}
+	invitee := env.user("invitee@example.com")
+	if _, err := env.svc.AcceptInvitation(env.ctx, invite.Token, invitee.ID); err != nil {
+		t.Fatal(err)
+	}
+	link, err := env.svc.CreateInviteLink(env.ctx, org.ID, owner.ID, "member", 0, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redeemer := env.user("redeemer@example.com")
+	if _, err := env.svc.RedeemInviteLink(env.ctx, link.Token, redeemer.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.svc.ChangeMemberRole(env.ctx, org.ID, redeemer.ID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deliveries are written in the transaction of the change, no need to wait for hooks.
+	deliveries, err := env.svc.ListWebhookDeliveries(env.ctx, org.ID, endpoint.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantData := map[string][]string{
+		"invitation.sent":      {"invite"},
+		"invitation.accepted":  {"invite", "membership"},
+		"member.added":         {"membership"},
+		"invite_link.redeemed": {"invite_link", "membership"},
+		"member.role_changed":  {"membership", "old_role"},
+	}
+	seen := map[string]bool{}
+	for _, delivery := range deliveries {
+		seen[delivery.EventType] = true
+		payload := string(delivery.Payload)
+		for _, secret := range []string{invite.Token, link.Token, endpoint.Secret} {
+			if strings.Contains(payload, secret) {
+				t.Errorf("%s payload leaks a secret: %s", delivery.EventType, payload)
+			}
+		}
+		var body struct {
+			Type           string                     `json:"type"`
+			OrganizationID uuid.UUID                  `json:"organization_id"`
+			Data           map[string]json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(delivery.Payload, &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Type != delivery.EventType || body.OrganizationID != org.ID {
+			t.Errorf("payload header = %s/%s, want %s/%s", body.Type, body.OrganizationID, delivery.EventType, org.ID)
+		}
+		for _, key := range wantData[delivery.EventType] {
+			if _, ok := body.Data[key]; !ok {
+				t.Errorf("%s payload has no %q: %s", delivery.EventType, key, payload)
+			}
+		}
+		if m, ok := body.Data["membership"]; ok && !strings.Contains(string(m), `"organization_id"`) {
+			t.Errorf("membership is not encoded in snake_case: %s", m)
+		}
+	}
+	for event := range wantData {
+		if !seen[event] {
+			t.Errorf("no %s delivery was queued", event)
+		}
+	}
+}
+
+// failingAuditStore fails every write, aborting the transaction of the change being audited.
+type failingAuditStore struct{ gordian.AuditStore }
+
+func (failingAuditStore) Create(context.Context, *gordian.AuditEntry) error {
+	return errors.New("audit store unavailable")
+}
+
+func TestWebhookDeliveriesRollBackWithChange(t *testing.T) {
+	env := newWebhookEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	endpoint, err := env.svc.CreateWebhookEndpoint(env.ctx, org.ID, "https://hooks.example.com", []string{"member.added"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second Service on the same database whose audit writes fail.
+	failing := gordian.New(
+		gormadapter.NewOrganizationStore(env.db),
+		gormadapter.NewUserStore(env.db),
+		gormadapter.NewMembershipStore(env.db),
+		gormadapter.NewInviteStore(env.db),
+		env.emailer,
+		gordian.WithTransactor(gormadapter.NewTransactor(env.db)),
+		gordian.WithWebhooks(gormadapter.NewWebhookStore(env.db)),
+		gordian.WithAuditLog(failingAuditStore{}),
+	)
+	user := env.user("user@example.com")
+	if _, err := failing.CreateMembership(env.ctx, user.ID, org.ID, "member"); err == nil {
+		t.Fatal("CreateMembership succeeded with a failing audit store")
+	}
+
+	deliveries, err := env.svc.ListWebhookDeliveries(env.ctx, org.ID, endpoint.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 0 {
+		t.Fatalf("rolled back change left %d webhook deliveries", len(deliveries))
+	}
+}
+
+func TestPingWebhookEndpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus gordian.WebhookDeliveryStatus
+	}{
+		{name: "receiver accepts", status: http.StatusOK, wantStatus: gordian.WebhookSucceeded},
+		{name: "receiver fails", status: http.StatusInternalServerError, wantStatus: gordian.WebhookFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newWebhookEnv(t, func(*gorm.DB) gordian.Option { return gordian.WithInsecureWebhooks() })
+			owner := env.user("owner@example.com")
+			org := env.org("Acme", owner)
+			rcv := newWebhookReceiver(t, tt.status)
+			endpoint, err := env.svc.CreateWebhookEndpoint(env.ctx, org.ID, rcv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rcv.secret = endpoint.Secret
+
+			delivery, err := env.svc.PingWebhookEndpoint(env.ctx, org.ID, endpoint.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if delivery.Status != tt.wantStatus || delivery.Attempts != 1 || delivery.ResponseStatus != tt.status {
+				t.Errorf("ping delivery = %s after %d attempts with HTTP %d, want %s after 1 with HTTP %d",
+					delivery.Status, delivery.Attempts, delivery.ResponseStatus, tt.wantStatus, tt.status)
+			}
+			if len(rcv.bodies) != 1 || !strings.Contains(string(rcv.bodies[0]), `"type":"ping"`) {
+				t.Errorf("receiver got %q, want one ping", rcv.bodies)
+			}
+		})
+	}
+}
+
+func TestCreateWebhookEndpointRequiresHTTPS(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		insecure bool
+		wantErr  error
+	}{
+		{name: "https", url: "https://hooks.example.com"},
+		{name: "http", url: "http://hooks.example.com", wantErr: gordian.ErrInvalidArgument},
+		{name: "http when insecure", url: "http://localhost:8080/hooks", insecure: true},
+		{name: "other scheme when insecure", url: "ftp://hooks.example.com", insecure: true, wantErr: gordian.ErrInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []func(*gorm.DB) gordian.Option
+			if tt.insecure {
+				opts = append(opts, func(*gorm.DB) gordian.Option { return gordian.WithInsecureWebhooks() })
+			}
+			env := newWebhookEnv(t, opts...)
+			org := env.org("Acme", env.user("owner@example.com"))
+			_, err := env.svc.CreateWebhookEndpoint(env.ctx, org.ID, tt.url, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateWebhookEndpoint(%q) = %v, want %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookClientRejectsPrivateAddresses(t *testing.T) {
+	rcv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(rcv.Close)
+	tests := []struct {
+		name         string
+		url          string
+		allowPrivate bool
+		wantErr      error
+	}{
+		{name: "loopback", url: rcv.URL, wantErr: gordian.ErrForbidden},
+		{name: "loopback by name", url: strings.Replace(rcv.URL, "127.0.0.1", "localhost", 1), wantErr: gordian.ErrForbidden},
+		{name: "ipv6 loopback", url: "http://[::1]:1", wantErr: gordian.ErrForbidden},
+		{name: "private", url: "http://10.0.0.1", wantErr: gordian.ErrForbidden},
+		{name: "link-local metadata", url: "http://169.254.169.254/latest/meta-data", wantErr: gordian.ErrForbidden},
+		{name: "ipv4-mapped ipv6", url: "http://[::ffff:192.168.0.1]", wantErr: gordian.ErrForbidden},
+		{name: "unspecified", url: "http://0.0.0.0:1", wantErr: gordian.ErrForbidden},
+		{name: "loopback when allowed", url: rcv.URL, allowPrivate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := gordian.NewWebhookClient(tt.allowPrivate).Get(tt.url)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Get(%q) = %v, want %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPingWebhookEndpointRefusesPrivateAddresses(t *testing.T) {
+	env := newWebhookEnv(t)
+	owner := env.user("owner@example.com")
+	org := env.org("Acme", owner)
+	rcv := newWebhookReceiver(t)
+	endpoint, err := env.svc.CreateWebhookEndpoint(env.ctx, org.ID, strings.Replace(rcv.URL, "http://", "https://", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivery, err := env.svc.PingWebhookEndpoint(env.ctx, org.ID, endpoint.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != gordian.WebhookFailed || !strings.Contains(delivery.LastError, "not public") {
+		t.Errorf("ping delivery = %s with error %q, want failed for a non-public address", delivery.Status, delivery.LastError)
+	}
+	if len(rcv.bodies) != 0 {
+		t.Errorf("receiver got %d requests, want none", len(rcv.bodies))
+	}
+}